@@ -146,6 +146,16 @@ func Test_CreateTempFile(t *testing.T) {
 	require.Equal(t, int64(bytesToWrite), file.Size())
 }
 
+func Test_FreeSpace(t *testing.T) {
+	store, err := NewLocalFileStore(baseDir)
+	require.NoError(t, err)
+	reporter, ok := store.(SpaceReporter)
+	require.True(t, ok)
+	free, err := reporter.FreeSpace()
+	require.NoError(t, err)
+	require.NotZero(t, free)
+}
+
 func Test_OpenAndReadFile(t *testing.T) {
 	store, err := NewLocalFileStore(baseDir)
 	require.NoError(t, err)
@@ -0,0 +1,20 @@
+// +build !windows
+
+package filestore
+
+import (
+	"fmt"
+	"syscall"
+)
+
+var _ SpaceReporter = fileStore{}
+
+// FreeSpace returns the number of bytes available to unprivileged users on the disk
+// the filestore is mounted on
+func (fs fileStore) FreeSpace() (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(fs.base, &stat); err != nil {
+		return 0, fmt.Errorf("error getting free space of %s: %s", fs.base, err.Error())
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
@@ -34,3 +34,9 @@ type FileStore interface {
 
 	CreateTemp() (File, error)
 }
+
+// SpaceReporter is implemented by FileStores that can report how much space is left
+// on the disk they store files on
+type SpaceReporter interface {
+	FreeSpace() (uint64, error)
+}
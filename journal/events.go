@@ -0,0 +1,108 @@
+package journal
+
+import (
+	"reflect"
+
+	"github.com/filecoin-project/go-statemachine/fsm"
+)
+
+// ActionWrapper wraps the action of an fsm event, given the event's name
+type ActionWrapper func(event fsm.EventName, action fsm.ActionFunc) fsm.ActionFunc
+
+// ArgsFunc is called with the deal state and arguments of an event whose action has run
+type ArgsFunc func(event fsm.EventName, state interface{}, args []interface{})
+
+// RecordArgs returns an ActionWrapper that passes the arguments each event is sent with to
+// record, once the event's action has run without error. The fsm notifier only receives the
+// event name and resulting state, so this is how event arguments reach the journal
+func RecordArgs(record ArgsFunc) ActionWrapper {
+	return func(event fsm.EventName, action fsm.ActionFunc) fsm.ActionFunc {
+		actionValue := reflect.ValueOf(action)
+		actionType := actionValue.Type()
+		return reflect.MakeFunc(actionType, func(in []reflect.Value) []reflect.Value {
+			var out []reflect.Value
+			if actionType.IsVariadic() {
+				out = actionValue.CallSlice(in)
+			} else {
+				out = actionValue.Call(in)
+			}
+			if len(out) > 0 && !out[0].IsNil() {
+				return out
+			}
+
+			args := make([]interface{}, 0, len(in)-1)
+			for _, arg := range in[1:] {
+				args = append(args, arg.Interface())
+			}
+			record(event, in[0].Interface(), args)
+			return out
+		}).Interface()
+	}
+}
+
+// EventBuilder describes an fsm event the way fsm.EventBuilder does, wrapping the action it
+// is given
+type EventBuilder struct {
+	name  fsm.EventName
+	inner fsm.EventBuilder
+	wrap  ActionWrapper
+}
+
+// TransitionToBuilder sets the destination of a transition for an EventBuilder
+type TransitionToBuilder struct {
+	event EventBuilder
+	inner fsm.TransitionToBuilder
+}
+
+// Event begins describing an fsm event whose action is wrapped by wrap, if wrap is set
+func Event(name fsm.EventName, wrap ActionWrapper) EventBuilder {
+	return EventBuilder{name: name, inner: fsm.Event(name), wrap: wrap}
+}
+
+// From begins describing a transition from a specific state
+func (b EventBuilder) From(s fsm.StateKey) TransitionToBuilder {
+	return TransitionToBuilder{event: b, inner: b.inner.From(s)}
+}
+
+// FromAny begins describing a transition from any state
+func (b EventBuilder) FromAny() TransitionToBuilder {
+	return TransitionToBuilder{event: b, inner: b.inner.FromAny()}
+}
+
+// FromMany begins describing a transition from many states
+func (b EventBuilder) FromMany(sources ...fsm.StateKey) TransitionToBuilder {
+	return TransitionToBuilder{event: b, inner: b.inner.FromMany(sources...)}
+}
+
+// Action describes the action taken on the state for the event
+func (b EventBuilder) Action(action fsm.ActionFunc) EventBuilder {
+	if b.wrap != nil {
+		action = b.wrap(b.name, action)
+	}
+	b.inner = b.inner.Action(action)
+	return b
+}
+
+// To finishes a transition to a specific state
+func (t TransitionToBuilder) To(s fsm.StateKey) EventBuilder {
+	t.event.inner = t.inner.To(s)
+	return t.event
+}
+
+// ToNoChange finishes a transition that stays in the same state
+func (t TransitionToBuilder) ToNoChange() EventBuilder {
+	t.event.inner = t.inner.ToNoChange()
+	return t.event
+}
+
+// Events is a list of events described with EventBuilders
+type Events []EventBuilder
+
+// Build returns the events in the form fsm.New takes
+func (e Events) Build() fsm.Events {
+	events := make(fsm.Events, 0, len(e))
+	for _, event := range e {
+		events = append(events, event.inner)
+	}
+	return events
+}
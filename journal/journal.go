@@ -0,0 +1,256 @@
+package journal
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/filecoin-project/go-statemachine/fsm"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
+	"github.com/ipfs/go-datastore/query"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-fil-markets/shared"
+)
+
+//go:generate cbor-gen-for StoredEntry
+
+// DSJournalPrefix is the name space for storing journal entries
+var DSJournalPrefix = "/deal-journal"
+
+// Entry is a record of one event that happened to a deal
+type Entry struct {
+	// Market is the market and side the deal belongs to
+	Market shared.Market
+	// Deal identifies the deal -- the proposal CID for storage deals, the deal ID for
+	// retrieval client deals and the client peer and deal ID for retrieval provider deals
+	Deal string
+	// Event is the name of the event
+	Event string
+	// Args are the arguments the event was sent with
+	Args []string
+	// State is the name of the state the deal was in after the event
+	State string
+	// Message is the deal's message after the event, which holds the reason for any error
+	Message string
+	// Time is when the event happened
+	Time time.Time
+}
+
+// StoredEntry is the form an Entry is kept in in the datastore. Time is in nanoseconds
+// since the Unix epoch
+type StoredEntry struct {
+	Market  shared.Market
+	Deal    string
+	Event   string
+	Args    []string
+	State   string
+	Message string
+	Time    int64
+}
+
+// Query selects entries from the journal. Empty fields match all entries
+type Query struct {
+	Market shared.Market
+	Deal   string
+	// Start is the earliest time to return entries for, inclusive
+	Start time.Time
+	// End is the latest time to return entries for, exclusive
+	End time.Time
+}
+
+// Journal is a persistent record of deal events
+type Journal struct {
+	ds  datastore.Batching
+	seq uint64
+
+	argsLk sync.Mutex
+	args   map[argsKey][][]string
+}
+
+// argsKey identifies the event of a deal that arguments were recorded for
+type argsKey struct {
+	market shared.Market
+	deal   string
+	event  fsm.EventName
+}
+
+// NewJournal returns a new journal based on the given datastore
+func NewJournal(ds datastore.Batching) *Journal {
+	return &Journal{
+		ds:   namespace.Wrap(ds, datastore.NewKey(DSJournalPrefix)),
+		args: make(map[argsKey][][]string),
+	}
+}
+
+// AddArgs keeps the arguments an event was sent with to a deal until they are taken with
+// TakeArgs, when the event is recorded
+func (j *Journal) AddArgs(market shared.Market, deal string, event fsm.EventName, args []interface{}) {
+	formatted := make([]string, 0, len(args))
+	for _, arg := range args {
+		formatted = append(formatted, fmt.Sprint(arg))
+	}
+
+	j.argsLk.Lock()
+	defer j.argsLk.Unlock()
+	key := argsKey{market, deal, event}
+	j.args[key] = append(j.args[key], formatted)
+}
+
+// TakeArgs returns and forgets the arguments of the earliest event with the given name that
+// was added for a deal with AddArgs, or nil if there are none
+func (j *Journal) TakeArgs(market shared.Market, deal string, event fsm.EventName) []string {
+	j.argsLk.Lock()
+	defer j.argsLk.Unlock()
+	key := argsKey{market, deal, event}
+	pending := j.args[key]
+	if len(pending) == 0 {
+		return nil
+	}
+	if len(pending) == 1 {
+		delete(j.args, key)
+	} else {
+		j.args[key] = pending[1:]
+	}
+	return pending[0]
+}
+
+// Record writes an entry to the journal, setting its time to now if it is not set
+func (j *Journal) Record(entry Entry) error {
+	if entry.Market == "" || entry.Deal == "" {
+		return xerrors.New("journal entries must have a market and a deal")
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+
+	buf := new(bytes.Buffer)
+	err := (&StoredEntry{
+		Market:  entry.Market,
+		Deal:    entry.Deal,
+		Event:   entry.Event,
+		Args:    entry.Args,
+		State:   entry.State,
+		Message: entry.Message,
+		Time:    entry.Time.UnixNano(),
+	}).MarshalCBOR(buf)
+	if err != nil {
+		return xerrors.Errorf("encoding journal entry: %w", err)
+	}
+
+	seq := atomic.AddUint64(&j.seq, 1)
+	key := dealKey(entry.Market, entry.Deal).ChildString(timeKey(entry.Time) + fmt.Sprintf("-%020d", seq))
+	if err := j.ds.Put(key, buf.Bytes()); err != nil {
+		return xerrors.Errorf("writing journal entry: %w", err)
+	}
+	return nil
+}
+
+// Query returns the entries that match the query, oldest first
+func (j *Journal) Query(q Query) ([]Entry, error) {
+	dsq := query.Query{
+		Prefix: "/",
+		// the name of each key starts with the time of its entry, followed by the order it was
+		// recorded in
+		Orders: []query.Order{query.OrderByFunction(byName)},
+	}
+	if q.Market != "" {
+		dsq.Prefix = datastore.NewKey(string(q.Market)).String()
+		if q.Deal != "" {
+			dsq.Prefix = dealKey(q.Market, q.Deal).String()
+		}
+	}
+	if q.Deal != "" {
+		// the prefix alone also matches deals whose identifiers have more path components
+		dsq.Filters = append(dsq.Filters, dealFilter{datastore.NewKey(q.Deal).String()})
+	}
+	if !q.Start.IsZero() || !q.End.IsZero() {
+		filter := timeFilter{}
+		if !q.Start.IsZero() {
+			filter.start = timeKey(q.Start)
+		}
+		if !q.End.IsZero() {
+			filter.end = timeKey(q.End)
+		}
+		dsq.Filters = append(dsq.Filters, filter)
+	}
+
+	res, err := j.ds.Query(dsq)
+	if err != nil {
+		return nil, xerrors.Errorf("querying journal: %w", err)
+	}
+	defer res.Close()
+
+	var entries []Entry
+	for r := range res.Next() {
+		if r.Error != nil {
+			return nil, xerrors.Errorf("reading journal: %w", r.Error)
+		}
+
+		var stored StoredEntry
+		if err := stored.UnmarshalCBOR(bytes.NewReader(r.Value)); err != nil {
+			return nil, xerrors.Errorf("decoding journal entry %s: %w", r.Key, err)
+		}
+		entries = append(entries, Entry{
+			Market:  stored.Market,
+			Deal:    stored.Deal,
+			Event:   stored.Event,
+			Args:    stored.Args,
+			State:   stored.State,
+			Message: stored.Message,
+			Time:    time.Unix(0, stored.Time),
+		})
+	}
+	return entries, nil
+}
+
+// timeKey is the part of the name of a key that holds the time of its entry. It is padded so
+// keys sort in time order
+func timeKey(t time.Time) string {
+	return fmt.Sprintf("%020d", t.UnixNano())
+}
+
+func byName(a, b query.Entry) int {
+	return strings.Compare(datastore.RawKey(a.Key).Name(), datastore.RawKey(b.Key).Name())
+}
+
+// timeFilter selects keys whose names start with times from start, inclusive, to end,
+// exclusive. An empty bound is open
+type timeFilter struct {
+	start string
+	end   string
+}
+
+func (f timeFilter) Filter(e query.Entry) bool {
+	name := datastore.RawKey(e.Key).Name()
+	if f.start != "" && name < f.start {
+		return false
+	}
+	// names at exactly the end time have the end as a prefix and sort after it
+	if f.end != "" && name >= f.end {
+		return false
+	}
+	return true
+}
+
+// dealFilter selects keys for one deal, in any market. Keys are the market, then the deal's
+// identifier, which can have several path components, then the name of the entry
+type dealFilter struct {
+	deal string
+}
+
+func (f dealFilter) Filter(e query.Entry) bool {
+	components := datastore.RawKey(e.Key).Parent().List()
+	if len(components) < 2 {
+		return false
+	}
+	return "/"+strings.Join(components[1:], "/") == f.deal
+}
+
+func dealKey(market shared.Market, deal string) datastore.Key {
+	return datastore.NewKey(string(market)).ChildString(deal)
+}
@@ -0,0 +1,171 @@
+package journal_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/go-statemachine/fsm"
+	"github.com/ipfs/go-datastore"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-fil-markets/journal"
+	"github.com/filecoin-project/go-fil-markets/shared"
+)
+
+func TestJournal(t *testing.T) {
+	start := time.Unix(1000, 0)
+	at := func(seconds int) time.Time {
+		return start.Add(time.Duration(seconds) * time.Second)
+	}
+
+	entries := []journal.Entry{
+		{Market: shared.StorageClient, Deal: "deal", Event: "ClientEventOpen", State: "StorageDealEnsureClientFunds", Time: at(0)},
+		{Market: shared.StorageClient, Deal: "deal", Event: "ClientEventFundsEnsured", State: "StorageDealFundsEnsured", Time: at(2)},
+		{Market: shared.StorageClient, Deal: "deal2", Event: "ClientEventOpen", State: "StorageDealEnsureClientFunds", Time: at(1)},
+		{Market: shared.StorageProvider, Deal: "deal", Event: "ProviderEventOpen", State: "StorageDealValidating", Time: at(1)},
+		{Market: shared.RetrievalProvider, Deal: "peer/1", Event: "ProviderEventOpen", State: "DealStatusNew", Time: at(3)},
+	}
+
+	tests := map[string]struct {
+		query    journal.Query
+		expected []journal.Entry
+	}{
+		"all entries": {
+			query:    journal.Query{},
+			expected: []journal.Entry{entries[0], entries[2], entries[3], entries[1], entries[4]},
+		},
+		"by market": {
+			query:    journal.Query{Market: shared.StorageClient},
+			expected: []journal.Entry{entries[0], entries[2], entries[1]},
+		},
+		"by deal": {
+			query:    journal.Query{Market: shared.StorageClient, Deal: "deal"},
+			expected: []journal.Entry{entries[0], entries[1]},
+		},
+		"by deal in any market": {
+			query:    journal.Query{Deal: "deal"},
+			expected: []journal.Entry{entries[0], entries[3], entries[1]},
+		},
+		"deal identifiers with slashes": {
+			query:    journal.Query{Market: shared.RetrievalProvider, Deal: "peer/1"},
+			expected: []journal.Entry{entries[4]},
+		},
+		"by time range": {
+			query:    journal.Query{Start: at(1), End: at(3)},
+			expected: []journal.Entry{entries[2], entries[3], entries[1]},
+		},
+		"by deal and time range": {
+			query:    journal.Query{Market: shared.StorageClient, Deal: "deal", Start: at(1)},
+			expected: []journal.Entry{entries[1]},
+		},
+		"no matches": {
+			query: journal.Query{Market: shared.RetrievalClient},
+		},
+	}
+
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			j := journal.NewJournal(datastore.NewMapDatastore())
+			for _, entry := range entries {
+				require.NoError(t, j.Record(entry))
+			}
+
+			result, err := j.Query(data.query)
+			require.NoError(t, err)
+			require.Len(t, result, len(data.expected))
+			for i, entry := range data.expected {
+				require.Equal(t, entry.Market, result[i].Market)
+				require.Equal(t, entry.Deal, result[i].Deal)
+				require.Equal(t, entry.Event, result[i].Event)
+				require.True(t, entry.Time.Equal(result[i].Time))
+			}
+		})
+	}
+
+	t.Run("entries are persisted", func(t *testing.T) {
+		ds := datastore.NewMapDatastore()
+		require.NoError(t, journal.NewJournal(ds).Record(entries[0]))
+
+		result, err := journal.NewJournal(ds).Query(journal.Query{})
+		require.NoError(t, err)
+		require.Len(t, result, 1)
+		require.Equal(t, entries[0].Event, result[0].Event)
+	})
+
+	t.Run("entries recorded at the same time keep their order", func(t *testing.T) {
+		j := journal.NewJournal(datastore.NewMapDatastore())
+		events := []string{"first", "second", "third", "fourth"}
+		for _, event := range events {
+			require.NoError(t, j.Record(journal.Entry{Market: shared.StorageClient, Deal: "deal", Event: event, Time: start}))
+		}
+
+		result, err := j.Query(journal.Query{})
+		require.NoError(t, err)
+		require.Len(t, result, len(events))
+		for i, event := range events {
+			require.Equal(t, event, result[i].Event)
+		}
+	})
+
+	t.Run("time defaults to now", func(t *testing.T) {
+		j := journal.NewJournal(datastore.NewMapDatastore())
+		before := time.Now()
+		require.NoError(t, j.Record(journal.Entry{Market: shared.StorageClient, Deal: "deal"}))
+
+		result, err := j.Query(journal.Query{})
+		require.NoError(t, err)
+		require.Len(t, result, 1)
+		require.False(t, result[0].Time.Before(before))
+	})
+
+	t.Run("entries keep their args", func(t *testing.T) {
+		j := journal.NewJournal(datastore.NewMapDatastore())
+		args := []string{"bafy", "out of disk"}
+		require.NoError(t, j.Record(journal.Entry{Market: shared.StorageProvider, Deal: "deal", Event: "ProviderEventCancelled", Args: args}))
+
+		result, err := j.Query(journal.Query{})
+		require.NoError(t, err)
+		require.Len(t, result, 1)
+		require.Equal(t, args, result[0].Args)
+	})
+
+	t.Run("entries must have a market and deal", func(t *testing.T) {
+		j := journal.NewJournal(datastore.NewMapDatastore())
+		require.Error(t, j.Record(journal.Entry{Market: shared.StorageClient}))
+		require.Error(t, j.Record(journal.Entry{Deal: "deal"}))
+	})
+}
+
+type testDeal struct {
+	ID    string
+	Count uint64
+}
+
+func TestRecordArgs(t *testing.T) {
+	j := journal.NewJournal(datastore.NewMapDatastore())
+	wrap := journal.RecordArgs(func(event fsm.EventName, state interface{}, args []interface{}) {
+		j.AddArgs(shared.StorageClient, state.(*testDeal).ID, event, args)
+	})
+
+	count := wrap("count", func(deal *testDeal, n uint64, reason error) error {
+		if reason == nil {
+			return errors.New("no reason")
+		}
+		deal.Count += n
+		return nil
+	}).(func(*testDeal, uint64, error) error)
+
+	deal := &testDeal{ID: "deal"}
+	require.NoError(t, count(deal, 2, errors.New("first")))
+	require.NoError(t, count(deal, 3, errors.New("second")))
+	require.EqualValues(t, 5, deal.Count)
+
+	// args are not kept for actions that fail, since their events do not happen
+	require.Error(t, count(deal, 4, nil))
+
+	require.Equal(t, []string{"2", "first"}, j.TakeArgs(shared.StorageClient, "deal", "count"))
+	require.Equal(t, []string{"3", "second"}, j.TakeArgs(shared.StorageClient, "deal", "count"))
+	require.Nil(t, j.TakeArgs(shared.StorageClient, "deal", "count"))
+	require.Nil(t, j.TakeArgs(shared.StorageClient, "other", "count"))
+}
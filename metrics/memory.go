@@ -0,0 +1,139 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/go-fil-markets/shared"
+)
+
+// MemoryRecorder is a Recorder that keeps all measurements in memory, so they can be
+// inspected in tests
+type MemoryRecorder struct {
+	lk               sync.Mutex
+	events           map[shared.Market]map[string]int64
+	dealsInState     map[shared.Market]map[string]int64
+	timesInState     map[shared.Market]map[string][]time.Duration
+	bytesTransferred map[shared.Market]uint64
+	commPDurations   map[shared.Market][]time.Duration
+	unsealDurations  map[shared.Market][]time.Duration
+}
+
+var _ Recorder = (*MemoryRecorder)(nil)
+
+// NewMemoryRecorder returns a new, empty MemoryRecorder
+func NewMemoryRecorder() *MemoryRecorder {
+	return &MemoryRecorder{
+		events:           make(map[shared.Market]map[string]int64),
+		dealsInState:     make(map[shared.Market]map[string]int64),
+		timesInState:     make(map[shared.Market]map[string][]time.Duration),
+		bytesTransferred: make(map[shared.Market]uint64),
+		commPDurations:   make(map[shared.Market][]time.Duration),
+		unsealDurations:  make(map[shared.Market][]time.Duration),
+	}
+}
+
+// RecordEvent counts an event that happened to a deal
+func (r *MemoryRecorder) RecordEvent(market shared.Market, event string) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	if r.events[market] == nil {
+		r.events[market] = make(map[string]int64)
+	}
+	r.events[market][event]++
+}
+
+// AddDealsInState changes the number of deals in a state by delta
+func (r *MemoryRecorder) AddDealsInState(market shared.Market, state string, delta int64) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	if r.dealsInState[market] == nil {
+		r.dealsInState[market] = make(map[string]int64)
+	}
+	r.dealsInState[market][state] += delta
+}
+
+// RecordTimeInState records how long a deal spent in a state before it moved to another
+func (r *MemoryRecorder) RecordTimeInState(market shared.Market, state string, duration time.Duration) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	if r.timesInState[market] == nil {
+		r.timesInState[market] = make(map[string][]time.Duration)
+	}
+	r.timesInState[market][state] = append(r.timesInState[market][state], duration)
+}
+
+// RecordBytesTransferred counts bytes of deal data sent or received
+func (r *MemoryRecorder) RecordBytesTransferred(market shared.Market, bytes uint64) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	r.bytesTransferred[market] += bytes
+}
+
+// RecordCommPDuration records how long it took to generate a piece commitment for a deal
+func (r *MemoryRecorder) RecordCommPDuration(market shared.Market, duration time.Duration) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	r.commPDurations[market] = append(r.commPDurations[market], duration)
+}
+
+// RecordUnsealDuration records how long it took to unseal a sector to serve a retrieval
+func (r *MemoryRecorder) RecordUnsealDuration(market shared.Market, duration time.Duration) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	r.unsealDurations[market] = append(r.unsealDurations[market], duration)
+}
+
+// Events returns the number of times an event has happened
+func (r *MemoryRecorder) Events(market shared.Market, event string) int64 {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	return r.events[market][event]
+}
+
+// DealsInState returns the number of deals currently in a state
+func (r *MemoryRecorder) DealsInState(market shared.Market, state string) int64 {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	return r.dealsInState[market][state]
+}
+
+// TimesInState returns how long deals spent in a state, for each time a deal left it
+func (r *MemoryRecorder) TimesInState(market shared.Market, state string) []time.Duration {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	return append([]time.Duration(nil), r.timesInState[market][state]...)
+}
+
+// BytesTransferred returns the total bytes transferred
+func (r *MemoryRecorder) BytesTransferred(market shared.Market) uint64 {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	return r.bytesTransferred[market]
+}
+
+// CommPDurations returns every recorded piece commitment duration
+func (r *MemoryRecorder) CommPDurations(market shared.Market) []time.Duration {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	return append([]time.Duration(nil), r.commPDurations[market]...)
+}
+
+// UnsealDurations returns every recorded unseal duration
+func (r *MemoryRecorder) UnsealDurations(market shared.Market) []time.Duration {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	return append([]time.Duration(nil), r.unsealDurations[market]...)
+}
@@ -0,0 +1,177 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+
+	"github.com/filecoin-project/go-fil-markets/shared"
+)
+
+var log = logging.Logger("markets_metrics")
+
+// Recorder receives measurements of deals as they move through the markets
+type Recorder interface {
+	// RecordEvent counts an event that happened to a deal
+	RecordEvent(market shared.Market, event string)
+	// AddDealsInState changes the number of deals in a state by delta
+	AddDealsInState(market shared.Market, state string, delta int64)
+	// RecordTimeInState records how long a deal spent in a state before it moved to another
+	RecordTimeInState(market shared.Market, state string, duration time.Duration)
+	// RecordBytesTransferred counts bytes of deal data sent or received
+	RecordBytesTransferred(market shared.Market, bytes uint64)
+	// RecordCommPDuration records how long it took to generate a piece commitment for a deal
+	RecordCommPDuration(market shared.Market, duration time.Duration)
+	// RecordUnsealDuration records how long it took to unseal a sector to serve a retrieval
+	RecordUnsealDuration(market shared.Market, duration time.Duration)
+}
+
+// NopRecorder is a Recorder that discards all measurements
+type NopRecorder struct{}
+
+var _ Recorder = NopRecorder{}
+
+// RecordEvent does nothing
+func (NopRecorder) RecordEvent(shared.Market, string) {}
+
+// AddDealsInState does nothing
+func (NopRecorder) AddDealsInState(shared.Market, string, int64) {}
+
+// RecordTimeInState does nothing
+func (NopRecorder) RecordTimeInState(shared.Market, string, time.Duration) {}
+
+// RecordBytesTransferred does nothing
+func (NopRecorder) RecordBytesTransferred(shared.Market, uint64) {}
+
+// RecordCommPDuration does nothing
+func (NopRecorder) RecordCommPDuration(shared.Market, time.Duration) {}
+
+// RecordUnsealDuration does nothing
+func (NopRecorder) RecordUnsealDuration(shared.Market, time.Duration) {}
+
+// DealTracker turns the events a market's state machines notify it of into measurements
+// for a Recorder. It remembers the last state of each deal still being processed, so it
+// can count the deals in each state and time how long deals spend in them. Deals are
+// forgotten once they reach a final state, and counted again from the market's stored
+// deals when it restarts
+type DealTracker struct {
+	market shared.Market
+
+	lk       sync.Mutex
+	recorder Recorder
+	deals    map[string]trackedDeal
+}
+
+type trackedDeal struct {
+	state       string
+	since       time.Time
+	transferred uint64
+}
+
+// NewDealTracker returns a new DealTracker for the given market that discards all
+// measurements until a recorder is set
+func NewDealTracker(market shared.Market) *DealTracker {
+	return &DealTracker{
+		market:   market,
+		recorder: NopRecorder{},
+		deals:    make(map[string]trackedDeal),
+	}
+}
+
+// SetRecorder changes where measurements are sent. A nil recorder discards them
+func (t *DealTracker) SetRecorder(recorder Recorder) {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+
+	if recorder == nil {
+		recorder = NopRecorder{}
+	}
+	t.recorder = recorder
+}
+
+// Restore counts a deal that was stored before the market started in the state it was
+// left in. Deals that are still being processed are tracked from here on
+func (t *DealTracker) Restore(deal string, state string, final bool) {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+
+	t.recorder.AddDealsInState(t.market, state, 1)
+	if !final {
+		t.deals[deal] = trackedDeal{state: state, since: time.Now()}
+	}
+}
+
+// Observe records that an event happened to a deal, leaving it in the given state. Final
+// states are ones the deal will not leave, so the deal is forgotten once it reaches one
+func (t *DealTracker) Observe(deal string, event string, state string, final bool) {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+
+	t.recorder.RecordEvent(t.market, event)
+
+	now := time.Now()
+	tracked, ok := t.deals[deal]
+	if ok && tracked.state == state {
+		return
+	}
+	if !ok && final {
+		// deals are forgotten once they reach a final state, so this one has been counted
+		// in it already
+		return
+	}
+	if ok {
+		t.recorder.RecordTimeInState(t.market, tracked.state, now.Sub(tracked.since))
+		t.recorder.AddDealsInState(t.market, tracked.state, -1)
+	}
+	t.recorder.AddDealsInState(t.market, state, 1)
+
+	if final {
+		delete(t.deals, deal)
+		return
+	}
+	tracked.state = state
+	tracked.since = now
+	t.deals[deal] = tracked
+}
+
+// TotalTransferred records the bytes an observed deal has transferred since this was last
+// called for it, given the total it has transferred so far
+func (t *DealTracker) TotalTransferred(deal string, total uint64) {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+
+	tracked, ok := t.deals[deal]
+	if !ok {
+		return
+	}
+	if total > tracked.transferred {
+		t.recorder.RecordBytesTransferred(t.market, total-tracked.transferred)
+	}
+	tracked.transferred = total
+	t.deals[deal] = tracked
+}
+
+// BytesTransferred records bytes transferred for a deal
+func (t *DealTracker) BytesTransferred(bytes uint64) {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+
+	t.recorder.RecordBytesTransferred(t.market, bytes)
+}
+
+// CommPDuration records how long it took to generate a piece commitment
+func (t *DealTracker) CommPDuration(duration time.Duration) {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+
+	t.recorder.RecordCommPDuration(t.market, duration)
+}
+
+// UnsealDuration records how long it took to unseal a sector
+func (t *DealTracker) UnsealDuration(duration time.Duration) {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+
+	t.recorder.RecordUnsealDuration(t.market, duration)
+}
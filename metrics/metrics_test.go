@@ -0,0 +1,142 @@
+package metrics_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-fil-markets/metrics"
+	"github.com/filecoin-project/go-fil-markets/shared"
+)
+
+func TestDealTracker(t *testing.T) {
+	market := shared.StorageProvider
+
+	t.Run("counts deals in each state", func(t *testing.T) {
+		recorder := metrics.NewMemoryRecorder()
+		tracker := metrics.NewDealTracker(market)
+		tracker.SetRecorder(recorder)
+
+		tracker.Observe("deal1", "Open", "Validating", false)
+		tracker.Observe("deal2", "Open", "Validating", false)
+		tracker.Observe("deal1", "Accepted", "WaitingForData", false)
+		tracker.Observe("deal1", "Progress", "WaitingForData", false)
+
+		require.Equal(t, int64(1), recorder.DealsInState(market, "Validating"))
+		require.Equal(t, int64(1), recorder.DealsInState(market, "WaitingForData"))
+		require.Equal(t, int64(2), recorder.Events(market, "Open"))
+		require.Equal(t, int64(1), recorder.Events(market, "Accepted"))
+		require.Equal(t, int64(1), recorder.Events(market, "Progress"))
+		require.Len(t, recorder.TimesInState(market, "Validating"), 1)
+		require.Empty(t, recorder.TimesInState(market, "WaitingForData"))
+		require.Zero(t, recorder.DealsInState(shared.StorageClient, "Validating"))
+	})
+
+	t.Run("times deals in each state", func(t *testing.T) {
+		recorder := metrics.NewMemoryRecorder()
+		tracker := metrics.NewDealTracker(market)
+		tracker.SetRecorder(recorder)
+
+		tracker.Observe("deal", "Open", "Validating", false)
+		time.Sleep(10 * time.Millisecond)
+		tracker.Observe("deal", "Progress", "Validating", false)
+		tracker.Observe("deal", "Accepted", "WaitingForData", false)
+
+		times := recorder.TimesInState(market, "Validating")
+		require.Len(t, times, 1)
+		require.True(t, times[0] >= 10*time.Millisecond)
+	})
+
+	t.Run("forgets deals that reach a final state", func(t *testing.T) {
+		recorder := metrics.NewMemoryRecorder()
+		tracker := metrics.NewDealTracker(market)
+		tracker.SetRecorder(recorder)
+
+		tracker.Observe("deal", "Open", "Validating", false)
+		tracker.Observe("deal", "Failed", "Error", true)
+		require.Zero(t, recorder.DealsInState(market, "Validating"))
+		require.Equal(t, int64(1), recorder.DealsInState(market, "Error"))
+		require.Len(t, recorder.TimesInState(market, "Validating"), 1)
+
+		// later events for the deal are counted without counting the deal again
+		tracker.Observe("deal", "Failed", "Error", true)
+		require.Equal(t, int64(1), recorder.DealsInState(market, "Error"))
+		require.Equal(t, int64(2), recorder.Events(market, "Failed"))
+
+		tracker.TotalTransferred("deal", 100)
+		require.Zero(t, recorder.BytesTransferred(market))
+	})
+
+	t.Run("restores stored deals", func(t *testing.T) {
+		recorder := metrics.NewMemoryRecorder()
+		tracker := metrics.NewDealTracker(market)
+		tracker.SetRecorder(recorder)
+
+		tracker.Restore("deal1", "Sealing", false)
+		tracker.Restore("deal2", "Active", false)
+		tracker.Restore("deal3", "Expired", true)
+		require.Equal(t, int64(1), recorder.DealsInState(market, "Sealing"))
+		require.Equal(t, int64(1), recorder.DealsInState(market, "Active"))
+		require.Equal(t, int64(1), recorder.DealsInState(market, "Expired"))
+
+		tracker.Observe("deal1", "Sealed", "Active", false)
+		tracker.Observe("deal2", "Expired", "Expired", true)
+		tracker.Observe("deal3", "Expired", "Expired", true)
+		require.Zero(t, recorder.DealsInState(market, "Sealing"))
+		require.Equal(t, int64(1), recorder.DealsInState(market, "Active"))
+		require.Equal(t, int64(2), recorder.DealsInState(market, "Expired"))
+		require.Len(t, recorder.TimesInState(market, "Sealing"), 1)
+	})
+
+	t.Run("records bytes transferred from running totals", func(t *testing.T) {
+		recorder := metrics.NewMemoryRecorder()
+		tracker := metrics.NewDealTracker(market)
+		tracker.SetRecorder(recorder)
+
+		// deals that have not been observed are ignored
+		tracker.TotalTransferred("deal", 100)
+		require.Zero(t, recorder.BytesTransferred(market))
+
+		tracker.Observe("deal", "Open", "Transferring", false)
+		tracker.TotalTransferred("deal", 100)
+		tracker.TotalTransferred("deal", 100)
+		tracker.TotalTransferred("deal", 250)
+		require.Equal(t, uint64(250), recorder.BytesTransferred(market))
+
+		// totals that go back to zero start counting again from there
+		tracker.TotalTransferred("deal", 0)
+		tracker.TotalTransferred("deal", 50)
+		require.Equal(t, uint64(300), recorder.BytesTransferred(market))
+
+		tracker.BytesTransferred(1000)
+		require.Equal(t, uint64(1300), recorder.BytesTransferred(market))
+	})
+
+	t.Run("records durations", func(t *testing.T) {
+		recorder := metrics.NewMemoryRecorder()
+		tracker := metrics.NewDealTracker(market)
+		tracker.SetRecorder(recorder)
+
+		tracker.CommPDuration(time.Second)
+		tracker.UnsealDuration(time.Minute)
+		require.Equal(t, []time.Duration{time.Second}, recorder.CommPDurations(market))
+		require.Equal(t, []time.Duration{time.Minute}, recorder.UnsealDurations(market))
+	})
+
+	t.Run("discards measurements without a recorder", func(t *testing.T) {
+		recorder := metrics.NewMemoryRecorder()
+		tracker := metrics.NewDealTracker(market)
+
+		tracker.Observe("deal", "Open", "Validating", false)
+		tracker.SetRecorder(recorder)
+		tracker.Observe("deal", "Accepted", "WaitingForData", false)
+		tracker.SetRecorder(nil)
+		tracker.Observe("deal", "Failed", "Failing", false)
+
+		require.Zero(t, recorder.Events(market, "Open"))
+		require.Equal(t, int64(1), recorder.Events(market, "Accepted"))
+		require.Zero(t, recorder.Events(market, "Failed"))
+		require.Equal(t, int64(1), recorder.DealsInState(market, "WaitingForData"))
+	})
+}
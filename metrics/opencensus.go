@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+
+	"github.com/filecoin-project/go-fil-markets/shared"
+)
+
+// Tags applied to market measurements
+var (
+	MarketKey = tag.MustNewKey("market")
+	EventKey  = tag.MustNewKey("event")
+	StateKey  = tag.MustNewKey("state")
+)
+
+// Measures recorded by the OpenCensus recorder
+var (
+	DealEvents       = stats.Int64("markets/deal_events", "Number of events that happened to deals", stats.UnitDimensionless)
+	DealsInState     = stats.Int64("markets/deals_in_state", "Change in the number of deals in a state", stats.UnitDimensionless)
+	TimeInState      = stats.Float64("markets/time_in_state", "Time a deal spent in a state before moving to another", stats.UnitMilliseconds)
+	BytesTransferred = stats.Int64("markets/bytes_transferred", "Bytes of deal data sent or received", stats.UnitBytes)
+	CommPDuration    = stats.Float64("markets/commp_duration", "Time taken to generate a piece commitment", stats.UnitMilliseconds)
+	UnsealDuration   = stats.Float64("markets/unseal_duration", "Time taken to unseal a sector for a retrieval", stats.UnitMilliseconds)
+)
+
+// durationDistribution buckets durations from a millisecond to a day
+var durationDistribution = view.Distribution(1, 10, 100, 1000, 10000, 60000, 600000, 3600000, 21600000, 86400000)
+
+// Views of the market measures
+var (
+	DealEventsView = &view.View{
+		Measure:     DealEvents,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{MarketKey, EventKey},
+	}
+	DealsInStateView = &view.View{
+		Measure:     DealsInState,
+		Aggregation: view.Sum(),
+		TagKeys:     []tag.Key{MarketKey, StateKey},
+	}
+	TimeInStateView = &view.View{
+		Measure:     TimeInState,
+		Aggregation: durationDistribution,
+		TagKeys:     []tag.Key{MarketKey, StateKey},
+	}
+	BytesTransferredView = &view.View{
+		Measure:     BytesTransferred,
+		Aggregation: view.Sum(),
+		TagKeys:     []tag.Key{MarketKey},
+	}
+	CommPDurationView = &view.View{
+		Measure:     CommPDuration,
+		Aggregation: durationDistribution,
+		TagKeys:     []tag.Key{MarketKey},
+	}
+	UnsealDurationView = &view.View{
+		Measure:     UnsealDuration,
+		Aggregation: durationDistribution,
+		TagKeys:     []tag.Key{MarketKey},
+	}
+)
+
+// DefaultViews are all the views of the market measures. They must be registered with
+// view.Register for measurements to be exported
+var DefaultViews = []*view.View{
+	DealEventsView,
+	DealsInStateView,
+	TimeInStateView,
+	BytesTransferredView,
+	CommPDurationView,
+	UnsealDurationView,
+}
+
+// OpenCensusRecorder is a Recorder that records measurements with OpenCensus, so they can
+// be exported to Prometheus or any other OpenCensus exporter
+type OpenCensusRecorder struct{}
+
+var _ Recorder = OpenCensusRecorder{}
+
+// RecordEvent counts an event that happened to a deal
+func (OpenCensusRecorder) RecordEvent(market shared.Market, event string) {
+	record(DealEvents.M(1), tag.Upsert(MarketKey, string(market)), tag.Upsert(EventKey, event))
+}
+
+// AddDealsInState changes the number of deals in a state by delta
+func (OpenCensusRecorder) AddDealsInState(market shared.Market, state string, delta int64) {
+	record(DealsInState.M(delta), tag.Upsert(MarketKey, string(market)), tag.Upsert(StateKey, state))
+}
+
+// RecordTimeInState records how long a deal spent in a state before it moved to another
+func (OpenCensusRecorder) RecordTimeInState(market shared.Market, state string, duration time.Duration) {
+	record(TimeInState.M(milliseconds(duration)), tag.Upsert(MarketKey, string(market)), tag.Upsert(StateKey, state))
+}
+
+// RecordBytesTransferred counts bytes of deal data sent or received
+func (OpenCensusRecorder) RecordBytesTransferred(market shared.Market, bytes uint64) {
+	record(BytesTransferred.M(int64(bytes)), tag.Upsert(MarketKey, string(market)))
+}
+
+// RecordCommPDuration records how long it took to generate a piece commitment for a deal
+func (OpenCensusRecorder) RecordCommPDuration(market shared.Market, duration time.Duration) {
+	record(CommPDuration.M(milliseconds(duration)), tag.Upsert(MarketKey, string(market)))
+}
+
+// RecordUnsealDuration records how long it took to unseal a sector to serve a retrieval
+func (OpenCensusRecorder) RecordUnsealDuration(market shared.Market, duration time.Duration) {
+	record(UnsealDuration.M(milliseconds(duration)), tag.Upsert(MarketKey, string(market)))
+}
+
+func record(measurement stats.Measurement, mutators ...tag.Mutator) {
+	if err := stats.RecordWithTags(context.Background(), mutators, measurement); err != nil {
+		log.Warnf("failed to record %s: %s", measurement.Measure().Name(), err)
+	}
+}
+
+func milliseconds(duration time.Duration) float64 {
+	return float64(duration) / float64(time.Millisecond)
+}
@@ -0,0 +1,100 @@
+package pieceio
+
+import (
+	"context"
+	"io"
+
+	"github.com/filecoin-project/go-padreader"
+	"github.com/filecoin-project/sector-storage/ffiwrapper"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+)
+
+// GeneratePieceCommitmentFromStream computes the piece commitment for data read from rd in a
+// single pass, copying the data to w as it is read. The data must be sized for a piece of the
+// given padded size, and reading stops with an error if the context is cancelled. It returns
+// the commitment and the number of bytes of data read
+func GeneratePieceCommitmentFromStream(ctx context.Context, rt abi.RegisteredProof, rd io.Reader, w io.Writer, pieceSize abi.PaddedPieceSize) (cid.Cid, uint64, error) {
+	if err := pieceSize.Validate(); err != nil {
+		return cid.Undef, 0, xerrors.Errorf("invalid piece size: %w", err)
+	}
+
+	pr := &pieceReader{
+		ctx:  ctx,
+		data: io.TeeReader(rd, w),
+		size: uint64(pieceSize.Unpadded()),
+	}
+	commitment, err := ffiwrapper.GeneratePieceCIDFromFile(rt, pr, pieceSize.Unpadded())
+	// a failed read may surface from the commitment as a short read, so report the cause
+	if pr.err != nil {
+		return cid.Undef, 0, pr.err
+	}
+	if err != nil {
+		return cid.Undef, 0, err
+	}
+
+	if !pr.padding {
+		// the piece is full, so the data must end here
+		var extra [1]byte
+		n, err := pr.data.Read(extra[:])
+		if n > 0 {
+			return cid.Undef, 0, xerrors.Errorf("data is larger than piece size %d", pieceSize)
+		}
+		if err != nil && err != io.EOF {
+			return cid.Undef, 0, err
+		}
+	}
+
+	if pr.dataRead == 0 || padreader.PaddedSize(pr.dataRead).Padded() != pieceSize {
+		return cid.Undef, 0, xerrors.Errorf("data size %d does not match piece size %d", pr.dataRead, pieceSize)
+	}
+
+	return commitment, pr.dataRead, nil
+}
+
+// pieceReader reads data until it is exhausted, then pads it with zeroes up to size bytes
+type pieceReader struct {
+	ctx      context.Context
+	data     io.Reader
+	size     uint64
+	read     uint64
+	dataRead uint64
+	padding  bool
+	err      error
+}
+
+func (r *pieceReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		r.err = err
+		return 0, err
+	}
+	if r.read == r.size {
+		return 0, io.EOF
+	}
+	if remaining := r.size - r.read; uint64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+
+	if !r.padding {
+		n, err := r.data.Read(p)
+		r.read += uint64(n)
+		r.dataRead += uint64(n)
+		if err == io.EOF {
+			r.padding = true
+			err = nil
+		}
+		if err != nil {
+			r.err = err
+		}
+		if n > 0 || err != nil || !r.padding {
+			return n, err
+		}
+	}
+
+	for i := range p {
+		p[i] = 0
+	}
+	r.read += uint64(len(p))
+	return len(p), nil
+}
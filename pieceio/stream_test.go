@@ -0,0 +1,53 @@
+package pieceio_test
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"testing"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-fil-markets/pieceio"
+)
+
+func Test_PieceCommitmentFromStream(t *testing.T) {
+	rt := abi.RegisteredProof_StackedDRG2KiBPoSt
+	data := make([]byte, 500)
+	_, err := rand.Read(data)
+	require.NoError(t, err)
+
+	expected, unpaddedSize, err := pieceio.GeneratePieceCommitment(rt, bytes.NewReader(data), uint64(len(data)))
+	require.NoError(t, err)
+	pieceSize := unpaddedSize.Padded()
+
+	t.Run("matches commitment of buffered data", func(t *testing.T) {
+		var written bytes.Buffer
+		commitment, n, err := pieceio.GeneratePieceCommitmentFromStream(context.Background(), rt, bytes.NewReader(data), &written, pieceSize)
+		require.NoError(t, err)
+		require.Equal(t, expected, commitment)
+		require.Equal(t, uint64(len(data)), n)
+		require.Equal(t, data, written.Bytes())
+	})
+
+	t.Run("data larger than piece size", func(t *testing.T) {
+		var written bytes.Buffer
+		_, _, err := pieceio.GeneratePieceCommitmentFromStream(context.Background(), rt, bytes.NewReader(data), &written, pieceSize/2)
+		require.EqualError(t, err, "data is larger than piece size 256")
+	})
+
+	t.Run("data smaller than piece size", func(t *testing.T) {
+		var written bytes.Buffer
+		_, _, err := pieceio.GeneratePieceCommitmentFromStream(context.Background(), rt, bytes.NewReader(data), &written, pieceSize*2)
+		require.EqualError(t, err, "data size 500 does not match piece size 1024")
+	})
+
+	t.Run("cancelled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		var written bytes.Buffer
+		_, _, err := pieceio.GeneratePieceCommitmentFromStream(ctx, rt, bytes.NewReader(data), &written, pieceSize)
+		require.Equal(t, context.Canceled, err)
+	})
+}
@@ -2,6 +2,7 @@ package piecestore
 
 import (
 	"github.com/filecoin-project/go-statestore"
+	"github.com/filecoin-project/specs-actors/actors/abi"
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-datastore"
 	"github.com/ipfs/go-datastore/namespace"
@@ -58,6 +59,34 @@ func (ps *pieceStore) AddPieceBlockLocations(pieceCID cid.Cid, blockLocations ma
 	return nil
 }
 
+// Remove the deal with `dealID` from the PieceInfo with key `pieceCID`. Once no deals are left
+// for the piece, its PieceInfo and the locations of its blocks are removed as well
+func (ps *pieceStore) RemoveDealForPiece(pieceCID cid.Cid, dealID abi.DealID) error {
+	var remaining int
+	err := ps.pieces.Get(pieceCID).Mutate(func(pi *PieceInfo) error {
+		deals := make([]DealInfo, 0, len(pi.Deals))
+		for _, di := range pi.Deals {
+			if di.DealID != dealID {
+				deals = append(deals, di)
+			}
+		}
+		pi.Deals = deals
+		remaining = len(deals)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if remaining > 0 {
+		return nil
+	}
+
+	if err := ps.pieces.Get(pieceCID).End(); err != nil {
+		return err
+	}
+	return ps.removePieceBlockLocations(pieceCID)
+}
+
 // Retrieve the PieceInfo associated with `pieceCID` from the piece info store.
 func (ps *pieceStore) GetPieceInfo(pieceCID cid.Cid) (PieceInfo, error) {
 	var out PieceInfo
@@ -76,6 +105,41 @@ func (ps *pieceStore) GetCIDInfo(payloadCID cid.Cid) (CIDInfo, error) {
 	return out, nil
 }
 
+// removePieceBlockLocations removes the locations of blocks in the given piece from the CID
+// info store, and removes CID infos that are no longer in any piece
+func (ps *pieceStore) removePieceBlockLocations(pieceCID cid.Cid) error {
+	var cidInfos []CIDInfo
+	if err := ps.cidInfos.List(&cidInfos); err != nil {
+		return err
+	}
+
+	for _, ci := range cidInfos {
+		locations := make([]PieceBlockLocation, 0, len(ci.PieceBlockLocations))
+		for _, pbl := range ci.PieceBlockLocations {
+			if !pbl.PieceCID.Equals(pieceCID) {
+				locations = append(locations, pbl)
+			}
+		}
+		if len(locations) == len(ci.PieceBlockLocations) {
+			continue
+		}
+
+		var err error
+		if len(locations) == 0 {
+			err = ps.cidInfos.Get(ci.CID).End()
+		} else {
+			err = ps.cidInfos.Get(ci.CID).Mutate(func(ci *CIDInfo) error {
+				ci.PieceBlockLocations = locations
+				return nil
+			})
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (ps *pieceStore) ensurePieceInfo(pieceCID cid.Cid) error {
 	has, err := ps.pieces.Has(pieceCID)
 
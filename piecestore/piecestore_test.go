@@ -171,3 +171,62 @@ func TestStoreCIDInfo(t *testing.T) {
 		assert.Equal(t, ci.PieceBlockLocations[0], piecestore.PieceBlockLocation{blockLocations[2], pieceCid1})
 	})
 }
+
+func TestRemoveDealForPiece(t *testing.T) {
+	pieceCids := shared_testutil.GenerateCids(2)
+	testCIDs := shared_testutil.GenerateCids(2)
+	dealInfos := []piecestore.DealInfo{
+		{DealID: abi.DealID(1), SectorID: 1},
+		{DealID: abi.DealID(2), SectorID: 2},
+	}
+	blockLocation := piecestore.BlockLocation{RelOffset: 10, BlockSize: 100}
+
+	initializePieceStore := func(t *testing.T) piecestore.PieceStore {
+		ps := piecestore.NewPieceStore(datastore.NewMapDatastore())
+		for _, dealInfo := range dealInfos {
+			assert.NoError(t, ps.AddDealForPiece(pieceCids[0], dealInfo))
+		}
+		assert.NoError(t, ps.AddPieceBlockLocations(pieceCids[0], map[cid.Cid]piecestore.BlockLocation{
+			testCIDs[0]: blockLocation,
+			testCIDs[1]: blockLocation,
+		}))
+		assert.NoError(t, ps.AddPieceBlockLocations(pieceCids[1], map[cid.Cid]piecestore.BlockLocation{
+			testCIDs[1]: blockLocation,
+		}))
+		return ps
+	}
+
+	t.Run("keeps piece with other deals", func(t *testing.T) {
+		ps := initializePieceStore(t)
+		assert.NoError(t, ps.RemoveDealForPiece(pieceCids[0], dealInfos[0].DealID))
+
+		pi, err := ps.GetPieceInfo(pieceCids[0])
+		assert.NoError(t, err)
+		assert.Equal(t, []piecestore.DealInfo{dealInfos[1]}, pi.Deals)
+
+		ci, err := ps.GetCIDInfo(testCIDs[0])
+		assert.NoError(t, err)
+		assert.Len(t, ci.PieceBlockLocations, 1)
+	})
+
+	t.Run("removes piece and its block locations with its last deal", func(t *testing.T) {
+		ps := initializePieceStore(t)
+		assert.NoError(t, ps.RemoveDealForPiece(pieceCids[0], dealInfos[0].DealID))
+		assert.NoError(t, ps.RemoveDealForPiece(pieceCids[0], dealInfos[1].DealID))
+
+		_, err := ps.GetPieceInfo(pieceCids[0])
+		assert.Error(t, err)
+
+		_, err = ps.GetCIDInfo(testCIDs[0])
+		assert.Error(t, err)
+
+		ci, err := ps.GetCIDInfo(testCIDs[1])
+		assert.NoError(t, err)
+		assert.Equal(t, []piecestore.PieceBlockLocation{{blockLocation, pieceCids[1]}}, ci.PieceBlockLocations)
+	})
+
+	t.Run("fails for an unknown piece", func(t *testing.T) {
+		ps := initializePieceStore(t)
+		assert.Error(t, ps.RemoveDealForPiece(pieceCids[1], dealInfos[0].DealID))
+	})
+}
@@ -52,6 +52,7 @@ var PieceInfoUndefined = PieceInfo{}
 type PieceStore interface {
 	AddDealForPiece(pieceCID cid.Cid, dealInfo DealInfo) error
 	AddPieceBlockLocations(pieceCID cid.Cid, blockLocations map[cid.Cid]BlockLocation) error
+	RemoveDealForPiece(pieceCID cid.Cid, dealID abi.DealID) error
 	GetPieceInfo(pieceCID cid.Cid) (PieceInfo, error)
 	GetCIDInfo(payloadCID cid.Cid) (CIDInfo, error)
 }
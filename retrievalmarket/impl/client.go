@@ -17,6 +17,8 @@ import (
 	"github.com/libp2p/go-libp2p-core/peer"
 	"golang.org/x/xerrors"
 
+	"github.com/filecoin-project/go-fil-markets/journal"
+	"github.com/filecoin-project/go-fil-markets/metrics"
 	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
 	"github.com/filecoin-project/go-fil-markets/retrievalmarket/impl/blockio"
 	"github.com/filecoin-project/go-fil-markets/retrievalmarket/impl/clientstates"
@@ -40,10 +42,29 @@ type client struct {
 	blockVerifiers map[retrievalmarket.DealID]blockio.BlockVerifier
 	dealStreams    map[retrievalmarket.DealID]rmnet.RetrievalDealStream
 	stateMachines  fsm.Group
+	journal        *journal.Journal
+	dealMetrics    *metrics.DealTracker
 }
 
 var _ retrievalmarket.RetrievalClient = &client{}
 
+// RetrievalClientOption allows custom configuration of a retrieval client
+type RetrievalClientOption func(c *client)
+
+// ClientDealJournal records every event that happens to the client's deals in the given journal
+func ClientDealJournal(j *journal.Journal) RetrievalClientOption {
+	return func(c *client) {
+		c.journal = j
+	}
+}
+
+// ClientMetricsRecorder sends measurements of the client's deals to the given recorder
+func ClientMetricsRecorder(recorder metrics.Recorder) RetrievalClientOption {
+	return func(c *client) {
+		c.dealMetrics.SetRecorder(recorder)
+	}
+}
+
 // NewClient creates a new retrieval client
 func NewClient(
 	network rmnet.RetrievalMarketNetwork,
@@ -52,6 +73,7 @@ func NewClient(
 	resolver retrievalmarket.PeerResolver,
 	ds datastore.Batching,
 	storedCounter *storedcounter.StoredCounter,
+	opts ...RetrievalClientOption,
 ) (retrievalmarket.RetrievalClient, error) {
 	c := &client{
 		network:        network,
@@ -61,12 +83,16 @@ func NewClient(
 		storedCounter:  storedCounter,
 		dealStreams:    make(map[retrievalmarket.DealID]rmnet.RetrievalDealStream),
 		blockVerifiers: make(map[retrievalmarket.DealID]blockio.BlockVerifier),
+		dealMetrics:    metrics.NewDealTracker(shared.RetrievalClient),
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
 	stateMachines, err := fsm.New(ds, fsm.Parameters{
 		Environment:     c,
 		StateType:       retrievalmarket.ClientDealState{},
 		StateKeyField:   "Status",
-		Events:          clientstates.ClientEvents,
+		Events:          clientstates.NewClientEvents(journal.RecordArgs(c.recordEventArgs)),
 		StateEntryFuncs: clientstates.ClientStateEntryFuncs,
 		Notifier:        c.notifySubscribers,
 	})
@@ -74,6 +100,14 @@ func NewClient(
 		return nil, err
 	}
 	c.stateMachines = stateMachines
+
+	var deals []retrievalmarket.ClientDealState
+	if err := c.stateMachines.List(&deals); err != nil {
+		return nil, xerrors.Errorf("listing deals: %w", err)
+	}
+	for _, deal := range deals {
+		c.dealMetrics.Restore(deal.ID.String(), retrievalmarket.DealStatuses[deal.Status], retrievalmarket.IsTerminalStatus(deal.Status))
+	}
 	return c, nil
 }
 
@@ -186,6 +220,13 @@ func (c *client) unsubscribeAt(sub retrievalmarket.ClientSubscriber) retrievalma
 	}
 }
 
+// recordEventArgs keeps the arguments of an event for the deal journal, if there is one
+func (c *client) recordEventArgs(event fsm.EventName, state interface{}, args []interface{}) {
+	if c.journal != nil {
+		c.journal.AddArgs(shared.RetrievalClient, state.(*retrievalmarket.ClientDealState).ID.String(), event, args)
+	}
+}
+
 func (c *client) notifySubscribers(eventName fsm.EventName, state fsm.StateType) {
 	c.subscribersLk.RLock()
 	defer c.subscribersLk.RUnlock()
@@ -194,6 +235,23 @@ func (c *client) notifySubscribers(eventName fsm.EventName, state fsm.StateType)
 	for _, cb := range c.subscribers {
 		cb(evt, ds)
 	}
+
+	c.dealMetrics.TotalTransferred(ds.ID.String(), ds.TotalReceived)
+	c.dealMetrics.Observe(ds.ID.String(), retrievalmarket.ClientEvents[evt], retrievalmarket.DealStatuses[ds.Status], retrievalmarket.IsTerminalStatus(ds.Status))
+
+	if c.journal != nil {
+		err := c.journal.Record(journal.Entry{
+			Market:  shared.RetrievalClient,
+			Deal:    ds.ID.String(),
+			Event:   retrievalmarket.ClientEvents[evt],
+			Args:    c.journal.TakeArgs(shared.RetrievalClient, ds.ID.String(), eventName),
+			State:   retrievalmarket.DealStatuses[ds.Status],
+			Message: ds.Message,
+		})
+		if err != nil {
+			log.Errorf("failed to journal event %d: %s", evt, err)
+		}
+	}
 }
 
 func (c *client) SubscribeToEvents(subscriber retrievalmarket.ClientSubscriber) retrievalmarket.Unsubscribe {
@@ -10,6 +10,7 @@ import (
 	"github.com/ipfs/go-cid"
 	"golang.org/x/xerrors"
 
+	"github.com/filecoin-project/go-fil-markets/journal"
 	rm "github.com/filecoin-project/go-fil-markets/retrievalmarket"
 )
 
@@ -25,161 +26,175 @@ func recordProcessed(deal *rm.ClientDealState, totalProcessed uint64) error {
 }
 
 // ClientEvents are the events that can happen in a retrieval client
-var ClientEvents = fsm.Events{
-	fsm.Event(rm.ClientEventOpen).
-		From(rm.DealStatusNew).ToNoChange(),
-	fsm.Event(rm.ClientEventPaymentChannelErrored).
-		FromMany(rm.DealStatusAccepted, rm.DealStatusPaymentChannelCreating).To(rm.DealStatusFailed).
-		Action(func(deal *rm.ClientDealState, err error) error {
-			deal.Message = xerrors.Errorf("get or create payment channel: %w", err).Error()
-			return nil
-		}),
-	fsm.Event(rm.ClientEventPaymentChannelCreateInitiated).
-		From(rm.DealStatusAccepted).To(rm.DealStatusPaymentChannelCreating).
-		Action(func(deal *rm.ClientDealState, msgCID cid.Cid) error {
-			deal.WaitMsgCID = &msgCID
-			return nil
-		}),
-	fsm.Event(rm.ClientEventPaymentChannelAddingFunds).
-		FromMany(rm.DealStatusAccepted).To(rm.DealStatusPaymentChannelAddingFunds).
-		Action(func(deal *rm.ClientDealState, msgCID cid.Cid, payCh address.Address) error {
-			deal.WaitMsgCID = &msgCID
-			deal.PaymentInfo = &rm.PaymentInfo{
-				PayCh: payCh,
-			}
-			return nil
-		}),
-	fsm.Event(rm.ClientEventPaymentChannelReady).
-		FromMany(rm.DealStatusPaymentChannelCreating, rm.DealStatusPaymentChannelAddingFunds).
-		To(rm.DealStatusPaymentChannelReady).
-		Action(func(deal *rm.ClientDealState, payCh address.Address, lane uint64) error {
-			deal.PaymentInfo = &rm.PaymentInfo{
-				PayCh: payCh,
-				Lane:  lane,
-			}
-			return nil
-		}),
-	fsm.Event(rm.ClientEventAllocateLaneErrored).
-		FromMany(rm.DealStatusPaymentChannelCreating, rm.DealStatusPaymentChannelAddingFunds).To(rm.DealStatusFailed).
-		Action(func(deal *rm.ClientDealState, err error) error {
-			deal.Message = xerrors.Errorf("allocating payment lane: %w", err).Error()
-			return nil
-		}),
-	fsm.Event(rm.ClientEventPaymentChannelAddFundsErrored).
-		From(rm.DealStatusPaymentChannelAddingFunds).To(rm.DealStatusFailed).
-		Action(func(deal *rm.ClientDealState, err error) error {
-			deal.Message = xerrors.Errorf("wait for add funds: %w", err).Error()
-			return nil
-		}),
-	fsm.Event(rm.ClientEventWriteDealProposalErrored).
-		FromAny().To(rm.DealStatusErrored).
-		Action(func(deal *rm.ClientDealState, err error) error {
-			deal.Message = xerrors.Errorf("proposing deal: %w", err).Error()
-			return nil
-		}),
-	fsm.Event(rm.ClientEventReadDealResponseErrored).
-		FromAny().To(rm.DealStatusErrored).
-		Action(func(deal *rm.ClientDealState, err error) error {
-			deal.Message = xerrors.Errorf("reading deal response: %w", err).Error()
-			return nil
-		}),
-	fsm.Event(rm.ClientEventDealRejected).
-		From(rm.DealStatusNew).To(rm.DealStatusRejected).
-		Action(func(deal *rm.ClientDealState, message string) error {
-			deal.Message = fmt.Sprintf("deal rejected: %s", message)
-			return nil
-		}),
-	fsm.Event(rm.ClientEventDealNotFound).
-		From(rm.DealStatusNew).To(rm.DealStatusDealNotFound).
-		Action(func(deal *rm.ClientDealState, message string) error {
-			deal.Message = fmt.Sprintf("deal not found: %s", message)
-			return nil
-		}),
-	fsm.Event(rm.ClientEventDealAccepted).
-		From(rm.DealStatusNew).To(rm.DealStatusAccepted),
-	fsm.Event(rm.ClientEventUnknownResponseReceived).
-		FromAny().To(rm.DealStatusFailed).
-		Action(func(deal *rm.ClientDealState) error {
-			deal.Message = "Unexpected deal response status"
-			return nil
-		}),
-	fsm.Event(rm.ClientEventFundsExpended).
-		FromMany(rm.DealStatusFundsNeeded, rm.DealStatusFundsNeededLastPayment).To(rm.DealStatusFailed).
-		Action(func(deal *rm.ClientDealState, expectedTotal string, actualTotal string) error {
-			deal.Message = fmt.Sprintf("not enough funds left: expected amt = %s, actual amt = %s", expectedTotal, actualTotal)
-			return nil
-		}),
-	fsm.Event(rm.ClientEventBadPaymentRequested).
-		FromMany(rm.DealStatusFundsNeeded, rm.DealStatusFundsNeededLastPayment).To(rm.DealStatusFailed).
-		Action(func(deal *rm.ClientDealState, message string) error {
-			deal.Message = message
-			return nil
-		}),
-	fsm.Event(rm.ClientEventCreateVoucherFailed).
-		FromMany(rm.DealStatusFundsNeeded, rm.DealStatusFundsNeededLastPayment).To(rm.DealStatusFailed).
-		Action(func(deal *rm.ClientDealState, err error) error {
-			deal.Message = xerrors.Errorf("creating payment voucher: %w", err).Error()
-			return nil
-		}),
-	fsm.Event(rm.ClientEventWriteDealPaymentErrored).
-		FromAny().To(rm.DealStatusErrored).
-		Action(func(deal *rm.ClientDealState, err error) error {
-			deal.Message = xerrors.Errorf("writing deal payment: %w", err).Error()
-			return nil
-		}),
-	fsm.Event(rm.ClientEventPaymentSent).
-		From(rm.DealStatusFundsNeeded).To(rm.DealStatusOngoing).
-		From(rm.DealStatusFundsNeededLastPayment).To(rm.DealStatusFinalizing).
-		Action(func(deal *rm.ClientDealState) error {
-			// paymentRequested = 0
-			// fundsSpent = fundsSpent + paymentRequested
-			// if paymentRequested / pricePerByte >= currentInterval
-			// currentInterval = currentInterval + proposal.intervalIncrease
-			// bytesPaidFor = bytesPaidFor + (paymentRequested / pricePerByte)
-			deal.FundsSpent = big.Add(deal.FundsSpent, deal.PaymentRequested)
-			bytesPaidFor := big.Div(deal.PaymentRequested, deal.PricePerByte).Uint64()
-			if bytesPaidFor >= deal.CurrentInterval {
-				deal.CurrentInterval += deal.DealProposal.PaymentIntervalIncrease
-			}
-			deal.BytesPaidFor += bytesPaidFor
-			deal.PaymentRequested = abi.NewTokenAmount(0)
-			return nil
-		}),
-	fsm.Event(rm.ClientEventConsumeBlockFailed).
-		FromMany(rm.DealStatusPaymentChannelReady, rm.DealStatusOngoing).To(rm.DealStatusFailed).
-		Action(func(deal *rm.ClientDealState, err error) error {
-			deal.Message = xerrors.Errorf("consuming block: %w", err).Error()
-			return nil
-		}),
-	fsm.Event(rm.ClientEventLastPaymentRequested).
-		FromMany(rm.DealStatusPaymentChannelReady,
-			rm.DealStatusOngoing,
-			rm.DealStatusBlocksComplete).To(rm.DealStatusFundsNeededLastPayment).
-		Action(recordPaymentOwed),
-	fsm.Event(rm.ClientEventAllBlocksReceived).
-		FromMany(rm.DealStatusPaymentChannelReady,
-			rm.DealStatusOngoing,
-			rm.DealStatusBlocksComplete).To(rm.DealStatusBlocksComplete).
-		Action(recordProcessed),
-	fsm.Event(rm.ClientEventComplete).
-		FromMany(rm.DealStatusPaymentChannelReady,
-			rm.DealStatusOngoing,
-			rm.DealStatusBlocksComplete,
-			rm.DealStatusFinalizing).To(rm.DealStatusCompleted).
-		Action(recordProcessed),
-	fsm.Event(rm.ClientEventEarlyTermination).
-		FromMany(rm.DealStatusPaymentChannelReady, rm.DealStatusOngoing).To(rm.DealStatusFailed).
-		Action(func(deal *rm.ClientDealState) error {
-			deal.Message = "received complete status before all blocks received"
-			return nil
-		}),
-	fsm.Event(rm.ClientEventPaymentRequested).
-		FromMany(rm.DealStatusPaymentChannelReady, rm.DealStatusOngoing).To(rm.DealStatusFundsNeeded).
-		Action(recordPaymentOwed),
-	fsm.Event(rm.ClientEventBlocksReceived).
-		From(rm.DealStatusPaymentChannelReady).To(rm.DealStatusOngoing).
-		From(rm.DealStatusOngoing).ToNoChange().
-		Action(recordProcessed),
+var ClientEvents = NewClientEvents(nil)
+
+// NewClientEvents returns the events that can happen in a retrieval client, with the
+// action of each event wrapped by wrap, if it is set
+func NewClientEvents(wrap journal.ActionWrapper) fsm.Events {
+	event := func(name fsm.EventName) journal.EventBuilder {
+		return journal.Event(name, wrap)
+	}
+	return journal.Events{
+		event(rm.ClientEventOpen).
+			From(rm.DealStatusNew).ToNoChange(),
+		event(rm.ClientEventPaymentChannelErrored).
+			FromMany(rm.DealStatusAccepted, rm.DealStatusPaymentChannelCreating).To(rm.DealStatusFailed).
+			Action(func(deal *rm.ClientDealState, err error) error {
+				deal.Message = xerrors.Errorf("get or create payment channel: %w", err).Error()
+				return nil
+			}),
+		event(rm.ClientEventPaymentChannelCreateInitiated).
+			From(rm.DealStatusAccepted).To(rm.DealStatusPaymentChannelCreating).
+			Action(func(deal *rm.ClientDealState, msgCID cid.Cid) error {
+				deal.WaitMsgCID = &msgCID
+				return nil
+			}),
+		event(rm.ClientEventPaymentChannelAddingFunds).
+			FromMany(rm.DealStatusAccepted).To(rm.DealStatusPaymentChannelAddingFunds).
+			Action(func(deal *rm.ClientDealState, msgCID cid.Cid, payCh address.Address) error {
+				deal.WaitMsgCID = &msgCID
+				deal.PaymentInfo = &rm.PaymentInfo{
+					PayCh: payCh,
+				}
+				return nil
+			}),
+		event(rm.ClientEventPaymentChannelReady).
+			FromMany(rm.DealStatusPaymentChannelCreating, rm.DealStatusPaymentChannelAddingFunds).
+			To(rm.DealStatusPaymentChannelReady).
+			Action(func(deal *rm.ClientDealState, payCh address.Address, lane uint64) error {
+				deal.PaymentInfo = &rm.PaymentInfo{
+					PayCh: payCh,
+					Lane:  lane,
+				}
+				return nil
+			}),
+		event(rm.ClientEventAllocateLaneErrored).
+			FromMany(rm.DealStatusPaymentChannelCreating, rm.DealStatusPaymentChannelAddingFunds).To(rm.DealStatusFailed).
+			Action(func(deal *rm.ClientDealState, err error) error {
+				deal.Message = xerrors.Errorf("allocating payment lane: %w", err).Error()
+				return nil
+			}),
+		event(rm.ClientEventPaymentChannelAddFundsErrored).
+			From(rm.DealStatusPaymentChannelAddingFunds).To(rm.DealStatusFailed).
+			Action(func(deal *rm.ClientDealState, err error) error {
+				deal.Message = xerrors.Errorf("wait for add funds: %w", err).Error()
+				return nil
+			}),
+		event(rm.ClientEventWriteDealProposalErrored).
+			FromAny().To(rm.DealStatusErrored).
+			Action(func(deal *rm.ClientDealState, err error) error {
+				deal.Message = xerrors.Errorf("proposing deal: %w", err).Error()
+				return nil
+			}),
+		event(rm.ClientEventReadDealResponseErrored).
+			FromAny().To(rm.DealStatusErrored).
+			Action(func(deal *rm.ClientDealState, err error) error {
+				deal.Message = xerrors.Errorf("reading deal response: %w", err).Error()
+				return nil
+			}),
+		event(rm.ClientEventDealRejected).
+			From(rm.DealStatusNew).To(rm.DealStatusRejected).
+			Action(func(deal *rm.ClientDealState, message string, reason rm.RejectionReason) error {
+				deal.Message = fmt.Sprintf("deal rejected: %s", message)
+				// providers that predate rejection reasons do not send one
+				if reason == rm.RejectionNone {
+					reason = rm.RejectionUnspecified
+				}
+				deal.RejectionReason = reason
+				return nil
+			}),
+		event(rm.ClientEventDealNotFound).
+			From(rm.DealStatusNew).To(rm.DealStatusDealNotFound).
+			Action(func(deal *rm.ClientDealState, message string) error {
+				deal.Message = fmt.Sprintf("deal not found: %s", message)
+				return nil
+			}),
+		event(rm.ClientEventDealAccepted).
+			From(rm.DealStatusNew).To(rm.DealStatusAccepted),
+		event(rm.ClientEventUnknownResponseReceived).
+			FromAny().To(rm.DealStatusFailed).
+			Action(func(deal *rm.ClientDealState) error {
+				deal.Message = "Unexpected deal response status"
+				return nil
+			}),
+		event(rm.ClientEventFundsExpended).
+			FromMany(rm.DealStatusFundsNeeded, rm.DealStatusFundsNeededLastPayment).To(rm.DealStatusFailed).
+			Action(func(deal *rm.ClientDealState, expectedTotal string, actualTotal string) error {
+				deal.Message = fmt.Sprintf("not enough funds left: expected amt = %s, actual amt = %s", expectedTotal, actualTotal)
+				return nil
+			}),
+		event(rm.ClientEventBadPaymentRequested).
+			FromMany(rm.DealStatusFundsNeeded, rm.DealStatusFundsNeededLastPayment).To(rm.DealStatusFailed).
+			Action(func(deal *rm.ClientDealState, message string) error {
+				deal.Message = message
+				return nil
+			}),
+		event(rm.ClientEventCreateVoucherFailed).
+			FromMany(rm.DealStatusFundsNeeded, rm.DealStatusFundsNeededLastPayment).To(rm.DealStatusFailed).
+			Action(func(deal *rm.ClientDealState, err error) error {
+				deal.Message = xerrors.Errorf("creating payment voucher: %w", err).Error()
+				return nil
+			}),
+		event(rm.ClientEventWriteDealPaymentErrored).
+			FromAny().To(rm.DealStatusErrored).
+			Action(func(deal *rm.ClientDealState, err error) error {
+				deal.Message = xerrors.Errorf("writing deal payment: %w", err).Error()
+				return nil
+			}),
+		event(rm.ClientEventPaymentSent).
+			From(rm.DealStatusFundsNeeded).To(rm.DealStatusOngoing).
+			From(rm.DealStatusFundsNeededLastPayment).To(rm.DealStatusFinalizing).
+			Action(func(deal *rm.ClientDealState) error {
+				// paymentRequested = 0
+				// fundsSpent = fundsSpent + paymentRequested
+				// if paymentRequested / pricePerByte >= currentInterval
+				// currentInterval = currentInterval + proposal.intervalIncrease
+				// bytesPaidFor = bytesPaidFor + (paymentRequested / pricePerByte)
+				deal.FundsSpent = big.Add(deal.FundsSpent, deal.PaymentRequested)
+				bytesPaidFor := big.Div(deal.PaymentRequested, deal.PricePerByte).Uint64()
+				if bytesPaidFor >= deal.CurrentInterval {
+					deal.CurrentInterval += deal.DealProposal.PaymentIntervalIncrease
+				}
+				deal.BytesPaidFor += bytesPaidFor
+				deal.PaymentRequested = abi.NewTokenAmount(0)
+				return nil
+			}),
+		event(rm.ClientEventConsumeBlockFailed).
+			FromMany(rm.DealStatusPaymentChannelReady, rm.DealStatusOngoing).To(rm.DealStatusFailed).
+			Action(func(deal *rm.ClientDealState, err error) error {
+				deal.Message = xerrors.Errorf("consuming block: %w", err).Error()
+				return nil
+			}),
+		event(rm.ClientEventLastPaymentRequested).
+			FromMany(rm.DealStatusPaymentChannelReady,
+				rm.DealStatusOngoing,
+				rm.DealStatusBlocksComplete).To(rm.DealStatusFundsNeededLastPayment).
+			Action(recordPaymentOwed),
+		event(rm.ClientEventAllBlocksReceived).
+			FromMany(rm.DealStatusPaymentChannelReady,
+				rm.DealStatusOngoing,
+				rm.DealStatusBlocksComplete).To(rm.DealStatusBlocksComplete).
+			Action(recordProcessed),
+		event(rm.ClientEventComplete).
+			FromMany(rm.DealStatusPaymentChannelReady,
+				rm.DealStatusOngoing,
+				rm.DealStatusBlocksComplete,
+				rm.DealStatusFinalizing).To(rm.DealStatusCompleted).
+			Action(recordProcessed),
+		event(rm.ClientEventEarlyTermination).
+			FromMany(rm.DealStatusPaymentChannelReady, rm.DealStatusOngoing).To(rm.DealStatusFailed).
+			Action(func(deal *rm.ClientDealState) error {
+				deal.Message = "received complete status before all blocks received"
+				return nil
+			}),
+		event(rm.ClientEventPaymentRequested).
+			FromMany(rm.DealStatusPaymentChannelReady, rm.DealStatusOngoing).To(rm.DealStatusFundsNeeded).
+			Action(recordPaymentOwed),
+		event(rm.ClientEventBlocksReceived).
+			From(rm.DealStatusPaymentChannelReady).To(rm.DealStatusOngoing).
+			From(rm.DealStatusOngoing).ToNoChange().
+			Action(recordProcessed),
+	}.Build()
 }
 
 // ClientStateEntryFuncs are the handlers for different states in a retrieval client
@@ -80,7 +80,7 @@ func ProposeDeal(ctx fsm.Context, environment ClientDealEnvironment, deal rm.Cli
 	}
 	switch response.Status {
 	case rm.DealStatusRejected:
-		return ctx.Trigger(rm.ClientEventDealRejected, response.Message)
+		return ctx.Trigger(rm.ClientEventDealRejected, response.Message, response.Reason)
 	case rm.DealStatusDealNotFound:
 		return ctx.Trigger(rm.ClientEventDealNotFound, response.Message)
 	case rm.DealStatusAccepted:
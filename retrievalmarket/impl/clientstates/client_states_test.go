@@ -275,6 +275,26 @@ func TestProposeDeal(t *testing.T) {
 		runProposeDeal(t, dealStreamParams, dealState)
 		require.NotEmpty(t, dealState.Message)
 		require.Equal(t, dealState.Status, retrievalmarket.DealStatusRejected)
+		require.Equal(t, retrievalmarket.RejectionUnspecified, dealState.RejectionReason)
+	})
+
+	t.Run("deal rejected with reason", func(t *testing.T) {
+		dealState := makeDealState(retrievalmarket.DealStatusNew)
+		dealStreamParams := testnet.TestDealStreamParams{
+			ResponseReader: testnet.StubbedDealResponseReader(retrievalmarket.DealResponse{
+				Status:  retrievalmarket.DealStatusRejected,
+				ID:      dealState.ID,
+				Message: "Payment interval too large",
+				Reason:  retrievalmarket.RejectionPaymentIntervalTooLarge,
+			}),
+		}
+		runProposeDeal(t, dealStreamParams, dealState)
+		require.Equal(t, dealState.Status, retrievalmarket.DealStatusRejected)
+		require.Equal(t, retrievalmarket.RejectionPaymentIntervalTooLarge, dealState.RejectionReason)
+
+		var rejected *retrievalmarket.DealRejectedError
+		require.True(t, errors.As(dealState.RejectionError(), &rejected))
+		require.Equal(t, retrievalmarket.RejectionPaymentIntervalTooLarge, rejected.Reason)
 	})
 
 	t.Run("deal not found", func(t *testing.T) {
@@ -0,0 +1,89 @@
+package dealfilter
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
+	"github.com/filecoin-project/go-fil-markets/shared"
+)
+
+// Filter evaluates a proposed retrieval deal. It returns a decision, and when the
+// deal is rejected, a reason that is sent back to the client
+type Filter func(ctx context.Context, state retrievalmarket.ProviderDealState) (shared.FilterDecision, string, error)
+
+// Pipeline combines filters into a single deal decider. Filters run in order, and the
+// first filter that accepts or rejects a deal decides it. A deal that every filter
+// abstains on is accepted
+func Pipeline(filters ...Filter) func(context.Context, retrievalmarket.ProviderDealState) (bool, string, error) {
+	return func(ctx context.Context, state retrievalmarket.ProviderDealState) (bool, string, error) {
+		for _, filter := range filters {
+			decision, reason, err := filter(ctx, state)
+			if err != nil {
+				return false, "", err
+			}
+			switch decision {
+			case shared.FilterAccept:
+				return true, "", nil
+			case shared.FilterReject:
+				return false, reason, nil
+			}
+		}
+		return true, "", nil
+	}
+}
+
+// AllowPeers rejects deals from any peer not in the given list
+func AllowPeers(peers ...peer.ID) Filter {
+	allowed := peerSet(peers)
+	return func(ctx context.Context, state retrievalmarket.ProviderDealState) (shared.FilterDecision, string, error) {
+		if _, ok := allowed[state.Receiver]; !ok {
+			return shared.FilterReject, fmt.Sprintf("peer %s is not allowed to make deals", state.Receiver), nil
+		}
+		return shared.FilterAbstain, "", nil
+	}
+}
+
+// BlockPeers rejects deals from any peer in the given list
+func BlockPeers(peers ...peer.ID) Filter {
+	blocked := peerSet(peers)
+	return func(ctx context.Context, state retrievalmarket.ProviderDealState) (shared.FilterDecision, string, error) {
+		if _, ok := blocked[state.Receiver]; ok {
+			return shared.FilterReject, fmt.Sprintf("peer %s is blocked from making deals", state.Receiver), nil
+		}
+		return shared.FilterAbstain, "", nil
+	}
+}
+
+// ListDealsFunc lists the deals known to a provider
+type ListDealsFunc func() map[retrievalmarket.ProviderDealID]retrievalmarket.ProviderDealState
+
+// ClientDealQuota rejects deals from a peer that already has the given number
+// of retrievals in progress with the provider
+func ClientDealQuota(listDeals ListDealsFunc, maxDealsPerClient int) Filter {
+	return func(ctx context.Context, state retrievalmarket.ProviderDealState) (shared.FilterDecision, string, error) {
+		inProgress := 0
+		for id, deal := range listDeals() {
+			if id.From != state.Receiver || id.ID == state.ID {
+				continue
+			}
+			if !retrievalmarket.IsTerminalStatus(deal.Status) {
+				inProgress++
+			}
+		}
+		if inProgress >= maxDealsPerClient {
+			return shared.FilterReject, fmt.Sprintf("peer %s already has %d retrievals in progress", state.Receiver, inProgress), nil
+		}
+		return shared.FilterAbstain, "", nil
+	}
+}
+
+func peerSet(peers []peer.ID) map[peer.ID]struct{} {
+	set := make(map[peer.ID]struct{}, len(peers))
+	for _, p := range peers {
+		set[p] = struct{}{}
+	}
+	return set
+}
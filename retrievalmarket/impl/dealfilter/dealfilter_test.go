@@ -0,0 +1,90 @@
+package dealfilter_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket/impl/dealfilter"
+	"github.com/filecoin-project/go-fil-markets/shared"
+)
+
+func TestPipeline(t *testing.T) {
+	reject := func(context.Context, retrievalmarket.ProviderDealState) (shared.FilterDecision, string, error) {
+		return shared.FilterReject, "rejected", nil
+	}
+	accept := func(context.Context, retrievalmarket.ProviderDealState) (shared.FilterDecision, string, error) {
+		return shared.FilterAccept, "", nil
+	}
+
+	accepted, reason, err := dealfilter.Pipeline(accept, reject)(context.Background(), retrievalmarket.ProviderDealState{})
+	require.NoError(t, err)
+	require.True(t, accepted)
+	require.Empty(t, reason)
+
+	accepted, reason, err = dealfilter.Pipeline(reject, accept)(context.Background(), retrievalmarket.ProviderDealState{})
+	require.NoError(t, err)
+	require.False(t, accepted)
+	require.Equal(t, "rejected", reason)
+}
+
+func TestFilters(t *testing.T) {
+	client := peer.ID("client")
+	otherPeer := peer.ID("other")
+	state := retrievalmarket.ProviderDealState{
+		DealProposal: retrievalmarket.DealProposal{ID: retrievalmarket.DealID(1)},
+		Receiver:     client,
+	}
+
+	tests := map[string]struct {
+		filter   dealfilter.Filter
+		expected shared.FilterDecision
+	}{
+		"allowed peer": {
+			filter:   dealfilter.AllowPeers(client),
+			expected: shared.FilterAbstain,
+		},
+		"peer not allowed": {
+			filter:   dealfilter.AllowPeers(otherPeer),
+			expected: shared.FilterReject,
+		},
+		"blocked peer": {
+			filter:   dealfilter.BlockPeers(client),
+			expected: shared.FilterReject,
+		},
+		"peer not blocked": {
+			filter:   dealfilter.BlockPeers(otherPeer),
+			expected: shared.FilterAbstain,
+		},
+		"peer under quota": {
+			filter: dealfilter.ClientDealQuota(func() map[retrievalmarket.ProviderDealID]retrievalmarket.ProviderDealState {
+				return map[retrievalmarket.ProviderDealID]retrievalmarket.ProviderDealState{
+					{From: client, ID: 1}:    {Status: retrievalmarket.DealStatusNew},
+					{From: client, ID: 2}:    {Status: retrievalmarket.DealStatusCompleted},
+					{From: otherPeer, ID: 3}: {Status: retrievalmarket.DealStatusOngoing},
+				}
+			}, 1),
+			expected: shared.FilterAbstain,
+		},
+		"peer over quota": {
+			filter: dealfilter.ClientDealQuota(func() map[retrievalmarket.ProviderDealID]retrievalmarket.ProviderDealState {
+				return map[retrievalmarket.ProviderDealID]retrievalmarket.ProviderDealState{
+					{From: client, ID: 1}: {Status: retrievalmarket.DealStatusNew},
+					{From: client, ID: 2}: {Status: retrievalmarket.DealStatusOngoing},
+				}
+			}, 1),
+			expected: shared.FilterReject,
+		},
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			decision, reason, err := data.filter(context.Background(), state)
+			require.NoError(t, err)
+			require.Equal(t, data.expected, decision)
+			require.Equal(t, data.expected == shared.FilterReject, reason != "")
+		})
+	}
+}
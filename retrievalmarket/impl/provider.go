@@ -3,8 +3,10 @@ package retrievalimpl
 import (
 	"context"
 	"errors"
+	"io"
 	"reflect"
 	"sync"
+	"time"
 
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-statemachine/fsm"
@@ -16,17 +18,23 @@ import (
 	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
 	"golang.org/x/xerrors"
 
+	"github.com/filecoin-project/go-fil-markets/journal"
+	"github.com/filecoin-project/go-fil-markets/metrics"
 	"github.com/filecoin-project/go-fil-markets/pieceio/cario"
 	"github.com/filecoin-project/go-fil-markets/piecestore"
 	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
 	"github.com/filecoin-project/go-fil-markets/retrievalmarket/impl/blockio"
 	"github.com/filecoin-project/go-fil-markets/retrievalmarket/impl/blockunsealing"
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket/impl/dealfilter"
 	"github.com/filecoin-project/go-fil-markets/retrievalmarket/impl/providerstates"
 	rmnet "github.com/filecoin-project/go-fil-markets/retrievalmarket/network"
 	"github.com/filecoin-project/go-fil-markets/shared"
 )
 
 type RetrievalProviderOption func(p *Provider)
+
+// DealDecider decides whether to accept a deal. Returning a *retrievalmarket.DealRejectedError
+// rejects the deal with the error's reason
 type DealDecider func(ctx context.Context, state retrievalmarket.ProviderDealState) (bool, string, error)
 
 type Provider struct {
@@ -44,6 +52,8 @@ type Provider struct {
 	blockReaders            map[retrievalmarket.ProviderDealIdentifier]blockio.BlockReader
 	stateMachines           fsm.Group
 	dealDecider             DealDecider
+	journal                 *journal.Journal
+	dealMetrics             *metrics.DealTracker
 }
 
 var _ retrievalmarket.RetrievalProvider = new(Provider)
@@ -78,12 +88,13 @@ func NewProvider(minerAddress address.Address, node retrievalmarket.RetrievalPro
 		paymentIntervalIncrease: DefaultPaymentIntervalIncrease,
 		dealStreams:             make(map[retrievalmarket.ProviderDealIdentifier]rmnet.RetrievalDealStream),
 		blockReaders:            make(map[retrievalmarket.ProviderDealIdentifier]blockio.BlockReader),
+		dealMetrics:             metrics.NewDealTracker(shared.RetrievalProvider),
 	}
 	statemachines, err := fsm.New(ds, fsm.Parameters{
 		Environment:     p,
 		StateType:       retrievalmarket.ProviderDealState{},
 		StateKeyField:   "Status",
-		Events:          providerstates.ProviderEvents,
+		Events:          providerstates.NewProviderEvents(journal.RecordArgs(p.recordEventArgs)),
 		StateEntryFuncs: providerstates.ProviderStateEntryFuncs,
 		Notifier:        p.notifySubscribers,
 	})
@@ -109,6 +120,14 @@ func (p *Provider) Stop() error {
 
 // Start begins listening for deals on the given host
 func (p *Provider) Start() error {
+	var deals []retrievalmarket.ProviderDealState
+	if err := p.stateMachines.List(&deals); err != nil {
+		return xerrors.Errorf("listing deals: %w", err)
+	}
+	for _, deal := range deals {
+		p.dealMetrics.Restore(deal.Identifier().String(), retrievalmarket.DealStatuses[deal.Status], retrievalmarket.IsTerminalStatus(deal.Status))
+	}
+
 	return p.network.SetDelegate(p)
 }
 
@@ -143,6 +162,13 @@ func (p *Provider) unsubscribeAt(sub retrievalmarket.ProviderSubscriber) retriev
 	}
 }
 
+// recordEventArgs keeps the arguments of an event for the deal journal, if there is one
+func (p *Provider) recordEventArgs(event fsm.EventName, state interface{}, args []interface{}) {
+	if p.journal != nil {
+		p.journal.AddArgs(shared.RetrievalProvider, state.(*retrievalmarket.ProviderDealState).Identifier().String(), event, args)
+	}
+}
+
 func (p *Provider) notifySubscribers(eventName fsm.EventName, state fsm.StateType) {
 	p.subscribersLk.RLock()
 	defer p.subscribersLk.RUnlock()
@@ -151,6 +177,23 @@ func (p *Provider) notifySubscribers(eventName fsm.EventName, state fsm.StateTyp
 	for _, cb := range p.subscribers {
 		cb(evt, ds)
 	}
+
+	p.dealMetrics.TotalTransferred(ds.Identifier().String(), ds.TotalSent)
+	p.dealMetrics.Observe(ds.Identifier().String(), retrievalmarket.ProviderEvents[evt], retrievalmarket.DealStatuses[ds.Status], retrievalmarket.IsTerminalStatus(ds.Status))
+
+	if p.journal != nil {
+		err := p.journal.Record(journal.Entry{
+			Market:  shared.RetrievalProvider,
+			Deal:    ds.Identifier().String(),
+			Event:   retrievalmarket.ProviderEvents[evt],
+			Args:    p.journal.TakeArgs(shared.RetrievalProvider, ds.Identifier().String(), eventName),
+			State:   retrievalmarket.DealStatuses[ds.Status],
+			Message: ds.Message,
+		})
+		if err != nil {
+			log.Errorf("failed to journal event %d: %s", evt, err)
+		}
+	}
 }
 
 // SubscribeToEvents listens for events that happen related to client retrievals
@@ -167,8 +210,18 @@ func (p *Provider) SetPricePerUnseal(price abi.TokenAmount) {
 	panic("not implemented")
 }
 
+// ListDeals lists all known retrieval deals
 func (p *Provider) ListDeals() map[retrievalmarket.ProviderDealID]retrievalmarket.ProviderDealState {
-	panic("not implemented")
+	var deals []retrievalmarket.ProviderDealState
+	if err := p.stateMachines.List(&deals); err != nil {
+		log.Errorf("listing deals: %s", err)
+		return nil
+	}
+	dealMap := make(map[retrievalmarket.ProviderDealID]retrievalmarket.ProviderDealState, len(deals))
+	for _, deal := range deals {
+		dealMap[retrievalmarket.ProviderDealID{From: deal.Receiver, ID: deal.ID}] = deal
+	}
+	return dealMap
 }
 
 func (p *Provider) HandleQueryStream(stream rmnet.RetrievalQueryStream) {
@@ -250,7 +303,7 @@ func (p *Provider) newProviderDeal(stream rmnet.RetrievalDealStream) error {
 
 	p.dealStreams[pds.Identifier()] = stream
 
-	loaderWithUnsealing := blockunsealing.NewLoaderWithUnsealing(context.TODO(), p.bs, p.pieceStore, cario.NewCarIO(), p.node.UnsealSector, dealProposal.PieceCID)
+	loaderWithUnsealing := blockunsealing.NewLoaderWithUnsealing(context.TODO(), p.bs, p.pieceStore, cario.NewCarIO(), p.unsealSector, dealProposal.PieceCID)
 
 	// validate the selector, if provided
 	var sel ipld.Node
@@ -290,13 +343,13 @@ func (p *Provider) DealStream(id retrievalmarket.ProviderDealIdentifier) rmnet.R
 
 func (p *Provider) CheckDealParams(pricePerByte abi.TokenAmount, paymentInterval uint64, paymentIntervalIncrease uint64) error {
 	if pricePerByte.LessThan(p.pricePerByte) {
-		return errors.New("Price per byte too low")
+		return &retrievalmarket.DealRejectedError{Reason: retrievalmarket.RejectionPriceTooLow, Details: "Price per byte too low"}
 	}
 	if paymentInterval > p.paymentInterval {
-		return errors.New("Payment interval too large")
+		return &retrievalmarket.DealRejectedError{Reason: retrievalmarket.RejectionPaymentIntervalTooLarge, Details: "Payment interval too large"}
 	}
 	if paymentIntervalIncrease > p.paymentIntervalIncrease {
-		return errors.New("Payment interval increase too large")
+		return &retrievalmarket.DealRejectedError{Reason: retrievalmarket.RejectionPaymentIntervalIncreaseTooLarge, Details: "Payment interval increase too large"}
 	}
 	return nil
 }
@@ -320,6 +373,16 @@ func (p *Provider) GetPieceSize(c cid.Cid) (uint64, error) {
 	return pieceInfo.Deals[0].Length, nil
 }
 
+// unsealSector unseals a sector through the node, recording how long it took
+func (p *Provider) unsealSector(ctx context.Context, sectorID uint64, offset uint64, length uint64) (io.ReadCloser, error) {
+	start := time.Now()
+	reader, err := p.node.UnsealSector(ctx, sectorID, offset, length)
+	if err == nil {
+		p.dealMetrics.UnsealDuration(time.Since(start))
+	}
+	return reader, err
+}
+
 func (p *Provider) Configure(opts ...RetrievalProviderOption) {
 	for _, opt := range opts {
 		opt(p)
@@ -332,6 +395,25 @@ func DealDeciderOpt(dd DealDecider) RetrievalProviderOption {
 	}
 }
 
+// DealFilters runs the given filters, in order, to decide whether to accept a deal
+func DealFilters(filters ...dealfilter.Filter) RetrievalProviderOption {
+	return DealDeciderOpt(dealfilter.Pipeline(filters...))
+}
+
+// DealJournal records every event that happens to the provider's deals in the given journal
+func DealJournal(j *journal.Journal) RetrievalProviderOption {
+	return func(provider *Provider) {
+		provider.journal = j
+	}
+}
+
+// MetricsRecorder sends measurements of the provider's deals to the given recorder
+func MetricsRecorder(recorder metrics.Recorder) RetrievalProviderOption {
+	return func(provider *Provider) {
+		provider.dealMetrics.SetRecorder(recorder)
+	}
+}
+
 func getPieceInfoFromCid(pieceStore piecestore.PieceStore, payloadCID, pieceCID cid.Cid) (piecestore.PieceInfo, error) {
 	cidInfo, err := pieceStore.GetCIDInfo(payloadCID)
 	if err != nil {
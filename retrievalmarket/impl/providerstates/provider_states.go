@@ -43,7 +43,12 @@ func ReceiveDeal(ctx fsm.Context, environment ProviderDealEnvironment, deal rm.P
 		dealProposal.PaymentInterval,
 		dealProposal.PaymentIntervalIncrease)
 	if err != nil {
-		return ctx.Trigger(rm.ProviderEventDealRejected, err)
+		reason := rm.RejectionUnspecified
+		var rejected *rm.DealRejectedError
+		if xerrors.As(err, &rejected) {
+			reason = rejected.Reason
+		}
+		return ctx.Trigger(rm.ProviderEventDealRejected, reason, err)
 	}
 	return ctx.Trigger(rm.ProviderEventDealReceived)
 }
@@ -53,10 +58,14 @@ func ReceiveDeal(ctx fsm.Context, environment ProviderDealEnvironment, deal rm.P
 func DecideOnDeal(ctx fsm.Context, env ProviderDealEnvironment, state rm.ProviderDealState) error {
 	accepted, reason, err := env.RunDealDecisioningLogic(ctx.Context(), state)
 	if err != nil {
+		var rejected *rm.DealRejectedError
+		if xerrors.As(err, &rejected) {
+			return ctx.Trigger(rm.ProviderEventDealRejected, rejected.Reason, err)
+		}
 		return ctx.Trigger(rm.ProviderEventDecisioningError, err)
 	}
 	if !accepted {
-		return ctx.Trigger(rm.ProviderEventDealRejected, errors.New(reason))
+		return ctx.Trigger(rm.ProviderEventDealRejected, rm.RejectionDeclined, errors.New(reason))
 	}
 	err = env.DealStream(state.Identifier()).WriteDealResponse(rm.DealResponse{
 		Status: rm.DealStatusAccepted,
@@ -163,6 +172,7 @@ func SendFailResponse(ctx fsm.Context, environment ProviderDealEnvironment, deal
 		Status:  deal.Status,
 		Message: deal.Message,
 		ID:      deal.ID,
+		Reason:  deal.RejectionReason,
 	})
 	if err != nil {
 		return ctx.Trigger(rm.ProviderEventWriteResponseFailed, err)
@@ -117,9 +117,34 @@ func TestReceiveDeal(t *testing.T) {
 		}
 		runReceiveDeal(t, node, dealStreamParams, setupEnv, dealState)
 		require.Equal(t, dealState.Status, retrievalmarket.DealStatusRejected)
+		require.Equal(t, retrievalmarket.RejectionUnspecified, dealState.RejectionReason)
 		require.NotEmpty(t, dealState.Message)
 	})
 
+	t.Run("deal rejected with reason", func(t *testing.T) {
+		node := testnodes.NewTestRetrievalProviderNode()
+		dealState := blankDealState()
+		message := "Price per byte too low"
+		dealStreamParams := testnet.TestDealStreamParams{
+			ProposalReader: testnet.StubbedDealProposalReader(proposal),
+			ResponseWriter: testnet.ExpectDealResponseWriter(t, retrievalmarket.DealResponse{
+				Status:  retrievalmarket.DealStatusRejected,
+				ID:      proposal.ID,
+				Message: message,
+				Reason:  retrievalmarket.RejectionPriceTooLow,
+			}),
+		}
+		setupEnv := func(fe *rmtesting.TestProviderDealEnvironment) {
+			fe.ExpectPiece(expectedPiece, 10000)
+			fe.ExpectParams(defaultPricePerByte, defaultCurrentInterval, defaultIntervalIncrease,
+				&retrievalmarket.DealRejectedError{Reason: retrievalmarket.RejectionPriceTooLow, Details: message})
+		}
+		runReceiveDeal(t, node, dealStreamParams, setupEnv, dealState)
+		require.Equal(t, dealState.Status, retrievalmarket.DealStatusRejected)
+		require.Equal(t, retrievalmarket.RejectionPriceTooLow, dealState.RejectionReason)
+		require.Equal(t, message, dealState.Message)
+	})
+
 }
 
 func TestSendBlocks(t *testing.T) {
@@ -407,9 +432,24 @@ func TestDecideOnDeal(t *testing.T) {
 			},
 			verify: func(t *testing.T, state *rm.ProviderDealState) {
 				assert.Equal(t, retrievalmarket.DealStatusRejected, state.Status)
+				assert.Equal(t, retrievalmarket.RejectionDeclined, state.RejectionReason)
 				assert.Equal(t, "Thursday, I don't care about you", state.Message)
 			},
 		},
+		"if decider rejects with a reason, deal is rejected with that reason": {
+			dsParams: acceptedDsParams,
+			decider: func(ctx context.Context, state rm.ProviderDealState) (bool, string, error) {
+				return false, "", &rm.DealRejectedError{Reason: rm.RejectionPriceTooLow, Details: "too cheap"}
+			},
+			setupEnv: func(te *rmtesting.TestProviderDealEnvironment) {
+				te.ExpectDeciderCalledWith(proposal.ID)
+			},
+			verify: func(t *testing.T, state *rm.ProviderDealState) {
+				assert.Equal(t, retrievalmarket.DealStatusRejected, state.Status)
+				assert.Equal(t, retrievalmarket.RejectionPriceTooLow, state.RejectionReason)
+				assert.Equal(t, "too cheap", state.Message)
+			},
+		},
 		"if response write error, deal errors": {
 			dsParams: testnet.TestDealStreamParams{
 				ProposalReader: testnet.StubbedDealProposalReader(proposal),
@@ -0,0 +1,59 @@
+package retrievalmarket
+
+// RejectionReason is a machine readable reason a provider rejected a retrieval deal
+type RejectionReason uint64
+
+const (
+	// RejectionNone means the deal was not rejected
+	RejectionNone RejectionReason = iota
+
+	// RejectionUnspecified means the provider rejected the deal without saying why
+	RejectionUnspecified
+
+	// RejectionPriceTooLow means the price per byte is below the provider's price
+	RejectionPriceTooLow
+
+	// RejectionPaymentIntervalTooLarge means the payment interval is larger than the provider allows
+	RejectionPaymentIntervalTooLarge
+
+	// RejectionPaymentIntervalIncreaseTooLarge means the payment interval increase is larger
+	// than the provider allows
+	RejectionPaymentIntervalIncreaseTooLarge
+
+	// RejectionDeclined means the provider's deal decision logic declined the deal
+	RejectionDeclined
+)
+
+// RejectionReasons maps rejection reason codes to string names
+var RejectionReasons = map[RejectionReason]string{
+	RejectionNone:                            "RejectionNone",
+	RejectionUnspecified:                     "RejectionUnspecified",
+	RejectionPriceTooLow:                     "RejectionPriceTooLow",
+	RejectionPaymentIntervalTooLarge:         "RejectionPaymentIntervalTooLarge",
+	RejectionPaymentIntervalIncreaseTooLarge: "RejectionPaymentIntervalIncreaseTooLarge",
+	RejectionDeclined:                        "RejectionDeclined",
+}
+
+// DealRejectedError is the error for a retrieval deal the provider rejected. Match on it
+// with errors.As to find out why the deal was rejected
+type DealRejectedError struct {
+	Reason RejectionReason
+
+	// Details is the provider's explanation of the rejection
+	Details string
+}
+
+func (e *DealRejectedError) Error() string {
+	if e.Details == "" {
+		return RejectionReasons[e.Reason]
+	}
+	return e.Details
+}
+
+// RejectionError returns a *DealRejectedError if the provider rejected the deal, and nil otherwise
+func (deal ClientDealState) RejectionError() error {
+	if deal.RejectionReason == RejectionNone {
+		return nil
+	}
+	return &DealRejectedError{Reason: deal.RejectionReason, Details: deal.Message}
+}
@@ -20,7 +20,7 @@ import (
 	"github.com/filecoin-project/go-fil-markets/shared"
 )
 
-//go:generate cbor-gen-for Query QueryResponse DealProposal DealResponse Params QueryParams DealPayment Block ClientDealState ProviderDealState PaymentInfo
+//go:generate cbor-gen-for Query QueryResponse DealProposal Params QueryParams DealPayment Block ClientDealStateTuple ProviderDealStateTuple PaymentInfo DealResponseTuple
 
 // ProtocolID is the protocol for proposing / responding to retrieval deals
 const ProtocolID = "/fil/retrieval/0.0.1"
@@ -56,6 +56,24 @@ type ClientDealState struct {
 	PaymentRequested abi.TokenAmount
 	FundsSpent       abi.TokenAmount
 	WaitMsgCID       *cid.Cid // the CID of any message the client deal is waiting for
+	RejectionReason  RejectionReason
+}
+
+// clientDealStateLengths are the field counts of deals stored before rejection reasons,
+// so those deals can still be read
+var clientDealStateLengths = shared.TupleLengths{14}
+
+// ClientDealStateTuple is the encoding of a ClientDealState with every field
+type ClientDealStateTuple ClientDealState
+
+// MarshalCBOR encodes a deal without a rejection reason in the original fourteen fields
+func (t *ClientDealState) MarshalCBOR(w io.Writer) error {
+	return clientDealStateLengths.Marshal(w, (*ClientDealStateTuple)(t))
+}
+
+// UnmarshalCBOR decodes a deal with or without a rejection reason
+func (t *ClientDealState) UnmarshalCBOR(r io.Reader) error {
+	return clientDealStateLengths.Unmarshal(r, (*ClientDealStateTuple)(t))
 }
 
 // ClientEvent is an event that occurs in a deal lifecycle on the client
@@ -152,6 +170,37 @@ const (
 	ClientEventComplete
 )
 
+// ClientEvents maps client event codes to string names
+var ClientEvents = map[ClientEvent]string{
+	ClientEventOpen:                          "ClientEventOpen",
+	ClientEventPaymentChannelErrored:         "ClientEventPaymentChannelErrored",
+	ClientEventAllocateLaneErrored:           "ClientEventAllocateLaneErrored",
+	ClientEventPaymentChannelCreateInitiated: "ClientEventPaymentChannelCreateInitiated",
+	ClientEventPaymentChannelReady:           "ClientEventPaymentChannelReady",
+	ClientEventPaymentChannelAddingFunds:     "ClientEventPaymentChannelAddingFunds",
+	ClientEventPaymentChannelAddFundsErrored: "ClientEventPaymentChannelAddFundsErrored",
+	ClientEventWriteDealProposalErrored:      "ClientEventWriteDealProposalErrored",
+	ClientEventReadDealResponseErrored:       "ClientEventReadDealResponseErrored",
+	ClientEventDealRejected:                  "ClientEventDealRejected",
+	ClientEventDealNotFound:                  "ClientEventDealNotFound",
+	ClientEventDealAccepted:                  "ClientEventDealAccepted",
+	ClientEventUnknownResponseReceived:       "ClientEventUnknownResponseReceived",
+	ClientEventFundsExpended:                 "ClientEventFundsExpended",
+	ClientEventBadPaymentRequested:           "ClientEventBadPaymentRequested",
+	ClientEventCreateVoucherFailed:           "ClientEventCreateVoucherFailed",
+	ClientEventWriteDealPaymentErrored:       "ClientEventWriteDealPaymentErrored",
+	ClientEventPaymentSent:                   "ClientEventPaymentSent",
+	ClientEventConsumeBlockFailed:            "ClientEventConsumeBlockFailed",
+	ClientEventLastPaymentRequested:          "ClientEventLastPaymentRequested",
+	ClientEventAllBlocksReceived:             "ClientEventAllBlocksReceived",
+	ClientEventEarlyTermination:              "ClientEventEarlyTermination",
+	ClientEventPaymentRequested:              "ClientEventPaymentRequested",
+	ClientEventBlocksReceived:                "ClientEventBlocksReceived",
+	ClientEventProgress:                      "ClientEventProgress",
+	ClientEventError:                         "ClientEventError",
+	ClientEventComplete:                      "ClientEventComplete",
+}
+
 // ClientSubscriber is a callback that is registered to listen for retrieval events
 type ClientSubscriber func(event ClientEvent, state ClientDealState)
 
@@ -230,6 +279,24 @@ type ProviderDealState struct {
 	FundsReceived   abi.TokenAmount
 	Message         string
 	CurrentInterval uint64
+	RejectionReason RejectionReason
+}
+
+// providerDealStateLengths are the field counts of deals stored before rejection reasons,
+// so those deals can still be read
+var providerDealStateLengths = shared.TupleLengths{7}
+
+// ProviderDealStateTuple is the encoding of a ProviderDealState with every field
+type ProviderDealStateTuple ProviderDealState
+
+// MarshalCBOR encodes a deal without a rejection reason in the original seven fields
+func (t *ProviderDealState) MarshalCBOR(w io.Writer) error {
+	return providerDealStateLengths.Marshal(w, (*ProviderDealStateTuple)(t))
+}
+
+// UnmarshalCBOR decodes a deal with or without a rejection reason
+func (t *ProviderDealState) UnmarshalCBOR(r io.Reader) error {
+	return providerDealStateLengths.Unmarshal(r, (*ProviderDealStateTuple)(t))
 }
 
 // Identifier provides a unique id for this provider deal
@@ -311,6 +378,26 @@ const (
 	ProviderEventComplete
 )
 
+// ProviderEvents maps provider event codes to string names
+var ProviderEvents = map[ProviderEvent]string{
+	ProviderEventOpen:                   "ProviderEventOpen",
+	ProviderEventDealReceived:           "ProviderEventDealReceived",
+	ProviderEventDecisioningError:       "ProviderEventDecisioningError",
+	ProviderEventWriteResponseFailed:    "ProviderEventWriteResponseFailed",
+	ProviderEventReadPaymentFailed:      "ProviderEventReadPaymentFailed",
+	ProviderEventGetPieceSizeErrored:    "ProviderEventGetPieceSizeErrored",
+	ProviderEventDealNotFound:           "ProviderEventDealNotFound",
+	ProviderEventDealRejected:           "ProviderEventDealRejected",
+	ProviderEventDealAccepted:           "ProviderEventDealAccepted",
+	ProviderEventBlockErrored:           "ProviderEventBlockErrored",
+	ProviderEventBlocksCompleted:        "ProviderEventBlocksCompleted",
+	ProviderEventPaymentRequested:       "ProviderEventPaymentRequested",
+	ProviderEventSaveVoucherFailed:      "ProviderEventSaveVoucherFailed",
+	ProviderEventPartialPaymentReceived: "ProviderEventPartialPaymentReceived",
+	ProviderEventPaymentReceived:        "ProviderEventPaymentReceived",
+	ProviderEventComplete:               "ProviderEventComplete",
+}
+
 // ProviderDealID is a unique identifier for a deal on a provider -- it is
 // a combination of DealID set by the client and the peer ID of the client
 type ProviderDealID struct {
@@ -640,11 +727,30 @@ type DealResponse struct {
 
 	Message string
 	Blocks  []Block // V0 only
+
+	// Reason is why the deal was rejected, if it was. Message holds the details
+	Reason RejectionReason
 }
 
 // DealResponseUndefined is an undefined deal response
 var DealResponseUndefined = DealResponse{}
 
+// dealResponseLengths are the field counts of deal responses from before rejection reasons
+var dealResponseLengths = shared.TupleLengths{5}
+
+// DealResponseTuple is the encoding of a DealResponse with every field
+type DealResponseTuple DealResponse
+
+// MarshalCBOR encodes a deal response without a rejection reason in the original five fields
+func (t *DealResponse) MarshalCBOR(w io.Writer) error {
+	return dealResponseLengths.Marshal(w, (*DealResponseTuple)(t))
+}
+
+// UnmarshalCBOR decodes a deal response with or without a rejection reason
+func (t *DealResponse) UnmarshalCBOR(r io.Reader) error {
+	return dealResponseLengths.Unmarshal(r, (*DealResponseTuple)(t))
+}
+
 // DealPayment is a payment for an in progress retrieval deal
 type DealPayment struct {
 	ID             DealID
@@ -335,154 +335,6 @@ func (t *DealProposal) UnmarshalCBOR(r io.Reader) error {
 	return nil
 }
 
-func (t *DealResponse) MarshalCBOR(w io.Writer) error {
-	if t == nil {
-		_, err := w.Write(cbg.CborNull)
-		return err
-	}
-	if _, err := w.Write([]byte{133}); err != nil {
-		return err
-	}
-
-	// t.Status (retrievalmarket.DealStatus) (uint64)
-
-	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.Status))); err != nil {
-		return err
-	}
-
-	// t.ID (retrievalmarket.DealID) (uint64)
-
-	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.ID))); err != nil {
-		return err
-	}
-
-	// t.PaymentOwed (big.Int) (struct)
-	if err := t.PaymentOwed.MarshalCBOR(w); err != nil {
-		return err
-	}
-
-	// t.Message (string) (string)
-	if len(t.Message) > cbg.MaxLength {
-		return xerrors.Errorf("Value in field t.Message was too long")
-	}
-
-	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len(t.Message)))); err != nil {
-		return err
-	}
-	if _, err := w.Write([]byte(t.Message)); err != nil {
-		return err
-	}
-
-	// t.Blocks ([]retrievalmarket.Block) (slice)
-	if len(t.Blocks) > cbg.MaxLength {
-		return xerrors.Errorf("Slice value in field t.Blocks was too long")
-	}
-
-	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajArray, uint64(len(t.Blocks)))); err != nil {
-		return err
-	}
-	for _, v := range t.Blocks {
-		if err := v.MarshalCBOR(w); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-func (t *DealResponse) UnmarshalCBOR(r io.Reader) error {
-	br := cbg.GetPeeker(r)
-
-	maj, extra, err := cbg.CborReadHeader(br)
-	if err != nil {
-		return err
-	}
-	if maj != cbg.MajArray {
-		return fmt.Errorf("cbor input should be of type array")
-	}
-
-	if extra != 5 {
-		return fmt.Errorf("cbor input had wrong number of fields")
-	}
-
-	// t.Status (retrievalmarket.DealStatus) (uint64)
-
-	{
-
-		maj, extra, err = cbg.CborReadHeader(br)
-		if err != nil {
-			return err
-		}
-		if maj != cbg.MajUnsignedInt {
-			return fmt.Errorf("wrong type for uint64 field")
-		}
-		t.Status = DealStatus(extra)
-
-	}
-	// t.ID (retrievalmarket.DealID) (uint64)
-
-	{
-
-		maj, extra, err = cbg.CborReadHeader(br)
-		if err != nil {
-			return err
-		}
-		if maj != cbg.MajUnsignedInt {
-			return fmt.Errorf("wrong type for uint64 field")
-		}
-		t.ID = DealID(extra)
-
-	}
-	// t.PaymentOwed (big.Int) (struct)
-
-	{
-
-		if err := t.PaymentOwed.UnmarshalCBOR(br); err != nil {
-			return xerrors.Errorf("unmarshaling t.PaymentOwed: %w", err)
-		}
-
-	}
-	// t.Message (string) (string)
-
-	{
-		sval, err := cbg.ReadString(br)
-		if err != nil {
-			return err
-		}
-
-		t.Message = string(sval)
-	}
-	// t.Blocks ([]retrievalmarket.Block) (slice)
-
-	maj, extra, err = cbg.CborReadHeader(br)
-	if err != nil {
-		return err
-	}
-
-	if extra > cbg.MaxLength {
-		return fmt.Errorf("t.Blocks: array too large (%d)", extra)
-	}
-
-	if maj != cbg.MajArray {
-		return fmt.Errorf("expected cbor array")
-	}
-
-	if extra > 0 {
-		t.Blocks = make([]Block, extra)
-	}
-
-	for i := 0; i < int(extra); i++ {
-
-		var v Block
-		if err := v.UnmarshalCBOR(br); err != nil {
-			return err
-		}
-
-		t.Blocks[i] = v
-	}
-
-	return nil
-}
-
 func (t *Params) MarshalCBOR(w io.Writer) error {
 	if t == nil {
 		_, err := w.Write(cbg.CborNull)
@@ -871,12 +723,12 @@ func (t *Block) UnmarshalCBOR(r io.Reader) error {
 	return nil
 }
 
-func (t *ClientDealState) MarshalCBOR(w io.Writer) error {
+func (t *ClientDealStateTuple) MarshalCBOR(w io.Writer) error {
 	if t == nil {
 		_, err := w.Write(cbg.CborNull)
 		return err
 	}
-	if _, err := w.Write([]byte{142}); err != nil {
+	if _, err := w.Write([]byte{143}); err != nil {
 		return err
 	}
 
@@ -975,10 +827,16 @@ func (t *ClientDealState) MarshalCBOR(w io.Writer) error {
 		}
 	}
 
+	// t.RejectionReason (retrievalmarket.RejectionReason) (uint64)
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.RejectionReason))); err != nil {
+		return err
+	}
+
 	return nil
 }
 
-func (t *ClientDealState) UnmarshalCBOR(r io.Reader) error {
+func (t *ClientDealStateTuple) UnmarshalCBOR(r io.Reader) error {
 	br := cbg.GetPeeker(r)
 
 	maj, extra, err := cbg.CborReadHeader(br)
@@ -989,7 +847,7 @@ func (t *ClientDealState) UnmarshalCBOR(r io.Reader) error {
 		return fmt.Errorf("cbor input should be of type array")
 	}
 
-	if extra != 14 {
+	if extra != 15 {
 		return fmt.Errorf("cbor input had wrong number of fields")
 	}
 
@@ -1167,16 +1025,30 @@ func (t *ClientDealState) UnmarshalCBOR(r io.Reader) error {
 			t.WaitMsgCID = &c
 		}
 
+	}
+	// t.RejectionReason (retrievalmarket.RejectionReason) (uint64)
+
+	{
+
+		maj, extra, err = cbg.CborReadHeader(br)
+		if err != nil {
+			return err
+		}
+		if maj != cbg.MajUnsignedInt {
+			return fmt.Errorf("wrong type for uint64 field")
+		}
+		t.RejectionReason = RejectionReason(extra)
+
 	}
 	return nil
 }
 
-func (t *ProviderDealState) MarshalCBOR(w io.Writer) error {
+func (t *ProviderDealStateTuple) MarshalCBOR(w io.Writer) error {
 	if t == nil {
 		_, err := w.Write(cbg.CborNull)
 		return err
 	}
-	if _, err := w.Write([]byte{135}); err != nil {
+	if _, err := w.Write([]byte{136}); err != nil {
 		return err
 	}
 
@@ -1232,10 +1104,16 @@ func (t *ProviderDealState) MarshalCBOR(w io.Writer) error {
 		return err
 	}
 
+	// t.RejectionReason (retrievalmarket.RejectionReason) (uint64)
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.RejectionReason))); err != nil {
+		return err
+	}
+
 	return nil
 }
 
-func (t *ProviderDealState) UnmarshalCBOR(r io.Reader) error {
+func (t *ProviderDealStateTuple) UnmarshalCBOR(r io.Reader) error {
 	br := cbg.GetPeeker(r)
 
 	maj, extra, err := cbg.CborReadHeader(br)
@@ -1246,7 +1124,7 @@ func (t *ProviderDealState) UnmarshalCBOR(r io.Reader) error {
 		return fmt.Errorf("cbor input should be of type array")
 	}
 
-	if extra != 7 {
+	if extra != 8 {
 		return fmt.Errorf("cbor input had wrong number of fields")
 	}
 
@@ -1329,6 +1207,20 @@ func (t *ProviderDealState) UnmarshalCBOR(r io.Reader) error {
 		}
 		t.CurrentInterval = uint64(extra)
 
+	}
+	// t.RejectionReason (retrievalmarket.RejectionReason) (uint64)
+
+	{
+
+		maj, extra, err = cbg.CborReadHeader(br)
+		if err != nil {
+			return err
+		}
+		if maj != cbg.MajUnsignedInt {
+			return fmt.Errorf("wrong type for uint64 field")
+		}
+		t.RejectionReason = RejectionReason(extra)
+
 	}
 	return nil
 }
@@ -1396,3 +1288,171 @@ func (t *PaymentInfo) UnmarshalCBOR(r io.Reader) error {
 	}
 	return nil
 }
+
+func (t *DealResponseTuple) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write([]byte{134}); err != nil {
+		return err
+	}
+
+	// t.Status (retrievalmarket.DealStatus) (uint64)
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.Status))); err != nil {
+		return err
+	}
+
+	// t.ID (retrievalmarket.DealID) (uint64)
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.ID))); err != nil {
+		return err
+	}
+
+	// t.PaymentOwed (big.Int) (struct)
+	if err := t.PaymentOwed.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.Message (string) (string)
+	if len(t.Message) > cbg.MaxLength {
+		return xerrors.Errorf("Value in field t.Message was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len(t.Message)))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(t.Message)); err != nil {
+		return err
+	}
+
+	// t.Blocks ([]retrievalmarket.Block) (slice)
+	if len(t.Blocks) > cbg.MaxLength {
+		return xerrors.Errorf("Slice value in field t.Blocks was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajArray, uint64(len(t.Blocks)))); err != nil {
+		return err
+	}
+	for _, v := range t.Blocks {
+		if err := v.MarshalCBOR(w); err != nil {
+			return err
+		}
+	}
+
+	// t.Reason (retrievalmarket.RejectionReason) (uint64)
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.Reason))); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *DealResponseTuple) UnmarshalCBOR(r io.Reader) error {
+	br := cbg.GetPeeker(r)
+
+	maj, extra, err := cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 6 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.Status (retrievalmarket.DealStatus) (uint64)
+
+	{
+
+		maj, extra, err = cbg.CborReadHeader(br)
+		if err != nil {
+			return err
+		}
+		if maj != cbg.MajUnsignedInt {
+			return fmt.Errorf("wrong type for uint64 field")
+		}
+		t.Status = DealStatus(extra)
+
+	}
+	// t.ID (retrievalmarket.DealID) (uint64)
+
+	{
+
+		maj, extra, err = cbg.CborReadHeader(br)
+		if err != nil {
+			return err
+		}
+		if maj != cbg.MajUnsignedInt {
+			return fmt.Errorf("wrong type for uint64 field")
+		}
+		t.ID = DealID(extra)
+
+	}
+	// t.PaymentOwed (big.Int) (struct)
+
+	{
+
+		if err := t.PaymentOwed.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.PaymentOwed: %w", err)
+		}
+
+	}
+	// t.Message (string) (string)
+
+	{
+		sval, err := cbg.ReadString(br)
+		if err != nil {
+			return err
+		}
+
+		t.Message = string(sval)
+	}
+	// t.Blocks ([]retrievalmarket.Block) (slice)
+
+	maj, extra, err = cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+
+	if extra > cbg.MaxLength {
+		return fmt.Errorf("t.Blocks: array too large (%d)", extra)
+	}
+
+	if maj != cbg.MajArray {
+		return fmt.Errorf("expected cbor array")
+	}
+
+	if extra > 0 {
+		t.Blocks = make([]Block, extra)
+	}
+
+	for i := 0; i < int(extra); i++ {
+
+		var v Block
+		if err := v.UnmarshalCBOR(br); err != nil {
+			return err
+		}
+
+		t.Blocks[i] = v
+	}
+
+	// t.Reason (retrievalmarket.RejectionReason) (uint64)
+
+	{
+
+		maj, extra, err = cbg.CborReadHeader(br)
+		if err != nil {
+			return err
+		}
+		if maj != cbg.MajUnsignedInt {
+			return fmt.Errorf("wrong type for uint64 field")
+		}
+		t.Reason = RejectionReason(extra)
+
+	}
+	return nil
+}
@@ -5,9 +5,13 @@ import (
 	"testing"
 
 	"github.com/filecoin-project/specs-actors/actors/abi"
+	specst "github.com/filecoin-project/specs-actors/support/testing"
 	"github.com/ipld/go-ipld-prime/codec/dagcbor"
 	basicnode "github.com/ipld/go-ipld-prime/node/basic"
+	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	cbg "github.com/whyrusleeping/cbor-gen"
 
 	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
 	"github.com/filecoin-project/go-fil-markets/shared"
@@ -36,3 +40,110 @@ func TestParamsMarshalUnmarshal(t *testing.T) {
 	sel := nb.Build()
 	assert.Equal(t, sel, allSelector)
 }
+
+func TestClientDealStateCBOR(t *testing.T) {
+	baseDeal := retrievalmarket.ClientDealState{
+		DealProposal:     tut.MakeTestDealProposal(),
+		TotalFunds:       abi.NewTokenAmount(1000),
+		ClientWallet:     specst.NewIDAddr(t, 101),
+		MinerWallet:      specst.NewIDAddr(t, 102),
+		Status:           retrievalmarket.DealStatusOngoing,
+		Sender:           peer.ID("sender"),
+		TotalReceived:    100,
+		Message:          "ongoing",
+		PaymentRequested: abi.NewTokenAmount(10),
+		FundsSpent:       abi.NewTokenAmount(20),
+	}
+
+	t.Run("deal without rejection reason uses original encoding", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		require.NoError(t, baseDeal.MarshalCBOR(buf))
+		require.Equal(t, byte(0x8e), buf.Bytes()[0])
+
+		var out retrievalmarket.ClientDealState
+		require.NoError(t, out.UnmarshalCBOR(buf))
+		require.Equal(t, baseDeal, out)
+	})
+
+	t.Run("deal stored with original encoding decodes", func(t *testing.T) {
+		deal := baseDeal
+		deal.RejectionReason = retrievalmarket.RejectionReason(1)
+		buf := new(bytes.Buffer)
+		require.NoError(t, deal.MarshalCBOR(buf))
+		require.Equal(t, byte(0x8f), buf.Bytes()[0])
+
+		var out retrievalmarket.ClientDealState
+		require.NoError(t, out.UnmarshalCBOR(bytes.NewReader(firstFields(t, buf.Bytes(), 14))))
+		require.Equal(t, baseDeal, out)
+		require.Equal(t, retrievalmarket.RejectionNone, out.RejectionReason)
+	})
+
+	t.Run("deal with rejection reason round trips", func(t *testing.T) {
+		deal := baseDeal
+		deal.RejectionReason = retrievalmarket.RejectionReason(1)
+		buf := new(bytes.Buffer)
+		require.NoError(t, deal.MarshalCBOR(buf))
+
+		var out retrievalmarket.ClientDealState
+		require.NoError(t, out.UnmarshalCBOR(buf))
+		require.Equal(t, deal, out)
+	})
+}
+
+func TestProviderDealStateCBOR(t *testing.T) {
+	baseDeal := retrievalmarket.ProviderDealState{
+		DealProposal:  tut.MakeTestDealProposal(),
+		Status:        retrievalmarket.DealStatusOngoing,
+		Receiver:      peer.ID("receiver"),
+		TotalSent:     100,
+		FundsReceived: abi.NewTokenAmount(10),
+		Message:       "ongoing",
+	}
+
+	t.Run("deal without rejection reason uses original encoding", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		require.NoError(t, baseDeal.MarshalCBOR(buf))
+		require.Equal(t, byte(0x87), buf.Bytes()[0])
+
+		var out retrievalmarket.ProviderDealState
+		require.NoError(t, out.UnmarshalCBOR(buf))
+		require.Equal(t, baseDeal, out)
+	})
+
+	t.Run("deal stored with original encoding decodes", func(t *testing.T) {
+		deal := baseDeal
+		deal.RejectionReason = retrievalmarket.RejectionReason(1)
+		buf := new(bytes.Buffer)
+		require.NoError(t, deal.MarshalCBOR(buf))
+		require.Equal(t, byte(0x88), buf.Bytes()[0])
+
+		var out retrievalmarket.ProviderDealState
+		require.NoError(t, out.UnmarshalCBOR(bytes.NewReader(firstFields(t, buf.Bytes(), 7))))
+		require.Equal(t, baseDeal, out)
+		require.Equal(t, retrievalmarket.RejectionNone, out.RejectionReason)
+	})
+
+	t.Run("deal with wrong number of fields fails", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		require.NoError(t, baseDeal.MarshalCBOR(buf))
+
+		var out retrievalmarket.ProviderDealState
+		require.Error(t, out.UnmarshalCBOR(bytes.NewReader(firstFields(t, buf.Bytes(), 6))))
+	})
+}
+
+// firstFields cuts an encoded tuple down to its first n fields, which is how deals were
+// encoded before fields were added to the end of them
+func firstFields(t *testing.T, encoded []byte, n uint64) []byte {
+	r := bytes.NewReader(encoded)
+	_, _, err := cbg.CborReadHeader(r)
+	require.NoError(t, err)
+
+	out := bytes.NewBuffer(cbg.CborEncodeMajorType(cbg.MajArray, n))
+	for i := uint64(0); i < n; i++ {
+		var field cbg.Deferred
+		require.NoError(t, field.UnmarshalCBOR(r))
+		out.Write(field.Raw)
+	}
+	return out.Bytes()
+}
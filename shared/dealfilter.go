@@ -0,0 +1,16 @@
+package shared
+
+// FilterDecision is the outcome of running a single deal filter on a proposed deal
+type FilterDecision uint64
+
+const (
+	// FilterAbstain means the filter has no opinion on the deal, and leaves the
+	// decision to the filters that follow it
+	FilterAbstain FilterDecision = iota
+
+	// FilterAccept means the deal is accepted without running any further filters
+	FilterAccept
+
+	// FilterReject means the deal is rejected without running any further filters
+	FilterReject
+)
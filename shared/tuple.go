@@ -0,0 +1,174 @@
+package shared
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+
+	cbg "github.com/whyrusleeping/cbor-gen"
+	"golang.org/x/xerrors"
+)
+
+// TupleLengths are the shorter field counts a struct encoded as a CBOR tuple is also sent
+// and stored with. Fields are only ever appended to such a struct, so each length is the
+// field count of an earlier version of it, which peers and datastores from before the
+// later fields still use
+type TupleLengths []uint64
+
+// Marshal writes the cbor-gen encoding of the struct v points to, cut down to the shortest
+// of the lengths that keeps every field that is set. Empty slices and maps count as unset
+func (l TupleLengths) Marshal(w io.Writer, v cbg.CBORMarshaler) error {
+	rv := reflect.ValueOf(v)
+	if rv.IsNil() {
+		return v.MarshalCBOR(w)
+	}
+	fields := rv.Elem()
+	full := uint64(fields.NumField())
+
+	set := uint64(0)
+	for i := full; i > 0; i-- {
+		if !isUnset(fields.Field(int(i - 1))) {
+			set = i
+			break
+		}
+	}
+	length := full
+	for _, candidate := range l {
+		if candidate >= set && candidate < length {
+			length = candidate
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	if err := v.MarshalCBOR(buf); err != nil {
+		return err
+	}
+	if length == full {
+		_, err := w.Write(buf.Bytes())
+		return err
+	}
+
+	if _, _, err := cbg.CborReadHeader(buf); err != nil {
+		return err
+	}
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajArray, length)); err != nil {
+		return err
+	}
+	for i := uint64(0); i < length; i++ {
+		var field cbg.Deferred
+		if err := field.UnmarshalCBOR(buf); err != nil {
+			return xerrors.Errorf("reading field %d: %w", i, err)
+		}
+		if _, err := w.Write(field.Raw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Unmarshal reads a tuple with either one of the lengths or every field of the struct v
+// points to into v. Fields missing from a shorter tuple are left at their zero value
+func (l TupleLengths) Unmarshal(r io.Reader, v cbg.CBORUnmarshaler) error {
+	br := cbg.GetPeeker(r)
+	fields := reflect.ValueOf(v).Elem()
+	full := uint64(fields.NumField())
+
+	maj, extra, err := cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+	if extra != full && !l.has(extra) {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// the cbor-gen decoder only reads complete tuples, so the missing fields are
+	// filled in with encodings of their zero values before it runs
+	buf := new(bytes.Buffer)
+	if _, err := buf.Write(cbg.CborEncodeMajorType(cbg.MajArray, full)); err != nil {
+		return err
+	}
+	for i := uint64(0); i < extra; i++ {
+		var field cbg.Deferred
+		if err := field.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("reading field %d: %w", i, err)
+		}
+		if _, err := buf.Write(field.Raw); err != nil {
+			return err
+		}
+	}
+	for i := extra; i < full; i++ {
+		zero, err := zeroEncoding(fields.Field(int(i)).Type())
+		if err != nil {
+			return xerrors.Errorf("field %d: %w", i, err)
+		}
+		if _, err := buf.Write(zero); err != nil {
+			return err
+		}
+	}
+	if err := v.UnmarshalCBOR(buf); err != nil {
+		return err
+	}
+
+	// decoding a zero encoding does not always give back the zero value, e.g. for big
+	// integers and maps
+	for i := extra; i < full; i++ {
+		field := fields.Field(int(i))
+		field.Set(reflect.Zero(field.Type()))
+	}
+	return nil
+}
+
+func (l TupleLengths) has(length uint64) bool {
+	for _, candidate := range l {
+		if candidate == length {
+			return true
+		}
+	}
+	return false
+}
+
+func isUnset(field reflect.Value) bool {
+	switch field.Kind() {
+	case reflect.Slice, reflect.Map:
+		return field.Len() == 0
+	default:
+		return field.IsZero()
+	}
+}
+
+var marshalerType = reflect.TypeOf((*cbg.CBORMarshaler)(nil)).Elem()
+
+func zeroEncoding(t reflect.Type) ([]byte, error) {
+	if t.Kind() == reflect.Ptr {
+		return cbg.CborNull, nil
+	}
+	if reflect.PtrTo(t).Implements(marshalerType) {
+		buf := new(bytes.Buffer)
+		if err := reflect.New(t).Interface().(cbg.CBORMarshaler).MarshalCBOR(buf); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return cbg.CborBoolFalse, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return cbg.CborEncodeMajorType(cbg.MajUnsignedInt, 0), nil
+	case reflect.String:
+		return cbg.CborEncodeMajorType(cbg.MajTextString, 0), nil
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return cbg.CborEncodeMajorType(cbg.MajByteString, 0), nil
+		}
+		return cbg.CborEncodeMajorType(cbg.MajArray, 0), nil
+	case reflect.Map:
+		return cbg.CborEncodeMajorType(cbg.MajMap, 0), nil
+	default:
+		return nil, xerrors.Errorf("no zero encoding for %s", t)
+	}
+}
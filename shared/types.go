@@ -5,3 +5,20 @@ type TipSetToken []byte
 
 // Unsubscribe is a function that gets called to unsubscribe from (storage|retrieval)market events
 type Unsubscribe func()
+
+// Market identifies one side of one of the markets
+type Market string
+
+const (
+	// StorageClient is the client side of the storage market
+	StorageClient Market = "storage-client"
+
+	// StorageProvider is the provider side of the storage market
+	StorageProvider Market = "storage-provider"
+
+	// RetrievalClient is the client side of the retrieval market
+	RetrievalClient Market = "retrieval-client"
+
+	// RetrievalProvider is the provider side of the retrieval market
+	RetrievalProvider Market = "retrieval-provider"
+)
@@ -245,6 +245,39 @@ func MakeTestStorageNetworkSignedResponse() smnet.SignedResponse {
 	}
 }
 
+// MakeTestDealStatusRequest generates a request to get a provider's deal state
+func MakeTestDealStatusRequest() smnet.DealStatusRequest {
+	return smnet.DealStatusRequest{
+		Proposal: GenerateCids(1)[0],
+	}
+}
+
+// MakeTestDealStatusResponse generates a signed response to a deal status request
+func MakeTestDealStatusResponse() smnet.DealStatusResponse {
+	publishCid := GenerateCids(1)[0]
+	return smnet.DealStatusResponse{
+		DealState: storagemarket.ProviderDealState{
+			State:      storagemarket.StorageDealSealing,
+			Message:    "sealing",
+			Proposal:   GenerateCids(1)[0],
+			PublishCid: &publishCid,
+			DealID:     abi.DealID(rand.Uint64()),
+		},
+		Signature: MakeTestSignature(),
+	}
+}
+
+// MakeTestSignedDealCancel generates a signed cancellation of a deal
+func MakeTestSignedDealCancel() smnet.SignedDealCancel {
+	return smnet.SignedDealCancel{
+		DealCancel: smnet.DealCancel{
+			Proposal: GenerateCids(1)[0],
+			Reason:   "no longer needed",
+		},
+		Signature: MakeTestSignature(),
+	}
+}
+
 // MakeTestStorageAskRequest generates a request to get a provider's ask
 func MakeTestStorageAskRequest() smnet.AskRequest {
 	return smnet.AskRequest{
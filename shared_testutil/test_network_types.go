@@ -579,7 +579,7 @@ func (tsds *TestStorageDealStream) ReadDealResponse() (smnet.SignedResponse, err
 }
 
 // WriteDealResponse calls the mocked deal response writer function.
-func (tsds *TestStorageDealStream) WriteDealResponse(dealResponse smnet.SignedResponse) error {
+func (tsds *TestStorageDealStream) WriteDealResponse(dealResponse smnet.SignedResponse, resign smnet.ResigningFunc) error {
 	return tsds.responseWriter(dealResponse)
 }
 
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"testing"
 
+	"github.com/filecoin-project/specs-actors/actors/abi"
 	"github.com/ipfs/go-cid"
 	"github.com/stretchr/testify/require"
 
@@ -16,6 +17,8 @@ type TestPieceStore struct {
 	addPieceBlockLocationsError error
 	addDealForPieceError        error
 	getPieceInfoError           error
+	removeDealForPieceError     error
+	removedDeals                map[cid.Cid][]abi.DealID
 	piecesStubbed               map[cid.Cid]piecestore.PieceInfo
 	piecesExpected              map[cid.Cid]struct{}
 	piecesReceived              map[cid.Cid]struct{}
@@ -29,6 +32,7 @@ type TestPieceStoreParams struct {
 	AddDealForPieceError        error
 	AddPieceBlockLocationsError error
 	GetPieceInfoError           error
+	RemoveDealForPieceError     error
 }
 
 var _ piecestore.PieceStore = &TestPieceStore{}
@@ -44,6 +48,8 @@ func NewTestPieceStoreWithParams(params TestPieceStoreParams) *TestPieceStore {
 		addDealForPieceError:        params.AddDealForPieceError,
 		addPieceBlockLocationsError: params.AddPieceBlockLocationsError,
 		getPieceInfoError:           params.GetPieceInfoError,
+		removeDealForPieceError:     params.RemoveDealForPieceError,
+		removedDeals:                make(map[cid.Cid][]abi.DealID),
 		piecesStubbed:               make(map[cid.Cid]piecestore.PieceInfo),
 		piecesExpected:              make(map[cid.Cid]struct{}),
 		piecesReceived:              make(map[cid.Cid]struct{}),
@@ -103,6 +109,20 @@ func (tps *TestPieceStore) AddPieceBlockLocations(pieceCID cid.Cid, blockLocatio
 	return tps.addPieceBlockLocationsError
 }
 
+// RemoveDealForPiece records the removed deal, or returns a preprogrammed error
+func (tps *TestPieceStore) RemoveDealForPiece(pieceCID cid.Cid, dealID abi.DealID) error {
+	if tps.removeDealForPieceError != nil {
+		return tps.removeDealForPieceError
+	}
+	tps.removedDeals[pieceCID] = append(tps.removedDeals[pieceCID], dealID)
+	return nil
+}
+
+// RemovedDeals returns the deals removed for the given piece
+func (tps *TestPieceStore) RemovedDeals(pieceCID cid.Cid) []abi.DealID {
+	return tps.removedDeals[pieceCID]
+}
+
 // GetPieceInfo returns a piece info if it's been stubbed
 func (tps *TestPieceStore) GetPieceInfo(pieceCID cid.Cid) (piecestore.PieceInfo, error) {
 	if tps.getPieceInfoError != nil {
@@ -0,0 +1,143 @@
+package storagemarket
+
+import (
+	"strconv"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/ipfs/go-cid"
+)
+
+// DealGroupID identifies a group of deals that replicate the same data
+type DealGroupID uint64
+
+func (id DealGroupID) String() string {
+	return strconv.FormatUint(uint64(id), 10)
+}
+
+// DealGroup is a set of deals that store the same data with different providers. When a
+// deal in the group fails, the client makes a deal with another provider to replace it
+type DealGroup struct {
+	ID     DealGroupID
+	Client address.Address
+
+	// Data is the data the group stores, with its piece CID and size filled in
+	Data *DataRef
+
+	StartEpoch abi.ChainEpoch
+	EndEpoch   abi.ChainEpoch
+
+	// StartDelay is how many epochs after it is proposed a deal starts. Deals that replace
+	// failed deals after the group's start epoch is too close start this long after they
+	// are proposed, and last as long as the original deals
+	StartDelay abi.ChainEpoch
+
+	ProofType abi.RegisteredProof
+
+	// MaxPrice is the most the client pays per GiB per epoch. Zero means no limit
+	MaxPrice abi.TokenAmount
+
+	// Replicas is how many providers the group stores the data with
+	Replicas     uint64
+	VerifiedDeal bool
+	Labels       DealLabels
+
+	// Deals are the proposal CIDs of every deal a provider accepted for the group,
+	// including deals that failed and were replaced
+	Deals []cid.Cid
+
+	// Exhausted is set when the client ran out of providers to replace failed deals with
+	Exhausted bool
+	Message   string
+}
+
+// DealGroupStatus is the aggregate status of the deals in a group
+type DealGroupStatus uint64
+
+const (
+	// DealGroupUnknown means the status of the group is not known
+	DealGroupUnknown DealGroupStatus = iota
+
+	// DealGroupInProgress means the group does not yet have as many active deals as it
+	// has replicas, but has enough deals in progress or is looking for providers
+	DealGroupInProgress
+
+	// DealGroupActive means the group has as many active deals as it has replicas
+	DealGroupActive
+
+	// DealGroupDegraded means the group has fewer deals than replicas, and there is no
+	// provider left to make more deals with
+	DealGroupDegraded
+
+	// DealGroupExpired means every deal in the group that did not fail has expired
+	DealGroupExpired
+)
+
+// DealGroupStatuses maps deal group status codes to string names
+var DealGroupStatuses = map[DealGroupStatus]string{
+	DealGroupUnknown:    "DealGroupUnknown",
+	DealGroupInProgress: "DealGroupInProgress",
+	DealGroupActive:     "DealGroupActive",
+	DealGroupDegraded:   "DealGroupDegraded",
+	DealGroupExpired:    "DealGroupExpired",
+}
+
+// DealGroupInfo is a deal group along with the deals made for it and their aggregate status
+type DealGroupInfo struct {
+	Group  DealGroup
+	Status DealGroupStatus
+	Deals  []ClientDeal
+
+	// Active counts deals that are active on chain
+	Active uint64
+	// InProgress counts deals that a provider accepted but that are not yet active
+	InProgress uint64
+	// Failed counts deals that failed, were cancelled or were slashed
+	Failed uint64
+	// Expired counts deals that reached their end epoch
+	Expired uint64
+}
+
+// NewDealGroupInfo counts the states of the given deals of a group and works out the
+// status of the group from them
+func NewDealGroupInfo(group DealGroup, deals []ClientDeal) DealGroupInfo {
+	info := DealGroupInfo{Group: group, Deals: deals}
+	for _, deal := range deals {
+		switch deal.State {
+		case StorageDealActive:
+			info.Active++
+		case StorageDealExpired:
+			info.Expired++
+		case StorageDealFailing, StorageDealError, StorageDealCancelled, StorageDealSlashed:
+			info.Failed++
+		default:
+			info.InProgress++
+		}
+	}
+
+	switch {
+	case info.Active >= group.Replicas:
+		info.Status = DealGroupActive
+	case info.Expired > 0 && info.Active+info.InProgress == 0:
+		info.Status = DealGroupExpired
+	case info.Active+info.InProgress >= group.Replicas || !group.Exhausted:
+		info.Status = DealGroupInProgress
+	default:
+		info.Status = DealGroupDegraded
+	}
+	return info
+}
+
+// Live returns the number of deals in the group that are active or on their way to it
+func (info DealGroupInfo) Live() uint64 {
+	return info.Active + info.InProgress
+}
+
+// ProposeReplicatedDealResult is the result of proposing a deal to several providers
+type ProposeReplicatedDealResult struct {
+	GroupID DealGroupID
+	Status  DealGroupStatus
+
+	// ProposalCids are the proposal CIDs of the deals providers accepted
+	ProposalCids []cid.Cid
+}
@@ -0,0 +1,106 @@
+package storagemarket_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	specst "github.com/filecoin-project/specs-actors/support/testing"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-fil-markets/shared_testutil"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+)
+
+func TestNewDealGroupInfo(t *testing.T) {
+	deal := func(state storagemarket.StorageDealStatus) storagemarket.ClientDeal {
+		return storagemarket.ClientDeal{State: state}
+	}
+	tests := map[string]struct {
+		exhausted bool
+		deals     []storagemarket.ClientDeal
+		status    storagemarket.DealGroupStatus
+		live      uint64
+		failed    uint64
+	}{
+		"no deals yet": {
+			status: storagemarket.DealGroupInProgress,
+		},
+		"all replicas active": {
+			deals:  []storagemarket.ClientDeal{deal(storagemarket.StorageDealActive), deal(storagemarket.StorageDealActive), deal(storagemarket.StorageDealActive)},
+			status: storagemarket.DealGroupActive,
+			live:   3,
+		},
+		"some deals sealing": {
+			deals:  []storagemarket.ClientDeal{deal(storagemarket.StorageDealActive), deal(storagemarket.StorageDealSealing), deal(storagemarket.StorageDealTransferring)},
+			status: storagemarket.DealGroupInProgress,
+			live:   3,
+		},
+		"failed deal being replaced": {
+			deals:  []storagemarket.ClientDeal{deal(storagemarket.StorageDealActive), deal(storagemarket.StorageDealActive), deal(storagemarket.StorageDealError)},
+			status: storagemarket.DealGroupInProgress,
+			live:   2,
+			failed: 1,
+		},
+		"replaced failed deal": {
+			deals:  []storagemarket.ClientDeal{deal(storagemarket.StorageDealActive), deal(storagemarket.StorageDealSlashed), deal(storagemarket.StorageDealActive), deal(storagemarket.StorageDealActive)},
+			status: storagemarket.DealGroupActive,
+			live:   3,
+			failed: 1,
+		},
+		"out of providers": {
+			exhausted: true,
+			deals:     []storagemarket.ClientDeal{deal(storagemarket.StorageDealActive), deal(storagemarket.StorageDealError)},
+			status:    storagemarket.DealGroupDegraded,
+			live:      1,
+			failed:    1,
+		},
+		"expired": {
+			deals:  []storagemarket.ClientDeal{deal(storagemarket.StorageDealExpired), deal(storagemarket.StorageDealExpired), deal(storagemarket.StorageDealError)},
+			status: storagemarket.DealGroupExpired,
+			failed: 1,
+		},
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			group := storagemarket.DealGroup{Replicas: 3, Exhausted: data.exhausted}
+			info := storagemarket.NewDealGroupInfo(group, data.deals)
+			require.Equal(t, data.status, info.Status)
+			require.Equal(t, data.live, info.Live())
+			require.Equal(t, data.failed, info.Failed)
+		})
+	}
+}
+
+func TestDealGroupCBOR(t *testing.T) {
+	cids := shared_testutil.GenerateCids(3)
+	group := storagemarket.DealGroup{
+		ID:     7,
+		Client: specst.NewIDAddr(t, 101),
+		Data: &storagemarket.DataRef{
+			TransferType: storagemarket.TTGraphsync,
+			Root:         cids[0],
+			PieceCid:     &cids[1],
+			PieceSize:    abi.UnpaddedPieceSize(1016),
+		},
+		StartEpoch:   1000,
+		EndEpoch:     11000,
+		StartDelay:   500,
+		ProofType:    abi.RegisteredProof_StackedDRG2KiBSeal,
+		MaxPrice:     big.NewInt(5000),
+		Replicas:     3,
+		VerifiedDeal: true,
+		Labels:       storagemarket.DealLabels{"dataset": "d1"},
+		Deals:        []cid.Cid{cids[2]},
+		Exhausted:    true,
+		Message:      "no provider left",
+	}
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, group.MarshalCBOR(buf))
+	var out storagemarket.DealGroup
+	require.NoError(t, out.UnmarshalCBOR(buf))
+	require.Equal(t, group, out)
+}
@@ -2,10 +2,13 @@ package blockrecorder
 
 import (
 	"bufio"
+	"encoding/binary"
 	"io"
+	"io/ioutil"
 
 	"github.com/ipfs/go-cid"
 	"github.com/ipld/go-car"
+	"golang.org/x/xerrors"
 )
 
 //go:generate cbor-gen-for PieceBlockMetadata
@@ -32,6 +35,93 @@ func RecordEachBlockTo(out io.Writer) car.OnNewCarBlockFunc {
 	}
 }
 
+// MaxSectionSize is the largest CAR header or block section RecordCarBlocks reads
+const MaxSectionSize = 4 << 20
+
+// RecordCarBlocks reads a complete CAR file, held in a piece of the given size, and
+// records the exact location of each block's data in it, in the same form as
+// RecordEachBlockTo
+func RecordCarBlocks(carData io.Reader, size uint64, out io.Writer) error {
+	cr := &countingReader{r: bufio.NewReader(carData)}
+
+	// skip the header
+	headerLen, err := binary.ReadUvarint(cr)
+	if err != nil {
+		return xerrors.Errorf("reading car header: %w", err)
+	}
+	if err := checkSectionLen(headerLen, cr.offset, size); err != nil {
+		return xerrors.Errorf("reading car header: %w", err)
+	}
+	if _, err := io.CopyN(ioutil.Discard, cr, int64(headerLen)); err != nil {
+		return xerrors.Errorf("reading car header: %w", err)
+	}
+
+	for {
+		sectionLen, err := binary.ReadUvarint(cr)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return xerrors.Errorf("reading car section length: %w", err)
+		}
+		// anything after a zero length section is padding
+		if sectionLen == 0 {
+			return nil
+		}
+		sectionStart := cr.offset
+		if err := checkSectionLen(sectionLen, sectionStart, size); err != nil {
+			return xerrors.Errorf("reading car section at offset %d: %w", sectionStart, err)
+		}
+		section := make([]byte, sectionLen)
+		if _, err := io.ReadFull(cr, section); err != nil {
+			return xerrors.Errorf("reading car section at offset %d: %w", sectionStart, err)
+		}
+		cidLen, c, err := cid.CidFromBytes(section)
+		if err != nil {
+			return xerrors.Errorf("reading block cid at offset %d: %w", sectionStart, err)
+		}
+		pbMetadata := &PieceBlockMetadata{
+			CID:    c,
+			Offset: sectionStart + uint64(cidLen),
+			Size:   sectionLen - uint64(cidLen),
+		}
+		if err := pbMetadata.MarshalCBOR(out); err != nil {
+			return err
+		}
+	}
+}
+
+// checkSectionLen rejects a section length read from the data that is larger than
+// MaxSectionSize or runs past the end of the piece, before anything is allocated for it
+func checkSectionLen(sectionLen uint64, offset uint64, size uint64) error {
+	if sectionLen > MaxSectionSize {
+		return xerrors.Errorf("section length %d is larger than the maximum of %d", sectionLen, MaxSectionSize)
+	}
+	if offset > size || sectionLen > size-offset {
+		return xerrors.Errorf("section length %d runs past the end of the %d byte piece", sectionLen, size)
+	}
+	return nil
+}
+
+type countingReader struct {
+	r      *bufio.Reader
+	offset uint64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.offset += uint64(n)
+	return n, err
+}
+
+func (cr *countingReader) ReadByte() (byte, error) {
+	b, err := cr.r.ReadByte()
+	if err == nil {
+		cr.offset++
+	}
+	return b, err
+}
+
 // ReadBlockMetadata reads previously recorded block metadata
 func ReadBlockMetadata(input io.Reader) ([]PieceBlockMetadata, error) {
 	var metadatas []PieceBlockMetadata
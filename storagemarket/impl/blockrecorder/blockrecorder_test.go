@@ -3,6 +3,7 @@ package blockrecorder_test
 import (
 	"bytes"
 	"context"
+	"encoding/binary"
 	"testing"
 
 	blocks "github.com/ipfs/go-block-format"
@@ -78,3 +79,73 @@ func TestBlockRecording(t *testing.T) {
 		require.False(t, found)
 	}
 }
+
+func TestRecordCarBlocks(t *testing.T) {
+	testData := shared_testutil.NewTestIPLDTree()
+	ssb := builder.NewSelectorSpecBuilder(basicnode.Style.Any)
+	node := ssb.ExploreRecursive(selector.RecursionLimitNone(), ssb.ExploreAll(ssb.ExploreRecursiveEdge())).Node()
+
+	ctx := context.Background()
+	sc := car.NewSelectiveCar(ctx, testData, []car.Dag{
+		car.Dag{
+			Root:     testData.RootNodeLnk.(cidlink.Link).Cid,
+			Selector: node,
+		},
+	})
+
+	carBuf := new(bytes.Buffer)
+	expectedBuf := new(bytes.Buffer)
+	err := sc.Write(carBuf, blockrecorder.RecordEachBlockTo(expectedBuf))
+	require.NoError(t, err)
+	expected, err := blockrecorder.ReadBlockMetadata(expectedBuf)
+	require.NoError(t, err)
+
+	t.Run("records every block in the car", func(t *testing.T) {
+		blockLocationBuf := new(bytes.Buffer)
+		err := blockrecorder.RecordCarBlocks(bytes.NewReader(carBuf.Bytes()), uint64(carBuf.Len()), blockLocationBuf)
+		require.NoError(t, err)
+		metadata, err := blockrecorder.ReadBlockMetadata(blockLocationBuf)
+		require.NoError(t, err)
+		require.Equal(t, expected, metadata)
+	})
+
+	t.Run("ignores trailing padding", func(t *testing.T) {
+		padded := append(append([]byte{}, carBuf.Bytes()...), make([]byte, 128)...)
+		blockLocationBuf := new(bytes.Buffer)
+		err := blockrecorder.RecordCarBlocks(bytes.NewReader(padded), uint64(len(padded)), blockLocationBuf)
+		require.NoError(t, err)
+		metadata, err := blockrecorder.ReadBlockMetadata(blockLocationBuf)
+		require.NoError(t, err)
+		require.Equal(t, expected, metadata)
+	})
+
+	t.Run("fails on truncated data", func(t *testing.T) {
+		truncated := carBuf.Bytes()[:carBuf.Len()-1]
+		err := blockrecorder.RecordCarBlocks(bytes.NewReader(truncated), uint64(carBuf.Len()), new(bytes.Buffer))
+		require.Error(t, err)
+	})
+
+	t.Run("fails on a section length past the end of the piece", func(t *testing.T) {
+		data := append(append([]byte{}, carBuf.Bytes()...), uvarint(1024)...)
+		data = append(data, make([]byte, 16)...)
+		err := blockrecorder.RecordCarBlocks(bytes.NewReader(data), uint64(len(data)), new(bytes.Buffer))
+		require.Error(t, err)
+	})
+
+	t.Run("fails on an oversized section length", func(t *testing.T) {
+		data := append(append([]byte{}, carBuf.Bytes()...), uvarint(1<<62)...)
+		err := blockrecorder.RecordCarBlocks(bytes.NewReader(data), 1<<63, new(bytes.Buffer))
+		require.Error(t, err)
+	})
+
+	t.Run("fails on an oversized header length", func(t *testing.T) {
+		data := uvarint(1 << 62)
+		err := blockrecorder.RecordCarBlocks(bytes.NewReader(data), 1<<63, new(bytes.Buffer))
+		require.Error(t, err)
+	})
+}
+
+func uvarint(n uint64) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	return buf[:binary.PutUvarint(buf, n)]
+}
@@ -3,11 +3,15 @@ package storageimpl
 import (
 	"context"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/filecoin-project/go-address"
 	cborutil "github.com/filecoin-project/go-cbor-util"
 	datatransfer "github.com/filecoin-project/go-data-transfer"
 	"github.com/filecoin-project/go-statemachine/fsm"
+	"github.com/filecoin-project/go-statestore"
+	"github.com/filecoin-project/go-storedcounter"
 	"github.com/filecoin-project/specs-actors/actors/abi"
 	"github.com/filecoin-project/specs-actors/actors/abi/big"
 	"github.com/filecoin-project/specs-actors/actors/builtin/market"
@@ -15,12 +19,16 @@ import (
 	"github.com/hannahhoward/go-pubsub"
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
+	"github.com/ipfs/go-datastore/query"
 	blockstore "github.com/ipfs/go-ipfs-blockstore"
 	logging "github.com/ipfs/go-log/v2"
 	"github.com/ipld/go-ipld-prime"
 	"github.com/libp2p/go-libp2p-core/peer"
 	"golang.org/x/xerrors"
 
+	"github.com/filecoin-project/go-fil-markets/journal"
+	"github.com/filecoin-project/go-fil-markets/metrics"
 	"github.com/filecoin-project/go-fil-markets/pieceio"
 	"github.com/filecoin-project/go-fil-markets/pieceio/cario"
 	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
@@ -32,12 +40,21 @@ import (
 	"github.com/filecoin-project/go-fil-markets/storagemarket/impl/connmanager"
 	"github.com/filecoin-project/go-fil-markets/storagemarket/impl/dtutils"
 	"github.com/filecoin-project/go-fil-markets/storagemarket/network"
+	"github.com/filecoin-project/go-fil-markets/storagemarket/providerselect"
 )
 
 var log = logging.Logger("storagemarket_impl")
 
 var _ storagemarket.StorageClient = &Client{}
 
+// DefaultPollingInterval is the default interval at which the client re-queries a provider
+// for the state of a deal after losing its stream to the provider
+const DefaultPollingInterval = 30 * time.Second
+
+// DSDealGroupPrefix is the name space the client keeps deal groups under in its datastore,
+// unless it is given a datastore for them
+var DSDealGroupPrefix = "/deal-groups"
+
 type Client struct {
 	net network.StorageMarketNetwork
 
@@ -50,6 +67,66 @@ type Client struct {
 	pubSub        *pubsub.PubSub
 	statemachines fsm.Group
 	conns         *connmanager.ConnManager
+
+	pollingInterval time.Duration
+	retryPolicy     storagemarket.RetryPolicy
+	journal         *journal.Journal
+	dealMetrics     *metrics.DealTracker
+
+	selector        *providerselect.Selector
+	selectorOptions []providerselect.Option
+
+	dealGroupsDs     datastore.Batching
+	dealGroups       *statestore.StateStore
+	dealGroupCounter *storedcounter.StoredCounter
+	dealGroupsLk     sync.Mutex
+	dealGroupFills   map[storagemarket.DealGroupID]bool
+	dealGroupsByDeal map[cid.Cid]storagemarket.DealGroupID
+
+	// ctx is cancelled when the client stops, ending work the client started in the background
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// StorageClientOption allows custom configuration of a storage client
+type StorageClientOption func(c *Client)
+
+// ClientNodeRetryPolicy sets how a storage client retries node calls that fail with transient
+// errors while processing a deal. The zero value disables retries
+func ClientNodeRetryPolicy(policy storagemarket.RetryPolicy) StorageClientOption {
+	return func(c *Client) {
+		c.retryPolicy = policy
+	}
+}
+
+// ClientDealJournal records every event that happens to the client's deals in the given journal
+func ClientDealJournal(j *journal.Journal) StorageClientOption {
+	return func(c *Client) {
+		c.journal = j
+	}
+}
+
+// ClientMetricsRecorder sends measurements of the client's deals to the given recorder
+func ClientMetricsRecorder(recorder metrics.Recorder) StorageClientOption {
+	return func(c *Client) {
+		c.dealMetrics.SetRecorder(recorder)
+	}
+}
+
+// ClientProviderSelection configures how the client picks providers for replicated deals
+func ClientProviderSelection(options ...providerselect.Option) StorageClientOption {
+	return func(c *Client) {
+		c.selectorOptions = options
+	}
+}
+
+// ClientDealGroupStore keeps the client's groups of replicated deals in the given datastore.
+// It must not be the datastore the client keeps its deals in. Without it, deal groups are
+// kept in the client's datastore under DSDealGroupPrefix
+func ClientDealGroupStore(ds datastore.Batching) StorageClientOption {
+	return func(c *Client) {
+		c.dealGroupsDs = ds
+	}
 }
 
 func NewClient(
@@ -59,6 +136,7 @@ func NewClient(
 	discovery *discovery.Local,
 	ds datastore.Batching,
 	scn storagemarket.StorageClientNode,
+	options ...StorageClientOption,
 ) (*Client, error) {
 	carIO := cario.NewCarIO()
 	pio := pieceio.NewPieceIO(carIO, bs)
@@ -72,13 +150,28 @@ func NewClient(
 		node:         scn,
 		pubSub:       pubsub.New(clientDispatcher),
 		conns:        connmanager.NewConnManager(),
+
+		pollingInterval: DefaultPollingInterval,
+		retryPolicy:     storagemarket.DefaultRetryPolicy,
+		dealMetrics:     metrics.NewDealTracker(shared.StorageClient),
+
+		dealGroupsDs:     namespace.Wrap(ds, datastore.NewKey(DSDealGroupPrefix)),
+		dealGroupFills:   make(map[storagemarket.DealGroupID]bool),
+		dealGroupsByDeal: make(map[cid.Cid]storagemarket.DealGroupID),
+	}
+	for _, option := range options {
+		option(c)
 	}
+	c.selector = providerselect.NewSelector(c, c.selectorOptions...)
+	c.dealGroups = statestore.New(namespace.Wrap(c.dealGroupsDs, datastore.NewKey("/groups")))
+	c.dealGroupCounter = storedcounter.New(c.dealGroupsDs, datastore.NewKey("/next-group-id"))
+	c.ctx, c.cancel = context.WithCancel(context.Background())
 
-	statemachines, err := fsm.New(ds, fsm.Parameters{
+	statemachines, err := fsm.New(dealsDatastore{ds}, fsm.Parameters{
 		Environment:     &clientDealEnvironment{c},
 		StateType:       storagemarket.ClientDeal{},
 		StateKeyField:   "State",
-		Events:          clientstates.ClientEvents,
+		Events:          clientstates.NewClientEvents(journal.RecordArgs(c.recordEventArgs)),
 		StateEntryFuncs: clientstates.ClientStateEntryFuncs,
 		Notifier:        c.dispatch,
 	})
@@ -89,14 +182,74 @@ func NewClient(
 
 	// register a data transfer event handler -- this will send events to the state machines based on DT events
 	dataTransfer.SubscribeToEvents(dtutils.ClientDataTransferSubscriber(statemachines))
+	dataTransfer.SubscribeToEvents(dtutils.TransferMetricsSubscriber(c.dealMetrics))
 
 	return c, nil
 }
 
+// dealsDatastore hides the deal groups kept under DSDealGroupPrefix from the client's deal
+// state machines, which expect every entry they list to be a deal
+type dealsDatastore struct {
+	datastore.Batching
+}
+
+func (d dealsDatastore) Query(q query.Query) (query.Results, error) {
+	filters := make([]query.Filter, 0, len(q.Filters)+1)
+	q.Filters = append(append(filters, q.Filters...), dealGroupFilter{})
+	return d.Batching.Query(q)
+}
+
+type dealGroupFilter struct{}
+
+func (dealGroupFilter) Filter(e query.Entry) bool {
+	return !datastore.NewKey(DSDealGroupPrefix).IsAncestorOf(datastore.NewKey(e.Key))
+}
+
+// Run restarts deals that were in progress when the client was last stopped, and replaces
+// deals of deal groups that failed while it was stopped
 func (c *Client) Run(ctx context.Context) {
+	err := c.restartDeals()
+	if err != nil {
+		log.Errorf("restarting deals: %s", err)
+	}
+	err = c.restartDealGroups()
+	if err != nil {
+		log.Errorf("restarting deal groups: %s", err)
+	}
+}
+
+// restartDeals re-enters the current state of every deal that was still being processed
+// when the client was stopped. Deals that held a stream to the provider move to a state
+// where acceptance must be checked again, since the provider's response was lost with the stream
+func (c *Client) restartDeals() error {
+	var deals []storagemarket.ClientDeal
+	err := c.statemachines.List(&deals)
+	if err != nil {
+		return err
+	}
+
+	for _, deal := range deals {
+		final := clientstates.IsFinalityState(deal.State)
+		c.dealMetrics.Restore(deal.ProposalCid.String(), storagemarket.DealStates[deal.State], final)
+		if final {
+			continue
+		}
+
+		evt := storagemarket.ClientEventRestart
+		if clientstates.HasOpenStream(deal.State) {
+			evt = storagemarket.ClientEventStreamLost
+		}
+
+		err = c.statemachines.Send(deal.ProposalCid, evt)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (c *Client) Stop() {
+	c.cancel()
 	_ = c.statemachines.Stop(context.TODO())
 }
 
@@ -136,11 +289,21 @@ func (c *Client) ListDeals(ctx context.Context, addr address.Address) ([]storage
 	return c.node.ListClientDeals(ctx, addr, tok)
 }
 
-func (c *Client) ListLocalDeals(ctx context.Context) ([]storagemarket.ClientDeal, error) {
-	var out []storagemarket.ClientDeal
-	if err := c.statemachines.List(&out); err != nil {
+func (c *Client) ListLocalDeals(ctx context.Context, selectors ...storagemarket.LabelSelector) ([]storagemarket.ClientDeal, error) {
+	var deals []storagemarket.ClientDeal
+	if err := c.statemachines.List(&deals); err != nil {
 		return nil, err
 	}
+	if len(selectors) == 0 {
+		return deals, nil
+	}
+
+	out := make([]storagemarket.ClientDeal, 0, len(deals))
+	for _, deal := range deals {
+		if storagemarket.SelectLabels(deal.Labels, selectors) {
+			out = append(out, deal)
+		}
+	}
 	return out, nil
 }
 
@@ -153,6 +316,14 @@ func (c *Client) GetLocalDeal(ctx context.Context, cid cid.Cid) (storagemarket.C
 }
 
 func (c *Client) GetAsk(ctx context.Context, info storagemarket.StorageProviderInfo) (*storagemarket.SignedStorageAsk, error) {
+	asks, err := c.GetAsks(ctx, info)
+	if err != nil {
+		return nil, err
+	}
+	return asks[0], nil
+}
+
+func (c *Client) GetAsks(ctx context.Context, info storagemarket.StorageProviderInfo) ([]*storagemarket.SignedStorageAsk, error) {
 	s, err := c.net.NewAskStream(info.PeerID)
 	if err != nil {
 		return nil, xerrors.Errorf("failed to open stream to miner: %w", err)
@@ -172,8 +343,10 @@ func (c *Client) GetAsk(ctx context.Context, info storagemarket.StorageProviderI
 		return nil, xerrors.Errorf("got no ask back")
 	}
 
-	if out.Ask.Ask.Miner != info.Address {
-		return nil, xerrors.Errorf("got back ask for wrong miner")
+	// miners without ask tiers only send their default ask
+	asks := out.Asks
+	if len(asks) == 0 {
+		asks = []*storagemarket.SignedStorageAsk{out.Ask}
 	}
 
 	tok, _, err := c.node.GetChainHead(ctx)
@@ -181,16 +354,154 @@ func (c *Client) GetAsk(ctx context.Context, info storagemarket.StorageProviderI
 		return nil, err
 	}
 
-	isValid, err := c.node.ValidateAskSignature(ctx, out.Ask, tok)
+	for _, ask := range asks {
+		if ask == nil || ask.Ask == nil {
+			return nil, xerrors.Errorf("got empty ask back")
+		}
+
+		if ask.Ask.Miner != info.Address {
+			return nil, xerrors.Errorf("got back ask for wrong miner")
+		}
+
+		isValid, err := c.node.ValidateAskSignature(ctx, ask, tok)
+		if err != nil {
+			return nil, err
+		}
+
+		if !isValid {
+			return nil, xerrors.Errorf("ask was not properly signed")
+		}
+	}
+
+	return asks, nil
+}
+
+// GetProviderDealState queries the provider of a deal for the deal's current state, which
+// works even after the stream the deal was proposed on has been closed
+func (c *Client) GetProviderDealState(ctx context.Context, proposalCid cid.Cid) (*storagemarket.ProviderDealState, error) {
+	var deal storagemarket.ClientDeal
+	err := c.statemachines.Get(proposalCid).Get(&deal)
+	if err != nil {
+		return nil, xerrors.Errorf("could not get client deal state: %w", err)
+	}
+
+	s, err := c.net.NewDealStatusStream(deal.Miner)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to open stream to miner: %w", err)
+	}
+	defer s.Close()
+
+	request := network.DealStatusRequest{Proposal: proposalCid}
+	if err := s.WriteDealStatusRequest(request); err != nil {
+		return nil, xerrors.Errorf("failed to send deal status request: %w", err)
+	}
+
+	resp, err := s.ReadDealStatusResponse()
+	if err != nil {
+		return nil, xerrors.Errorf("failed to read deal status response: %w", err)
+	}
+
+	if resp.DealState.Proposal != proposalCid {
+		return nil, xerrors.Errorf("miner responded to a wrong proposal: %s != %s", resp.DealState.Proposal, proposalCid)
+	}
+
+	tok, _, err := c.node.GetChainHead(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	if !isValid {
-		return nil, xerrors.Errorf("ask was not properly signed")
+	err = clientutils.VerifyDealStatusResponse(ctx, resp, deal.MinerWorker, tok, c.node.VerifySignature)
+	if err != nil {
+		return nil, xerrors.Errorf("verifying deal status response: %w", err)
+	}
+
+	return &resp.DealState, nil
+}
+
+// CancelDeal withdraws a deal that the provider has not yet accepted for publishing. Unless
+// the deal is still being funded, and so has not been proposed yet, the provider is sent a
+// cancellation signed by the client address of the deal on the deal protocol, and the deal
+// is only cancelled once the provider confirms it cancelled its side. Cancelling the deal
+// stops any data transfer and closes the deal stream. The client does not reserve escrow
+// locally, so funds already added to the market actor for the deal remain available for
+// other deals
+func (c *Client) CancelDeal(ctx context.Context, proposalCid cid.Cid, reason string) error {
+	var deal storagemarket.ClientDeal
+	if err := c.statemachines.Get(proposalCid).Get(&deal); err != nil {
+		return xerrors.Errorf("could not get client deal state: %w", err)
+	}
+
+	if clientstates.IsFinalityState(deal.State) || deal.State == storagemarket.StorageDealFailing {
+		return xerrors.Errorf("deal %s has already finished (%s)", proposalCid, storagemarket.DealStates[deal.State])
+	}
+	if !clientstates.CanCancel(deal.State) {
+		return xerrors.Errorf("deal %s has already been accepted for publishing (%s) and can no longer be cancelled", proposalCid, storagemarket.DealStates[deal.State])
 	}
 
-	return out.Ask, nil
+	// the provider has not seen a deal that is still being funded
+	if deal.State != storagemarket.StorageDealEnsureClientFunds && deal.State != storagemarket.StorageDealClientFunding {
+		if err := c.sendDealCancel(ctx, deal, reason); err != nil {
+			return xerrors.Errorf("provider did not cancel deal %s: %w", proposalCid, err)
+		}
+	}
+
+	if err := c.statemachines.Send(proposalCid, storagemarket.ClientEventCancelled, reason); err != nil {
+		return xerrors.Errorf("cancelling deal: %w", err)
+	}
+	return nil
+}
+
+// sendDealCancel asks the provider to cancel a deal, and returns an error unless the
+// provider replies that it did
+func (c *Client) sendDealCancel(ctx context.Context, deal storagemarket.ClientDeal, reason string) error {
+	dealCancel := network.DealCancel{
+		Proposal: deal.ProposalCid,
+		Reason:   reason,
+	}
+	msg, err := cborutil.Dump(&dealCancel)
+	if err != nil {
+		return xerrors.Errorf("serializing deal cancellation: %w", err)
+	}
+	sig, err := c.node.SignBytes(ctx, deal.Proposal.Client, msg)
+	if err != nil {
+		return xerrors.Errorf("signing deal cancellation: %w", err)
+	}
+
+	s, err := c.net.NewDealStream(deal.Miner)
+	if err != nil {
+		return xerrors.Errorf("failed to open stream to miner: %w", err)
+	}
+	defer s.Close()
+
+	err = s.WriteDealProposal(network.Proposal{
+		Cancel: &network.SignedDealCancel{
+			DealCancel: dealCancel,
+			Signature:  sig,
+		},
+	})
+	if err != nil {
+		return xerrors.Errorf("sending deal cancellation: %w", err)
+	}
+
+	resp, err := s.ReadDealResponse()
+	if err != nil {
+		return xerrors.Errorf("reading deal cancellation response: %w", err)
+	}
+
+	tok, _, err := c.node.GetChainHead(ctx)
+	if err != nil {
+		return xerrors.Errorf("getting chain head: %w", err)
+	}
+	if err := clientutils.VerifyResponse(ctx, resp, deal.MinerWorker, tok, c.node.VerifySignature); err != nil {
+		return xerrors.Errorf("verifying deal cancellation response: %w", err)
+	}
+	if resp.Response.Proposal != deal.ProposalCid {
+		return xerrors.Errorf("deal cancellation response is for proposal %s", resp.Response.Proposal)
+	}
+	if resp.Response.State != storagemarket.StorageDealCancelled {
+		return xerrors.Errorf("provider refused (%s): %s", storagemarket.DealStates[resp.Response.State], resp.Response.Message)
+	}
+	return nil
 }
 
 func (c *Client) ProposeStorageDeal(
@@ -203,16 +514,40 @@ func (c *Client) ProposeStorageDeal(
 	price abi.TokenAmount,
 	collateral abi.TokenAmount,
 	rt abi.RegisteredProof,
+	options ...storagemarket.ProposeStorageDealOption,
 ) (*storagemarket.ProposeStorageDealResult, error) {
+	var params storagemarket.ProposeStorageDealParams
+	for _, option := range options {
+		option(&params)
+	}
+	if err := params.Labels.Validate(); err != nil {
+		return nil, xerrors.Errorf("invalid deal labels: %w", err)
+	}
+
+	start := time.Now()
 	commP, pieceSize, err := clientutils.CommP(ctx, c.pio, rt, data)
 	if err != nil {
 		return nil, xerrors.Errorf("computing commP failed: %w", err)
 	}
+	if data.PieceCid == nil {
+		c.dealMetrics.CommPDuration(time.Since(start))
+	}
 
 	if uint64(pieceSize.Padded()) > info.SectorSize {
 		return nil, fmt.Errorf("cannot propose a deal whose piece size (%d) is greater than sector size (%d)", pieceSize.Padded(), info.SectorSize)
 	}
 
+	if collateral.Nil() {
+		ask := params.Ask
+		if ask == nil {
+			ask, err = c.dealAsk(ctx, info, pieceSize.Padded(), endEpoch-startEpoch)
+			if err != nil {
+				return nil, err
+			}
+		}
+		collateral = clientutils.ProviderCollateral(*ask, pieceSize.Padded())
+	}
+
 	dealProposal := market.DealProposal{
 		PieceCID:             commP,
 		PieceSize:            pieceSize.Padded(),
@@ -221,8 +556,9 @@ func (c *Client) ProposeStorageDeal(
 		StartEpoch:           startEpoch,
 		EndEpoch:             endEpoch,
 		StoragePricePerEpoch: price,
-		ProviderCollateral:   abi.NewTokenAmount(int64(pieceSize)), // TODO: real calc
+		ProviderCollateral:   collateral,
 		ClientCollateral:     big.Zero(),
+		VerifiedDeal:         params.VerifiedDeal,
 	}
 
 	clientDealProposal, err := c.node.SignProposal(ctx, addr, dealProposal)
@@ -242,6 +578,7 @@ func (c *Client) ProposeStorageDeal(
 		Miner:              info.PeerID,
 		MinerWorker:        info.Worker,
 		DataRef:            data,
+		Labels:             params.Labels,
 	}
 
 	err = c.statemachines.Begin(proposalNd.Cid(), deal)
@@ -262,6 +599,24 @@ func (c *Client) ProposeStorageDeal(
 		})
 }
 
+// dealAsk returns the ask the provider prices a deal with: the cheapest of its asks that
+// covers the deal, or its default ask if none do
+func (c *Client) dealAsk(ctx context.Context, info *storagemarket.StorageProviderInfo, pieceSize abi.PaddedPieceSize, duration abi.ChainEpoch) (*storagemarket.StorageAsk, error) {
+	signedAsks, err := c.GetAsks(ctx, *info)
+	if err != nil {
+		return nil, xerrors.Errorf("getting provider asks: %w", err)
+	}
+	asks := make([]storagemarket.StorageAsk, 0, len(signedAsks))
+	for _, signedAsk := range signedAsks {
+		asks = append(asks, *signedAsk.Ask)
+	}
+	ask, ok := storagemarket.SelectAsk(asks, pieceSize, duration)
+	if !ok {
+		ask = asks[0]
+	}
+	return &ask, nil
+}
+
 func (c *Client) GetPaymentEscrow(ctx context.Context, addr address.Address) (storagemarket.Balance, error) {
 	tok, _, err := c.node.GetChainHead(ctx)
 	if err != nil {
@@ -301,6 +656,13 @@ func (c *Client) SubscribeToEvents(subscriber storagemarket.ClientSubscriber) sh
 	return shared.Unsubscribe(c.pubSub.Subscribe(subscriber))
 }
 
+// recordEventArgs keeps the arguments of an event for the deal journal, if there is one
+func (c *Client) recordEventArgs(event fsm.EventName, state interface{}, args []interface{}) {
+	if c.journal != nil {
+		c.journal.AddArgs(shared.StorageClient, state.(*storagemarket.ClientDeal).ProposalCid.String(), event, args)
+	}
+}
+
 func (c *Client) dispatch(eventName fsm.EventName, deal fsm.StateType) {
 	evt, ok := eventName.(storagemarket.ClientEvent)
 	if !ok {
@@ -315,6 +677,26 @@ func (c *Client) dispatch(eventName fsm.EventName, deal fsm.StateType) {
 	if err := c.pubSub.Publish(pubSubEvt); err != nil {
 		log.Errorf("failed to publish event %d", evt)
 	}
+
+	c.dealMetrics.Observe(realDeal.ProposalCid.String(), storagemarket.ClientEvents[evt], storagemarket.DealStates[realDeal.State], clientstates.IsFinalityState(realDeal.State))
+
+	if realDeal.State == storagemarket.StorageDealError || realDeal.State == storagemarket.StorageDealSlashed {
+		c.replaceDealGroupDeal(realDeal.ProposalCid)
+	}
+
+	if c.journal != nil {
+		err := c.journal.Record(journal.Entry{
+			Market:  shared.StorageClient,
+			Deal:    realDeal.ProposalCid.String(),
+			Event:   storagemarket.ClientEvents[evt],
+			Args:    c.journal.TakeArgs(shared.StorageClient, realDeal.ProposalCid.String(), eventName),
+			State:   storagemarket.DealStates[realDeal.State],
+			Message: realDeal.Message,
+		})
+		if err != nil {
+			log.Errorf("failed to journal event %d: %s", evt, err)
+		}
+	}
 }
 
 type internalClientEvent struct {
@@ -386,6 +768,22 @@ func (c *clientDealEnvironment) CloseStream(proposalCid cid.Cid) error {
 	return c.c.conns.Disconnect(proposalCid)
 }
 
+func (c *clientDealEnvironment) GetProviderDealState(ctx context.Context, proposalCid cid.Cid) (*storagemarket.ProviderDealState, error) {
+	return c.c.GetProviderDealState(ctx, proposalCid)
+}
+
+func (c *clientDealEnvironment) PollingInterval() time.Duration {
+	return c.c.pollingInterval
+}
+
+func (c *clientDealEnvironment) CloseDataTransfer(proposalCid cid.Cid) {
+	dtutils.CloseDealTransfers(c.c.dataTransfer, proposalCid)
+}
+
+func (c *clientDealEnvironment) RetryPolicy() storagemarket.RetryPolicy {
+	return c.c.retryPolicy
+}
+
 func (c *clientDealEnvironment) StartDataTransfer(ctx context.Context, to peer.ID, voucher datatransfer.Voucher, baseCid cid.Cid, selector ipld.Node) error {
 	_, err := c.c.dataTransfer.OpenPushDataChannel(ctx, to, voucher, baseCid, selector)
 	return err
@@ -6,110 +6,248 @@ import (
 	"github.com/ipfs/go-cid"
 	"golang.org/x/xerrors"
 
+	"github.com/filecoin-project/go-fil-markets/journal"
 	"github.com/filecoin-project/go-fil-markets/storagemarket"
 )
 
 // ClientEvents are the events that can happen in a storage client
-var ClientEvents = fsm.Events{
-	fsm.Event(storagemarket.ClientEventOpen).
-		From(storagemarket.StorageDealUnknown).To(storagemarket.StorageDealEnsureClientFunds),
-	fsm.Event(storagemarket.ClientEventFundingInitiated).
-		From(storagemarket.StorageDealEnsureClientFunds).To(storagemarket.StorageDealClientFunding).
-		Action(func(deal *storagemarket.ClientDeal, mcid cid.Cid) error {
-			deal.AddFundsCid = &mcid
-			return nil
-		}),
-	fsm.Event(storagemarket.ClientEventEnsureFundsFailed).
-		FromMany(storagemarket.StorageDealClientFunding, storagemarket.StorageDealEnsureClientFunds).To(storagemarket.StorageDealFailing).
-		Action(func(deal *storagemarket.ClientDeal, err error) error {
-			deal.ConnectionClosed = true
-			deal.Message = xerrors.Errorf("adding market funds failed: %w", err).Error()
-			return nil
-		}),
-	fsm.Event(storagemarket.ClientEventFundsEnsured).
-		FromMany(storagemarket.StorageDealEnsureClientFunds, storagemarket.StorageDealClientFunding).To(storagemarket.StorageDealFundsEnsured),
-	fsm.Event(storagemarket.ClientEventWriteProposalFailed).
-		From(storagemarket.StorageDealFundsEnsured).To(storagemarket.StorageDealError).
-		Action(func(deal *storagemarket.ClientDeal, err error) error {
-			deal.Message = xerrors.Errorf("sending proposal to storage provider failed: %w", err).Error()
-			return nil
-		}),
-	fsm.Event(storagemarket.ClientEventDealProposed).
-		From(storagemarket.StorageDealFundsEnsured).To(storagemarket.StorageDealWaitingForDataRequest),
-	fsm.Event(storagemarket.ClientEventReadResponseFailed).
-		FromMany(storagemarket.StorageDealWaitingForDataRequest, storagemarket.StorageDealValidating).To(storagemarket.StorageDealError).
-		Action(func(deal *storagemarket.ClientDeal, err error) error {
-			deal.Message = xerrors.Errorf("error reading Response message: %w", err).Error()
-			return nil
-		}),
-	fsm.Event(storagemarket.ClientEventResponseVerificationFailed).
-		FromMany(storagemarket.StorageDealWaitingForDataRequest, storagemarket.StorageDealValidating).To(storagemarket.StorageDealFailing).
-		Action(func(deal *storagemarket.ClientDeal) error {
-			deal.Message = "unable to verify signature on deal response"
-			return nil
-		}),
-	fsm.Event(storagemarket.ClientEventUnexpectedDealState).
-		From(storagemarket.StorageDealWaitingForDataRequest).To(storagemarket.StorageDealFailing).
-		Action(func(deal *storagemarket.ClientDeal, status storagemarket.StorageDealStatus) error {
-			deal.Message = xerrors.Errorf("unexpected deal status while waiting for data request: %d", status).Error()
-			return nil
-		}),
-	fsm.Event(storagemarket.ClientEventDataTransferFailed).
-		FromMany(storagemarket.StorageDealWaitingForDataRequest, storagemarket.StorageDealTransferring).To(storagemarket.StorageDealFailing).
-		Action(func(deal *storagemarket.ClientDeal, err error) error {
-			deal.Message = xerrors.Errorf("failed to initiate data transfer: %w", err).Error()
-			return nil
-		}),
-	fsm.Event(storagemarket.ClientEventDataTransferInitiated).
-		From(storagemarket.StorageDealWaitingForDataRequest).To(storagemarket.StorageDealTransferring),
-	fsm.Event(storagemarket.ClientEventDataTransferComplete).
-		FromMany(storagemarket.StorageDealTransferring, storagemarket.StorageDealWaitingForDataRequest).To(storagemarket.StorageDealValidating),
-	fsm.Event(storagemarket.ClientEventResponseDealDidNotMatch).
-		From(storagemarket.StorageDealValidating).To(storagemarket.StorageDealFailing).
-		Action(func(deal *storagemarket.ClientDeal, responseCid cid.Cid, proposalCid cid.Cid) error {
-			deal.Message = xerrors.Errorf("miner responded to a wrong proposal: %s != %s", responseCid, proposalCid).Error()
-			return nil
-		}),
-	fsm.Event(storagemarket.ClientEventDealRejected).
-		From(storagemarket.StorageDealValidating).To(storagemarket.StorageDealFailing).
-		Action(func(deal *storagemarket.ClientDeal, state storagemarket.StorageDealStatus, reason string) error {
-			deal.Message = xerrors.Errorf("deal failed: (State=%d) %s", state, reason).Error()
-			return nil
-		}),
-	fsm.Event(storagemarket.ClientEventDealAccepted).
-		From(storagemarket.StorageDealValidating).To(storagemarket.StorageDealProposalAccepted).
-		Action(func(deal *storagemarket.ClientDeal, publishMessage *cid.Cid) error {
-			deal.PublishMessage = publishMessage
-			return nil
-		}),
-	fsm.Event(storagemarket.ClientEventStreamCloseError).
-		FromAny().To(storagemarket.StorageDealError).
-		Action(func(deal *storagemarket.ClientDeal, err error) error {
-			deal.Message = xerrors.Errorf("error attempting to close stream: %w", err).Error()
-			return nil
-		}),
-	fsm.Event(storagemarket.ClientEventDealPublishFailed).
-		From(storagemarket.StorageDealProposalAccepted).To(storagemarket.StorageDealError).
-		Action(func(deal *storagemarket.ClientDeal, err error) error {
-			deal.Message = xerrors.Errorf("error validating deal published: %w", err).Error()
-			return nil
-		}),
-	fsm.Event(storagemarket.ClientEventDealPublished).
-		From(storagemarket.StorageDealProposalAccepted).To(storagemarket.StorageDealSealing).
-		Action(func(deal *storagemarket.ClientDeal, dealID abi.DealID) error {
-			deal.DealID = dealID
-			return nil
-		}),
-	fsm.Event(storagemarket.ClientEventDealActivationFailed).
-		From(storagemarket.StorageDealSealing).To(storagemarket.StorageDealError).
-		Action(func(deal *storagemarket.ClientDeal, err error) error {
-			deal.Message = xerrors.Errorf("error in deal activation: %w", err).Error()
-			return nil
-		}),
-	fsm.Event(storagemarket.ClientEventDealActivated).
-		From(storagemarket.StorageDealSealing).To(storagemarket.StorageDealActive),
-	fsm.Event(storagemarket.ClientEventFailed).
-		From(storagemarket.StorageDealFailing).To(storagemarket.StorageDealError),
+var ClientEvents = NewClientEvents(nil)
+
+// NewClientEvents returns the events that can happen in a storage client, with the
+// action of each event wrapped by wrap, if it is set
+func NewClientEvents(wrap journal.ActionWrapper) fsm.Events {
+	event := func(name fsm.EventName) journal.EventBuilder {
+		return journal.Event(name, wrap)
+	}
+	return journal.Events{
+		event(storagemarket.ClientEventOpen).
+			From(storagemarket.StorageDealUnknown).To(storagemarket.StorageDealEnsureClientFunds),
+		event(storagemarket.ClientEventFundingInitiated).
+			From(storagemarket.StorageDealEnsureClientFunds).To(storagemarket.StorageDealClientFunding).
+			Action(func(deal *storagemarket.ClientDeal, mcid cid.Cid) error {
+				deal.AddFundsCid = &mcid
+				return nil
+			}),
+		event(storagemarket.ClientEventEnsureFundsFailed).
+			FromMany(storagemarket.StorageDealClientFunding, storagemarket.StorageDealEnsureClientFunds).To(storagemarket.StorageDealFailing).
+			Action(func(deal *storagemarket.ClientDeal, err error) error {
+				deal.ConnectionClosed = true
+				deal.Message = xerrors.Errorf("adding market funds failed: %w", err).Error()
+				return nil
+			}),
+		event(storagemarket.ClientEventNodeErrorRetry).
+			FromMany(clientRetryableStates...).ToNoChange().
+			Action(func(deal *storagemarket.ClientDeal, err error) error {
+				deal.Retries++
+				return nil
+			}),
+		event(storagemarket.ClientEventFundsEnsured).
+			FromMany(storagemarket.StorageDealEnsureClientFunds, storagemarket.StorageDealClientFunding).To(storagemarket.StorageDealFundsEnsured),
+		event(storagemarket.ClientEventWriteProposalFailed).
+			From(storagemarket.StorageDealFundsEnsured).To(storagemarket.StorageDealError).
+			Action(func(deal *storagemarket.ClientDeal, err error) error {
+				deal.Message = xerrors.Errorf("sending proposal to storage provider failed: %w", err).Error()
+				return nil
+			}),
+		event(storagemarket.ClientEventDealProposed).
+			From(storagemarket.StorageDealFundsEnsured).To(storagemarket.StorageDealWaitingForDataRequest),
+		event(storagemarket.ClientEventReadResponseFailed).
+			FromMany(storagemarket.StorageDealWaitingForDataRequest, storagemarket.StorageDealValidating).To(storagemarket.StorageDealError).
+			Action(func(deal *storagemarket.ClientDeal, err error) error {
+				deal.Message = xerrors.Errorf("error reading Response message: %w", err).Error()
+				return nil
+			}),
+		event(storagemarket.ClientEventResponseVerificationFailed).
+			FromMany(storagemarket.StorageDealWaitingForDataRequest, storagemarket.StorageDealValidating).To(storagemarket.StorageDealFailing).
+			Action(func(deal *storagemarket.ClientDeal) error {
+				deal.Message = "unable to verify signature on deal response"
+				return nil
+			}),
+		event(storagemarket.ClientEventUnexpectedDealState).
+			From(storagemarket.StorageDealWaitingForDataRequest).To(storagemarket.StorageDealFailing).
+			Action(func(deal *storagemarket.ClientDeal, status storagemarket.StorageDealStatus) error {
+				deal.Message = xerrors.Errorf("unexpected deal status while waiting for data request: %d", status).Error()
+				return nil
+			}),
+		event(storagemarket.ClientEventDataTransferFailed).
+			FromMany(storagemarket.StorageDealWaitingForDataRequest, storagemarket.StorageDealTransferring).To(storagemarket.StorageDealFailing).
+			Action(func(deal *storagemarket.ClientDeal, err error) error {
+				deal.Message = xerrors.Errorf("failed to initiate data transfer: %w", err).Error()
+				return nil
+			}),
+		event(storagemarket.ClientEventDataTransferInitiated).
+			From(storagemarket.StorageDealWaitingForDataRequest).To(storagemarket.StorageDealTransferring),
+		event(storagemarket.ClientEventDataTransferComplete).
+			FromMany(storagemarket.StorageDealTransferring, storagemarket.StorageDealWaitingForDataRequest).To(storagemarket.StorageDealValidating),
+		event(storagemarket.ClientEventResponseDealDidNotMatch).
+			From(storagemarket.StorageDealValidating).To(storagemarket.StorageDealFailing).
+			Action(func(deal *storagemarket.ClientDeal, responseCid cid.Cid, proposalCid cid.Cid) error {
+				deal.Message = xerrors.Errorf("miner responded to a wrong proposal: %s != %s", responseCid, proposalCid).Error()
+				return nil
+			}),
+		event(storagemarket.ClientEventDealRejected).
+			FromMany(storagemarket.StorageDealValidating, storagemarket.StorageDealCheckForAcceptance).To(storagemarket.StorageDealFailing).
+			Action(func(deal *storagemarket.ClientDeal, state storagemarket.StorageDealStatus, reason string, code storagemarket.RejectionReason) error {
+				deal.Message = xerrors.Errorf("deal failed: (State=%d) %s", state, reason).Error()
+				// providers that predate rejection reasons do not send one
+				if code == storagemarket.RejectionNone {
+					code = storagemarket.RejectionUnspecified
+				}
+				deal.RejectionReason = code
+				return nil
+			}),
+		event(storagemarket.ClientEventDealAccepted).
+			FromMany(storagemarket.StorageDealValidating, storagemarket.StorageDealCheckForAcceptance).To(storagemarket.StorageDealProposalAccepted).
+			Action(func(deal *storagemarket.ClientDeal, publishMessage *cid.Cid) error {
+				deal.PublishMessage = publishMessage
+				return nil
+			}),
+		event(storagemarket.ClientEventWaitForDealState).
+			From(storagemarket.StorageDealCheckForAcceptance).ToNoChange(),
+		event(storagemarket.ClientEventDealAcceptanceExpired).
+			From(storagemarket.StorageDealCheckForAcceptance).To(storagemarket.StorageDealFailing).
+			Action(func(deal *storagemarket.ClientDeal, height abi.ChainEpoch) error {
+				deal.Message = xerrors.Errorf("provider did not publish deal by its start epoch %d (current epoch %d)", deal.Proposal.StartEpoch, height).Error()
+				return nil
+			}),
+		event(storagemarket.ClientEventStreamCloseError).
+			FromAny().To(storagemarket.StorageDealError).
+			Action(func(deal *storagemarket.ClientDeal, err error) error {
+				deal.Message = xerrors.Errorf("error attempting to close stream: %w", err).Error()
+				return nil
+			}),
+		event(storagemarket.ClientEventDealPublishFailed).
+			From(storagemarket.StorageDealProposalAccepted).To(storagemarket.StorageDealError).
+			Action(func(deal *storagemarket.ClientDeal, err error) error {
+				deal.Message = xerrors.Errorf("error validating deal published: %w", err).Error()
+				return nil
+			}),
+		event(storagemarket.ClientEventDealPublished).
+			From(storagemarket.StorageDealProposalAccepted).To(storagemarket.StorageDealSealing).
+			Action(func(deal *storagemarket.ClientDeal, dealID abi.DealID) error {
+				deal.DealID = dealID
+				return nil
+			}),
+		event(storagemarket.ClientEventDealActivationFailed).
+			From(storagemarket.StorageDealSealing).To(storagemarket.StorageDealError).
+			Action(func(deal *storagemarket.ClientDeal, err error) error {
+				deal.Message = xerrors.Errorf("error in deal activation: %w", err).Error()
+				return nil
+			}),
+		event(storagemarket.ClientEventDealActivated).
+			From(storagemarket.StorageDealSealing).To(storagemarket.StorageDealActive),
+		event(storagemarket.ClientEventDealExpired).From(storagemarket.StorageDealActive).To(storagemarket.StorageDealExpired),
+		event(storagemarket.ClientEventDealSlashed).
+			From(storagemarket.StorageDealActive).To(storagemarket.StorageDealSlashed).
+			Action(func(deal *storagemarket.ClientDeal, slashEpoch abi.ChainEpoch) error {
+				deal.SlashEpoch = slashEpoch
+				return nil
+			}),
+		event(storagemarket.ClientEventDealCompletionFailed).
+			From(storagemarket.StorageDealActive).To(storagemarket.StorageDealError).
+			Action(func(deal *storagemarket.ClientDeal, err error) error {
+				deal.Message = xerrors.Errorf("error waiting for deal completion: %w", err).Error()
+				return nil
+			}),
+		event(storagemarket.ClientEventFailed).
+			From(storagemarket.StorageDealFailing).To(storagemarket.StorageDealError),
+		event(storagemarket.ClientEventRestart).
+			FromMany(clientResumableStates...).ToNoChange(),
+		event(storagemarket.ClientEventStreamLost).
+			FromMany(
+				storagemarket.StorageDealWaitingForDataRequest,
+				storagemarket.StorageDealTransferring,
+				storagemarket.StorageDealValidating,
+			).To(storagemarket.StorageDealCheckForAcceptance).
+			From(storagemarket.StorageDealFailing).ToNoChange().
+			Action(func(deal *storagemarket.ClientDeal) error {
+				deal.ConnectionClosed = true
+				return nil
+			}),
+		event(storagemarket.ClientEventCancelled).
+			FromMany(clientCancellableStates...).To(storagemarket.StorageDealCancelled).
+			// the provider closes the deal stream and data transfer when it cancels the deal, which
+			// can fail the deal before the provider's confirmation of the cancellation is read
+			FromMany(storagemarket.StorageDealFailing, storagemarket.StorageDealError).To(storagemarket.StorageDealCancelled).
+			Action(func(deal *storagemarket.ClientDeal, reason string) error {
+				deal.Message = "deal cancelled by client: " + reason
+				return nil
+			}),
+	}.Build()
+}
+
+// ClientFinalityStates are the states that terminate deal processing for a deal.
+// When a client restarts, it restarts only deals that are not in a finality state.
+var ClientFinalityStates = []fsm.StateKey{
+	storagemarket.StorageDealError,
+	storagemarket.StorageDealCancelled,
+	storagemarket.StorageDealExpired,
+	storagemarket.StorageDealSlashed,
+}
+
+// clientResumableStates are the states from which a deal can continue processing
+// after a restart, because they do not depend on an open stream to the provider
+var clientResumableStates = []fsm.StateKey{
+	storagemarket.StorageDealEnsureClientFunds,
+	storagemarket.StorageDealClientFunding,
+	storagemarket.StorageDealFundsEnsured,
+	storagemarket.StorageDealCheckForAcceptance,
+	storagemarket.StorageDealProposalAccepted,
+	storagemarket.StorageDealSealing,
+	storagemarket.StorageDealActive,
+}
+
+// clientStreamStates are the states in which a deal holds an open stream to the provider
+var clientStreamStates = []fsm.StateKey{
+	storagemarket.StorageDealWaitingForDataRequest,
+	storagemarket.StorageDealTransferring,
+	storagemarket.StorageDealValidating,
+	storagemarket.StorageDealFailing,
+}
+
+// clientRetryableStates are the states whose node calls are retried after transient errors
+var clientRetryableStates = []fsm.StateKey{
+	storagemarket.StorageDealEnsureClientFunds,
+	storagemarket.StorageDealClientFunding,
+	storagemarket.StorageDealProposalAccepted,
+}
+
+// clientCancellableStates are the states a deal can be cancelled from, before the
+// provider has accepted it for publishing
+var clientCancellableStates = []fsm.StateKey{
+	storagemarket.StorageDealEnsureClientFunds,
+	storagemarket.StorageDealClientFunding,
+	storagemarket.StorageDealFundsEnsured,
+	storagemarket.StorageDealWaitingForDataRequest,
+	storagemarket.StorageDealTransferring,
+	storagemarket.StorageDealValidating,
+	storagemarket.StorageDealCheckForAcceptance,
+}
+
+// IsFinalityState returns true if a deal in the given state is no longer processed
+func IsFinalityState(state storagemarket.StorageDealStatus) bool {
+	return containsState(ClientFinalityStates, state)
+}
+
+// HasOpenStream returns true if a deal in the given state holds a stream to the provider,
+// which does not survive a restart of the client
+func HasOpenStream(state storagemarket.StorageDealStatus) bool {
+	return containsState(clientStreamStates, state)
+}
+
+// CanCancel returns true if a deal in the given state has not yet been accepted for
+// publishing and can still be cancelled
+func CanCancel(state storagemarket.StorageDealStatus) bool {
+	return containsState(clientCancellableStates, state)
+}
+
+func containsState(states []fsm.StateKey, state storagemarket.StorageDealStatus) bool {
+	for _, s := range states {
+		if s == state {
+			return true
+		}
+	}
+	return false
 }
 
 // ClientStateEntryFuncs are the handlers for different states in a storage client
@@ -119,7 +257,10 @@ var ClientStateEntryFuncs = fsm.StateEntryFuncs{
 	storagemarket.StorageDealFundsEnsured:          ProposeDeal,
 	storagemarket.StorageDealWaitingForDataRequest: WaitingForDataRequest,
 	storagemarket.StorageDealValidating:            VerifyDealResponse,
+	storagemarket.StorageDealCheckForAcceptance:    CheckForDealAcceptance,
 	storagemarket.StorageDealProposalAccepted:      ValidateDealPublished,
 	storagemarket.StorageDealSealing:               VerifyDealActivated,
 	storagemarket.StorageDealFailing:               FailDeal,
+	storagemarket.StorageDealCancelled:             CancelDeal,
+	storagemarket.StorageDealActive:                WaitForDealCompletion,
 }
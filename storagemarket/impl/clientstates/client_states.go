@@ -2,9 +2,11 @@ package clientstates
 
 import (
 	"context"
+	"time"
 
 	datatransfer "github.com/filecoin-project/go-data-transfer"
 	"github.com/filecoin-project/go-statemachine/fsm"
+	"github.com/filecoin-project/specs-actors/actors/abi"
 	"github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
 	"github.com/ipfs/go-cid"
 	logging "github.com/ipfs/go-log/v2"
@@ -30,24 +32,50 @@ type ClientDealEnvironment interface {
 	ReadDealResponse(proposalCid cid.Cid) (network.SignedResponse, error)
 	CloseStream(proposalCid cid.Cid) error
 	StartDataTransfer(ctx context.Context, to peer.ID, voucher datatransfer.Voucher, baseCid cid.Cid, selector ipld.Node) error
+	CloseDataTransfer(proposalCid cid.Cid)
+	GetProviderDealState(ctx context.Context, proposalCid cid.Cid) (*storagemarket.ProviderDealState, error)
+	PollingInterval() time.Duration
+	RetryPolicy() storagemarket.RetryPolicy
 }
 
 // ClientStateEntryFunc is the type for all state entry functions on a storage client
 type ClientStateEntryFunc func(ctx fsm.Context, environment ClientDealEnvironment, deal storagemarket.ClientDeal) error
 
+// nodeErrored enters the current state again after a backoff if a node call failed with
+// a transient error and the retry policy allows it, and otherwise triggers the given failure event
+func nodeErrored(ctx fsm.Context, environment ClientDealEnvironment, deal storagemarket.ClientDeal, failEvent storagemarket.ClientEvent, err error) error {
+	delay, retry := environment.RetryPolicy().Backoff(deal.Retries, err)
+	if !retry {
+		return ctx.Trigger(failEvent, err)
+	}
+
+	log.Warnf("deal %s: retrying in %s after node error: %s", deal.ProposalCid, delay, err)
+	if delay <= 0 {
+		return ctx.Trigger(storagemarket.ClientEventNodeErrorRetry, err)
+	}
+	go func() {
+		select {
+		case <-ctx.Context().Done():
+		case <-time.After(delay):
+			_ = ctx.Trigger(storagemarket.ClientEventNodeErrorRetry, err)
+		}
+	}()
+	return nil
+}
+
 // EnsureClientFunds attempts to ensure the client has enough funds for the deal being proposed
 func EnsureClientFunds(ctx fsm.Context, environment ClientDealEnvironment, deal storagemarket.ClientDeal) error {
 	node := environment.Node()
 
 	tok, _, err := node.GetChainHead(ctx.Context())
 	if err != nil {
-		return ctx.Trigger(storagemarket.ClientEventEnsureFundsFailed, xerrors.Errorf("acquiring chain head: %w", err))
+		return nodeErrored(ctx, environment, deal, storagemarket.ClientEventEnsureFundsFailed, xerrors.Errorf("acquiring chain head: %w", err))
 	}
 
 	mcid, err := node.EnsureFunds(ctx.Context(), deal.Proposal.Client, deal.Proposal.Client, deal.Proposal.ClientBalanceRequirement(), tok)
 
 	if err != nil {
-		return ctx.Trigger(storagemarket.ClientEventEnsureFundsFailed, err)
+		return nodeErrored(ctx, environment, deal, storagemarket.ClientEventEnsureFundsFailed, err)
 	}
 
 	// if no message was sent, and there was no error, funds were already available
@@ -64,7 +92,7 @@ func WaitForFunding(ctx fsm.Context, environment ClientDealEnvironment, deal sto
 
 	return node.WaitForMessage(ctx.Context(), *deal.AddFundsCid, func(code exitcode.ExitCode, bytes []byte, err error) error {
 		if err != nil {
-			return ctx.Trigger(storagemarket.ClientEventEnsureFundsFailed, xerrors.Errorf("AddFunds err: %w", err))
+			return nodeErrored(ctx, environment, deal, storagemarket.ClientEventEnsureFundsFailed, xerrors.Errorf("AddFunds err: %w", err))
 		}
 		if code != exitcode.Ok {
 			return ctx.Trigger(storagemarket.ClientEventEnsureFundsFailed, xerrors.Errorf("AddFunds exit code: %s", code.String()))
@@ -77,7 +105,7 @@ func WaitForFunding(ctx fsm.Context, environment ClientDealEnvironment, deal sto
 // ProposeDeal sends the deal proposal to the provider
 func ProposeDeal(ctx fsm.Context, environment ClientDealEnvironment, deal storagemarket.ClientDeal) error {
 
-	proposal := network.Proposal{DealProposal: &deal.ClientDealProposal, Piece: deal.DataRef}
+	proposal := network.Proposal{DealProposal: &deal.ClientDealProposal, Piece: deal.DataRef, Labels: deal.Labels}
 	if err := environment.WriteDealProposal(deal.Miner, deal.ProposalCid, proposal); err != nil {
 		return ctx.Trigger(storagemarket.ClientEventWriteProposalFailed, err)
 	}
@@ -156,7 +184,7 @@ func VerifyDealResponse(ctx fsm.Context, environment ClientDealEnvironment, deal
 	}
 
 	if resp.Response.State != storagemarket.StorageDealProposalAccepted {
-		return ctx.Trigger(storagemarket.ClientEventDealRejected, resp.Response.State, resp.Response.Message)
+		return ctx.Trigger(storagemarket.ClientEventDealRejected, resp.Response.State, resp.Response.Message, resp.Response.Reason)
 	}
 
 	if err := environment.CloseStream(deal.ProposalCid); err != nil {
@@ -166,12 +194,55 @@ func VerifyDealResponse(ctx fsm.Context, environment ClientDealEnvironment, deal
 	return ctx.Trigger(storagemarket.ClientEventDealAccepted, resp.Response.PublishMessage)
 }
 
+// CheckForDealAcceptance queries the provider for the state of a deal whose stream was lost,
+// and waits until the provider has either published or rejected the deal. A deal the provider
+// has not published by its start epoch can no longer be published, and fails
+func CheckForDealAcceptance(ctx fsm.Context, environment ClientDealEnvironment, deal storagemarket.ClientDeal) error {
+	dealState, err := environment.GetProviderDealState(ctx.Context(), deal.ProposalCid)
+	if err != nil {
+		log.Warnf("error querying provider deal state for deal %s: %s", deal.ProposalCid, err)
+		return waitForDealState(ctx, environment, deal)
+	}
+
+	switch dealState.State {
+	case storagemarket.StorageDealProposalNotFound, storagemarket.StorageDealProposalRejected,
+		storagemarket.StorageDealFailing, storagemarket.StorageDealError:
+		return ctx.Trigger(storagemarket.ClientEventDealRejected, dealState.State, dealState.Message, storagemarket.RejectionUnspecified)
+	}
+
+	if dealState.PublishCid != nil {
+		return ctx.Trigger(storagemarket.ClientEventDealAccepted, dealState.PublishCid)
+	}
+
+	return waitForDealState(ctx, environment, deal)
+}
+
+// waitForDealState schedules another check of the provider deal state after the polling
+// interval, unless the deal's start epoch has passed
+func waitForDealState(ctx fsm.Context, environment ClientDealEnvironment, deal storagemarket.ClientDeal) error {
+	_, height, err := environment.Node().GetChainHead(ctx.Context())
+	if err != nil {
+		log.Warnf("error getting chain head while waiting for deal %s: %s", deal.ProposalCid, err)
+	} else if height >= deal.Proposal.StartEpoch {
+		return ctx.Trigger(storagemarket.ClientEventDealAcceptanceExpired, height)
+	}
+
+	go func() {
+		select {
+		case <-ctx.Context().Done():
+		case <-time.After(environment.PollingInterval()):
+			_ = ctx.Trigger(storagemarket.ClientEventWaitForDealState)
+		}
+	}()
+	return nil
+}
+
 // ValidateDealPublished confirms with the chain that a deal was published
 func ValidateDealPublished(ctx fsm.Context, environment ClientDealEnvironment, deal storagemarket.ClientDeal) error {
 
 	dealID, err := environment.Node().ValidatePublishedDeal(ctx.Context(), deal)
 	if err != nil {
-		return ctx.Trigger(storagemarket.ClientEventDealPublishFailed, err)
+		return nodeErrored(ctx, environment, deal, storagemarket.ClientEventDealPublishFailed, err)
 	}
 
 	return ctx.Trigger(storagemarket.ClientEventDealPublished, dealID)
@@ -194,6 +265,31 @@ func VerifyDealActivated(ctx fsm.Context, environment ClientDealEnvironment, dea
 	return nil
 }
 
+// WaitForDealCompletion waits for an active deal to expire or be slashed
+func WaitForDealCompletion(ctx fsm.Context, environment ClientDealEnvironment, deal storagemarket.ClientDeal) error {
+	onDealExpired := func(err error) {
+		if err != nil {
+			_ = ctx.Trigger(storagemarket.ClientEventDealCompletionFailed, xerrors.Errorf("expiry err: %w", err))
+		} else {
+			_ = ctx.Trigger(storagemarket.ClientEventDealExpired)
+		}
+	}
+
+	onDealSlashed := func(slashEpoch abi.ChainEpoch, err error) {
+		if err != nil {
+			_ = ctx.Trigger(storagemarket.ClientEventDealCompletionFailed, xerrors.Errorf("slashing err: %w", err))
+		} else {
+			_ = ctx.Trigger(storagemarket.ClientEventDealSlashed, slashEpoch)
+		}
+	}
+
+	err := environment.Node().OnDealExpiredOrSlashed(ctx.Context(), deal.DealID, onDealExpired, onDealSlashed)
+	if err != nil {
+		return ctx.Trigger(storagemarket.ClientEventDealCompletionFailed, err)
+	}
+	return nil
+}
+
 // FailDeal cleans up a failing deal
 func FailDeal(ctx fsm.Context, environment ClientDealEnvironment, deal storagemarket.ClientDeal) error {
 
@@ -203,8 +299,25 @@ func FailDeal(ctx fsm.Context, environment ClientDealEnvironment, deal storagema
 		}
 	}
 
-	// TODO: store in some sort of audit log
+	// the ClientEventFailed event and its message are also recorded in the deal journal, if the client has one
 	log.Errorf("deal %s failed: %s", deal.ProposalCid, deal.Message)
 
 	return ctx.Trigger(storagemarket.ClientEventFailed)
 }
+
+// CancelDeal stops sending data for a deal the client cancelled and closes its stream to
+// the provider
+func CancelDeal(ctx fsm.Context, environment ClientDealEnvironment, deal storagemarket.ClientDeal) error {
+
+	environment.CloseDataTransfer(deal.ProposalCid)
+
+	if !deal.ConnectionClosed {
+		// there is no stream if the deal was cancelled before it was proposed
+		if err := environment.CloseStream(deal.ProposalCid); err != nil {
+			log.Debugf("closing stream for cancelled deal %s: %s", deal.ProposalCid, err)
+		}
+	}
+
+	log.Infof("deal %s cancelled: %s", deal.ProposalCid, deal.Message)
+	return nil
+}
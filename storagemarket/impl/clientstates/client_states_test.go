@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/filecoin-project/go-address"
 	cborutil "github.com/filecoin-project/go-cbor-util"
@@ -18,6 +19,7 @@ import (
 	"github.com/ipld/go-ipld-prime"
 	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	tut "github.com/filecoin-project/go-fil-markets/shared_testutil"
 	"github.com/filecoin-project/go-fil-markets/storagemarket"
@@ -55,6 +57,31 @@ func TestEnsureFunds(t *testing.T) {
 			},
 		})
 	})
+	t.Run("EnsureClientFunds transient error is retried", func(t *testing.T) {
+		runAndInspect(t, storagemarket.StorageDealEnsureClientFunds, clientstates.EnsureClientFunds, testCase{
+			nodeParams: nodeParams{
+				EnsureFundsError: storagemarket.NewTransientError(errors.New("rpc timeout")),
+			},
+			envParams: envParams{retryPolicy: storagemarket.RetryPolicy{MaxRetries: 1}},
+			inspector: func(deal storagemarket.ClientDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealEnsureClientFunds, deal.State)
+				assert.Equal(t, uint64(1), deal.Retries)
+			},
+		})
+	})
+	t.Run("EnsureClientFunds transient error fails when out of retries", func(t *testing.T) {
+		runAndInspect(t, storagemarket.StorageDealEnsureClientFunds, clientstates.EnsureClientFunds, testCase{
+			nodeParams: nodeParams{
+				EnsureFundsError: storagemarket.NewTransientError(errors.New("rpc timeout")),
+			},
+			envParams:   envParams{retryPolicy: storagemarket.RetryPolicy{MaxRetries: 1}},
+			stateParams: dealStateParams{retries: 1},
+			inspector: func(deal storagemarket.ClientDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealFailing, deal.State)
+				assert.Equal(t, "adding market funds failed: rpc timeout", deal.Message)
+			},
+		})
+	})
 }
 
 func TestWaitForFunding(t *testing.T) {
@@ -237,6 +264,26 @@ func TestVerifyDealResponse(t *testing.T) {
 
 				tut.AssertDealState(t, storagemarket.StorageDealFailing, deal.State)
 				assert.Equal(t, deal.Message, expErr)
+				assert.Equal(t, storagemarket.RejectionUnspecified, deal.RejectionReason)
+			},
+		})
+	})
+	t.Run("deal rejected with reason", func(t *testing.T) {
+		runAndInspect(t, storagemarket.StorageDealValidating, clientstates.VerifyDealResponse, testCase{
+			envParams: envParams{dealStream: testResponseStream(t, responseParams{
+				proposal: clientDealProposal,
+				state:    storagemarket.StorageDealFailing,
+				message:  "deal rejected: storage price per epoch less than asking price",
+				reason:   storagemarket.RejectionPriceTooLow,
+			})},
+			inspector: func(deal storagemarket.ClientDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealFailing, deal.State)
+				assert.Equal(t, storagemarket.RejectionPriceTooLow, deal.RejectionReason)
+
+				var rejection *storagemarket.DealRejectedError
+				require.True(t, errors.As(deal.RejectionError(), &rejection))
+				assert.Equal(t, storagemarket.RejectionPriceTooLow, rejection.Reason)
+				assert.Equal(t, deal.Message, rejection.Details)
 			},
 		})
 	})
@@ -254,6 +301,77 @@ func TestVerifyDealResponse(t *testing.T) {
 	})
 }
 
+func TestCheckForDealAcceptance(t *testing.T) {
+	t.Run("provider published the deal", func(t *testing.T) {
+		publishCid := tut.GenerateCids(1)[0]
+		runAndInspect(t, storagemarket.StorageDealCheckForAcceptance, clientstates.CheckForDealAcceptance, testCase{
+			envParams: envParams{
+				providerDealState: &storagemarket.ProviderDealState{
+					State:      storagemarket.StorageDealSealing,
+					PublishCid: &publishCid,
+				},
+			},
+			inspector: func(deal storagemarket.ClientDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealProposalAccepted, deal.State)
+				assert.Equal(t, &publishCid, deal.PublishMessage)
+				assert.Equal(t, []cid.Cid{deal.ProposalCid}, env.getDealStateCalls)
+			},
+		})
+	})
+	t.Run("provider rejected the deal", func(t *testing.T) {
+		runAndInspect(t, storagemarket.StorageDealCheckForAcceptance, clientstates.CheckForDealAcceptance, testCase{
+			envParams: envParams{
+				providerDealState: &storagemarket.ProviderDealState{
+					State:   storagemarket.StorageDealFailing,
+					Message: "because",
+				},
+			},
+			inspector: func(deal storagemarket.ClientDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealFailing, deal.State)
+				assert.Equal(t, fmt.Sprintf("deal failed: (State=%d) because", storagemarket.StorageDealFailing), deal.Message)
+			},
+		})
+	})
+	t.Run("provider has not published the deal yet", func(t *testing.T) {
+		runAndInspect(t, storagemarket.StorageDealCheckForAcceptance, clientstates.CheckForDealAcceptance, testCase{
+			envParams: envParams{
+				providerDealState: &storagemarket.ProviderDealState{
+					State: storagemarket.StorageDealPublish,
+				},
+				pollingInterval: time.Hour,
+			},
+			inspector: func(deal storagemarket.ClientDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealCheckForAcceptance, deal.State)
+			},
+		})
+	})
+	t.Run("querying the provider fails", func(t *testing.T) {
+		runAndInspect(t, storagemarket.StorageDealCheckForAcceptance, clientstates.CheckForDealAcceptance, testCase{
+			envParams: envParams{
+				getDealStateErr: errors.New("provider offline"),
+				pollingInterval: time.Hour,
+			},
+			inspector: func(deal storagemarket.ClientDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealCheckForAcceptance, deal.State)
+				assert.Len(t, env.getDealStateCalls, 1)
+			},
+		})
+	})
+	t.Run("provider does not publish the deal by its start epoch", func(t *testing.T) {
+		runAndInspect(t, storagemarket.StorageDealCheckForAcceptance, clientstates.CheckForDealAcceptance, testCase{
+			nodeParams: nodeParams{ChainHeadEpoch: clientDealProposal.Proposal.StartEpoch},
+			envParams: envParams{
+				getDealStateErr: errors.New("provider offline"),
+				pollingInterval: time.Hour,
+			},
+			inspector: func(deal storagemarket.ClientDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealFailing, deal.State)
+				assert.Equal(t, fmt.Sprintf("provider did not publish deal by its start epoch %d (current epoch %d)", deal.Proposal.StartEpoch, deal.Proposal.StartEpoch), deal.Message)
+			},
+		})
+	})
+}
+
 func TestValidateDealPublished(t *testing.T) {
 	t.Run("succeeds", func(t *testing.T) {
 		runAndInspect(t, storagemarket.StorageDealProposalAccepted, clientstates.ValidateDealPublished, testCase{
@@ -306,6 +424,54 @@ func TestVerifyDealActivated(t *testing.T) {
 	})
 }
 
+func TestWaitForDealCompletion(t *testing.T) {
+	t.Run("waits while deal is active", func(t *testing.T) {
+		runAndInspect(t, storagemarket.StorageDealActive, clientstates.WaitForDealCompletion, testCase{
+			inspector: func(deal storagemarket.ClientDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealActive, deal.State)
+			},
+		})
+	})
+	t.Run("deal expires", func(t *testing.T) {
+		runAndInspect(t, storagemarket.StorageDealActive, clientstates.WaitForDealCompletion, testCase{
+			nodeParams: nodeParams{DealExpired: true},
+			inspector: func(deal storagemarket.ClientDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealExpired, deal.State)
+			},
+		})
+	})
+	t.Run("deal is slashed", func(t *testing.T) {
+		runAndInspect(t, storagemarket.StorageDealActive, clientstates.WaitForDealCompletion, testCase{
+			nodeParams: nodeParams{DealSlashedEpoch: abi.ChainEpoch(100)},
+			inspector: func(deal storagemarket.ClientDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealSlashed, deal.State)
+				assert.Equal(t, abi.ChainEpoch(100), deal.SlashEpoch)
+			},
+		})
+	})
+	t.Run("fails synchronously", func(t *testing.T) {
+		runAndInspect(t, storagemarket.StorageDealActive, clientstates.WaitForDealCompletion, testCase{
+			nodeParams: nodeParams{OnDealExpiredOrSlashedError: errors.New("Something went wrong")},
+			inspector: func(deal storagemarket.ClientDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealError, deal.State)
+				assert.Equal(t, "error waiting for deal completion: Something went wrong", deal.Message)
+			},
+		})
+	})
+	t.Run("fails asynchronously", func(t *testing.T) {
+		runAndInspect(t, storagemarket.StorageDealActive, clientstates.WaitForDealCompletion, testCase{
+			nodeParams: nodeParams{
+				DealSlashedEpoch:    abi.ChainEpoch(100),
+				DealCompletionError: errors.New("Something went wrong later"),
+			},
+			inspector: func(deal storagemarket.ClientDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealError, deal.State)
+				assert.Equal(t, "error waiting for deal completion: slashing err: Something went wrong later", deal.Message)
+			},
+		})
+	})
+}
+
 func TestFailDeal(t *testing.T) {
 	t.Run("closes an open stream", func(t *testing.T) {
 		runAndInspect(t, storagemarket.StorageDealFailing, clientstates.FailDeal, testCase{
@@ -336,16 +502,111 @@ func TestFailDeal(t *testing.T) {
 	})
 }
 
+func TestCancelDeal(t *testing.T) {
+	t.Run("closes the data transfer and stream", func(t *testing.T) {
+		runAndInspect(t, storagemarket.StorageDealCancelled, clientstates.CancelDeal, testCase{
+			stateParams: dealStateParams{connectionClosed: false},
+			inspector: func(deal storagemarket.ClientDeal, env *fakeEnvironment) {
+				assert.Equal(t, storagemarket.StorageDealCancelled, deal.State)
+				assert.Equal(t, []cid.Cid{deal.ProposalCid}, env.closedTransfers)
+				assert.Equal(t, []cid.Cid{deal.ProposalCid}, env.closeStreamCalls)
+			},
+		})
+	})
+	t.Run("stays cancelled when there is no stream to close", func(t *testing.T) {
+		runAndInspect(t, storagemarket.StorageDealCancelled, clientstates.CancelDeal, testCase{
+			stateParams: dealStateParams{connectionClosed: false},
+			envParams:   envParams{closeStreamErr: errors.New("no connection to provider")},
+			inspector: func(deal storagemarket.ClientDeal, env *fakeEnvironment) {
+				assert.Equal(t, storagemarket.StorageDealCancelled, deal.State)
+			},
+		})
+	})
+}
+
+func TestCancelEvent(t *testing.T) {
+	eventProcessor, err := fsm.NewEventProcessor(storagemarket.ClientDeal{}, "State", clientstates.ClientEvents)
+	assert.NoError(t, err)
+
+	deal := &storagemarket.ClientDeal{State: storagemarket.StorageDealTransferring}
+	fsmCtx := fsmtest.NewTestContext(context.Background(), eventProcessor)
+	assert.NoError(t, fsmCtx.Trigger(storagemarket.ClientEventCancelled, "found a cheaper provider"))
+	fsmCtx.ReplayEvents(t, deal)
+	tut.AssertDealState(t, storagemarket.StorageDealCancelled, deal.State)
+	assert.Equal(t, "deal cancelled by client: found a cheaper provider", deal.Message)
+
+	// the provider tearing down the deal can fail it before its confirmation is read
+	deal = &storagemarket.ClientDeal{State: storagemarket.StorageDealError}
+	fsmCtx = fsmtest.NewTestContext(context.Background(), eventProcessor)
+	assert.NoError(t, fsmCtx.Trigger(storagemarket.ClientEventCancelled, "found a cheaper provider"))
+	fsmCtx.ReplayEvents(t, deal)
+	tut.AssertDealState(t, storagemarket.StorageDealCancelled, deal.State)
+
+	assert.True(t, clientstates.CanCancel(storagemarket.StorageDealValidating))
+	assert.False(t, clientstates.CanCancel(storagemarket.StorageDealProposalAccepted))
+	assert.True(t, clientstates.IsFinalityState(storagemarket.StorageDealCancelled))
+}
+
+func TestRestartEvents(t *testing.T) {
+	eventProcessor, err := fsm.NewEventProcessor(storagemarket.ClientDeal{}, "State", clientstates.ClientEvents)
+	assert.NoError(t, err)
+
+	t.Run("restart keeps state", func(t *testing.T) {
+		deal := &storagemarket.ClientDeal{State: storagemarket.StorageDealSealing}
+		fsmCtx := fsmtest.NewTestContext(context.Background(), eventProcessor)
+		assert.NoError(t, fsmCtx.Trigger(storagemarket.ClientEventRestart))
+		fsmCtx.ReplayEvents(t, deal)
+		tut.AssertDealState(t, storagemarket.StorageDealSealing, deal.State)
+		assert.False(t, deal.ConnectionClosed)
+	})
+
+	t.Run("stream lost while waiting for a response", func(t *testing.T) {
+		deal := &storagemarket.ClientDeal{State: storagemarket.StorageDealValidating}
+		fsmCtx := fsmtest.NewTestContext(context.Background(), eventProcessor)
+		assert.NoError(t, fsmCtx.Trigger(storagemarket.ClientEventStreamLost))
+		fsmCtx.ReplayEvents(t, deal)
+		tut.AssertDealState(t, storagemarket.StorageDealCheckForAcceptance, deal.State)
+		assert.True(t, deal.ConnectionClosed)
+	})
+
+	t.Run("stream lost while failing", func(t *testing.T) {
+		deal := &storagemarket.ClientDeal{State: storagemarket.StorageDealFailing}
+		fsmCtx := fsmtest.NewTestContext(context.Background(), eventProcessor)
+		assert.NoError(t, fsmCtx.Trigger(storagemarket.ClientEventStreamLost))
+		fsmCtx.ReplayEvents(t, deal)
+		tut.AssertDealState(t, storagemarket.StorageDealFailing, deal.State)
+		assert.True(t, deal.ConnectionClosed)
+	})
+}
+
+func TestRestartStates(t *testing.T) {
+	assert.True(t, clientstates.IsFinalityState(storagemarket.StorageDealExpired))
+	assert.True(t, clientstates.IsFinalityState(storagemarket.StorageDealSlashed))
+	assert.True(t, clientstates.IsFinalityState(storagemarket.StorageDealError))
+	assert.False(t, clientstates.IsFinalityState(storagemarket.StorageDealSealing))
+	assert.False(t, clientstates.IsFinalityState(storagemarket.StorageDealActive))
+
+	assert.True(t, clientstates.HasOpenStream(storagemarket.StorageDealWaitingForDataRequest))
+	assert.True(t, clientstates.HasOpenStream(storagemarket.StorageDealFailing))
+	assert.False(t, clientstates.HasOpenStream(storagemarket.StorageDealClientFunding))
+	assert.False(t, clientstates.HasOpenStream(storagemarket.StorageDealProposalAccepted))
+}
+
 type envParams struct {
 	dealStream             smnet.StorageDealStream
 	closeStreamErr         error
 	startDataTransferError error
 	manualTransfer         bool
+	providerDealState      *storagemarket.ProviderDealState
+	getDealStateErr        error
+	pollingInterval        time.Duration
+	retryPolicy            storagemarket.RetryPolicy
 }
 
 type dealStateParams struct {
 	connectionClosed bool
 	addFundsCid      *cid.Cid
+	retries          uint64
 }
 
 type executor func(t *testing.T,
@@ -368,6 +629,7 @@ func makeExecutor(ctx context.Context,
 		assert.NoError(t, err)
 		dealState.AddFundsCid = &tut.GenerateCids(1)[0]
 		dealState.ConnectionClosed = dealParams.connectionClosed
+		dealState.Retries = dealParams.retries
 
 		if dealParams.addFundsCid != nil {
 			dealState.AddFundsCid = dealParams.addFundsCid
@@ -378,6 +640,10 @@ func makeExecutor(ctx context.Context,
 			dealStream:             envParams.dealStream,
 			closeStreamErr:         envParams.closeStreamErr,
 			startDataTransferError: envParams.startDataTransferError,
+			providerDealState:      envParams.providerDealState,
+			getDealStateErr:        envParams.getDealStateErr,
+			pollingInterval:        envParams.pollingInterval,
+			retryPolicy:            envParams.retryPolicy,
 		}
 		fsmCtx := fsmtest.NewTestContext(ctx, eventProcessor)
 		err = stateEntryFunc(fsmCtx, environment, *dealState)
@@ -403,11 +669,19 @@ type nodeParams struct {
 	ValidatePublishedError  error
 	DealCommittedSyncError  error
 	DealCommittedAsyncError error
+
+	OnDealExpiredOrSlashedError error
+	DealExpired                 bool
+	DealSlashedEpoch            abi.ChainEpoch
+	DealCompletionError         error
+
+	ChainHeadEpoch abi.ChainEpoch
 }
 
 func makeNode(params nodeParams) storagemarket.StorageClientNode {
 	var out testnodes.FakeClientNode
 	out.SMState = testnodes.NewStorageMarketState()
+	out.SMState.Epoch = params.ChainHeadEpoch
 	out.AddFundsCid = params.AddFundsCid
 	out.EnsureFundsError = params.EnsureFundsError
 	out.VerifySignatureFails = params.VerifySignatureFails
@@ -423,6 +697,10 @@ func makeNode(params nodeParams) storagemarket.StorageClientNode {
 	out.ValidatePublishedError = params.ValidatePublishedError
 	out.DealCommittedSyncError = params.DealCommittedSyncError
 	out.DealCommittedAsyncError = params.DealCommittedAsyncError
+	out.OnDealExpiredOrSlashedError = params.OnDealExpiredOrSlashedError
+	out.DealExpired = params.DealExpired
+	out.DealSlashedEpoch = params.DealSlashedEpoch
+	out.DealCompletionError = params.DealCompletionError
 	return &out
 }
 
@@ -433,6 +711,12 @@ type fakeEnvironment struct {
 	closeStreamCalls       []cid.Cid
 	startDataTransferError error
 	startDataTransferCalls []dataTransferParams
+	providerDealState      *storagemarket.ProviderDealState
+	getDealStateErr        error
+	getDealStateCalls      []cid.Cid
+	pollingInterval        time.Duration
+	retryPolicy            storagemarket.RetryPolicy
+	closedTransfers        []cid.Cid
 }
 
 type dataTransferParams struct {
@@ -452,6 +736,14 @@ func (fe *fakeEnvironment) StartDataTransfer(ctx context.Context, to peer.ID, vo
 	return fe.startDataTransferError
 }
 
+func (fe *fakeEnvironment) RetryPolicy() storagemarket.RetryPolicy {
+	return fe.retryPolicy
+}
+
+func (fe *fakeEnvironment) CloseDataTransfer(proposalCid cid.Cid) {
+	fe.closedTransfers = append(fe.closedTransfers, proposalCid)
+}
+
 func (fe *fakeEnvironment) Node() storagemarket.StorageClientNode {
 	return fe.node
 }
@@ -474,6 +766,15 @@ func (fe *fakeEnvironment) CloseStream(proposalCid cid.Cid) error {
 	return fe.closeStreamErr
 }
 
+func (fe *fakeEnvironment) GetProviderDealState(ctx context.Context, proposalCid cid.Cid) (*storagemarket.ProviderDealState, error) {
+	fe.getDealStateCalls = append(fe.getDealStateCalls, proposalCid)
+	return fe.providerDealState, fe.getDealStateErr
+}
+
+func (fe *fakeEnvironment) PollingInterval() time.Duration {
+	return fe.pollingInterval
+}
+
 var _ clientstates.ClientDealEnvironment = &fakeEnvironment{}
 
 type responseParams struct {
@@ -482,6 +783,7 @@ type responseParams struct {
 	message        string
 	publishMessage *cid.Cid
 	proposalCid    cid.Cid
+	reason         storagemarket.RejectionReason
 }
 
 func testResponseStream(t *testing.T, params responseParams) smnet.StorageDealStream {
@@ -490,6 +792,7 @@ func testResponseStream(t *testing.T, params responseParams) smnet.StorageDealSt
 		Proposal:       params.proposalCid,
 		Message:        params.message,
 		PublishMessage: params.publishMessage,
+		Reason:         params.reason,
 	}
 
 	if response.Proposal == cid.Undef {
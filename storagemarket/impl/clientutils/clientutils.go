@@ -6,6 +6,7 @@ import (
 	"github.com/filecoin-project/go-address"
 	cborutil "github.com/filecoin-project/go-cbor-util"
 	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
 	"github.com/filecoin-project/specs-actors/actors/crypto"
 	"github.com/ipfs/go-cid"
 	"golang.org/x/xerrors"
@@ -55,3 +56,36 @@ func VerifyResponse(ctx context.Context, resp network.SignedResponse, minerAddr
 
 	return nil
 }
+
+// VerifyDealStatusResponse verifies the signature on the given deal status response matches
+// the given miner address, using the given signature verification function
+func VerifyDealStatusResponse(ctx context.Context, resp network.DealStatusResponse, minerAddr address.Address, tok shared.TipSetToken, verifier VerifyFunc) error {
+	if resp.Signature == nil {
+		return xerrors.New("deal status response is not signed")
+	}
+
+	b, err := cborutil.Dump(&resp.DealState)
+	if err != nil {
+		return err
+	}
+	verified, err := verifier(ctx, *resp.Signature, minerAddr, b, tok)
+	if err != nil {
+		return err
+	}
+
+	if !verified {
+		return xerrors.New("could not verify signature")
+	}
+
+	return nil
+}
+
+// ProviderCollateral returns the provider collateral to propose for a deal with the given
+// piece size: the minimum the provider's ask requires, or zero if the ask sets no minimum
+func ProviderCollateral(ask storagemarket.StorageAsk, pieceSize abi.PaddedPieceSize) abi.TokenAmount {
+	minCollateral, _ := ask.ProviderCollateralBounds(pieceSize)
+	if minCollateral.Nil() {
+		return big.Zero()
+	}
+	return minCollateral
+}
@@ -106,6 +106,54 @@ func TestVerifyResponse(t *testing.T) {
 	}
 }
 
+func TestVerifyDealStatusResponse(t *testing.T) {
+	tests := map[string]struct {
+		response  network.DealStatusResponse
+		verifier  clientutils.VerifyFunc
+		shouldErr bool
+	}{
+		"successful verification": {
+			response: shared_testutil.MakeTestDealStatusResponse(),
+			verifier: func(context.Context, crypto.Signature, address.Address, []byte, shared.TipSetToken) (bool, error) {
+				return true, nil
+			},
+			shouldErr: false,
+		},
+		"missing signature": {
+			response: network.DealStatusResponse{
+				DealState: shared_testutil.MakeTestDealStatusResponse().DealState,
+			},
+			verifier: func(context.Context, crypto.Signature, address.Address, []byte, shared.TipSetToken) (bool, error) {
+				return true, nil
+			},
+			shouldErr: true,
+		},
+		"bad deal state": {
+			response: network.DealStatusResponse{
+				DealState: storagemarket.ProviderDealState{},
+				Signature: shared_testutil.MakeTestSignature(),
+			},
+			verifier: func(context.Context, crypto.Signature, address.Address, []byte, shared.TipSetToken) (bool, error) {
+				return true, nil
+			},
+			shouldErr: true,
+		},
+		"verification fails": {
+			response: shared_testutil.MakeTestDealStatusResponse(),
+			verifier: func(context.Context, crypto.Signature, address.Address, []byte, shared.TipSetToken) (bool, error) {
+				return false, nil
+			},
+			shouldErr: true,
+		},
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := clientutils.VerifyDealStatusResponse(context.Background(), data.response, address.TestAddress, shared.TipSetToken{}, data.verifier)
+			require.Equal(t, err != nil, data.shouldErr)
+		})
+	}
+}
+
 type testPieceIO struct {
 	t                  *testing.T
 	expectedRt         abi.RegisteredProof
@@ -126,3 +174,34 @@ func (t *testPieceIO) GeneratePieceCommitment(rt abi.RegisteredProof, payloadCid
 func (t *testPieceIO) ReadPiece(r io.Reader) (cid.Cid, error) {
 	panic("not implemented")
 }
+
+func TestProviderCollateral(t *testing.T) {
+	pieceSize := abi.PaddedPieceSize(1 << 20)
+	tests := map[string]struct {
+		ask      storagemarket.StorageAsk
+		expected abi.TokenAmount
+	}{
+		"ask without collateral bounds": {
+			ask:      storagemarket.StorageAsk{},
+			expected: abi.NewTokenAmount(0),
+		},
+		"ask with minimum collateral": {
+			ask: storagemarket.StorageAsk{
+				MinProviderCollateral: abi.NewTokenAmount(1024 * 1000),
+				MaxProviderCollateral: abi.NewTokenAmount(1024 * 2000),
+			},
+			expected: abi.NewTokenAmount(1000),
+		},
+		"ask with only maximum collateral": {
+			ask: storagemarket.StorageAsk{
+				MaxProviderCollateral: abi.NewTokenAmount(1024 * 2000),
+			},
+			expected: abi.NewTokenAmount(0),
+		},
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, data.expected, clientutils.ProviderCollateral(data.ask, pieceSize))
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package dealfilter
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/libp2p/go-libp2p-core/peer"
+
+	"github.com/filecoin-project/go-fil-markets/shared"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/go-fil-markets/storagemarket/impl/providerstates"
+)
+
+// Filter evaluates a proposed storage deal. It returns a decision, and when the
+// deal is rejected, a reason that is sent back to the client
+type Filter func(ctx context.Context, deal storagemarket.MinerDeal) (shared.FilterDecision, string, error)
+
+// Pipeline combines filters into a single deal decider. Filters run in order, and the
+// first filter that accepts or rejects a deal decides it. A deal that every filter
+// abstains on is accepted
+func Pipeline(filters ...Filter) func(context.Context, storagemarket.MinerDeal) (bool, string, error) {
+	return func(ctx context.Context, deal storagemarket.MinerDeal) (bool, string, error) {
+		for _, filter := range filters {
+			decision, reason, err := filter(ctx, deal)
+			if err != nil {
+				return false, "", err
+			}
+			switch decision {
+			case shared.FilterAccept:
+				return true, "", nil
+			case shared.FilterReject:
+				return false, reason, nil
+			}
+		}
+		return true, "", nil
+	}
+}
+
+// AllowPeers rejects deals from any peer not in the given list
+func AllowPeers(peers ...peer.ID) Filter {
+	allowed := peerSet(peers)
+	return func(ctx context.Context, deal storagemarket.MinerDeal) (shared.FilterDecision, string, error) {
+		if _, ok := allowed[deal.Client]; !ok {
+			return shared.FilterReject, fmt.Sprintf("peer %s is not allowed to make deals", deal.Client), nil
+		}
+		return shared.FilterAbstain, "", nil
+	}
+}
+
+// BlockPeers rejects deals from any peer in the given list
+func BlockPeers(peers ...peer.ID) Filter {
+	blocked := peerSet(peers)
+	return func(ctx context.Context, deal storagemarket.MinerDeal) (shared.FilterDecision, string, error) {
+		if _, ok := blocked[deal.Client]; ok {
+			return shared.FilterReject, fmt.Sprintf("peer %s is blocked from making deals", deal.Client), nil
+		}
+		return shared.FilterAbstain, "", nil
+	}
+}
+
+// AllowClients rejects deals from any client address not in the given list
+func AllowClients(clients ...address.Address) Filter {
+	allowed := addressSet(clients)
+	return func(ctx context.Context, deal storagemarket.MinerDeal) (shared.FilterDecision, string, error) {
+		if _, ok := allowed[deal.Proposal.Client]; !ok {
+			return shared.FilterReject, fmt.Sprintf("client %s is not allowed to make deals", deal.Proposal.Client), nil
+		}
+		return shared.FilterAbstain, "", nil
+	}
+}
+
+// BlockClients rejects deals from any client address in the given list
+func BlockClients(clients ...address.Address) Filter {
+	blocked := addressSet(clients)
+	return func(ctx context.Context, deal storagemarket.MinerDeal) (shared.FilterDecision, string, error) {
+		if _, ok := blocked[deal.Proposal.Client]; ok {
+			return shared.FilterReject, fmt.Sprintf("client %s is blocked from making deals", deal.Proposal.Client), nil
+		}
+		return shared.FilterAbstain, "", nil
+	}
+}
+
+// DurationBounds rejects deals whose duration in epochs is outside the given bounds.
+// A bound of zero is not checked
+func DurationBounds(min, max abi.ChainEpoch) Filter {
+	return func(ctx context.Context, deal storagemarket.MinerDeal) (shared.FilterDecision, string, error) {
+		duration := deal.Proposal.EndEpoch - deal.Proposal.StartEpoch
+		if min > 0 && duration < min {
+			return shared.FilterReject, fmt.Sprintf("deal duration %d is less than minimum %d", duration, min), nil
+		}
+		if max > 0 && duration > max {
+			return shared.FilterReject, fmt.Sprintf("deal duration %d is greater than maximum %d", duration, max), nil
+		}
+		return shared.FilterAbstain, "", nil
+	}
+}
+
+// ChainHeadFunc returns the current chain head
+type ChainHeadFunc func(ctx context.Context) (shared.TipSetToken, abi.ChainEpoch, error)
+
+// StartEpochBounds rejects deals that start too soon or too far after the current chain
+// head. minDelay and maxDelay are epochs after the chain head; a bound of zero is not checked
+func StartEpochBounds(chainHead ChainHeadFunc, minDelay, maxDelay abi.ChainEpoch) Filter {
+	return func(ctx context.Context, deal storagemarket.MinerDeal) (shared.FilterDecision, string, error) {
+		_, height, err := chainHead(ctx)
+		if err != nil {
+			return shared.FilterReject, "", err
+		}
+		delay := deal.Proposal.StartEpoch - height
+		if minDelay > 0 && delay < minDelay {
+			return shared.FilterReject, fmt.Sprintf("deal start epoch %d is less than %d epochs after current height %d", deal.Proposal.StartEpoch, minDelay, height), nil
+		}
+		if maxDelay > 0 && delay > maxDelay {
+			return shared.FilterReject, fmt.Sprintf("deal start epoch %d is more than %d epochs after current height %d", deal.Proposal.StartEpoch, maxDelay, height), nil
+		}
+		return shared.FilterAbstain, "", nil
+	}
+}
+
+// PieceSizeBounds rejects deals whose piece size is outside the given range.
+// A bound of zero is not checked
+func PieceSizeBounds(min, max abi.PaddedPieceSize) Filter {
+	return func(ctx context.Context, deal storagemarket.MinerDeal) (shared.FilterDecision, string, error) {
+		size := deal.Proposal.PieceSize
+		if min > 0 && size < min {
+			return shared.FilterReject, fmt.Sprintf("piece size %d is less than minimum %d", size, min), nil
+		}
+		if max > 0 && size > max {
+			return shared.FilterReject, fmt.Sprintf("piece size %d is greater than maximum %d", size, max), nil
+		}
+		return shared.FilterAbstain, "", nil
+	}
+}
+
+// RequireLabels rejects deals whose labels are not picked by every one of the given selectors
+func RequireLabels(selectors ...storagemarket.LabelSelector) Filter {
+	return func(ctx context.Context, deal storagemarket.MinerDeal) (shared.FilterDecision, string, error) {
+		if !storagemarket.SelectLabels(deal.Labels, selectors) {
+			return shared.FilterReject, "deal does not have the required labels", nil
+		}
+		return shared.FilterAbstain, "", nil
+	}
+}
+
+// ListDealsFunc lists the deals known to a provider
+type ListDealsFunc func() ([]storagemarket.MinerDeal, error)
+
+// ClientDealQuota rejects deals from a client address that already has the given number
+// of deals in progress with the provider
+func ClientDealQuota(listDeals ListDealsFunc, maxDealsPerClient int) Filter {
+	return func(ctx context.Context, deal storagemarket.MinerDeal) (shared.FilterDecision, string, error) {
+		deals, err := listDeals()
+		if err != nil {
+			return shared.FilterReject, "", err
+		}
+		inProgress := 0
+		for _, d := range deals {
+			if d.ProposalCid.Equals(deal.ProposalCid) || d.Proposal.Client != deal.Proposal.Client {
+				continue
+			}
+			if !providerstates.IsFinalityState(d.State) {
+				inProgress++
+			}
+		}
+		if inProgress >= maxDealsPerClient {
+			return shared.FilterReject, fmt.Sprintf("client %s already has %d deals in progress", deal.Proposal.Client, inProgress), nil
+		}
+		return shared.FilterAbstain, "", nil
+	}
+}
+
+func peerSet(peers []peer.ID) map[peer.ID]struct{} {
+	set := make(map[peer.ID]struct{}, len(peers))
+	for _, p := range peers {
+		set[p] = struct{}{}
+	}
+	return set
+}
+
+func addressSet(addrs []address.Address) map[address.Address]struct{} {
+	set := make(map[address.Address]struct{}, len(addrs))
+	for _, a := range addrs {
+		set[a] = struct{}{}
+	}
+	return set
+}
@@ -0,0 +1,238 @@
+package dealfilter_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-fil-markets/shared"
+	"github.com/filecoin-project/go-fil-markets/shared_testutil"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/go-fil-markets/storagemarket/impl/dealfilter"
+)
+
+func TestPipeline(t *testing.T) {
+	accept := func(context.Context, storagemarket.MinerDeal) (shared.FilterDecision, string, error) {
+		return shared.FilterAccept, "", nil
+	}
+	reject := func(context.Context, storagemarket.MinerDeal) (shared.FilterDecision, string, error) {
+		return shared.FilterReject, "rejected", nil
+	}
+	abstain := func(context.Context, storagemarket.MinerDeal) (shared.FilterDecision, string, error) {
+		return shared.FilterAbstain, "", nil
+	}
+	fail := func(context.Context, storagemarket.MinerDeal) (shared.FilterDecision, string, error) {
+		return shared.FilterAbstain, "", errors.New("something went wrong")
+	}
+
+	tests := map[string]struct {
+		filters        []dealfilter.Filter
+		expectedAccept bool
+		expectedReason string
+		expectedErr    bool
+	}{
+		"no filters accepts": {
+			expectedAccept: true,
+		},
+		"all abstain accepts": {
+			filters:        []dealfilter.Filter{abstain, abstain},
+			expectedAccept: true,
+		},
+		"first decision wins when accepting": {
+			filters:        []dealfilter.Filter{abstain, accept, reject},
+			expectedAccept: true,
+		},
+		"first decision wins when rejecting": {
+			filters:        []dealfilter.Filter{abstain, reject, accept},
+			expectedAccept: false,
+			expectedReason: "rejected",
+		},
+		"error stops the pipeline": {
+			filters:     []dealfilter.Filter{fail, accept},
+			expectedErr: true,
+		},
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			accepted, reason, err := dealfilter.Pipeline(data.filters...)(context.Background(), makeDeal(t))
+			require.Equal(t, data.expectedErr, err != nil)
+			if !data.expectedErr {
+				require.Equal(t, data.expectedAccept, accepted)
+				require.Equal(t, data.expectedReason, reason)
+			}
+		})
+	}
+}
+
+func TestFilters(t *testing.T) {
+	otherPeer := peer.ID("other")
+	otherClient := address.TestAddress2
+	chainHead := func(context.Context) (shared.TipSetToken, abi.ChainEpoch, error) {
+		return shared.TipSetToken{}, 100, nil
+	}
+
+	tests := map[string]struct {
+		filter   func(deal storagemarket.MinerDeal) dealfilter.Filter
+		expected shared.FilterDecision
+	}{
+		"allowed peer": {
+			filter: func(deal storagemarket.MinerDeal) dealfilter.Filter {
+				return dealfilter.AllowPeers(deal.Client)
+			},
+			expected: shared.FilterAbstain,
+		},
+		"peer not allowed": {
+			filter: func(deal storagemarket.MinerDeal) dealfilter.Filter {
+				return dealfilter.AllowPeers(otherPeer)
+			},
+			expected: shared.FilterReject,
+		},
+		"blocked peer": {
+			filter: func(deal storagemarket.MinerDeal) dealfilter.Filter {
+				return dealfilter.BlockPeers(otherPeer, deal.Client)
+			},
+			expected: shared.FilterReject,
+		},
+		"peer not blocked": {
+			filter: func(deal storagemarket.MinerDeal) dealfilter.Filter {
+				return dealfilter.BlockPeers(otherPeer)
+			},
+			expected: shared.FilterAbstain,
+		},
+		"allowed client": {
+			filter: func(deal storagemarket.MinerDeal) dealfilter.Filter {
+				return dealfilter.AllowClients(deal.Proposal.Client)
+			},
+			expected: shared.FilterAbstain,
+		},
+		"client not allowed": {
+			filter: func(deal storagemarket.MinerDeal) dealfilter.Filter {
+				return dealfilter.AllowClients(otherClient)
+			},
+			expected: shared.FilterReject,
+		},
+		"blocked client": {
+			filter: func(deal storagemarket.MinerDeal) dealfilter.Filter {
+				return dealfilter.BlockClients(deal.Proposal.Client)
+			},
+			expected: shared.FilterReject,
+		},
+		"duration within bounds": {
+			filter: func(deal storagemarket.MinerDeal) dealfilter.Filter {
+				return dealfilter.DurationBounds(100, 300)
+			},
+			expected: shared.FilterAbstain,
+		},
+		"duration too short": {
+			filter: func(deal storagemarket.MinerDeal) dealfilter.Filter {
+				return dealfilter.DurationBounds(300, 0)
+			},
+			expected: shared.FilterReject,
+		},
+		"duration too long": {
+			filter: func(deal storagemarket.MinerDeal) dealfilter.Filter {
+				return dealfilter.DurationBounds(0, 100)
+			},
+			expected: shared.FilterReject,
+		},
+		"start epoch within bounds": {
+			filter: func(deal storagemarket.MinerDeal) dealfilter.Filter {
+				return dealfilter.StartEpochBounds(chainHead, 50, 150)
+			},
+			expected: shared.FilterAbstain,
+		},
+		"start epoch too soon": {
+			filter: func(deal storagemarket.MinerDeal) dealfilter.Filter {
+				return dealfilter.StartEpochBounds(chainHead, 150, 0)
+			},
+			expected: shared.FilterReject,
+		},
+		"start epoch too late": {
+			filter: func(deal storagemarket.MinerDeal) dealfilter.Filter {
+				return dealfilter.StartEpochBounds(chainHead, 0, 50)
+			},
+			expected: shared.FilterReject,
+		},
+		"piece size within bounds": {
+			filter: func(deal storagemarket.MinerDeal) dealfilter.Filter {
+				return dealfilter.PieceSizeBounds(256, 2048)
+			},
+			expected: shared.FilterAbstain,
+		},
+		"piece size too small": {
+			filter: func(deal storagemarket.MinerDeal) dealfilter.Filter {
+				return dealfilter.PieceSizeBounds(2048, 0)
+			},
+			expected: shared.FilterReject,
+		},
+		"piece size too large": {
+			filter: func(deal storagemarket.MinerDeal) dealfilter.Filter {
+				return dealfilter.PieceSizeBounds(0, 512)
+			},
+			expected: shared.FilterReject,
+		},
+		"client under quota": {
+			filter: func(deal storagemarket.MinerDeal) dealfilter.Filter {
+				inProgress := deal
+				inProgress.ProposalCid = shared_testutil.GenerateCids(1)[0]
+				inProgress.State = storagemarket.StorageDealSealing
+				completed := inProgress
+				completed.ProposalCid = shared_testutil.GenerateCids(1)[0]
+				completed.State = storagemarket.StorageDealCompleted
+				return dealfilter.ClientDealQuota(func() ([]storagemarket.MinerDeal, error) {
+					return []storagemarket.MinerDeal{deal, inProgress, completed}, nil
+				}, 2)
+			},
+			expected: shared.FilterAbstain,
+		},
+		"required labels": {
+			filter: func(deal storagemarket.MinerDeal) dealfilter.Filter {
+				return dealfilter.RequireLabels(storagemarket.HasLabels(storagemarket.DealLabels{"dataset": "a"}))
+			},
+			expected: shared.FilterAbstain,
+		},
+		"missing required labels": {
+			filter: func(deal storagemarket.MinerDeal) dealfilter.Filter {
+				return dealfilter.RequireLabels(storagemarket.HasLabelKey("dataset"), storagemarket.HasLabelKey("owner"))
+			},
+			expected: shared.FilterReject,
+		},
+		"client over quota": {
+			filter: func(deal storagemarket.MinerDeal) dealfilter.Filter {
+				inProgress := deal
+				inProgress.ProposalCid = shared_testutil.GenerateCids(1)[0]
+				inProgress.State = storagemarket.StorageDealSealing
+				return dealfilter.ClientDealQuota(func() ([]storagemarket.MinerDeal, error) {
+					return []storagemarket.MinerDeal{deal, inProgress}, nil
+				}, 1)
+			},
+			expected: shared.FilterReject,
+		},
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			deal := makeDeal(t)
+			decision, reason, err := data.filter(deal)(context.Background(), deal)
+			require.NoError(t, err)
+			require.Equal(t, data.expected, decision)
+			require.Equal(t, data.expected == shared.FilterReject, reason != "")
+		})
+	}
+}
+
+func makeDeal(t *testing.T) storagemarket.MinerDeal {
+	proposal := shared_testutil.MakeTestClientDealProposal()
+	proposal.Proposal.Client = address.TestAddress
+	proposal.Proposal.StartEpoch = 200
+	proposal.Proposal.EndEpoch = 400
+	proposal.Proposal.PieceSize = 1024
+	deal, err := shared_testutil.MakeTestMinerDeal(storagemarket.StorageDealValidating, proposal, &storagemarket.DataRef{})
+	require.NoError(t, err)
+	deal.Labels = storagemarket.DealLabels{"dataset": "a"}
+	return *deal
+}
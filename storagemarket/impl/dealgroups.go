@@ -0,0 +1,338 @@
+package storageimpl
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/go-fil-markets/storagemarket/impl/clientutils"
+	"github.com/filecoin-project/go-fil-markets/storagemarket/providerselect"
+)
+
+// ProposeReplicatedDeal stores the same data with the given number of providers, picked by
+// the client's provider selector. The piece commitment of the data is computed once and
+// shared by every deal. It returns once providers have accepted deals for every replica or
+// there are no providers left to ask. A group that could not place every replica is returned
+// with the status DealGroupDegraded rather than an error. Deals of the group that fail later,
+// or are slashed, are replaced with deals with providers the group has not used yet
+func (c *Client) ProposeReplicatedDeal(
+	ctx context.Context,
+	addr address.Address,
+	data *storagemarket.DataRef,
+	replicas uint64,
+	startEpoch abi.ChainEpoch,
+	endEpoch abi.ChainEpoch,
+	maxPrice abi.TokenAmount,
+	rt abi.RegisteredProof,
+	options ...storagemarket.ProposeStorageDealOption,
+) (*storagemarket.ProposeReplicatedDealResult, error) {
+	if replicas == 0 {
+		return nil, xerrors.New("a replicated deal needs at least one replica")
+	}
+	var params storagemarket.ProposeStorageDealParams
+	for _, option := range options {
+		option(&params)
+	}
+	if err := params.Labels.Validate(); err != nil {
+		return nil, xerrors.Errorf("invalid deal labels: %w", err)
+	}
+
+	start := time.Now()
+	commP, pieceSize, err := clientutils.CommP(ctx, c.pio, rt, data)
+	if err != nil {
+		return nil, xerrors.Errorf("computing commP failed: %w", err)
+	}
+	if data.PieceCid == nil {
+		c.dealMetrics.CommPDuration(time.Since(start))
+	}
+	ref := *data
+	ref.PieceCid = &commP
+	ref.PieceSize = pieceSize
+
+	_, head, err := c.node.GetChainHead(ctx)
+	if err != nil {
+		return nil, xerrors.Errorf("getting chain head: %w", err)
+	}
+	if maxPrice.Nil() {
+		maxPrice = big.Zero()
+	}
+
+	id, err := c.dealGroupCounter.Next()
+	if err != nil {
+		return nil, xerrors.Errorf("allocating deal group ID: %w", err)
+	}
+	group := storagemarket.DealGroup{
+		ID:           storagemarket.DealGroupID(id),
+		Client:       addr,
+		Data:         &ref,
+		StartEpoch:   startEpoch,
+		EndEpoch:     endEpoch,
+		StartDelay:   startEpoch - head,
+		ProofType:    rt,
+		MaxPrice:     maxPrice,
+		Replicas:     replicas,
+		VerifiedDeal: params.VerifiedDeal,
+		Labels:       params.Labels,
+	}
+	if err := c.dealGroups.Begin(group.ID, &group); err != nil {
+		return nil, xerrors.Errorf("saving deal group: %w", err)
+	}
+
+	if err := c.fillDealGroup(ctx, group.ID); err != nil {
+		return nil, xerrors.Errorf("proposing deals for group %s: %w", group.ID, err)
+	}
+
+	info, err := c.GetDealGroup(ctx, group.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &storagemarket.ProposeReplicatedDealResult{
+		GroupID:      group.ID,
+		Status:       info.Status,
+		ProposalCids: info.Group.Deals,
+	}, nil
+}
+
+// GetDealGroup returns a group of replicated deals, the deals made for it and its aggregate status
+func (c *Client) GetDealGroup(ctx context.Context, id storagemarket.DealGroupID) (storagemarket.DealGroupInfo, error) {
+	var group storagemarket.DealGroup
+	if err := c.dealGroups.Get(id).Get(&group); err != nil {
+		return storagemarket.DealGroupInfo{}, xerrors.Errorf("could not get deal group %s: %w", id, err)
+	}
+	return c.dealGroupInfo(group)
+}
+
+// ListDealGroups lists the client's groups of replicated deals
+func (c *Client) ListDealGroups(ctx context.Context) ([]storagemarket.DealGroupInfo, error) {
+	var groups []storagemarket.DealGroup
+	if err := c.dealGroups.List(&groups); err != nil {
+		return nil, xerrors.Errorf("listing deal groups: %w", err)
+	}
+	out := make([]storagemarket.DealGroupInfo, 0, len(groups))
+	for _, group := range groups {
+		info, err := c.dealGroupInfo(group)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, info)
+	}
+	return out, nil
+}
+
+func (c *Client) dealGroupInfo(group storagemarket.DealGroup) (storagemarket.DealGroupInfo, error) {
+	deals := make([]storagemarket.ClientDeal, 0, len(group.Deals))
+	for _, proposalCid := range group.Deals {
+		var deal storagemarket.ClientDeal
+		if err := c.statemachines.Get(proposalCid).Get(&deal); err != nil {
+			return storagemarket.DealGroupInfo{}, xerrors.Errorf("could not get deal %s of group %s: %w", proposalCid, group.ID, err)
+		}
+		deals = append(deals, deal)
+	}
+	return storagemarket.NewDealGroupInfo(group, deals), nil
+}
+
+// fillDealGroup proposes deals to new providers until the group has a live deal for each of
+// its replicas, or until no provider is left that the group has not tried. If the group is
+// already being filled, the fill that is running checks the group again once it is done
+func (c *Client) fillDealGroup(ctx context.Context, id storagemarket.DealGroupID) error {
+	c.dealGroupsLk.Lock()
+	if _, filling := c.dealGroupFills[id]; filling {
+		c.dealGroupFills[id] = true
+		c.dealGroupsLk.Unlock()
+		return nil
+	}
+	c.dealGroupFills[id] = false
+	c.dealGroupsLk.Unlock()
+
+	for {
+		err := c.fillDealGroupOnce(ctx, id)
+
+		c.dealGroupsLk.Lock()
+		again := c.dealGroupFills[id]
+		if err != nil || !again {
+			delete(c.dealGroupFills, id)
+			c.dealGroupsLk.Unlock()
+			return err
+		}
+		c.dealGroupFills[id] = false
+		c.dealGroupsLk.Unlock()
+	}
+}
+
+// fillDealGroupOnce proposes a deal for each replica a group is missing to a different
+// provider at the same time, then moves on to further providers for any deals that were not
+// accepted
+func (c *Client) fillDealGroupOnce(ctx context.Context, id storagemarket.DealGroupID) error {
+	info, err := c.GetDealGroup(ctx, id)
+	if err != nil {
+		return err
+	}
+	group := info.Group
+	if info.Status == storagemarket.DealGroupExpired || info.Live() >= group.Replicas {
+		return nil
+	}
+	needed := group.Replicas - info.Live()
+
+	// a provider whose deal failed is not asked again
+	used := make(map[address.Address]struct{}, len(info.Deals))
+	for _, deal := range info.Deals {
+		used[deal.Proposal.Provider] = struct{}{}
+	}
+
+	_, head, err := c.node.GetChainHead(ctx)
+	if err != nil {
+		return xerrors.Errorf("getting chain head: %w", err)
+	}
+	startEpoch, endEpoch := group.StartEpoch, group.EndEpoch
+	if head+group.StartDelay > startEpoch {
+		startEpoch = head + group.StartDelay
+		endEpoch = startEpoch + (group.EndEpoch - group.StartEpoch)
+	}
+
+	dealParams := providerselect.DealParams{
+		PieceSize:    group.Data.PieceSize.Padded(),
+		Duration:     endEpoch - startEpoch,
+		VerifiedDeal: group.VerifiedDeal,
+	}
+	if big.Cmp(group.MaxPrice, big.Zero()) > 0 {
+		dealParams.MaxPrice = group.MaxPrice
+	}
+	candidates, err := c.selector.Candidates(ctx, dealParams)
+	if err != nil {
+		return xerrors.Errorf("finding providers: %w", err)
+	}
+
+	options := []storagemarket.ProposeStorageDealOption{storagemarket.WithDealLabels(group.Labels)}
+	if group.VerifiedDeal {
+		options = append(options, storagemarket.WithVerifiedDeal())
+	}
+	proposal := providerselect.Proposal{
+		Client:     group.Client,
+		Data:       group.Data,
+		StartEpoch: startEpoch,
+		EndEpoch:   endEpoch,
+		ProofType:  group.ProofType,
+		Options:    options,
+	}
+
+	unused := make([]providerselect.Candidate, 0, len(candidates))
+	for _, candidate := range candidates {
+		if _, ok := used[candidate.Provider.Address]; !ok {
+			unused = append(unused, candidate)
+		}
+	}
+
+	for needed > 0 && len(unused) > 0 {
+		next := unused
+		if uint64(len(next)) > needed {
+			next = next[:needed]
+		}
+		unused = unused[len(next):]
+
+		for _, proposalCid := range c.proposeToEach(ctx, id, next, proposal) {
+			if err := c.addDealGroupDeal(id, proposalCid); err != nil {
+				return err
+			}
+			needed--
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+
+	return c.dealGroups.Get(id).Mutate(func(g *storagemarket.DealGroup) error {
+		g.Exhausted = needed > 0
+		g.Message = ""
+		if needed > 0 {
+			g.Message = fmt.Sprintf("no provider left to store %d of %d replicas with", needed, g.Replicas)
+		}
+		return nil
+	})
+}
+
+// proposeToEach proposes a deal to each of the candidates at the same time, and returns the
+// proposals of the deals that were accepted
+func (c *Client) proposeToEach(ctx context.Context, id storagemarket.DealGroupID, candidates []providerselect.Candidate, proposal providerselect.Proposal) []cid.Cid {
+	var lk sync.Mutex
+	var accepted []cid.Cid
+	var wg sync.WaitGroup
+	for _, candidate := range candidates {
+		wg.Add(1)
+		go func(candidate providerselect.Candidate) {
+			defer wg.Done()
+			deal, err := c.selector.ProposeToBest(ctx, []providerselect.Candidate{candidate}, proposal)
+			if err != nil {
+				log.Infof("deal group %s: no deal with provider %s: %s", id, candidate.Provider.Address, err)
+				return
+			}
+			lk.Lock()
+			accepted = append(accepted, deal.ProposalCid)
+			lk.Unlock()
+		}(candidate)
+	}
+	wg.Wait()
+	return accepted
+}
+
+func (c *Client) addDealGroupDeal(id storagemarket.DealGroupID, proposalCid cid.Cid) error {
+	c.dealGroupsLk.Lock()
+	c.dealGroupsByDeal[proposalCid] = id
+	c.dealGroupsLk.Unlock()
+
+	return c.dealGroups.Get(id).Mutate(func(g *storagemarket.DealGroup) error {
+		g.Deals = append(g.Deals, proposalCid)
+		return nil
+	})
+}
+
+// replaceDealGroupDeal makes a deal with a new provider in the background if the given
+// deal belongs to a group. Cancelled deals are not replaced
+func (c *Client) replaceDealGroupDeal(proposalCid cid.Cid) {
+	c.dealGroupsLk.Lock()
+	id, ok := c.dealGroupsByDeal[proposalCid]
+	c.dealGroupsLk.Unlock()
+	if !ok {
+		return
+	}
+
+	go func() {
+		if err := c.fillDealGroup(c.ctx, id); err != nil {
+			log.Errorf("replacing deal %s of deal group %s: %s", proposalCid, id, err)
+		}
+	}()
+}
+
+// restartDealGroups indexes the deals of every group, and replaces deals of groups that
+// failed while the client was stopped
+func (c *Client) restartDealGroups() error {
+	var groups []storagemarket.DealGroup
+	if err := c.dealGroups.List(&groups); err != nil {
+		return err
+	}
+
+	c.dealGroupsLk.Lock()
+	for _, group := range groups {
+		for _, proposalCid := range group.Deals {
+			c.dealGroupsByDeal[proposalCid] = group.ID
+		}
+	}
+	c.dealGroupsLk.Unlock()
+
+	for _, group := range groups {
+		id := group.ID
+		go func() {
+			if err := c.fillDealGroup(c.ctx, id); err != nil {
+				log.Errorf("restarting deal group %s: %s", id, err)
+			}
+		}()
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+package storageimpl
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+)
+
+// DealPublisher collects deals that are ready to be published and publishes them
+// together in a single PublishStorageDeals message, to reduce the on-chain fees paid per deal.
+// A batch is published when it reaches the maximum number of deals per message, or when the
+// publish period has elapsed since the first deal was added to it, whichever happens first.
+// Deals with different providers are batched separately, as each message publishes deals for
+// a single provider
+type DealPublisher struct {
+	node                  storagemarket.StorageProviderNode
+	publishPeriod         time.Duration
+	maxDealsPerPublishMsg uint64
+
+	// ctx is the context publish messages are sent with. It is cancelled when the
+	// publisher is stopped
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	lk      sync.Mutex
+	batches map[address.Address]*dealBatch
+}
+
+type dealBatch struct {
+	pending []*pendingDeal
+	timer   *time.Timer
+}
+
+type pendingDeal struct {
+	ctx    context.Context
+	deal   storagemarket.MinerDeal
+	result chan publishResult
+}
+
+type publishResult struct {
+	publishCid cid.Cid
+	index      uint64
+	err        error
+}
+
+// NewDealPublisher returns a new DealPublisher. A maximum of zero deals per message means
+// there is no limit. With a publish period of zero, every deal is published in its own
+// message as soon as it is added
+func NewDealPublisher(node storagemarket.StorageProviderNode, publishPeriod time.Duration, maxDealsPerPublishMsg uint64) *DealPublisher {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &DealPublisher{
+		node:                  node,
+		publishPeriod:         publishPeriod,
+		maxDealsPerPublishMsg: maxDealsPerPublishMsg,
+		ctx:                   ctx,
+		cancel:                cancel,
+		batches:               make(map[address.Address]*dealBatch),
+	}
+}
+
+// Publish adds a deal to the next batch and waits for the batch to be published. It returns
+// the cid of the publish message and the position of the deal in that message
+func (p *DealPublisher) Publish(ctx context.Context, deal storagemarket.MinerDeal) (cid.Cid, uint64, error) {
+	pd := &pendingDeal{
+		ctx:    ctx,
+		deal:   deal,
+		result: make(chan publishResult, 1),
+	}
+
+	provider := deal.Proposal.Provider
+	p.lk.Lock()
+	if p.ctx.Err() != nil {
+		p.lk.Unlock()
+		return cid.Undef, 0, xerrors.New("deal publisher is stopped")
+	}
+	b, ok := p.batches[provider]
+	if !ok {
+		b = &dealBatch{}
+		p.batches[provider] = b
+	}
+	b.pending = append(b.pending, pd)
+	if p.publishPeriod <= 0 || (p.maxDealsPerPublishMsg > 0 && uint64(len(b.pending)) >= p.maxDealsPerPublishMsg) {
+		batch := p.takePending(provider)
+		p.lk.Unlock()
+		go p.publishBatch(batch)
+	} else {
+		if b.timer == nil {
+			b.timer = time.AfterFunc(p.publishPeriod, func() { p.publishPending(provider, b) })
+		}
+		p.lk.Unlock()
+	}
+
+	select {
+	case res := <-pd.result:
+		return res.publishCid, res.index, res.err
+	case <-ctx.Done():
+		return cid.Undef, 0, ctx.Err()
+	case <-p.ctx.Done():
+		return cid.Undef, 0, xerrors.New("deal publisher is stopped")
+	}
+}
+
+// Stop stops the timers of the batches waiting for the publish period to elapse and cancels
+// the publish messages being sent. Deals still waiting to be published are not published
+func (p *DealPublisher) Stop() {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+
+	p.cancel()
+	for provider := range p.batches {
+		p.takePending(provider)
+	}
+}
+
+// publishPending publishes the batch of deals for a provider the publish period has elapsed
+// for. The batch may already have been published because it filled up, in which case the
+// deals now waiting belong to a later batch with its own timer
+func (p *DealPublisher) publishPending(provider address.Address, b *dealBatch) {
+	p.lk.Lock()
+	if p.batches[provider] != b {
+		p.lk.Unlock()
+		return
+	}
+	batch := p.takePending(provider)
+	p.lk.Unlock()
+
+	p.publishBatch(batch)
+}
+
+// takePending removes the current batch of deals for a provider from the queue. It must be
+// called with the lock held
+func (p *DealPublisher) takePending(provider address.Address) []*pendingDeal {
+	b, ok := p.batches[provider]
+	if !ok {
+		return nil
+	}
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+	delete(p.batches, provider)
+	return b.pending
+}
+
+func (p *DealPublisher) publishBatch(batch []*pendingDeal) {
+	// deals whose context has been cancelled are no longer waiting for a result
+	var waiting []*pendingDeal
+	for _, pd := range batch {
+		if pd.ctx.Err() == nil {
+			waiting = append(waiting, pd)
+		}
+	}
+	waiting = p.checkDeals(waiting)
+	if len(waiting) == 0 {
+		return
+	}
+
+	deals := make([]storagemarket.MinerDeal, 0, len(waiting))
+	for _, pd := range waiting {
+		deals = append(deals, pd.deal)
+	}
+
+	publishCid, err := p.node.PublishDeals(p.ctx, deals)
+	if err != nil && len(waiting) > 1 && p.ctx.Err() == nil {
+		// a single deal the chain rejects fails the whole message, so the deals are
+		// published one at a time to keep it from failing the others
+		log.Warnf("publishing %d deals together failed, publishing them one at a time: %s", len(waiting), err)
+		for _, pd := range waiting {
+			publishCid, err := p.node.PublishDeals(p.ctx, []storagemarket.MinerDeal{pd.deal})
+			pd.result <- publishResult{publishCid: publishCid, index: 0, err: err}
+		}
+		return
+	}
+	for i, pd := range waiting {
+		pd.result <- publishResult{publishCid: publishCid, index: uint64(i), err: err}
+	}
+}
+
+// checkDeals fails the deals whose start epoch has passed, or whose client no longer has
+// the funds to pay for them, since they were accepted. It returns the deals left to publish
+func (p *DealPublisher) checkDeals(batch []*pendingDeal) []*pendingDeal {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	tok, height, err := p.node.GetChainHead(p.ctx)
+	if err != nil {
+		for _, pd := range batch {
+			pd.result <- publishResult{err: xerrors.Errorf("getting chain head: %w", err)}
+		}
+		return nil
+	}
+
+	// a client's deals in the batch are paid for from the same balance
+	available := make(map[address.Address]abi.TokenAmount)
+	var valid []*pendingDeal
+	for _, pd := range batch {
+		proposal := pd.deal.Proposal
+		if height >= proposal.StartEpoch {
+			pd.result <- publishResult{err: xerrors.Errorf("deal start epoch %d has already passed at epoch %d", proposal.StartEpoch, height)}
+			continue
+		}
+
+		funds, ok := available[proposal.Client]
+		if !ok {
+			balance, err := p.node.GetBalance(p.ctx, proposal.Client, tok)
+			if err != nil {
+				pd.result <- publishResult{err: xerrors.Errorf("getting client market balance: %w", err)}
+				continue
+			}
+			funds = balance.Available
+		}
+		fee := proposal.TotalStorageFee()
+		if funds.LessThan(fee) {
+			pd.result <- publishResult{err: xerrors.Errorf("client market balance %s is too small for the deal's storage fee %s", funds, fee)}
+			available[proposal.Client] = funds
+			continue
+		}
+		available[proposal.Client] = big.Sub(funds, fee)
+		valid = append(valid, pd)
+	}
+	return valid
+}
@@ -0,0 +1,276 @@
+package storageimpl_test
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-fil-markets/shared_testutil"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	storageimpl "github.com/filecoin-project/go-fil-markets/storagemarket/impl"
+	"github.com/filecoin-project/go-fil-markets/storagemarket/testnodes"
+)
+
+func TestDealPublisher(t *testing.T) {
+	type publishResult struct {
+		publishCid cid.Cid
+		index      uint64
+		err        error
+	}
+
+	makeDeal := func(t *testing.T) *storagemarket.MinerDeal {
+		deal, err := shared_testutil.MakeTestMinerDeal(storagemarket.StorageDealPublish, shared_testutil.MakeTestClientDealProposal(), &storagemarket.DataRef{})
+		require.NoError(t, err)
+		return deal
+	}
+
+	publish := func(t *testing.T, publisher *storageimpl.DealPublisher, deals []*storagemarket.MinerDeal) []publishResult {
+		results := make(chan publishResult, len(deals))
+		for _, deal := range deals {
+			deal := deal
+			go func() {
+				publishCid, index, err := publisher.Publish(context.Background(), *deal)
+				results <- publishResult{publishCid, index, err}
+			}()
+		}
+
+		var out []publishResult
+		for range deals {
+			select {
+			case res := <-results:
+				out = append(out, res)
+			case <-time.After(5 * time.Second):
+				t.Fatal("deals were not published")
+			}
+		}
+		sort.Slice(out, func(i, j int) bool { return out[i].index < out[j].index })
+		return out
+	}
+
+	publishDeals := func(t *testing.T, publisher *storageimpl.DealPublisher, count int) []publishResult {
+		deals := make([]*storagemarket.MinerDeal, 0, count)
+		for i := 0; i < count; i++ {
+			deals = append(deals, makeDeal(t))
+		}
+		return publish(t, publisher, deals)
+	}
+
+	newNode := func() *testnodes.FakeProviderNode {
+		node := &testnodes.FakeProviderNode{
+			FakeCommonNode: testnodes.FakeCommonNode{SMState: testnodes.NewStorageMarketState()},
+		}
+		node.SMState.AddFunds(address.TestAddress, big.Mul(big.NewInt(1<<62), big.NewInt(1<<62)))
+		return node
+	}
+
+	t.Run("publishes immediately without a publish period", func(t *testing.T) {
+		node := newNode()
+		publisher := storageimpl.NewDealPublisher(node, 0, 0)
+
+		results := publishDeals(t, publisher, 1)
+		require.NoError(t, results[0].err)
+		require.Equal(t, uint64(0), results[0].index)
+		require.Len(t, node.PublishDealsCalls, 1)
+		require.Len(t, node.PublishDealsCalls[0], 1)
+	})
+
+	t.Run("publishes when batch is full", func(t *testing.T) {
+		node := newNode()
+		publisher := storageimpl.NewDealPublisher(node, time.Hour, 3)
+
+		results := publishDeals(t, publisher, 3)
+		for i, res := range results {
+			require.NoError(t, res.err)
+			require.Equal(t, uint64(i), res.index)
+			require.Equal(t, results[0].publishCid, res.publishCid)
+		}
+		require.Len(t, node.PublishDealsCalls, 1)
+		require.Len(t, node.PublishDealsCalls[0], 3)
+	})
+
+	t.Run("publishes when publish period elapses", func(t *testing.T) {
+		node := newNode()
+		publisher := storageimpl.NewDealPublisher(node, 50*time.Millisecond, 10)
+
+		results := publishDeals(t, publisher, 2)
+		for i, res := range results {
+			require.NoError(t, res.err)
+			require.Equal(t, uint64(i), res.index)
+		}
+		require.Len(t, node.PublishDealsCalls, 1)
+		require.Len(t, node.PublishDealsCalls[0], 2)
+	})
+
+	t.Run("returns publish error for every deal", func(t *testing.T) {
+		node := newNode()
+		node.PublishDealsError = errors.New("could not post to chain")
+		publisher := storageimpl.NewDealPublisher(node, time.Hour, 2)
+
+		results := publishDeals(t, publisher, 2)
+		for _, res := range results {
+			require.EqualError(t, res.err, "could not post to chain")
+		}
+	})
+
+	t.Run("publishes deals for each provider separately", func(t *testing.T) {
+		node := newNode()
+		publisher := storageimpl.NewDealPublisher(node, time.Hour, 2)
+
+		otherProvider, err := address.NewIDAddress(1001)
+		require.NoError(t, err)
+		results := make(chan publishResult, 4)
+		for i := 0; i < 4; i++ {
+			deal, err := shared_testutil.MakeTestMinerDeal(storagemarket.StorageDealPublish, shared_testutil.MakeTestClientDealProposal(), &storagemarket.DataRef{})
+			require.NoError(t, err)
+			if i%2 == 1 {
+				deal.Proposal.Provider = otherProvider
+			}
+			go func() {
+				publishCid, index, err := publisher.Publish(context.Background(), *deal)
+				results <- publishResult{publishCid, index, err}
+			}()
+		}
+		for i := 0; i < 4; i++ {
+			select {
+			case res := <-results:
+				require.NoError(t, res.err)
+			case <-time.After(5 * time.Second):
+				t.Fatal("deals were not published")
+			}
+		}
+
+		require.Len(t, node.PublishDealsCalls, 2)
+		for _, call := range node.PublishDealsCalls {
+			require.Len(t, call, 2)
+			require.Equal(t, call[0].Proposal.Provider, call[1].Proposal.Provider)
+		}
+	})
+
+	t.Run("does not publish deals that are no longer waiting", func(t *testing.T) {
+		node := newNode()
+		publisher := storageimpl.NewDealPublisher(node, 50*time.Millisecond, 10)
+
+		deal := makeDeal(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, _, err := publisher.Publish(ctx, *deal)
+		require.Equal(t, context.Canceled, err)
+
+		results := publishDeals(t, publisher, 1)
+		require.NoError(t, results[0].err)
+		require.Equal(t, uint64(0), results[0].index)
+		require.Len(t, node.PublishDealsCalls, 1)
+		require.Len(t, node.PublishDealsCalls[0], 1)
+	})
+	t.Run("fails deals whose start epoch has passed", func(t *testing.T) {
+		node := newNode()
+		node.SMState.SetEpoch(100)
+		publisher := storageimpl.NewDealPublisher(node, time.Hour, 2)
+
+		late := makeDeal(t)
+		late.Proposal.StartEpoch = 50
+		onTime := makeDeal(t)
+		onTime.Proposal.StartEpoch = 200
+		onTime.Proposal.EndEpoch = 300
+
+		results := publish(t, publisher, []*storagemarket.MinerDeal{late, onTime})
+		var failed int
+		for _, res := range results {
+			if res.err != nil {
+				failed++
+			}
+		}
+		require.Equal(t, 1, failed)
+		require.Len(t, node.PublishDealsCalls, 1)
+		require.Equal(t, []storagemarket.MinerDeal{*onTime}, node.PublishDealsCalls[0])
+	})
+
+	t.Run("fails deals the client can no longer pay for", func(t *testing.T) {
+		node := &testnodes.FakeProviderNode{
+			FakeCommonNode: testnodes.FakeCommonNode{SMState: testnodes.NewStorageMarketState()},
+		}
+		// each deal costs 100, and the client can only pay for one of them
+		node.SMState.AddFunds(address.TestAddress, abi.NewTokenAmount(150))
+		publisher := storageimpl.NewDealPublisher(node, time.Hour, 2)
+
+		var deals []*storagemarket.MinerDeal
+		for i := 0; i < 2; i++ {
+			deal := makeDeal(t)
+			deal.Proposal.StartEpoch = 10
+			deal.Proposal.EndEpoch = 110
+			deal.Proposal.StoragePricePerEpoch = abi.NewTokenAmount(1)
+			deals = append(deals, deal)
+		}
+
+		results := publish(t, publisher, deals)
+		var failed int
+		for _, res := range results {
+			if res.err != nil {
+				failed++
+			}
+		}
+		require.Equal(t, 1, failed)
+		require.Len(t, node.PublishDealsCalls, 1)
+		require.Len(t, node.PublishDealsCalls[0], 1)
+	})
+
+	t.Run("publishes deals one at a time when the batch fails", func(t *testing.T) {
+		node := &batchFailingNode{FakeProviderNode: newNode()}
+		publisher := storageimpl.NewDealPublisher(node, time.Hour, 3)
+
+		results := publishDeals(t, publisher, 3)
+		for _, res := range results {
+			require.NoError(t, res.err)
+			require.Equal(t, uint64(0), res.index)
+		}
+		require.Len(t, node.PublishDealsCalls, 3)
+		for _, call := range node.PublishDealsCalls {
+			require.Len(t, call, 1)
+		}
+	})
+
+	t.Run("releases waiting deals when stopped", func(t *testing.T) {
+		node := newNode()
+		publisher := storageimpl.NewDealPublisher(node, time.Hour, 10)
+
+		deal := makeDeal(t)
+		errs := make(chan error, 1)
+		go func() {
+			_, _, err := publisher.Publish(context.Background(), *deal)
+			errs <- err
+		}()
+		publisher.Stop()
+
+		select {
+		case err := <-errs:
+			require.Error(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("deal was not released")
+		}
+		require.Empty(t, node.PublishDealsCalls)
+
+		_, _, err := publisher.Publish(context.Background(), *deal)
+		require.Error(t, err)
+	})
+}
+
+// batchFailingNode fails to publish more than one deal in a message, as happens when the
+// chain rejects one of the deals in it
+type batchFailingNode struct {
+	*testnodes.FakeProviderNode
+}
+
+func (n *batchFailingNode) PublishDeals(ctx context.Context, deals []storagemarket.MinerDeal) (cid.Cid, error) {
+	if len(deals) > 1 {
+		return cid.Undef, errors.New("deal rejected")
+	}
+	return n.FakeProviderNode.PublishDeals(ctx, deals)
+}
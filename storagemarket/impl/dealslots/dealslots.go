@@ -0,0 +1,166 @@
+package dealslots
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+)
+
+// ErrReleased is returned to a deal waiting for a slot when it is released before
+// it gets one
+var ErrReleased = xerrors.New("deal released before it acquired a slot")
+
+// DealSlots limits how many deals can do something at once. Deals waiting for a slot
+// are given one in the order they started waiting. A limit of zero means there is no limit
+type DealSlots struct {
+	lk     sync.Mutex
+	limit  uint64
+	active map[cid.Cid]struct{}
+	queue  []*waiter
+}
+
+type waiter struct {
+	proposalCid cid.Cid
+	ready       chan error
+}
+
+// NewDealSlots returns a new DealSlots with the given limit
+func NewDealSlots(limit uint64) *DealSlots {
+	return &DealSlots{
+		limit:  limit,
+		active: make(map[cid.Cid]struct{}),
+	}
+}
+
+// TryAcquire takes a slot for a deal if one is free and no other deal is waiting for one.
+// It returns true if the deal now has a slot, including if it already had one
+func (s *DealSlots) TryAcquire(proposalCid cid.Cid) bool {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	if _, ok := s.active[proposalCid]; ok {
+		return true
+	}
+	if len(s.queue) > 0 || !s.hasFreeSlot() {
+		return false
+	}
+	s.active[proposalCid] = struct{}{}
+	return true
+}
+
+// Acquire waits for a slot for a deal. It returns an error if the context is cancelled,
+// or the deal is released, before it gets one
+func (s *DealSlots) Acquire(ctx context.Context, proposalCid cid.Cid) error {
+	s.lk.Lock()
+	if _, ok := s.active[proposalCid]; ok {
+		s.lk.Unlock()
+		return nil
+	}
+	if len(s.queue) == 0 && s.hasFreeSlot() {
+		s.active[proposalCid] = struct{}{}
+		s.lk.Unlock()
+		return nil
+	}
+	w := &waiter{proposalCid: proposalCid, ready: make(chan error, 1)}
+	s.queue = append(s.queue, w)
+	s.lk.Unlock()
+
+	select {
+	case err := <-w.ready:
+		return err
+	case <-ctx.Done():
+		s.lk.Lock()
+		defer s.lk.Unlock()
+		if !s.removeWaiter(w) {
+			// the slot was granted or released while the context was cancelled
+			if err := <-w.ready; err == nil {
+				delete(s.active, proposalCid)
+				s.fillSlots()
+			}
+		}
+		return ctx.Err()
+	}
+}
+
+// Release frees the slot held by a deal, or stops it waiting for one
+func (s *DealSlots) Release(proposalCid cid.Cid) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	queue := s.queue[:0]
+	for _, w := range s.queue {
+		if w.proposalCid.Equals(proposalCid) {
+			w.ready <- ErrReleased
+			continue
+		}
+		queue = append(queue, w)
+	}
+	s.queue = queue
+
+	if _, ok := s.active[proposalCid]; ok {
+		delete(s.active, proposalCid)
+		s.fillSlots()
+	}
+}
+
+// SetLimit changes the number of slots. Lowering the limit does not take slots away from
+// deals that already have them
+func (s *DealSlots) SetLimit(limit uint64) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	s.limit = limit
+	s.fillSlots()
+}
+
+// Limit returns the current number of slots
+func (s *DealSlots) Limit() uint64 {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	return s.limit
+}
+
+// Active returns the number of deals that have a slot
+func (s *DealSlots) Active() int {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	return len(s.active)
+}
+
+// Queued returns the number of deals waiting for a slot
+func (s *DealSlots) Queued() int {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	return len(s.queue)
+}
+
+func (s *DealSlots) hasFreeSlot() bool {
+	return s.limit == 0 || uint64(len(s.active)) < s.limit
+}
+
+// fillSlots gives free slots to waiting deals, in order. It must be called with the lock held
+func (s *DealSlots) fillSlots() {
+	for len(s.queue) > 0 && s.hasFreeSlot() {
+		w := s.queue[0]
+		s.queue = s.queue[1:]
+		s.active[w.proposalCid] = struct{}{}
+		w.ready <- nil
+	}
+}
+
+// removeWaiter removes a deal from the queue, returning false if it was no longer in it.
+// It must be called with the lock held
+func (s *DealSlots) removeWaiter(w *waiter) bool {
+	for i, queued := range s.queue {
+		if queued == w {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,115 @@
+package dealslots_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-fil-markets/shared_testutil"
+	"github.com/filecoin-project/go-fil-markets/storagemarket/impl/dealslots"
+)
+
+func TestDealSlots(t *testing.T) {
+	deals := shared_testutil.GenerateCids(4)
+
+	acquireAsync := func(slots *dealslots.DealSlots, ctx context.Context, proposalCid cid.Cid) <-chan error {
+		queued := slots.Queued()
+		done := make(chan error, 1)
+		go func() {
+			done <- slots.Acquire(ctx, proposalCid)
+		}()
+		// wait for the deal to join the queue
+		require.Eventually(t, func() bool { return slots.Queued() > queued }, time.Second, time.Millisecond)
+		return done
+	}
+
+	waitFor := func(t *testing.T, done <-chan error) error {
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(time.Second):
+			t.Fatal("deal did not stop waiting for a slot")
+			return nil
+		}
+	}
+
+	t.Run("limits active deals", func(t *testing.T) {
+		slots := dealslots.NewDealSlots(2)
+		require.True(t, slots.TryAcquire(deals[0]))
+		require.True(t, slots.TryAcquire(deals[1]))
+		require.True(t, slots.TryAcquire(deals[1]))
+		require.False(t, slots.TryAcquire(deals[2]))
+		require.Equal(t, 2, slots.Active())
+
+		slots.Release(deals[0])
+		require.True(t, slots.TryAcquire(deals[2]))
+	})
+
+	t.Run("zero limit is unlimited", func(t *testing.T) {
+		slots := dealslots.NewDealSlots(0)
+		for _, deal := range deals {
+			require.True(t, slots.TryAcquire(deal))
+		}
+	})
+
+	t.Run("gives slots to waiting deals in order", func(t *testing.T) {
+		ctx := context.Background()
+		slots := dealslots.NewDealSlots(1)
+		require.True(t, slots.TryAcquire(deals[0]))
+
+		first := acquireAsync(slots, ctx, deals[1])
+		second := acquireAsync(slots, ctx, deals[2])
+		require.Equal(t, 2, slots.Queued())
+		// a deal can't jump the queue
+		require.False(t, slots.TryAcquire(deals[3]))
+
+		slots.Release(deals[0])
+		require.NoError(t, waitFor(t, first))
+		require.Equal(t, 1, slots.Queued())
+
+		slots.Release(deals[1])
+		require.NoError(t, waitFor(t, second))
+		require.Equal(t, 0, slots.Queued())
+	})
+
+	t.Run("raising the limit frees waiting deals", func(t *testing.T) {
+		ctx := context.Background()
+		slots := dealslots.NewDealSlots(1)
+		require.True(t, slots.TryAcquire(deals[0]))
+
+		done := acquireAsync(slots, ctx, deals[1])
+		slots.SetLimit(2)
+		require.NoError(t, waitFor(t, done))
+		require.Equal(t, uint64(2), slots.Limit())
+		require.Equal(t, 2, slots.Active())
+	})
+
+	t.Run("releasing a waiting deal removes it from the queue", func(t *testing.T) {
+		ctx := context.Background()
+		slots := dealslots.NewDealSlots(1)
+		require.True(t, slots.TryAcquire(deals[0]))
+
+		done := acquireAsync(slots, ctx, deals[1])
+		slots.Release(deals[1])
+		require.Equal(t, dealslots.ErrReleased, waitFor(t, done))
+		require.Equal(t, 0, slots.Queued())
+		require.Equal(t, 1, slots.Active())
+	})
+
+	t.Run("cancelling the context stops waiting", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		slots := dealslots.NewDealSlots(1)
+		require.True(t, slots.TryAcquire(deals[0]))
+
+		done := acquireAsync(slots, ctx, deals[1])
+		cancel()
+		require.Equal(t, context.Canceled, waitFor(t, done))
+		require.Equal(t, 0, slots.Queued())
+
+		slots.Release(deals[0])
+		require.True(t, slots.TryAcquire(deals[2]))
+	})
+}
@@ -7,8 +7,10 @@ import (
 
 	datatransfer "github.com/filecoin-project/go-data-transfer"
 	"github.com/filecoin-project/go-statemachine/fsm"
+	"github.com/ipfs/go-cid"
 	logging "github.com/ipfs/go-log/v2"
 
+	"github.com/filecoin-project/go-fil-markets/metrics"
 	"github.com/filecoin-project/go-fil-markets/storagemarket"
 	"github.com/filecoin-project/go-fil-markets/storagemarket/impl/requestvalidation"
 )
@@ -88,3 +90,31 @@ func ClientDataTransferSubscriber(deals EventReceiver) datatransfer.Subscriber {
 		}
 	}
 }
+
+// TransferMetricsSubscriber records the bytes moved by each data transfer for a storage
+// deal once the transfer finishes, whether or not it succeeded
+func TransferMetricsSubscriber(dealMetrics *metrics.DealTracker) datatransfer.Subscriber {
+	return func(event datatransfer.Event, channelState datatransfer.ChannelState) {
+		if _, ok := channelState.Voucher().(*requestvalidation.StorageDataTransferVoucher); !ok {
+			return
+		}
+
+		switch event.Code {
+		case datatransfer.Complete, datatransfer.Error:
+			// one side only sends and the other only receives, so this is the total for either side
+			dealMetrics.BytesTransferred(channelState.Sent() + channelState.Received())
+		default:
+		}
+	}
+}
+
+// CloseDealTransfers closes any data transfer channels still in progress for the
+// deal with the given proposal cid
+func CloseDealTransfers(dt datatransfer.Manager, proposalCid cid.Cid) {
+	for chid, channelState := range dt.InProgressChannels() {
+		voucher, ok := channelState.Voucher().(*requestvalidation.StorageDataTransferVoucher)
+		if ok && voucher.Proposal.Equals(proposalCid) {
+			dt.CloseDataTransferChannel(chid)
+		}
+	}
+}
@@ -3,12 +3,16 @@ package storageimpl
 import (
 	"context"
 	"io"
+	"io/ioutil"
+	"sync"
+	"time"
 
 	"github.com/filecoin-project/go-address"
 	cborutil "github.com/filecoin-project/go-cbor-util"
 	datatransfer "github.com/filecoin-project/go-data-transfer"
 	"github.com/filecoin-project/go-statemachine/fsm"
 	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/crypto"
 	"github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
 	"github.com/hannahhoward/go-pubsub"
 	"github.com/ipfs/go-cid"
@@ -19,12 +23,17 @@ import (
 	"golang.org/x/xerrors"
 
 	"github.com/filecoin-project/go-fil-markets/filestore"
+	"github.com/filecoin-project/go-fil-markets/journal"
+	"github.com/filecoin-project/go-fil-markets/metrics"
 	"github.com/filecoin-project/go-fil-markets/pieceio"
 	"github.com/filecoin-project/go-fil-markets/pieceio/cario"
 	"github.com/filecoin-project/go-fil-markets/piecestore"
 	"github.com/filecoin-project/go-fil-markets/shared"
 	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/go-fil-markets/storagemarket/impl/blockrecorder"
 	"github.com/filecoin-project/go-fil-markets/storagemarket/impl/connmanager"
+	"github.com/filecoin-project/go-fil-markets/storagemarket/impl/dealfilter"
+	"github.com/filecoin-project/go-fil-markets/storagemarket/impl/dealslots"
 	"github.com/filecoin-project/go-fil-markets/storagemarket/impl/dtutils"
 	"github.com/filecoin-project/go-fil-markets/storagemarket/impl/providerstates"
 	"github.com/filecoin-project/go-fil-markets/storagemarket/impl/providerutils"
@@ -32,11 +41,23 @@ import (
 )
 
 var DefaultDealAcceptanceBuffer = abi.ChainEpoch(100)
+
+// DefaultTransferStartTimeout is how long an accepted deal waits for the client to start
+// sending its data before the deal fails and its data transfer slot is given up
+var DefaultTransferStartTimeout = 10 * time.Minute
 var _ storagemarket.StorageProvider = &Provider{}
 
 type StoredAsk interface {
 	GetAsk(address.Address) *storagemarket.SignedStorageAsk
+	GetAsks(address.Address) []*storagemarket.SignedStorageAsk
 	AddAsk(price abi.TokenAmount, duration abi.ChainEpoch, options ...storagemarket.StorageAskOption) error
+	AddAskTier(price abi.TokenAmount, duration abi.ChainEpoch, options ...storagemarket.StorageAskOption) error
+}
+
+// AskRenewer is a StoredAsk that can renew its asks in the background as they approach
+// expiry. A provider renews the asks of each of its miner actors while it is running
+type AskRenewer interface {
+	Start(ctx context.Context)
 }
 
 // Provider is a storage provider implementation
@@ -50,12 +71,27 @@ type Provider struct {
 	pio                       pieceio.PieceIOWithStore
 	pieceStore                piecestore.PieceStore
 	conns                     *connmanager.ConnManager
-	storedAsk                 StoredAsk
+	actorsLk                  sync.RWMutex
+	storedAsks                map[address.Address]StoredAsk
+	actors                    []address.Address
+	renewalCtx                context.Context
+	stopRenewals              context.CancelFunc
 	actor                     address.Address
 	dataTransfer              datatransfer.Manager
 	universalRetrievalEnabled bool
 	customDealDeciderFunc     DealDeciderFunc
 	dealAcceptanceBuffer      abi.ChainEpoch
+	publishPeriod             time.Duration
+	maxDealsPerPublishMsg     uint64
+	dealPublisher             *DealPublisher
+	retryPolicy               storagemarket.RetryPolicy
+	stagingSpaceBudget        uint64
+	stagingSpace              *StagingSpace
+	transferSlots             *dealslots.DealSlots
+	transferStartTimeout      time.Duration
+	commPSlots                *dealslots.DealSlots
+	journal                   *journal.Journal
+	dealMetrics               *metrics.DealTracker
 	pubSub                    *pubsub.PubSub
 
 	deals fsm.Group
@@ -72,6 +108,25 @@ func EnableUniversalRetrieval() StorageProviderOption {
 	}
 }
 
+// MinerActor causes a storage provider to also serve deals for the given miner actor, using
+// the given stored ask. Proposals are routed to a miner actor by their Provider address
+func MinerActor(minerAddress address.Address, storedAsk StoredAsk) StorageProviderOption {
+	return func(p *Provider) {
+		p.actorsLk.Lock()
+		defer p.actorsLk.Unlock()
+		if p.storedAsks == nil {
+			p.storedAsks = make(map[address.Address]StoredAsk)
+		}
+		if _, ok := p.storedAsks[minerAddress]; !ok {
+			p.actors = append(p.actors, minerAddress)
+		}
+		p.storedAsks[minerAddress] = storedAsk
+		if p.renewalCtx != nil {
+			startRenewal(p.renewalCtx, storedAsk)
+		}
+	}
+}
+
 // DealAcceptanceBuffer allows a provider to set a buffer (in epochs) to account for the time
 // required for data transfer, deal verification, publishing, sealing, and committing.
 func DealAcceptanceBuffer(buffer abi.ChainEpoch) StorageProviderOption {
@@ -80,12 +135,85 @@ func DealAcceptanceBuffer(buffer abi.ChainEpoch) StorageProviderOption {
 	}
 }
 
+// BatchPublishDeals causes a storage provider to collect deals that are ready to be published
+// for up to the given period, and publish them together in messages of at most maxDealsPerMsg deals
+func BatchPublishDeals(publishPeriod time.Duration, maxDealsPerMsg uint64) StorageProviderOption {
+	return func(p *Provider) {
+		p.publishPeriod = publishPeriod
+		p.maxDealsPerPublishMsg = maxDealsPerMsg
+	}
+}
+
+// NodeRetryPolicy sets how a storage provider retries node calls that fail with transient
+// errors while processing a deal. The zero value disables retries
+func NodeRetryPolicy(policy storagemarket.RetryPolicy) StorageProviderOption {
+	return func(p *Provider) {
+		p.retryPolicy = policy
+	}
+}
+
+// StagingSpaceBudget limits the space in the provider's FileStore that can be reserved for
+// data staged for deals. Space for a deal is reserved when it is accepted and released once
+// the deal completes or fails. Deals that would exceed the budget are rejected. A budget of
+// zero means the budget is the free space on the FileStore's disk when the provider is
+// created, if the FileStore can report it, and that there is no limit otherwise
+func StagingSpaceBudget(budget uint64) StorageProviderOption {
+	return func(p *Provider) {
+		p.stagingSpaceBudget = budget
+	}
+}
+
+// MaxConcurrentTransfers limits how many deals can transfer data to the provider at once.
+// Accepted deals wait in the StorageDealTransferQueued state, in the order they were accepted,
+// until a transfer finishes. Zero means there is no limit. The limit can be changed while
+// the provider is running by passing this option to Configure
+func MaxConcurrentTransfers(limit uint64) StorageProviderOption {
+	return func(p *Provider) {
+		p.transferSlots.SetLimit(limit)
+	}
+}
+
+// TransferStartTimeout sets how long an accepted deal waits for the client to start sending
+// its data. Deals the client does not start sending data for in time fail, so their data
+// transfer slot goes to the next queued deal. Zero means deals wait indefinitely
+func TransferStartTimeout(timeout time.Duration) StorageProviderOption {
+	return func(p *Provider) {
+		p.transferStartTimeout = timeout
+	}
+}
+
+// MaxConcurrentCommP limits how many deals can generate piece commitments for their data
+// at once. Deals wait in the StorageDealVerifyDataQueued state, in the order their transfers
+// finished, until a slot is free. Zero means there is no limit. The limit can be changed while
+// the provider is running by passing this option to Configure
+func MaxConcurrentCommP(limit uint64) StorageProviderOption {
+	return func(p *Provider) {
+		p.commPSlots.SetLimit(limit)
+	}
+}
+
+// DealJournal records every event that happens to the provider's deals in the given journal
+func DealJournal(j *journal.Journal) StorageProviderOption {
+	return func(p *Provider) {
+		p.journal = j
+	}
+}
+
+// MetricsRecorder sends measurements of the provider's deals to the given recorder
+func MetricsRecorder(recorder metrics.Recorder) StorageProviderOption {
+	return func(p *Provider) {
+		p.dealMetrics.SetRecorder(recorder)
+	}
+}
+
 // DealDeciderFunc is a function which evaluates an incoming deal to decide if
 // it its accepted
 // It returns:
 // - boolean = true if deal accepted, false if rejected
 // - string = reason deal was not excepted, if rejected
 // - error = if an error occurred trying to decide
+// Deals rejected with a reason string are rejected with storagemarket.RejectionDeclined.
+// To reject a deal with a different reason code, return a *storagemarket.DealRejectedError
 type DealDeciderFunc func(context.Context, storagemarket.MinerDeal) (bool, string, error)
 
 // CustomDealDecisionLogic allows a provider to call custom decision logic when validating incoming
@@ -96,6 +224,11 @@ func CustomDealDecisionLogic(decider DealDeciderFunc) StorageProviderOption {
 	}
 }
 
+// DealFilters runs the given filters, in order, to decide whether to accept a deal
+func DealFilters(filters ...dealfilter.Filter) StorageProviderOption {
+	return CustomDealDecisionLogic(dealfilter.Pipeline(filters...))
+}
+
 // NewProvider returns a new storage provider
 func NewProvider(net network.StorageMarketNetwork,
 	ds datastore.Batching,
@@ -120,10 +253,16 @@ func NewProvider(net network.StorageMarketNetwork,
 		pio:                  pio,
 		pieceStore:           pieceStore,
 		conns:                connmanager.NewConnManager(),
-		storedAsk:            storedAsk,
+		storedAsks:           map[address.Address]StoredAsk{minerAddress: storedAsk},
+		actors:               []address.Address{minerAddress},
 		actor:                minerAddress,
 		dataTransfer:         dataTransfer,
 		dealAcceptanceBuffer: DefaultDealAcceptanceBuffer,
+		retryPolicy:          storagemarket.DefaultRetryPolicy,
+		transferSlots:        dealslots.NewDealSlots(0),
+		transferStartTimeout: DefaultTransferStartTimeout,
+		commPSlots:           dealslots.NewDealSlots(0),
+		dealMetrics:          metrics.NewDealTracker(shared.StorageProvider),
 		pubSub:               pubsub.New(providerDispatcher),
 	}
 
@@ -131,7 +270,7 @@ func NewProvider(net network.StorageMarketNetwork,
 		Environment:     &providerDealEnvironment{h},
 		StateType:       storagemarket.MinerDeal{},
 		StateKeyField:   "State",
-		Events:          providerstates.ProviderEvents,
+		Events:          providerstates.NewProviderEvents(journal.RecordArgs(h.recordEventArgs)),
 		StateEntryFuncs: providerstates.ProviderStateEntryFuncs,
 		Notifier:        h.dispatch,
 	})
@@ -143,21 +282,81 @@ func NewProvider(net network.StorageMarketNetwork,
 
 	h.Configure(options...)
 
+	h.dealPublisher = NewDealPublisher(spn, h.publishPeriod, h.maxDealsPerPublishMsg)
+	h.stagingSpace = NewStagingSpace(h.stagingSpaceBudget, fs)
+
 	// register a data transfer event handler -- this will send events to the state machines based on DT events
 	dataTransfer.SubscribeToEvents(dtutils.ProviderDataTransferSubscriber(deals))
+	dataTransfer.SubscribeToEvents(dtutils.TransferMetricsSubscriber(h.dealMetrics))
 
 	return h, nil
 }
 
+// Start initializes deal processing on a Provider, restarts deals that were in progress
+// when the provider was last stopped, starts renewing the asks of its miner actors and
+// sets up network handlers for incoming deals
 func (p *Provider) Start(ctx context.Context) error {
-	// TODO: restore state
-	err := p.net.SetDelegate(p)
+	err := p.restartDeals()
+	if err != nil {
+		return xerrors.Errorf("restarting deals: %w", err)
+	}
+
+	p.actorsLk.Lock()
+	p.renewalCtx, p.stopRenewals = context.WithCancel(context.Background())
+	for _, storedAsk := range p.storedAsks {
+		startRenewal(p.renewalCtx, storedAsk)
+	}
+	p.actorsLk.Unlock()
+
+	err = p.net.SetDelegate(p)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// startRenewal renews the asks of a stored ask in the background, if it can renew them
+func startRenewal(ctx context.Context, storedAsk StoredAsk) {
+	if renewer, ok := storedAsk.(AskRenewer); ok {
+		renewer.Start(ctx)
+	}
+}
+
+// restartDeals re-enters the current state of every deal that was still being processed
+// when the provider was stopped. Deals that depended on a stream to the client can't be
+// resumed, so they are failed instead
+func (p *Provider) restartDeals() error {
+	var deals []storagemarket.MinerDeal
+	err := p.deals.List(&deals)
+	if err != nil {
+		return err
+	}
+
+	for _, deal := range deals {
+		final := providerstates.IsFinalityState(deal.State)
+		p.dealMetrics.Restore(deal.ProposalCid.String(), storagemarket.DealStates[deal.State], final)
+		if final {
+			continue
+		}
+
+		if providerstates.HasStagingSpace(deal.State) {
+			p.stagingSpace.Restore(deal.ProposalCid, StagingSpaceForPiece(deal.Proposal.PieceSize))
+		}
+
+		evt := storagemarket.ProviderEventRestart
+		if !providerstates.CanResume(deal) {
+			log.Warnf("deal %s cannot be resumed in state %s, failing", deal.ProposalCid, storagemarket.DealStates[deal.State])
+			evt = storagemarket.ProviderEventRestartFailed
+		}
+
+		err = p.deals.Send(deal.ProposalCid, evt)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (p *Provider) HandleDealStream(s network.StorageDealStream) {
 	log.Info("Handling storage deal proposal!")
 
@@ -175,6 +374,10 @@ func (p *Provider) receiveDeal(s network.StorageDealStream) error {
 		return xerrors.Errorf("failed to read proposal message: %w", err)
 	}
 
+	if proposal.Cancel != nil {
+		return p.receiveDealCancel(s, *proposal.Cancel)
+	}
+
 	proposalNd, err := cborutil.AsIpld(proposal.DealProposal)
 	if err != nil {
 		return err
@@ -187,6 +390,7 @@ func (p *Provider) receiveDeal(s network.StorageDealStream) error {
 		ProposalCid:        proposalNd.Cid(),
 		State:              storagemarket.StorageDealUnknown,
 		Ref:                proposal.Piece,
+		Labels:             proposal.Labels,
 	}
 
 	err = p.deals.Begin(proposalNd.Cid(), deal)
@@ -201,19 +405,37 @@ func (p *Provider) receiveDeal(s network.StorageDealStream) error {
 }
 
 func (p *Provider) Stop() error {
+	p.actorsLk.Lock()
+	if p.stopRenewals != nil {
+		p.stopRenewals()
+		p.renewalCtx, p.stopRenewals = nil, nil
+	}
+	p.actorsLk.Unlock()
+
 	err := p.deals.Stop(context.TODO())
 	if err != nil {
 		return err
 	}
+	// deals are stopped first, so the deals released from waiting on the publisher are
+	// published again when the provider restarts instead of failing
+	p.dealPublisher.Stop()
 	return p.net.StopHandlingRequests()
 }
 
 func (p *Provider) ImportDataForDeal(ctx context.Context, propCid cid.Cid, data io.Reader) error {
-	// TODO: be able to check if we have enough disk space
 	var d storagemarket.MinerDeal
 	if err := p.deals.Get(propCid).Get(&d); err != nil {
 		return xerrors.Errorf("failed getting deal %s: %w", propCid, err)
 	}
+	if providerstates.IsFinalityState(d.State) {
+		return xerrors.Errorf("deal %s has already finished (%s)", propCid, storagemarket.DealStates[d.State])
+	}
+
+	// imports share the slots for generating piece commitments with transferred deals
+	if err := p.commPSlots.Acquire(ctx, propCid); err != nil {
+		return xerrors.Errorf("waiting to generate piece commitment: %w", err)
+	}
+	defer p.commPSlots.Release(propCid)
 
 	tempfi, err := p.fs.CreateTemp()
 	if err != nil {
@@ -224,44 +446,91 @@ func (p *Provider) ImportDataForDeal(ctx context.Context, propCid cid.Cid, data
 		_ = p.fs.Delete(tempfi.Path())
 	}
 
-	n, err := io.Copy(tempfi, data)
+	// record the location of every block in the imported CAR alongside the commP
+	// calculation, so all its CIDs can be retrieved. This does not depend on universal
+	// retrieval being enabled, as the blocks are read in the same pass as the commP
+	// calculation and recording them costs no extra read of the data
+	metadataFile, err := p.fs.CreateTemp()
 	if err != nil {
 		cleanup()
-		return xerrors.Errorf("importing deal data failed: %w", err)
+		return xerrors.Errorf("failed to create temp file for block metadata: %w", err)
+	}
+	cleanupAll := func() {
+		cleanup()
+		_ = metadataFile.Close()
+		_ = p.fs.Delete(metadataFile.Path())
 	}
 
-	_ = n // TODO: verify n?
+	pr, pw := io.Pipe()
+	recordErr := make(chan error, 1)
+	go func() {
+		err := blockrecorder.RecordCarBlocks(pr, uint64(d.Proposal.PieceSize), metadataFile)
+		// keep draining so the import is never blocked on a failed recording
+		_, _ = io.Copy(ioutil.Discard, pr)
+		recordErr <- err
+	}()
+
+	start := time.Now()
+	pieceCid, _, err := pieceio.GeneratePieceCommitmentFromStream(ctx, p.proofType, data, io.MultiWriter(tempfi, pw), d.Proposal.PieceSize)
+	_ = pw.CloseWithError(err)
+	if rerr := <-recordErr; err == nil && rerr != nil {
+		err = xerrors.Errorf("reading imported data as a CAR file: %w", rerr)
+	}
+	if err != nil {
+		cleanupAll()
+		return xerrors.Errorf("importing deal data failed: %w", err)
+	}
+	p.dealMetrics.CommPDuration(time.Since(start))
+	if err := verifyImportedPiece(pieceCid, d); err != nil {
+		cleanupAll()
+		return err
+	}
 
-	pieceSize := uint64(tempfi.Size())
+	return p.deals.Send(propCid, storagemarket.ProviderEventVerifiedData, tempfi.Path(), metadataFile.Path())
+}
 
-	_, err = tempfi.Seek(0, io.SeekStart)
-	if err != nil {
-		cleanup()
-		return xerrors.Errorf("failed to seek through temp imported file: %w", err)
+// CancelDeal aborts a deal that has not yet been published. Deals that are being published,
+// or have been published already, cannot be cancelled
+func (p *Provider) CancelDeal(ctx context.Context, propCid cid.Cid, reason string) error {
+	var d storagemarket.MinerDeal
+	if err := p.deals.Get(propCid).Get(&d); err != nil {
+		return xerrors.Errorf("failed getting deal %s: %w", propCid, err)
 	}
 
-	pieceCid, _, err := pieceio.GeneratePieceCommitment(p.proofType, tempfi, pieceSize)
-	if err != nil {
-		cleanup()
-		return xerrors.Errorf("failed to generate commP")
+	if providerstates.IsFinalityState(d.State) || d.State == storagemarket.StorageDealFailing {
+		return xerrors.Errorf("deal %s has already finished (%s)", propCid, storagemarket.DealStates[d.State])
+	}
+	if d.State == storagemarket.StorageDealCancelling {
+		return xerrors.Errorf("deal %s is already being cancelled", propCid)
+	}
+	if !providerstates.CanCancel(d.State) {
+		return xerrors.Errorf("deal %s has already been published (%s) and can no longer be cancelled", propCid, storagemarket.DealStates[d.State])
 	}
 
-	// Verify CommP matches
+	return p.deals.Send(propCid, storagemarket.ProviderEventCancelled, reason)
+}
+
+func verifyImportedPiece(pieceCid cid.Cid, d storagemarket.MinerDeal) error {
 	if !pieceCid.Equals(d.Proposal.PieceCID) {
-		cleanup()
 		return xerrors.Errorf("given data does not match expected commP (got: %x, expected %x)", pieceCid, d.Proposal.PieceCID)
 	}
-
-	return p.deals.Send(propCid, storagemarket.ProviderEventVerifiedData, tempfi.Path(), filestore.Path(""))
-
+	return nil
 }
 
 func (p *Provider) ListAsks(addr address.Address) []*storagemarket.SignedStorageAsk {
-	ask := p.storedAsk.GetAsk(addr)
-	if ask != nil {
-		return []*storagemarket.SignedStorageAsk{ask}
+	storedAsk, ok := p.lookupStoredAsk(addr)
+	if !ok {
+		return nil
 	}
-	return nil
+	return storedAsk.GetAsks(addr)
+}
+
+// MinerActors returns the addresses of the miner actors this provider serves deals for,
+// starting with the address it was created with
+func (p *Provider) MinerActors() []address.Address {
+	p.actorsLk.RLock()
+	defer p.actorsLk.RUnlock()
+	return append([]address.Address(nil), p.actors...)
 }
 
 func (p *Provider) ListDeals(ctx context.Context) ([]storagemarket.StorageDeal, error) {
@@ -270,13 +539,29 @@ func (p *Provider) ListDeals(ctx context.Context) ([]storagemarket.StorageDeal,
 		return nil, err
 	}
 
-	return p.spn.ListProviderDeals(ctx, p.actor, tok)
+	var deals []storagemarket.StorageDeal
+	for _, actor := range p.MinerActors() {
+		actorDeals, err := p.spn.ListProviderDeals(ctx, actor, tok)
+		if err != nil {
+			return nil, err
+		}
+		deals = append(deals, actorDeals...)
+	}
+	return deals, nil
 }
 
 func (p *Provider) AddStorageCollateral(ctx context.Context, amount abi.TokenAmount) error {
+	return p.AddMinerStorageCollateral(ctx, p.actor, amount)
+}
+
+func (p *Provider) AddMinerStorageCollateral(ctx context.Context, miner address.Address, amount abi.TokenAmount) error {
+	if _, err := p.storedAskFor(miner); err != nil {
+		return err
+	}
+
 	done := make(chan error, 1)
 
-	mcid, err := p.spn.AddFunds(ctx, p.actor, amount)
+	mcid, err := p.spn.AddFunds(ctx, miner, amount)
 	if err != nil {
 		return err
 	}
@@ -300,24 +585,79 @@ func (p *Provider) AddStorageCollateral(ctx context.Context, amount abi.TokenAmo
 }
 
 func (p *Provider) GetStorageCollateral(ctx context.Context) (storagemarket.Balance, error) {
+	return p.GetMinerStorageCollateral(ctx, p.actor)
+}
+
+func (p *Provider) GetMinerStorageCollateral(ctx context.Context, miner address.Address) (storagemarket.Balance, error) {
+	if _, err := p.storedAskFor(miner); err != nil {
+		return storagemarket.Balance{}, err
+	}
+
 	tok, _, err := p.spn.GetChainHead(ctx)
 	if err != nil {
 		return storagemarket.Balance{}, err
 	}
 
-	return p.spn.GetBalance(ctx, p.actor, tok)
+	return p.spn.GetBalance(ctx, miner, tok)
 }
 
-func (p *Provider) ListLocalDeals() ([]storagemarket.MinerDeal, error) {
-	var out []storagemarket.MinerDeal
-	if err := p.deals.List(&out); err != nil {
+func (p *Provider) ListLocalDeals(selectors ...storagemarket.LabelSelector) ([]storagemarket.MinerDeal, error) {
+	var deals []storagemarket.MinerDeal
+	if err := p.deals.List(&deals); err != nil {
 		return nil, err
 	}
+	if len(selectors) == 0 {
+		return deals, nil
+	}
+
+	out := make([]storagemarket.MinerDeal, 0, len(deals))
+	for _, deal := range deals {
+		if storagemarket.SelectLabels(deal.Labels, selectors) {
+			out = append(out, deal)
+		}
+	}
 	return out, nil
 }
 
 func (p *Provider) AddAsk(price abi.TokenAmount, duration abi.ChainEpoch, options ...storagemarket.StorageAskOption) error {
-	return p.storedAsk.AddAsk(price, duration, options...)
+	return p.AddMinerAsk(p.actor, price, duration, options...)
+}
+
+func (p *Provider) AddAskTier(price abi.TokenAmount, duration abi.ChainEpoch, options ...storagemarket.StorageAskOption) error {
+	return p.AddMinerAskTier(p.actor, price, duration, options...)
+}
+
+func (p *Provider) AddMinerAsk(miner address.Address, price abi.TokenAmount, duration abi.ChainEpoch, options ...storagemarket.StorageAskOption) error {
+	storedAsk, err := p.storedAskFor(miner)
+	if err != nil {
+		return err
+	}
+	return storedAsk.AddAsk(price, duration, options...)
+}
+
+func (p *Provider) AddMinerAskTier(miner address.Address, price abi.TokenAmount, duration abi.ChainEpoch, options ...storagemarket.StorageAskOption) error {
+	storedAsk, err := p.storedAskFor(miner)
+	if err != nil {
+		return err
+	}
+	return storedAsk.AddAskTier(price, duration, options...)
+}
+
+// storedAskFor returns the stored ask of a miner actor served by this provider
+func (p *Provider) storedAskFor(miner address.Address) (StoredAsk, error) {
+	storedAsk, ok := p.lookupStoredAsk(miner)
+	if !ok {
+		return nil, xerrors.Errorf("provider does not serve miner actor %s", miner)
+	}
+	return storedAsk, nil
+}
+
+// lookupStoredAsk returns the stored ask of a miner actor, if this provider serves it
+func (p *Provider) lookupStoredAsk(miner address.Address) (StoredAsk, bool) {
+	p.actorsLk.RLock()
+	defer p.actorsLk.RUnlock()
+	storedAsk, ok := p.storedAsks[miner]
+	return storedAsk, ok
 }
 
 func (p *Provider) HandleAskStream(s network.StorageAskStream) {
@@ -328,8 +668,19 @@ func (p *Provider) HandleAskStream(s network.StorageAskStream) {
 		return
 	}
 
-	resp := network.AskResponse{
-		Ask: p.storedAsk.GetAsk(ar.Miner),
+	var resp network.AskResponse
+	if storedAsk, ok := p.lookupStoredAsk(ar.Miner); ok {
+		asks, err := p.unexpiredAsks(storedAsk.GetAsks(ar.Miner))
+		if err != nil {
+			log.Errorf("failed to check ask expiry: %s", err)
+			return
+		}
+		if len(asks) > 0 {
+			resp.Ask = asks[0]
+		}
+		if len(asks) > 1 {
+			resp.Asks = asks
+		}
 	}
 
 	if err := s.WriteAskResponse(resp); err != nil {
@@ -338,6 +689,192 @@ func (p *Provider) HandleAskStream(s network.StorageAskStream) {
 	}
 }
 
+// unexpiredAsks filters out asks that have expired as of the current chain height
+func (p *Provider) unexpiredAsks(asks []*storagemarket.SignedStorageAsk) ([]*storagemarket.SignedStorageAsk, error) {
+	_, height, err := p.spn.GetChainHead(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+
+	var out []*storagemarket.SignedStorageAsk
+	for _, ask := range asks {
+		if ask.Ask.Expiry > height {
+			out = append(out, ask)
+		}
+	}
+	return out, nil
+}
+
+// HandleDealStatusStream reports the state of a deal to the client that proposed it
+func (p *Provider) HandleDealStatusStream(s network.DealStatusStream) {
+	ctx := context.TODO()
+	defer s.Close()
+	request, err := s.ReadDealStatusRequest()
+	if err != nil {
+		log.Errorf("failed to read DealStatusRequest from incoming stream: %s", err)
+		return
+	}
+
+	dealState, miner := p.providerDealState(request.Proposal, s.RemotePeer())
+
+	tok, _, err := p.spn.GetChainHead(ctx)
+	if err != nil {
+		log.Errorf("failed to get chain head: %s", err)
+		return
+	}
+
+	sig, err := providerutils.SignMinerData(ctx, &dealState, miner, tok, p.spn.GetMinerWorkerAddress, p.spn.SignBytes)
+	if err != nil {
+		log.Errorf("failed to sign deal status response: %s", err)
+		return
+	}
+
+	resp := network.DealStatusResponse{
+		DealState: dealState,
+		Signature: sig,
+	}
+	if err := s.WriteDealStatusResponse(resp); err != nil {
+		log.Errorf("failed to write deal status response: %s", err)
+		return
+	}
+}
+
+// receiveDealCancel cancels a deal at the request of the client that proposed it, and
+// replies with a signed response. The response is in the StorageDealCancelled state if
+// the deal was cancelled, and holds the reason otherwise
+func (p *Provider) receiveDealCancel(s network.StorageDealStream, sdc network.SignedDealCancel) error {
+	ctx, cancel := context.WithTimeout(context.TODO(), clientCancelTimeout)
+	defer cancel()
+	defer s.Close()
+
+	proposalCid := sdc.DealCancel.Proposal
+	resp := network.Response{
+		State:    storagemarket.StorageDealCancelled,
+		Proposal: proposalCid,
+	}
+	deal, err := p.clientCancelDeal(ctx, sdc, s.RemotePeer())
+	if err != nil {
+		log.Warnf("refusing cancellation of deal %s: %s", proposalCid, err)
+		resp.State = deal.State
+		resp.Message = err.Error()
+	}
+
+	miner := p.actor
+	if deal.Proposal.Provider != address.Undef {
+		miner = p.signerFor(deal.Proposal.Provider)
+	}
+	tok, _, err := p.spn.GetChainHead(ctx)
+	if err != nil {
+		return xerrors.Errorf("couldn't get chain head: %w", err)
+	}
+	sig, err := providerutils.SignMinerData(ctx, &resp, miner, tok, p.spn.GetMinerWorkerAddress, p.spn.SignBytes)
+	if err != nil {
+		return xerrors.Errorf("failed to sign deal cancellation response: %w", err)
+	}
+
+	// only versions of the deal protocol that encode responses the current way can carry
+	// a cancellation, so the response never has to be signed again
+	return s.WriteDealResponse(network.SignedResponse{Response: resp, Signature: sig}, nil)
+}
+
+// clientCancelTimeout is how long a provider waits for a deal to be cancelled before it
+// refuses a client's cancellation
+const clientCancelTimeout = time.Minute
+
+// clientCancelDeal cancels a deal if the cancellation is signed by the deal's client, and
+// comes from the peer that proposed it. It waits for the deal to either be cancelled or
+// move past the states it can be cancelled from, and returns the deal in its latest state
+func (p *Provider) clientCancelDeal(ctx context.Context, sdc network.SignedDealCancel, requester peer.ID) (storagemarket.MinerDeal, error) {
+	proposalCid := sdc.DealCancel.Proposal
+
+	// watch the deal before checking its state, so no change of state is missed
+	outcome := make(chan storagemarket.MinerDeal, 1)
+	unsubscribe := p.SubscribeToEvents(func(event storagemarket.ProviderEvent, deal storagemarket.MinerDeal) {
+		if !deal.ProposalCid.Equals(proposalCid) || providerstates.CanCancel(deal.State) {
+			return
+		}
+		select {
+		case outcome <- deal:
+		default:
+		}
+	})
+	defer unsubscribe()
+
+	var deal storagemarket.MinerDeal
+	err := p.deals.Get(proposalCid).Get(&deal)
+	if err != nil || deal.Client != requester {
+		return storagemarket.MinerDeal{State: storagemarket.StorageDealProposalNotFound}, xerrors.New("deal not found")
+	}
+
+	if sdc.Signature == nil {
+		return deal, xerrors.New("cancellation is not signed")
+	}
+	tok, _, err := p.spn.GetChainHead(ctx)
+	if err != nil {
+		return deal, xerrors.Errorf("getting chain head: %w", err)
+	}
+	msg, err := cborutil.Dump(&sdc.DealCancel)
+	if err != nil {
+		return deal, xerrors.Errorf("serializing deal cancellation: %w", err)
+	}
+	valid, err := p.spn.VerifySignature(ctx, *sdc.Signature, deal.Proposal.Client, msg, tok)
+	if err != nil {
+		return deal, xerrors.Errorf("verifying signature: %w", err)
+	}
+	if !valid {
+		return deal, xerrors.New("cancellation is not signed by the deal client")
+	}
+
+	if !providerstates.CanCancel(deal.State) {
+		return deal, xerrors.Errorf("deal can no longer be cancelled (%s)", storagemarket.DealStates[deal.State])
+	}
+	if err := p.deals.Send(proposalCid, storagemarket.ProviderEventClientCancelled, sdc.DealCancel.Reason); err != nil {
+		return deal, xerrors.Errorf("cancelling deal: %w", err)
+	}
+
+	select {
+	case deal = <-outcome:
+	case <-ctx.Done():
+		return deal, xerrors.Errorf("waiting for deal to be cancelled: %w", ctx.Err())
+	}
+	// once a deal is cancelling it goes on to be cancelled
+	if deal.State != storagemarket.StorageDealCancelling && deal.State != storagemarket.StorageDealCancelled {
+		return deal, xerrors.Errorf("deal can no longer be cancelled (%s)", storagemarket.DealStates[deal.State])
+	}
+	return deal, nil
+}
+
+// providerDealState looks up the state of a deal for a status request, along with the miner
+// actor that should sign it. Deals are only reported to the peer that proposed them
+func (p *Provider) providerDealState(proposalCid cid.Cid, requester peer.ID) (storagemarket.ProviderDealState, address.Address) {
+	var deal storagemarket.MinerDeal
+	err := p.deals.Get(proposalCid).Get(&deal)
+	if err != nil || deal.Client != requester {
+		return storagemarket.ProviderDealState{
+			State:    storagemarket.StorageDealProposalNotFound,
+			Message:  "deal not found",
+			Proposal: proposalCid,
+		}, p.actor
+	}
+
+	return storagemarket.ProviderDealState{
+		State:      deal.State,
+		Message:    deal.Message,
+		Proposal:   deal.ProposalCid,
+		PublishCid: deal.PublishCid,
+		DealID:     deal.DealID,
+	}, p.signerFor(deal.Proposal.Provider)
+}
+
+// signerFor returns the miner actor that signs messages about a deal with the given provider.
+// Deals proposed to a miner actor this provider does not serve are answered by the default actor
+func (p *Provider) signerFor(miner address.Address) address.Address {
+	if _, ok := p.lookupStoredAsk(miner); ok {
+		return miner
+	}
+	return p.actor
+}
+
 func (p *Provider) Configure(options ...StorageProviderOption) {
 	for _, option := range options {
 		option(p)
@@ -356,6 +893,13 @@ func (p *Provider) SubscribeToEvents(subscriber storagemarket.ProviderSubscriber
 	return shared.Unsubscribe(p.pubSub.Subscribe(subscriber))
 }
 
+// recordEventArgs keeps the arguments of an event for the deal journal, if there is one
+func (p *Provider) recordEventArgs(event fsm.EventName, state interface{}, args []interface{}) {
+	if p.journal != nil {
+		p.journal.AddArgs(shared.StorageProvider, state.(*storagemarket.MinerDeal).ProposalCid.String(), event, args)
+	}
+}
+
 // dispatch puts the fsm event into a form that pubSub can consume,
 // then publishes the event
 func (p *Provider) dispatch(eventName fsm.EventName, deal fsm.StateType) {
@@ -372,6 +916,22 @@ func (p *Provider) dispatch(eventName fsm.EventName, deal fsm.StateType) {
 	if err := p.pubSub.Publish(pubSubEvt); err != nil {
 		log.Errorf("failed to publish event %d", evt)
 	}
+
+	p.dealMetrics.Observe(realDeal.ProposalCid.String(), storagemarket.ProviderEvents[evt], storagemarket.DealStates[realDeal.State], providerstates.IsFinalityState(realDeal.State))
+
+	if p.journal != nil {
+		err := p.journal.Record(journal.Entry{
+			Market:  shared.StorageProvider,
+			Deal:    realDeal.ProposalCid.String(),
+			Event:   storagemarket.ProviderEvents[evt],
+			Args:    p.journal.TakeArgs(shared.StorageProvider, realDeal.ProposalCid.String(), eventName),
+			State:   storagemarket.DealStates[realDeal.State],
+			Message: realDeal.Message,
+		})
+		if err != nil {
+			log.Errorf("failed to journal event %d: %s", evt, err)
+		}
+	}
 }
 
 type internalProviderEvent struct {
@@ -400,20 +960,26 @@ type providerDealEnvironment struct {
 	p *Provider
 }
 
-func (p *providerDealEnvironment) Address() address.Address {
-	return p.p.actor
+func (p *providerDealEnvironment) IsMinerActor(addr address.Address) bool {
+	_, ok := p.p.lookupStoredAsk(addr)
+	return ok
 }
 
 func (p *providerDealEnvironment) Node() storagemarket.StorageProviderNode {
 	return p.p.spn
 }
 
-func (p *providerDealEnvironment) Ask() storagemarket.StorageAsk {
-	sask := p.p.storedAsk.GetAsk(p.p.actor)
-	if sask == nil {
-		return storagemarket.StorageAskUndefined
+func (p *providerDealEnvironment) Asks(miner address.Address) []storagemarket.StorageAsk {
+	storedAsk, ok := p.p.lookupStoredAsk(miner)
+	if !ok {
+		return nil
+	}
+	sasks := storedAsk.GetAsks(miner)
+	asks := make([]storagemarket.StorageAsk, 0, len(sasks))
+	for _, sask := range sasks {
+		asks = append(asks, *sask.Ask)
 	}
-	return *sask.Ask
+	return asks
 }
 
 func (p *providerDealEnvironment) StartDataTransfer(ctx context.Context, to peer.ID, voucher datatransfer.Voucher, baseCid cid.Cid, selector ipld.Node) error {
@@ -421,7 +987,20 @@ func (p *providerDealEnvironment) StartDataTransfer(ctx context.Context, to peer
 	return err
 }
 
+func (p *providerDealEnvironment) CloseDataTransfer(proposalCid cid.Cid) {
+	dtutils.CloseDealTransfers(p.p.dataTransfer, proposalCid)
+}
+
 func (p *providerDealEnvironment) GeneratePieceCommitmentToFile(payloadCid cid.Cid, selector ipld.Node) (cid.Cid, filestore.Path, filestore.Path, error) {
+	start := time.Now()
+	pieceCid, piecePath, metadataPath, err := p.generatePieceCommitmentToFile(payloadCid, selector)
+	if err == nil {
+		p.p.dealMetrics.CommPDuration(time.Since(start))
+	}
+	return pieceCid, piecePath, metadataPath, err
+}
+
+func (p *providerDealEnvironment) generatePieceCommitmentToFile(payloadCid cid.Cid, selector ipld.Node) (cid.Cid, filestore.Path, filestore.Path, error) {
 	if p.p.universalRetrievalEnabled {
 		return providerutils.GeneratePieceCommitmentWithMetadata(p.p.fs, p.p.pio.GeneratePieceCommitmentToFile, p.p.proofType, payloadCid, selector)
 	}
@@ -437,7 +1016,7 @@ func (p *providerDealEnvironment) PieceStore() piecestore.PieceStore {
 	return p.p.pieceStore
 }
 
-func (p *providerDealEnvironment) SendSignedResponse(ctx context.Context, resp *network.Response) error {
+func (p *providerDealEnvironment) SendSignedResponse(ctx context.Context, miner address.Address, resp *network.Response) error {
 	s, err := p.p.conns.DealStream(resp.Proposal)
 	if err != nil {
 		return xerrors.Errorf("couldn't send response: %w", err)
@@ -448,7 +1027,7 @@ func (p *providerDealEnvironment) SendSignedResponse(ctx context.Context, resp *
 		return xerrors.Errorf("couldn't get chain head: %w", err)
 	}
 
-	sig, err := providerutils.SignMinerData(ctx, resp, p.p.actor, tok, p.Node().GetMinerWorkerAddress, p.Node().SignBytes)
+	sig, err := providerutils.SignMinerData(ctx, resp, p.p.signerFor(miner), tok, p.Node().GetMinerWorkerAddress, p.Node().SignBytes)
 	if err != nil {
 		return xerrors.Errorf("failed to sign response message: %w", err)
 	}
@@ -458,7 +1037,13 @@ func (p *providerDealEnvironment) SendSignedResponse(ctx context.Context, resp *
 		Signature: sig,
 	}
 
-	err = s.WriteDealResponse(signedResponse)
+	// the stream signs the response again if it has to translate it for an older
+	// version of the deal protocol
+	resign := func(data interface{}) (*crypto.Signature, error) {
+		return providerutils.SignMinerData(ctx, data, p.p.signerFor(miner), tok, p.Node().GetMinerWorkerAddress, p.Node().SignBytes)
+	}
+
+	err = s.WriteDealResponse(signedResponse, resign)
 	if err != nil {
 		// Assume client disconnected
 		_ = p.p.conns.Disconnect(resp.Proposal)
@@ -466,6 +1051,10 @@ func (p *providerDealEnvironment) SendSignedResponse(ctx context.Context, resp *
 	return err
 }
 
+func (p *providerDealEnvironment) PublishDeal(ctx context.Context, deal storagemarket.MinerDeal) (cid.Cid, uint64, error) {
+	return p.p.dealPublisher.Publish(ctx, deal)
+}
+
 func (p *providerDealEnvironment) TagConnection(proposalCid cid.Cid) error {
 	s, err := p.p.conns.DealStream(proposalCid)
 	if err != nil {
@@ -488,6 +1077,30 @@ func (p *providerDealEnvironment) DealAcceptanceBuffer() abi.ChainEpoch {
 	return p.p.dealAcceptanceBuffer
 }
 
+func (p *providerDealEnvironment) RetryPolicy() storagemarket.RetryPolicy {
+	return p.p.retryPolicy
+}
+
+func (p *providerDealEnvironment) ReserveStagingSpace(proposalCid cid.Cid, pieceSize abi.PaddedPieceSize) error {
+	return p.p.stagingSpace.Reserve(proposalCid, StagingSpaceForPiece(pieceSize))
+}
+
+func (p *providerDealEnvironment) ReleaseStagingSpace(proposalCid cid.Cid) {
+	p.p.stagingSpace.Release(proposalCid)
+}
+
+func (p *providerDealEnvironment) TransferSlots() *dealslots.DealSlots {
+	return p.p.transferSlots
+}
+
+func (p *providerDealEnvironment) TransferStartTimeout() time.Duration {
+	return p.p.transferStartTimeout
+}
+
+func (p *providerDealEnvironment) CommPSlots() *dealslots.DealSlots {
+	return p.p.commPSlots
+}
+
 func (p *providerDealEnvironment) RunCustomDecisionLogic(ctx context.Context, deal storagemarket.MinerDeal) (bool, string, error) {
 	if p.p.customDealDeciderFunc == nil {
 		return true, "", nil
@@ -3,10 +3,16 @@ package storageimpl_test
 import (
 	"testing"
 
+	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/ipfs/go-datastore"
+	dss "github.com/ipfs/go-datastore/sync"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	storageimpl "github.com/filecoin-project/go-fil-markets/storagemarket/impl"
+	"github.com/filecoin-project/go-fil-markets/storagemarket/impl/storedask"
+	"github.com/filecoin-project/go-fil-markets/storagemarket/testnodes"
 )
 
 func TestConfigure(t *testing.T) {
@@ -23,3 +29,40 @@ func TestConfigure(t *testing.T) {
 	assert.True(t, p.UniversalRetrievalEnabled())
 	assert.Equal(t, abi.ChainEpoch(123), p.DealAcceptanceBuffer())
 }
+
+func TestMinerActors(t *testing.T) {
+	ds := dss.MutexWrap(datastore.NewMapDatastore())
+	spn := &testnodes.FakeProviderNode{
+		FakeCommonNode: testnodes.FakeCommonNode{
+			SMState: testnodes.NewStorageMarketState(),
+		},
+	}
+	actor1, err := address.NewIDAddress(1001)
+	require.NoError(t, err)
+	actor2, err := address.NewIDAddress(1002)
+	require.NoError(t, err)
+	storedAsk1, err := storedask.NewStoredAsk(ds, datastore.NewKey("ask-1"), spn, actor1)
+	require.NoError(t, err)
+	storedAsk2, err := storedask.NewStoredAsk(ds, datastore.NewKey("ask-2"), spn, actor2)
+	require.NoError(t, err)
+
+	p := &storageimpl.Provider{}
+	p.Configure(
+		storageimpl.MinerActor(actor1, storedAsk1),
+		storageimpl.MinerActor(actor2, storedAsk2),
+	)
+	assert.Equal(t, []address.Address{actor1, actor2}, p.MinerActors())
+
+	price := abi.NewTokenAmount(1234)
+	require.NoError(t, p.AddMinerAsk(actor2, price, 100))
+	asks := p.ListAsks(actor2)
+	require.Len(t, asks, 1)
+	assert.Equal(t, actor2, asks[0].Ask.Miner)
+	assert.Equal(t, price, asks[0].Ask.Price)
+	assert.NotEqual(t, price, p.ListAsks(actor1)[0].Ask.Price)
+
+	otherActor, err := address.NewIDAddress(1003)
+	require.NoError(t, err)
+	assert.Nil(t, p.ListAsks(otherActor))
+	assert.Error(t, p.AddMinerAsk(otherActor, price, 100))
+}
@@ -1,131 +1,341 @@
 package providerstates
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/filecoin-project/go-statemachine/fsm"
 	"github.com/filecoin-project/specs-actors/actors/abi"
 	"github.com/ipfs/go-cid"
 	"golang.org/x/xerrors"
 
 	"github.com/filecoin-project/go-fil-markets/filestore"
+	"github.com/filecoin-project/go-fil-markets/journal"
 	"github.com/filecoin-project/go-fil-markets/storagemarket"
 )
 
 // ProviderEvents are the events that can happen in a storage provider
-var ProviderEvents = fsm.Events{
-	fsm.Event(storagemarket.ProviderEventOpen).From(storagemarket.StorageDealUnknown).To(storagemarket.StorageDealValidating),
-	fsm.Event(storagemarket.ProviderEventNodeErrored).FromAny().To(storagemarket.StorageDealFailing).
-		Action(func(deal *storagemarket.MinerDeal, err error) error {
-			deal.Message = xerrors.Errorf("error calling node: %w", err).Error()
-			return nil
-		}),
-	fsm.Event(storagemarket.ProviderEventDealRejected).
-		FromMany(storagemarket.StorageDealValidating, storagemarket.StorageDealVerifyData, storagemarket.StorageDealAcceptWait).To(storagemarket.StorageDealFailing).
-		Action(func(deal *storagemarket.MinerDeal, err error) error {
-			deal.Message = xerrors.Errorf("deal rejected: %w", err).Error()
-			return nil
-		}),
-	fsm.Event(storagemarket.ProviderEventDealDeciding).
-		From(storagemarket.StorageDealValidating).To(storagemarket.StorageDealAcceptWait),
-	fsm.Event(storagemarket.ProviderEventDataRequested).
-		From(storagemarket.StorageDealAcceptWait).To(storagemarket.StorageDealWaitingForData),
-	fsm.Event(storagemarket.ProviderEventDataTransferFailed).
-		From(storagemarket.StorageDealTransferring).To(storagemarket.StorageDealFailing).
-		Action(func(deal *storagemarket.MinerDeal, err error) error {
-			deal.Message = xerrors.Errorf("error transferring data: %w", err).Error()
-			return nil
-		}),
-	fsm.Event(storagemarket.ProviderEventDataTransferInitiated).
-		From(storagemarket.StorageDealWaitingForData).To(storagemarket.StorageDealTransferring),
-	fsm.Event(storagemarket.ProviderEventDataTransferCompleted).
-		From(storagemarket.StorageDealTransferring).To(storagemarket.StorageDealVerifyData),
-	fsm.Event(storagemarket.ProviderEventGeneratePieceCIDFailed).
-		From(storagemarket.StorageDealVerifyData).To(storagemarket.StorageDealFailing).
-		Action(func(deal *storagemarket.MinerDeal, err error) error {
-			deal.Message = xerrors.Errorf("generating piece committment: %w", err).Error()
-			return nil
-		}),
-	fsm.Event(storagemarket.ProviderEventVerifiedData).
-		FromMany(storagemarket.StorageDealVerifyData, storagemarket.StorageDealWaitingForData).To(storagemarket.StorageDealEnsureProviderFunds).
-		Action(func(deal *storagemarket.MinerDeal, path filestore.Path, metadataPath filestore.Path) error {
-			deal.PiecePath = path
-			deal.MetadataPath = metadataPath
-			return nil
-		}),
-	fsm.Event(storagemarket.ProviderEventFundingInitiated).
-		From(storagemarket.StorageDealEnsureProviderFunds).To(storagemarket.StorageDealProviderFunding).
-		Action(func(deal *storagemarket.MinerDeal, mcid cid.Cid) error {
-			deal.AddFundsCid = &mcid
-			return nil
-		}),
-	fsm.Event(storagemarket.ProviderEventFunded).
-		FromMany(storagemarket.StorageDealProviderFunding, storagemarket.StorageDealEnsureProviderFunds).To(storagemarket.StorageDealPublish),
-	fsm.Event(storagemarket.ProviderEventDealPublishInitiated).
-		From(storagemarket.StorageDealPublish).To(storagemarket.StorageDealPublishing).
-		Action(func(deal *storagemarket.MinerDeal, publishCid cid.Cid) error {
-			deal.PublishCid = &publishCid
-			return nil
-		}),
-	fsm.Event(storagemarket.ProviderEventDealPublishError).
-		From(storagemarket.StorageDealPublishing).To(storagemarket.StorageDealFailing).
-		Action(func(deal *storagemarket.MinerDeal, err error) error {
-			deal.Message = xerrors.Errorf("PublishStorageDeal error: %w", err).Error()
-			return nil
-		}),
-	fsm.Event(storagemarket.ProviderEventSendResponseFailed).
-		FromMany(storagemarket.StorageDealAcceptWait, storagemarket.StorageDealPublishing, storagemarket.StorageDealFailing).To(storagemarket.StorageDealError).
-		Action(func(deal *storagemarket.MinerDeal, err error) error {
-			deal.Message = xerrors.Errorf("sending response to deal: %w", err).Error()
-			return nil
-		}),
-	fsm.Event(storagemarket.ProviderEventDealPublished).
-		From(storagemarket.StorageDealPublishing).To(storagemarket.StorageDealStaged).
-		Action(func(deal *storagemarket.MinerDeal, dealID abi.DealID) error {
-			deal.ConnectionClosed = true
-			deal.DealID = dealID
-			return nil
-		}),
-	fsm.Event(storagemarket.ProviderEventFileStoreErrored).
-		FromMany(storagemarket.StorageDealStaged, storagemarket.StorageDealSealing, storagemarket.StorageDealActive).To(storagemarket.StorageDealFailing).
-		Action(func(deal *storagemarket.MinerDeal, err error) error {
-			deal.Message = xerrors.Errorf("accessing file store: %w", err).Error()
-			return nil
-		}),
-	fsm.Event(storagemarket.ProviderEventDealHandoffFailed).From(storagemarket.StorageDealStaged).To(storagemarket.StorageDealFailing).
-		Action(func(deal *storagemarket.MinerDeal, err error) error {
-			deal.Message = xerrors.Errorf("handing off deal to node: %w", err).Error()
-			return nil
-		}),
-	fsm.Event(storagemarket.ProviderEventDealHandedOff).From(storagemarket.StorageDealStaged).To(storagemarket.StorageDealSealing),
-	fsm.Event(storagemarket.ProviderEventDealActivationFailed).
-		From(storagemarket.StorageDealSealing).To(storagemarket.StorageDealFailing).
-		Action(func(deal *storagemarket.MinerDeal, err error) error {
-			deal.Message = xerrors.Errorf("error activating deal: %w", err).Error()
-			return nil
-		}),
-	fsm.Event(storagemarket.ProviderEventDealActivated).From(storagemarket.StorageDealSealing).To(storagemarket.StorageDealActive),
-	fsm.Event(storagemarket.ProviderEventPieceStoreErrored).From(storagemarket.StorageDealActive).To(storagemarket.StorageDealFailing).
-		Action(func(deal *storagemarket.MinerDeal, err error) error {
-			deal.Message = xerrors.Errorf("accessing piece store: %w", err).Error()
-			return nil
-		}),
-	fsm.Event(storagemarket.ProviderEventDealCompleted).From(storagemarket.StorageDealActive).To(storagemarket.StorageDealCompleted),
-	fsm.Event(storagemarket.ProviderEventUnableToLocatePiece).From(storagemarket.StorageDealActive).To(storagemarket.StorageDealFailing).
-		Action(func(deal *storagemarket.MinerDeal, dealID abi.DealID, err error) error {
-			deal.Message = xerrors.Errorf("locating piece for deal ID %d in sector: %w", deal.DealID, err).Error()
-			return nil
-		}),
-	fsm.Event(storagemarket.ProviderEventReadMetadataErrored).From(storagemarket.StorageDealActive).To(storagemarket.StorageDealFailing).
-		Action(func(deal *storagemarket.MinerDeal, err error) error {
-			deal.Message = xerrors.Errorf("error reading piece metadata: %w", err).Error()
-			return nil
-		}),
-	fsm.Event(storagemarket.ProviderEventFailed).From(storagemarket.StorageDealFailing).To(storagemarket.StorageDealError),
+var ProviderEvents = NewProviderEvents(nil)
+
+// NewProviderEvents returns the events that can happen in a storage provider, with the
+// action of each event wrapped by wrap, if it is set
+func NewProviderEvents(wrap journal.ActionWrapper) fsm.Events {
+	event := func(name fsm.EventName) journal.EventBuilder {
+		return journal.Event(name, wrap)
+	}
+	return journal.Events{
+		event(storagemarket.ProviderEventOpen).From(storagemarket.StorageDealUnknown).To(storagemarket.StorageDealValidating),
+		event(storagemarket.ProviderEventNodeErrored).FromAny().To(storagemarket.StorageDealFailing).
+			Action(func(deal *storagemarket.MinerDeal, err error) error {
+				deal.Message = xerrors.Errorf("error calling node: %w", err).Error()
+				return nil
+			}),
+		event(storagemarket.ProviderEventNodeErrorRetry).
+			FromMany(providerRetryableStates...).ToNoChange().
+			Action(func(deal *storagemarket.MinerDeal, err error) error {
+				deal.Retries++
+				return nil
+			}),
+		event(storagemarket.ProviderEventDealRejected).
+			FromMany(storagemarket.StorageDealValidating, storagemarket.StorageDealVerifyData, storagemarket.StorageDealAcceptWait).To(storagemarket.StorageDealFailing).
+			Action(func(deal *storagemarket.MinerDeal, reason storagemarket.RejectionReason, err error) error {
+				deal.RejectionReason = reason
+				deal.Message = xerrors.Errorf("deal rejected: %w", err).Error()
+				return nil
+			}),
+		event(storagemarket.ProviderEventDealDeciding).
+			From(storagemarket.StorageDealValidating).To(storagemarket.StorageDealAcceptWait),
+		event(storagemarket.ProviderEventTransferQueued).
+			From(storagemarket.StorageDealAcceptWait).To(storagemarket.StorageDealTransferQueued),
+		event(storagemarket.ProviderEventDataRequested).
+			FromMany(storagemarket.StorageDealAcceptWait, storagemarket.StorageDealTransferQueued).To(storagemarket.StorageDealWaitingForData),
+		event(storagemarket.ProviderEventDataTransferFailed).
+			From(storagemarket.StorageDealTransferring).To(storagemarket.StorageDealFailing).
+			Action(func(deal *storagemarket.MinerDeal, err error) error {
+				deal.Message = xerrors.Errorf("error transferring data: %w", err).Error()
+				return nil
+			}),
+		event(storagemarket.ProviderEventDataTransferInitiated).
+			From(storagemarket.StorageDealWaitingForData).To(storagemarket.StorageDealTransferring),
+		event(storagemarket.ProviderEventTransferStartTimedOut).
+			From(storagemarket.StorageDealWaitingForData).To(storagemarket.StorageDealFailing).
+			Action(func(deal *storagemarket.MinerDeal, timeout time.Duration) error {
+				deal.Message = fmt.Sprintf("client did not start sending data within %s", timeout)
+				return nil
+			}),
+		event(storagemarket.ProviderEventDataTransferCompleted).
+			From(storagemarket.StorageDealTransferring).To(storagemarket.StorageDealVerifyData),
+		event(storagemarket.ProviderEventVerifyDataQueued).
+			From(storagemarket.StorageDealVerifyData).To(storagemarket.StorageDealVerifyDataQueued),
+		event(storagemarket.ProviderEventVerifyDataSlotAcquired).
+			From(storagemarket.StorageDealVerifyDataQueued).To(storagemarket.StorageDealVerifyData),
+		event(storagemarket.ProviderEventGeneratePieceCIDFailed).
+			From(storagemarket.StorageDealVerifyData).To(storagemarket.StorageDealFailing).
+			Action(func(deal *storagemarket.MinerDeal, err error) error {
+				deal.Message = xerrors.Errorf("generating piece committment: %w", err).Error()
+				return nil
+			}),
+		event(storagemarket.ProviderEventVerifiedData).
+			FromMany(storagemarket.StorageDealVerifyData, storagemarket.StorageDealWaitingForData).To(storagemarket.StorageDealEnsureProviderFunds).
+			// data that finishes verifying after its deal was cancelled is recorded on the
+			// cancelled deal, and the deal is cancelled again so the data is deleted
+			FromMany(storagemarket.StorageDealCancelling, storagemarket.StorageDealCancelled).To(storagemarket.StorageDealCancelling).
+			Action(func(deal *storagemarket.MinerDeal, path filestore.Path, metadataPath filestore.Path) error {
+				deal.PiecePath = path
+				deal.MetadataPath = metadataPath
+				if deal.State == storagemarket.StorageDealCancelling || deal.State == storagemarket.StorageDealCancelled {
+					// the client was told about the cancellation the first time
+					deal.ConnectionClosed = true
+				}
+				return nil
+			}),
+		event(storagemarket.ProviderEventFundingInitiated).
+			From(storagemarket.StorageDealEnsureProviderFunds).To(storagemarket.StorageDealProviderFunding).
+			Action(func(deal *storagemarket.MinerDeal, mcid cid.Cid) error {
+				deal.AddFundsCid = &mcid
+				return nil
+			}),
+		event(storagemarket.ProviderEventFunded).
+			FromMany(storagemarket.StorageDealProviderFunding, storagemarket.StorageDealEnsureProviderFunds).To(storagemarket.StorageDealPublish),
+		event(storagemarket.ProviderEventDealPublishInitiated).
+			From(storagemarket.StorageDealPublish).To(storagemarket.StorageDealPublishing).
+			Action(func(deal *storagemarket.MinerDeal, publishCid cid.Cid, publishIndex uint64) error {
+				deal.PublishCid = &publishCid
+				deal.PublishIndex = publishIndex
+				return nil
+			}),
+		event(storagemarket.ProviderEventDealPublishError).
+			From(storagemarket.StorageDealPublishing).To(storagemarket.StorageDealFailing).
+			Action(func(deal *storagemarket.MinerDeal, err error) error {
+				deal.Message = xerrors.Errorf("PublishStorageDeal error: %w", err).Error()
+				return nil
+			}),
+		event(storagemarket.ProviderEventSendResponseFailed).
+			FromMany(storagemarket.StorageDealAcceptWait, storagemarket.StorageDealTransferQueued, storagemarket.StorageDealPublishing, storagemarket.StorageDealFailing).To(storagemarket.StorageDealError).
+			Action(func(deal *storagemarket.MinerDeal, err error) error {
+				deal.Message = xerrors.Errorf("sending response to deal: %w", err).Error()
+				return nil
+			}),
+		event(storagemarket.ProviderEventDealPublished).
+			From(storagemarket.StorageDealPublishing).To(storagemarket.StorageDealStaged).
+			Action(func(deal *storagemarket.MinerDeal, dealID abi.DealID) error {
+				deal.ConnectionClosed = true
+				deal.DealID = dealID
+				return nil
+			}),
+		event(storagemarket.ProviderEventFileStoreErrored).
+			FromMany(storagemarket.StorageDealStaged, storagemarket.StorageDealSealing, storagemarket.StorageDealActive).To(storagemarket.StorageDealFailing).
+			Action(func(deal *storagemarket.MinerDeal, err error) error {
+				deal.Message = xerrors.Errorf("accessing file store: %w", err).Error()
+				return nil
+			}),
+		event(storagemarket.ProviderEventDealHandoffFailed).From(storagemarket.StorageDealStaged).To(storagemarket.StorageDealFailing).
+			Action(func(deal *storagemarket.MinerDeal, err error) error {
+				deal.Message = xerrors.Errorf("handing off deal to node: %w", err).Error()
+				return nil
+			}),
+		event(storagemarket.ProviderEventDealHandedOff).From(storagemarket.StorageDealStaged).To(storagemarket.StorageDealSealing),
+		event(storagemarket.ProviderEventDealActivationFailed).
+			From(storagemarket.StorageDealSealing).To(storagemarket.StorageDealFailing).
+			Action(func(deal *storagemarket.MinerDeal, err error) error {
+				deal.Message = xerrors.Errorf("error activating deal: %w", err).Error()
+				return nil
+			}),
+		event(storagemarket.ProviderEventDealActivated).From(storagemarket.StorageDealSealing).To(storagemarket.StorageDealActive),
+		event(storagemarket.ProviderEventPieceStoreErrored).From(storagemarket.StorageDealActive).To(storagemarket.StorageDealFailing).
+			Action(func(deal *storagemarket.MinerDeal, err error) error {
+				deal.Message = xerrors.Errorf("accessing piece store: %w", err).Error()
+				return nil
+			}),
+		event(storagemarket.ProviderEventDealCompleted).From(storagemarket.StorageDealActive).To(storagemarket.StorageDealCompleted),
+		event(storagemarket.ProviderEventUnableToLocatePiece).From(storagemarket.StorageDealActive).To(storagemarket.StorageDealFailing).
+			Action(func(deal *storagemarket.MinerDeal, dealID abi.DealID, err error) error {
+				deal.Message = xerrors.Errorf("locating piece for deal ID %d in sector: %w", deal.DealID, err).Error()
+				return nil
+			}),
+		event(storagemarket.ProviderEventReadMetadataErrored).From(storagemarket.StorageDealActive).To(storagemarket.StorageDealFailing).
+			Action(func(deal *storagemarket.MinerDeal, err error) error {
+				deal.Message = xerrors.Errorf("error reading piece metadata: %w", err).Error()
+				return nil
+			}),
+		event(storagemarket.ProviderEventDealExpired).From(storagemarket.StorageDealCompleted).To(storagemarket.StorageDealExpired),
+		event(storagemarket.ProviderEventDealSlashed).
+			From(storagemarket.StorageDealCompleted).To(storagemarket.StorageDealSlashed).
+			Action(func(deal *storagemarket.MinerDeal, slashEpoch abi.ChainEpoch) error {
+				deal.SlashEpoch = slashEpoch
+				return nil
+			}),
+		event(storagemarket.ProviderEventDealCompletionFailed).
+			From(storagemarket.StorageDealCompleted).To(storagemarket.StorageDealError).
+			Action(func(deal *storagemarket.MinerDeal, err error) error {
+				deal.Message = xerrors.Errorf("error waiting for deal completion: %w", err).Error()
+				return nil
+			}),
+		event(storagemarket.ProviderEventFailed).From(storagemarket.StorageDealFailing).To(storagemarket.StorageDealError),
+		event(storagemarket.ProviderEventRestart).
+			FromMany(providerResumableStates...).ToNoChange().
+			Action(func(deal *storagemarket.MinerDeal) error {
+				// deal streams do not survive a restart
+				deal.ConnectionClosed = true
+				return nil
+			}),
+		event(storagemarket.ProviderEventRestartFailed).
+			FromMany(providerUnresumableStates...).To(storagemarket.StorageDealFailing).
+			Action(func(deal *storagemarket.MinerDeal) error {
+				deal.ConnectionClosed = true
+				deal.Message = "deal could not be resumed after restart: connection to client was lost"
+				return nil
+			}),
+		event(storagemarket.ProviderEventCancelled).
+			FromMany(providerCancellableStates...).To(storagemarket.StorageDealCancelling).
+			Action(func(deal *storagemarket.MinerDeal, reason string) error {
+				deal.Message = "deal cancelled by provider: " + reason
+				return nil
+			}),
+		event(storagemarket.ProviderEventClientCancelled).
+			FromMany(providerCancellableStates...).To(storagemarket.StorageDealCancelling).
+			Action(func(deal *storagemarket.MinerDeal, reason string) error {
+				deal.Message = "deal cancelled by client: " + reason
+				return nil
+			}),
+		event(storagemarket.ProviderEventCancelCompleted).
+			From(storagemarket.StorageDealCancelling).To(storagemarket.StorageDealCancelled).
+			From(storagemarket.StorageDealCancelled).ToNoChange().
+			Action(func(deal *storagemarket.MinerDeal) error {
+				// the client was told about the cancellation, and the stream closed
+				deal.ConnectionClosed = true
+				return nil
+			}),
+	}.Build()
+}
+
+// ProviderFinalityStates are the states that terminate deal processing for a deal.
+// When a provider restarts, it restarts only deals that are not in a finality state.
+var ProviderFinalityStates = []fsm.StateKey{
+	storagemarket.StorageDealError,
+	storagemarket.StorageDealCancelled,
+	storagemarket.StorageDealExpired,
+	storagemarket.StorageDealSlashed,
+}
+
+// providerResumableStates are the states from which a deal can continue processing
+// after a restart, because they do not depend on an open stream to the client
+var providerResumableStates = []fsm.StateKey{
+	storagemarket.StorageDealWaitingForData,
+	storagemarket.StorageDealVerifyDataQueued,
+	storagemarket.StorageDealVerifyData,
+	storagemarket.StorageDealEnsureProviderFunds,
+	storagemarket.StorageDealProviderFunding,
+	storagemarket.StorageDealPublish,
+	storagemarket.StorageDealPublishing,
+	storagemarket.StorageDealStaged,
+	storagemarket.StorageDealSealing,
+	storagemarket.StorageDealActive,
+	storagemarket.StorageDealCompleted,
+	storagemarket.StorageDealFailing,
+	storagemarket.StorageDealCancelling,
+}
+
+// providerUnresumableStates are the states that require an open stream to the client
+// (to read a proposal, send a response, or receive data), and therefore cannot
+// be resumed after a restart
+var providerUnresumableStates = []fsm.StateKey{
+	storagemarket.StorageDealUnknown,
+	storagemarket.StorageDealValidating,
+	storagemarket.StorageDealAcceptWait,
+	storagemarket.StorageDealTransferQueued,
+	storagemarket.StorageDealWaitingForData,
+	storagemarket.StorageDealTransferring,
+}
+
+// providerRetryableStates are the states whose node calls are retried after transient errors.
+// Deals are not retried while validating or deciding on a proposal, as the client is
+// waiting on the stream for a response
+var providerRetryableStates = []fsm.StateKey{
+	storagemarket.StorageDealEnsureProviderFunds,
+	storagemarket.StorageDealProviderFunding,
+	storagemarket.StorageDealPublish,
+}
+
+// providerCancellableStates are the states a deal can be cancelled from, before
+// it has been handed to the deal publisher
+var providerCancellableStates = []fsm.StateKey{
+	storagemarket.StorageDealValidating,
+	storagemarket.StorageDealAcceptWait,
+	storagemarket.StorageDealTransferQueued,
+	storagemarket.StorageDealWaitingForData,
+	storagemarket.StorageDealTransferring,
+	storagemarket.StorageDealVerifyDataQueued,
+	storagemarket.StorageDealVerifyData,
+	storagemarket.StorageDealEnsureProviderFunds,
+	storagemarket.StorageDealProviderFunding,
+}
+
+// CanCancel returns true if a deal in the given state has not yet been published
+// and can still be cancelled
+func CanCancel(state storagemarket.StorageDealStatus) bool {
+	for _, s := range providerCancellableStates {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
+// providerUndecidedStates are the states before the provider has decided to accept a
+// deal, and reserved staging space for it
+var providerUndecidedStates = []fsm.StateKey{
+	storagemarket.StorageDealUnknown,
+	storagemarket.StorageDealValidating,
+	storagemarket.StorageDealAcceptWait,
+}
+
+// HasStagingSpace returns true if staging space was reserved for a deal in the given state
+// and has not yet been released
+func HasStagingSpace(state storagemarket.StorageDealStatus) bool {
+	if IsFinalityState(state) || state == storagemarket.StorageDealCompleted {
+		return false
+	}
+	for _, s := range providerUndecidedStates {
+		if s == state {
+			return false
+		}
+	}
+	return true
+}
+
+// IsFinalityState returns true if a deal in the given state is no longer processed
+func IsFinalityState(state storagemarket.StorageDealStatus) bool {
+	for _, s := range ProviderFinalityStates {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
+// CanResume returns true if a deal that was in progress when the provider stopped
+// can safely continue processing. Deals waiting for data can resume only if the data
+// will be imported manually, since a graphsync transfer from the client is not retried
+func CanResume(deal storagemarket.MinerDeal) bool {
+	if deal.State == storagemarket.StorageDealWaitingForData {
+		return deal.Ref != nil && deal.Ref.TransferType == storagemarket.TTManual
+	}
+	for _, s := range providerResumableStates {
+		if s == deal.State {
+			return true
+		}
+	}
+	return false
 }
 
 // ProviderStateEntryFuncs are the handlers for different states in a storage client
 var ProviderStateEntryFuncs = fsm.StateEntryFuncs{
 	storagemarket.StorageDealValidating:          ValidateDealProposal,
 	storagemarket.StorageDealAcceptWait:          DecideOnProposal,
+	storagemarket.StorageDealTransferQueued:      WaitForTransferSlot,
+	storagemarket.StorageDealWaitingForData:      WaitForTransferStart,
+	storagemarket.StorageDealVerifyDataQueued:    WaitForVerifyDataSlot,
 	storagemarket.StorageDealVerifyData:          VerifyData,
 	storagemarket.StorageDealEnsureProviderFunds: EnsureProviderFunds,
 	storagemarket.StorageDealProviderFunding:     WaitForFunding,
@@ -135,4 +345,6 @@ var ProviderStateEntryFuncs = fsm.StateEntryFuncs{
 	storagemarket.StorageDealSealing:             VerifyDealActivated,
 	storagemarket.StorageDealActive:              RecordPieceInfo,
 	storagemarket.StorageDealFailing:             FailDeal,
+	storagemarket.StorageDealCancelling:          CancelDeal,
+	storagemarket.StorageDealCompleted:           WaitForDealCompletion,
 }
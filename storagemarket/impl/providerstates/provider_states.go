@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/filecoin-project/go-address"
 	datatransfer "github.com/filecoin-project/go-data-transfer"
@@ -23,6 +24,7 @@ import (
 	"github.com/filecoin-project/go-fil-markets/piecestore"
 	"github.com/filecoin-project/go-fil-markets/shared"
 	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/go-fil-markets/storagemarket/impl/dealslots"
 	"github.com/filecoin-project/go-fil-markets/storagemarket/impl/providerutils"
 	"github.com/filecoin-project/go-fil-markets/storagemarket/network"
 )
@@ -32,23 +34,53 @@ var log = logging.Logger("providerstates")
 // ProviderDealEnvironment are the dependencies needed for processing deals
 // with a ProviderStateEntryFunc
 type ProviderDealEnvironment interface {
-	Address() address.Address
+	IsMinerActor(addr address.Address) bool
 	Node() storagemarket.StorageProviderNode
-	Ask() storagemarket.StorageAsk
+	Asks(miner address.Address) []storagemarket.StorageAsk
 	StartDataTransfer(ctx context.Context, to peer.ID, voucher datatransfer.Voucher, baseCid cid.Cid, selector ipld.Node) error
+	CloseDataTransfer(proposalCid cid.Cid)
 	GeneratePieceCommitmentToFile(payloadCid cid.Cid, selector ipld.Node) (cid.Cid, filestore.Path, filestore.Path, error)
-	SendSignedResponse(ctx context.Context, response *network.Response) error
+	SendSignedResponse(ctx context.Context, miner address.Address, response *network.Response) error
 	TagConnection(proposalCid cid.Cid) error
 	Disconnect(proposalCid cid.Cid) error
 	FileStore() filestore.FileStore
 	PieceStore() piecestore.PieceStore
 	DealAcceptanceBuffer() abi.ChainEpoch
 	RunCustomDecisionLogic(context.Context, storagemarket.MinerDeal) (bool, string, error)
+	PublishDeal(context.Context, storagemarket.MinerDeal) (cid.Cid, uint64, error)
+	RetryPolicy() storagemarket.RetryPolicy
+	ReserveStagingSpace(proposalCid cid.Cid, pieceSize abi.PaddedPieceSize) error
+	ReleaseStagingSpace(proposalCid cid.Cid)
+	TransferSlots() *dealslots.DealSlots
+	TransferStartTimeout() time.Duration
+	CommPSlots() *dealslots.DealSlots
 }
 
 // ProviderStateEntryFunc is the signature for a StateEntryFunc in the provider FSM
 type ProviderStateEntryFunc func(ctx fsm.Context, environment ProviderDealEnvironment, deal storagemarket.MinerDeal) error
 
+// nodeErrored enters the current state again after a backoff if a node call failed with
+// a transient error and the retry policy allows it, and fails the deal otherwise
+func nodeErrored(ctx fsm.Context, environment ProviderDealEnvironment, deal storagemarket.MinerDeal, err error) error {
+	delay, retry := environment.RetryPolicy().Backoff(deal.Retries, err)
+	if !retry {
+		return ctx.Trigger(storagemarket.ProviderEventNodeErrored, err)
+	}
+
+	log.Warnf("deal %s: retrying in %s after node error: %s", deal.ProposalCid, delay, err)
+	if delay <= 0 {
+		return ctx.Trigger(storagemarket.ProviderEventNodeErrorRetry, err)
+	}
+	go func() {
+		select {
+		case <-ctx.Context().Done():
+		case <-time.After(delay):
+			_ = ctx.Trigger(storagemarket.ProviderEventNodeErrorRetry, err)
+		}
+	}()
+	return nil
+}
+
 // ValidateDealProposal validates a proposed deal against the provider criteria
 func ValidateDealProposal(ctx fsm.Context, environment ProviderDealEnvironment, deal storagemarket.MinerDeal) error {
 	tok, _, err := environment.Node().GetChainHead(ctx.Context())
@@ -57,11 +89,15 @@ func ValidateDealProposal(ctx fsm.Context, environment ProviderDealEnvironment,
 	}
 
 	if err := providerutils.VerifyProposal(ctx.Context(), deal.ClientDealProposal, tok, environment.Node().VerifySignature); err != nil {
-		return ctx.Trigger(storagemarket.ProviderEventDealRejected, xerrors.Errorf("verifying StorageDealProposal: %w", err))
+		return ctx.Trigger(storagemarket.ProviderEventDealRejected, storagemarket.RejectionInvalidSignature, xerrors.Errorf("verifying StorageDealProposal: %w", err))
 	}
 
-	if deal.Proposal.Provider != environment.Address() {
-		return ctx.Trigger(storagemarket.ProviderEventDealRejected, xerrors.Errorf("incorrect provider for deal"))
+	if !environment.IsMinerActor(deal.Proposal.Provider) {
+		return ctx.Trigger(storagemarket.ProviderEventDealRejected, storagemarket.RejectionWrongProvider, xerrors.Errorf("incorrect provider for deal"))
+	}
+
+	if err := deal.Labels.Validate(); err != nil {
+		return ctx.Trigger(storagemarket.ProviderEventDealRejected, storagemarket.RejectionInvalidLabels, xerrors.Errorf("invalid deal labels: %w", err))
 	}
 
 	tok, height, err := environment.Node().GetChainHead(ctx.Context())
@@ -70,25 +106,74 @@ func ValidateDealProposal(ctx fsm.Context, environment ProviderDealEnvironment,
 	}
 
 	if height > deal.Proposal.StartEpoch-environment.DealAcceptanceBuffer() {
-		return ctx.Trigger(storagemarket.ProviderEventDealRejected, xerrors.Errorf("deal start epoch is too soon or deal already expired"))
+		return ctx.Trigger(storagemarket.ProviderEventDealRejected, storagemarket.RejectionStartEpochTooSoon, xerrors.Errorf("deal start epoch is too soon or deal already expired"))
+	}
+
+	asks := environment.Asks(deal.Proposal.Provider)
+	if len(asks) == 0 {
+		return ctx.Trigger(storagemarket.ProviderEventDealRejected, storagemarket.RejectionNoAsk, xerrors.New("provider has no current ask"))
 	}
 
-	// TODO: check StorageCollateral
+	// price the deal with the cheapest ask that covers it. If none do, check against the
+	// default ask so the rejection explains what is out of range
+	duration := deal.Proposal.EndEpoch - deal.Proposal.StartEpoch
+	ask, ok := storagemarket.SelectAsk(asks, deal.Proposal.PieceSize, duration)
+	if !ok {
+		ask = asks[0]
+	}
 
-	minPrice := big.Div(big.Mul(environment.Ask().Price, abi.NewTokenAmount(int64(deal.Proposal.PieceSize))), abi.NewTokenAmount(1<<30))
+	minCollateral, maxCollateral := ask.ProviderCollateralBounds(deal.Proposal.PieceSize)
+	if !minCollateral.Nil() && deal.Proposal.ProviderCollateral.LessThan(minCollateral) {
+		return ctx.Trigger(storagemarket.ProviderEventDealRejected, storagemarket.RejectionCollateralTooLow,
+			xerrors.Errorf("proposed provider collateral below minimum: %s < %s", deal.Proposal.ProviderCollateral, minCollateral))
+	}
+
+	if !maxCollateral.Nil() && deal.Proposal.ProviderCollateral.GreaterThan(maxCollateral) {
+		return ctx.Trigger(storagemarket.ProviderEventDealRejected, storagemarket.RejectionCollateralTooHigh,
+			xerrors.Errorf("proposed provider collateral above maximum: %s > %s", deal.Proposal.ProviderCollateral, maxCollateral))
+	}
+
+	minPrice := big.Div(big.Mul(ask.DealPrice(deal.Proposal.VerifiedDeal), abi.NewTokenAmount(int64(deal.Proposal.PieceSize))), abi.NewTokenAmount(1<<30))
 	if deal.Proposal.StoragePricePerEpoch.LessThan(minPrice) {
-		return ctx.Trigger(storagemarket.ProviderEventDealRejected,
+		return ctx.Trigger(storagemarket.ProviderEventDealRejected, storagemarket.RejectionPriceTooLow,
 			xerrors.Errorf("storage price per epoch less than asking price: %s < %s", deal.Proposal.StoragePricePerEpoch, minPrice))
 	}
 
-	if deal.Proposal.PieceSize < environment.Ask().MinPieceSize {
-		return ctx.Trigger(storagemarket.ProviderEventDealRejected,
-			xerrors.Errorf("piece size less than minimum required size: %d < %d", deal.Proposal.PieceSize, environment.Ask().MinPieceSize))
+	if deal.Proposal.PieceSize < ask.MinPieceSize {
+		return ctx.Trigger(storagemarket.ProviderEventDealRejected, storagemarket.RejectionPieceTooSmall,
+			xerrors.Errorf("piece size less than minimum required size: %d < %d", deal.Proposal.PieceSize, ask.MinPieceSize))
+	}
+
+	if deal.Proposal.PieceSize > ask.MaxPieceSize {
+		return ctx.Trigger(storagemarket.ProviderEventDealRejected, storagemarket.RejectionPieceTooLarge,
+			xerrors.Errorf("piece size more than maximum allowed size: %d > %d", deal.Proposal.PieceSize, ask.MaxPieceSize))
+	}
+
+	if ask.MinDuration != 0 && duration < ask.MinDuration {
+		return ctx.Trigger(storagemarket.ProviderEventDealRejected, storagemarket.RejectionDurationTooShort,
+			xerrors.Errorf("deal duration less than minimum required duration: %d < %d", duration, ask.MinDuration))
 	}
 
-	if deal.Proposal.PieceSize > environment.Ask().MaxPieceSize {
-		return ctx.Trigger(storagemarket.ProviderEventDealRejected,
-			xerrors.Errorf("piece size more than maximum allowed size: %d > %d", deal.Proposal.PieceSize, environment.Ask().MaxPieceSize))
+	if ask.MaxDuration != 0 && duration > ask.MaxDuration {
+		return ctx.Trigger(storagemarket.ProviderEventDealRejected, storagemarket.RejectionDurationTooLong,
+			xerrors.Errorf("deal duration more than maximum allowed duration: %d > %d", duration, ask.MaxDuration))
+	}
+
+	if deal.Proposal.VerifiedDeal {
+		dataCap, err := environment.Node().GetDataCap(ctx.Context(), deal.Proposal.Client, tok)
+		if err != nil {
+			return ctx.Trigger(storagemarket.ProviderEventNodeErrored, xerrors.Errorf("getting client datacap: %w", err))
+		}
+
+		if dataCap == nil {
+			return ctx.Trigger(storagemarket.ProviderEventDealRejected, storagemarket.RejectionClientNotVerified, xerrors.New("verified deal proposed by a client that is not verified"))
+		}
+
+		pieceSize := big.NewInt(int64(deal.Proposal.PieceSize))
+		if dataCap.LessThan(pieceSize) {
+			return ctx.Trigger(storagemarket.ProviderEventDealRejected, storagemarket.RejectionDataCapTooLow,
+				xerrors.Errorf("client datacap too low for verified deal: %s < %s", *dataCap, pieceSize))
+		}
 	}
 
 	// check market funds
@@ -100,7 +185,7 @@ func ValidateDealProposal(ctx fsm.Context, environment ProviderDealEnvironment,
 	// This doesn't guarantee that the client won't withdraw / lock those funds
 	// but it's a decent first filter
 	if clientMarketBalance.Available.LessThan(deal.Proposal.TotalStorageFee()) {
-		return ctx.Trigger(storagemarket.ProviderEventDealRejected, xerrors.New("clientMarketBalance.Available too small"))
+		return ctx.Trigger(storagemarket.ProviderEventDealRejected, storagemarket.RejectionInsufficientFunds, xerrors.New("clientMarketBalance.Available too small"))
 	}
 
 	if err := environment.TagConnection(deal.ProposalCid); err != nil {
@@ -116,15 +201,49 @@ func ValidateDealProposal(ctx fsm.Context, environment ProviderDealEnvironment,
 func DecideOnProposal(ctx fsm.Context, environment ProviderDealEnvironment, deal storagemarket.MinerDeal) error {
 	accept, reason, err := environment.RunCustomDecisionLogic(ctx.Context(), deal)
 	if err != nil {
-		return ctx.Trigger(storagemarket.ProviderEventNodeErrored, xerrors.Errorf("custom deal decision logic failed: %w", err))
+		// deciders reject deals for a specific reason by returning a DealRejectedError
+		var rejection *storagemarket.DealRejectedError
+		if xerrors.As(err, &rejection) {
+			return ctx.Trigger(storagemarket.ProviderEventDealRejected, rejection.Reason, rejection)
+		}
+		return ctx.Trigger(storagemarket.ProviderEventDealRejected, storagemarket.RejectionUnspecified, xerrors.Errorf("custom deal decision logic failed: %w", err))
 	}
 
 	if !accept {
-		return ctx.Trigger(storagemarket.ProviderEventDealRejected, fmt.Errorf(reason))
+		return ctx.Trigger(storagemarket.ProviderEventDealRejected, storagemarket.RejectionDeclined, fmt.Errorf(reason))
+	}
+
+	// reserve space for the deal data now, rather than finding out the disk is full
+	// once it has been transferred
+	if err := environment.ReserveStagingSpace(deal.ProposalCid, deal.Proposal.PieceSize); err != nil {
+		return ctx.Trigger(storagemarket.ProviderEventDealRejected, storagemarket.RejectionNoStagingSpace, err)
 	}
 
-	// Send intent to accept
-	err = environment.SendSignedResponse(ctx.Context(), &network.Response{
+	// data imported manually does not use a data transfer slot
+	manual := deal.Ref != nil && deal.Ref.TransferType == storagemarket.TTManual
+	if !manual && !environment.TransferSlots().TryAcquire(deal.ProposalCid) {
+		return ctx.Trigger(storagemarket.ProviderEventTransferQueued)
+	}
+
+	return requestData(ctx, environment, deal)
+}
+
+// WaitForTransferSlot waits for a free data transfer slot before asking the client
+// to send the data for an accepted deal
+func WaitForTransferSlot(ctx fsm.Context, environment ProviderDealEnvironment, deal storagemarket.MinerDeal) error {
+	go func() {
+		// fails if the deal is cancelled while queued, or the provider shuts down
+		if err := environment.TransferSlots().Acquire(ctx.Context(), deal.ProposalCid); err != nil {
+			return
+		}
+		_ = requestData(ctx, environment, deal)
+	}()
+	return nil
+}
+
+// requestData sends the client the intent to accept a deal, which asks for the deal data
+func requestData(ctx fsm.Context, environment ProviderDealEnvironment, deal storagemarket.MinerDeal) error {
+	err := environment.SendSignedResponse(ctx.Context(), deal.Proposal.Provider, &network.Response{
 		State:    storagemarket.StorageDealWaitingForData,
 		Proposal: deal.ProposalCid,
 	})
@@ -136,18 +255,55 @@ func DecideOnProposal(ctx fsm.Context, environment ProviderDealEnvironment, deal
 	return ctx.Trigger(storagemarket.ProviderEventDataRequested)
 }
 
+// WaitForTransferStart fails a deal if the client does not start sending its data in time,
+// so a client that never sends data does not keep a data transfer slot forever
+func WaitForTransferStart(ctx fsm.Context, environment ProviderDealEnvironment, deal storagemarket.MinerDeal) error {
+	timeout := environment.TransferStartTimeout()
+	if timeout == 0 || (deal.Ref != nil && deal.Ref.TransferType == storagemarket.TTManual) {
+		return nil
+	}
+	go func() {
+		select {
+		case <-ctx.Context().Done():
+		case <-time.After(timeout):
+			// the event is ignored if the transfer started in the meantime
+			_ = ctx.Trigger(storagemarket.ProviderEventTransferStartTimedOut, timeout)
+		}
+	}()
+	return nil
+}
+
+// WaitForVerifyDataSlot waits for a free slot to generate the piece commitment for
+// transferred data
+func WaitForVerifyDataSlot(ctx fsm.Context, environment ProviderDealEnvironment, deal storagemarket.MinerDeal) error {
+	go func() {
+		if err := environment.CommPSlots().Acquire(ctx.Context(), deal.ProposalCid); err != nil {
+			return
+		}
+		_ = ctx.Trigger(storagemarket.ProviderEventVerifyDataSlotAcquired)
+	}()
+	return nil
+}
+
 // VerifyData verifies that data received for a deal matches the pieceCID
 // in the proposal
 func VerifyData(ctx fsm.Context, environment ProviderDealEnvironment, deal storagemarket.MinerDeal) error {
+	// the transfer has finished, so its slot can go to the next deal
+	environment.TransferSlots().Release(deal.ProposalCid)
+
+	if !environment.CommPSlots().TryAcquire(deal.ProposalCid) {
+		return ctx.Trigger(storagemarket.ProviderEventVerifyDataQueued)
+	}
 
 	pieceCid, piecePath, metadataPath, err := environment.GeneratePieceCommitmentToFile(deal.Ref.Root, shared.AllSelector())
+	environment.CommPSlots().Release(deal.ProposalCid)
 	if err != nil {
 		return ctx.Trigger(storagemarket.ProviderEventGeneratePieceCIDFailed, err)
 	}
 
 	// Verify CommP matches
 	if pieceCid != deal.Proposal.PieceCID {
-		return ctx.Trigger(storagemarket.ProviderEventDealRejected, xerrors.Errorf("proposal CommP doesn't match calculated CommP"))
+		return ctx.Trigger(storagemarket.ProviderEventDealRejected, storagemarket.RejectionPieceCIDMismatch, xerrors.Errorf("proposal CommP doesn't match calculated CommP"))
 	}
 
 	return ctx.Trigger(storagemarket.ProviderEventVerifiedData, piecePath, metadataPath)
@@ -158,18 +314,18 @@ func EnsureProviderFunds(ctx fsm.Context, environment ProviderDealEnvironment, d
 
 	tok, _, err := node.GetChainHead(ctx.Context())
 	if err != nil {
-		return ctx.Trigger(storagemarket.ProviderEventNodeErrored, xerrors.Errorf("acquiring chain head: %w", err))
+		return nodeErrored(ctx, environment, deal, xerrors.Errorf("acquiring chain head: %w", err))
 	}
 
 	waddr, err := node.GetMinerWorkerAddress(ctx.Context(), deal.Proposal.Provider, tok)
 	if err != nil {
-		return ctx.Trigger(storagemarket.ProviderEventNodeErrored, xerrors.Errorf("looking up miner worker: %w", err))
+		return nodeErrored(ctx, environment, deal, xerrors.Errorf("looking up miner worker: %w", err))
 	}
 
 	mcid, err := node.EnsureFunds(ctx.Context(), deal.Proposal.Provider, waddr, deal.Proposal.ProviderCollateral, tok)
 
 	if err != nil {
-		return ctx.Trigger(storagemarket.ProviderEventNodeErrored, xerrors.Errorf("ensuring funds: %w", err))
+		return nodeErrored(ctx, environment, deal, xerrors.Errorf("ensuring funds: %w", err))
 	}
 
 	// if no message was sent, and there was no error, it was instantaneous
@@ -185,10 +341,10 @@ func WaitForFunding(ctx fsm.Context, environment ProviderDealEnvironment, deal s
 
 	return node.WaitForMessage(ctx.Context(), *deal.AddFundsCid, func(code exitcode.ExitCode, bytes []byte, err error) error {
 		if err != nil {
-			return ctx.Trigger(storagemarket.ProviderEventNodeErrored, xerrors.Errorf("AddFunds errored: %w", err))
+			return nodeErrored(ctx, environment, deal, xerrors.Errorf("AddFunds errored: %w", err))
 		}
 		if code != exitcode.Ok {
-			return ctx.Trigger(storagemarket.ProviderEventNodeErrored, xerrors.Errorf("AddFunds exit code: %s", code.String()))
+			return nodeErrored(ctx, environment, deal, xerrors.Errorf("AddFunds exit code: %s", code.String()))
 		}
 		return ctx.Trigger(storagemarket.ProviderEventFunded)
 	})
@@ -204,12 +360,12 @@ func PublishDeal(ctx fsm.Context, environment ProviderDealEnvironment, deal stor
 		Ref:                deal.Ref,
 	}
 
-	mcid, err := environment.Node().PublishDeals(ctx.Context(), smDeal)
+	mcid, index, err := environment.PublishDeal(ctx.Context(), smDeal)
 	if err != nil {
-		return ctx.Trigger(storagemarket.ProviderEventNodeErrored, xerrors.Errorf("publishing deal: %w", err))
+		return nodeErrored(ctx, environment, deal, xerrors.Errorf("publishing deal: %w", err))
 	}
 
-	return ctx.Trigger(storagemarket.ProviderEventDealPublishInitiated, mcid)
+	return ctx.Trigger(storagemarket.ProviderEventDealPublishInitiated, mcid, index)
 }
 
 // WaitForPublish waits for the publish message on chain and sends the deal id back to the client
@@ -226,22 +382,28 @@ func WaitForPublish(ctx fsm.Context, environment ProviderDealEnvironment, deal s
 		if err != nil {
 			return ctx.Trigger(storagemarket.ProviderEventDealPublishError, xerrors.Errorf("PublishStorageDeals error unmarshalling result: %w", err))
 		}
-
-		err = environment.SendSignedResponse(ctx.Context(), &network.Response{
-			State:          storagemarket.StorageDealProposalAccepted,
-			Proposal:       deal.ProposalCid,
-			PublishMessage: deal.PublishCid,
-		})
-
-		if err != nil {
-			return ctx.Trigger(storagemarket.ProviderEventSendResponseFailed, err)
+		if deal.PublishIndex >= uint64(len(retval.IDs)) {
+			return ctx.Trigger(storagemarket.ProviderEventDealPublishError, xerrors.Errorf("PublishStorageDeals returned %d deal IDs, but deal is at index %d", len(retval.IDs), deal.PublishIndex))
 		}
 
-		if err := environment.Disconnect(deal.ProposalCid); err != nil {
-			log.Warnf("closing client connection: %+v", err)
+		// the connection may already be gone if the provider restarted while publishing
+		if !deal.ConnectionClosed {
+			err = environment.SendSignedResponse(ctx.Context(), deal.Proposal.Provider, &network.Response{
+				State:          storagemarket.StorageDealProposalAccepted,
+				Proposal:       deal.ProposalCid,
+				PublishMessage: deal.PublishCid,
+			})
+
+			if err != nil {
+				return ctx.Trigger(storagemarket.ProviderEventSendResponseFailed, err)
+			}
+
+			if err := environment.Disconnect(deal.ProposalCid); err != nil {
+				log.Warnf("closing client connection: %+v", err)
+			}
 		}
 
-		return ctx.Trigger(storagemarket.ProviderEventDealPublished, retval.IDs[0])
+		return ctx.Trigger(storagemarket.ProviderEventDealPublished, retval.IDs[deal.PublishIndex])
 
 	})
 }
@@ -345,20 +507,64 @@ func RecordPieceInfo(ctx fsm.Context, environment ProviderDealEnvironment, deal
 			log.Warnf("deleting piece at path %s: %w", deal.MetadataPath, err)
 		}
 	}
+	environment.ReleaseStagingSpace(deal.ProposalCid)
 
 	return ctx.Trigger(storagemarket.ProviderEventDealCompleted)
 }
 
+// WaitForDealCompletion waits for a completed deal to expire or be slashed
+func WaitForDealCompletion(ctx fsm.Context, environment ProviderDealEnvironment, deal storagemarket.MinerDeal) error {
+	// the deal is removed from the piece store while it is still completed, so the removal
+	// is retried when the deal is resumed if the provider stops before it finishes
+	onDealExpired := func(err error) {
+		if err != nil {
+			_ = ctx.Trigger(storagemarket.ProviderEventDealCompletionFailed, xerrors.Errorf("expiry err: %w", err))
+		} else {
+			removeDealPieceInfo(environment, deal)
+			_ = ctx.Trigger(storagemarket.ProviderEventDealExpired)
+		}
+	}
+
+	onDealSlashed := func(slashEpoch abi.ChainEpoch, err error) {
+		if err != nil {
+			_ = ctx.Trigger(storagemarket.ProviderEventDealCompletionFailed, xerrors.Errorf("slashing err: %w", err))
+		} else {
+			removeDealPieceInfo(environment, deal)
+			_ = ctx.Trigger(storagemarket.ProviderEventDealSlashed, slashEpoch)
+		}
+	}
+
+	err := environment.Node().OnDealExpiredOrSlashed(ctx.Context(), deal.DealID, onDealExpired, onDealSlashed)
+	if err != nil {
+		return ctx.Trigger(storagemarket.ProviderEventDealCompletionFailed, err)
+	}
+	return nil
+}
+
+// removeDealPieceInfo removes a deal that has expired or been slashed from the piece store,
+// so its data is no longer offered for retrieval
+func removeDealPieceInfo(environment ProviderDealEnvironment, deal storagemarket.MinerDeal) {
+	err := environment.PieceStore().RemoveDealForPiece(deal.Proposal.PieceCID, deal.DealID)
+	if err != nil {
+		log.Warnf("removing deal %d for piece %s: %s", deal.DealID, deal.Proposal.PieceCID, err)
+	}
+}
+
 // FailDeal sends a failure response before terminating a deal
 func FailDeal(ctx fsm.Context, environment ProviderDealEnvironment, deal storagemarket.MinerDeal) error {
 
 	log.Warnf("deal %s failed: %s", deal.ProposalCid, deal.Message)
 
+	// release the deal's staging space and slots before responding, since a failed response
+	// moves the deal straight to StorageDealError without coming back here
+	deleteDealFiles(environment, deal)
+
 	if !deal.ConnectionClosed {
-		err := environment.SendSignedResponse(ctx.Context(), &network.Response{
+		err := environment.SendSignedResponse(ctx.Context(), deal.Proposal.Provider, &network.Response{
 			State:    storagemarket.StorageDealFailing,
 			Message:  deal.Message,
 			Proposal: deal.ProposalCid,
+			Reason:   deal.RejectionReason,
 		})
 
 		if err != nil {
@@ -370,6 +576,40 @@ func FailDeal(ctx fsm.Context, environment ProviderDealEnvironment, deal storage
 		}
 	}
 
+	return ctx.Trigger(storagemarket.ProviderEventFailed)
+}
+
+// CancelDeal stops any data transfer for a cancelled deal, tells the client it will not go
+// ahead, and deletes any data received for it, before the deal is finally cancelled. It runs
+// again for data that finishes verifying after the deal was cancelled, to delete that data too
+func CancelDeal(ctx fsm.Context, environment ProviderDealEnvironment, deal storagemarket.MinerDeal) error {
+
+	log.Infof("deal %s cancelled: %s", deal.ProposalCid, deal.Message)
+
+	environment.CloseDataTransfer(deal.ProposalCid)
+
+	if !deal.ConnectionClosed {
+		err := environment.SendSignedResponse(ctx.Context(), deal.Proposal.Provider, &network.Response{
+			State:    storagemarket.StorageDealFailing,
+			Message:  deal.Message,
+			Proposal: deal.ProposalCid,
+		})
+		if err != nil {
+			log.Warnf("sending cancellation response: %+v", err)
+		}
+
+		if err := environment.Disconnect(deal.ProposalCid); err != nil {
+			log.Warnf("closing client connection: %+v", err)
+		}
+	}
+
+	deleteDealFiles(environment, deal)
+	return ctx.Trigger(storagemarket.ProviderEventCancelCompleted)
+}
+
+// deleteDealFiles deletes the files staged for a deal and releases the space and any slots
+// reserved for it
+func deleteDealFiles(environment ProviderDealEnvironment, deal storagemarket.MinerDeal) {
 	if deal.PiecePath != filestore.Path("") {
 		err := environment.FileStore().Delete(deal.PiecePath)
 		if err != nil {
@@ -382,5 +622,7 @@ func FailDeal(ctx fsm.Context, environment ProviderDealEnvironment, deal storage
 			log.Warnf("deleting piece at path %s: %w", deal.MetadataPath, err)
 		}
 	}
-	return ctx.Trigger(storagemarket.ProviderEventFailed)
+	environment.ReleaseStagingSpace(deal.ProposalCid)
+	environment.TransferSlots().Release(deal.ProposalCid)
+	environment.CommPSlots().Release(deal.ProposalCid)
 }
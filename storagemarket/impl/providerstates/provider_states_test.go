@@ -6,7 +6,9 @@ import (
 	"errors"
 	"fmt"
 	"math/rand"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/filecoin-project/go-address"
 	datatransfer "github.com/filecoin-project/go-data-transfer"
@@ -26,6 +28,7 @@ import (
 	tut "github.com/filecoin-project/go-fil-markets/shared_testutil"
 	"github.com/filecoin-project/go-fil-markets/storagemarket"
 	"github.com/filecoin-project/go-fil-markets/storagemarket/impl/blockrecorder"
+	"github.com/filecoin-project/go-fil-markets/storagemarket/impl/dealslots"
 	"github.com/filecoin-project/go-fil-markets/storagemarket/impl/providerstates"
 	"github.com/filecoin-project/go-fil-markets/storagemarket/network"
 	"github.com/filecoin-project/go-fil-markets/storagemarket/testnodes"
@@ -63,6 +66,15 @@ func TestValidateDealProposal(t *testing.T) {
 				require.Equal(t, "deal rejected: verifying StorageDealProposal: could not verify signature", deal.Message)
 			},
 		},
+		"labels too large": {
+			dealParams: dealParams{
+				Labels: storagemarket.DealLabels{"dataset": strings.Repeat("a", storagemarket.MaxDealLabelsSize)},
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealFailing, deal.State)
+				require.Equal(t, fmt.Sprintf("deal rejected: invalid deal labels: deal labels are too large: %d bytes > %d bytes", storagemarket.MaxDealLabelsSize+7, storagemarket.MaxDealLabelsSize), deal.Message)
+			},
+		},
 		"provider address does not match": {
 			environmentParams: environmentParams{
 				Address: otherAddr,
@@ -81,6 +93,18 @@ func TestValidateDealProposal(t *testing.T) {
 				require.Equal(t, "error calling node: getting most recent state id: couldn't get id", deal.Message)
 			},
 		},
+		"transient MostRecentStateID errors are not retried": {
+			nodeParams: nodeParams{
+				MostRecentStateIDError: storagemarket.NewTransientError(errors.New("couldn't get id")),
+			},
+			environmentParams: environmentParams{
+				RetryPolicy: storagemarket.RetryPolicy{MaxRetries: 2},
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealFailing, deal.State)
+				require.Equal(t, uint64(0), deal.Retries)
+			},
+		},
 		"CurrentHeight <= StartEpoch - DealAcceptanceBuffer() succeeds": {
 			environmentParams: environmentParams{DealAcceptanceBuffer: 10, TagsProposal: true},
 			dealParams:        dealParams{StartEpoch: 200},
@@ -105,6 +129,7 @@ func TestValidateDealProposal(t *testing.T) {
 			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
 				tut.AssertDealState(t, storagemarket.StorageDealFailing, deal.State)
 				require.Equal(t, "deal rejected: storage price per epoch less than asking price: 5000 < 9765", deal.Message)
+				require.Equal(t, storagemarket.RejectionPriceTooLow, deal.RejectionReason)
 			},
 		},
 		"PieceSize < MinPieceSize": {
@@ -114,6 +139,144 @@ func TestValidateDealProposal(t *testing.T) {
 			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
 				tut.AssertDealState(t, storagemarket.StorageDealFailing, deal.State)
 				require.Equal(t, "deal rejected: piece size less than minimum required size: 128 < 256", deal.Message)
+				require.Equal(t, storagemarket.RejectionPieceTooSmall, deal.RejectionReason)
+			},
+		},
+		"ProviderCollateral within ask bounds succeeds": {
+			environmentParams: environmentParams{
+				Asks:         []storagemarket.StorageAsk{collateralBoundsAsk},
+				TagsProposal: true,
+			},
+			dealParams: dealParams{
+				ProviderCollateral: abi.NewTokenAmount(1500),
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealAcceptWait, deal.State)
+			},
+		},
+		"ProviderCollateral < MinProviderCollateral": {
+			environmentParams: environmentParams{
+				Asks: []storagemarket.StorageAsk{collateralBoundsAsk},
+			},
+			dealParams: dealParams{
+				ProviderCollateral: abi.NewTokenAmount(500),
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealFailing, deal.State)
+				require.Equal(t, "deal rejected: proposed provider collateral below minimum: 500 < 1000", deal.Message)
+			},
+		},
+		"ProviderCollateral > MaxProviderCollateral": {
+			environmentParams: environmentParams{
+				Asks: []storagemarket.StorageAsk{collateralBoundsAsk},
+			},
+			dealParams: dealParams{
+				ProviderCollateral: abi.NewTokenAmount(3000),
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealFailing, deal.State)
+				require.Equal(t, "deal rejected: proposed provider collateral above maximum: 3000 > 2000", deal.Message)
+			},
+		},
+		"selects cheaper ask tier covering deal duration": {
+			environmentParams: environmentParams{
+				Asks:         []storagemarket.StorageAsk{defaultAsk, longDurationAsk(100)},
+				TagsProposal: true,
+			},
+			dealParams: dealParams{
+				StoragePricePerEpoch: abi.NewTokenAmount(5000),
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealAcceptWait, deal.State)
+			},
+		},
+		"ask tier not covering deal duration is not used": {
+			environmentParams: environmentParams{
+				Asks: []storagemarket.StorageAsk{defaultAsk, longDurationAsk(300)},
+			},
+			dealParams: dealParams{
+				StoragePricePerEpoch: abi.NewTokenAmount(5000),
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealFailing, deal.State)
+				require.Equal(t, "deal rejected: storage price per epoch less than asking price: 5000 < 9765", deal.Message)
+			},
+		},
+		"Duration > MaxDuration": {
+			environmentParams: environmentParams{
+				Asks: []storagemarket.StorageAsk{{
+					Price:        defaultAsk.Price,
+					MinPieceSize: defaultAsk.MinPieceSize,
+					MaxPieceSize: defaultAsk.MaxPieceSize,
+					MaxDuration:  100,
+				}},
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealFailing, deal.State)
+				require.Equal(t, "deal rejected: deal duration more than maximum allowed duration: 200 > 100", deal.Message)
+			},
+		},
+		"verified deal is charged the verified price": {
+			environmentParams: environmentParams{
+				Asks:         []storagemarket.StorageAsk{verifiedPriceAsk},
+				TagsProposal: true,
+			},
+			nodeParams: nodeParams{
+				DataCap: &defaultDataCap,
+			},
+			dealParams: dealParams{
+				StoragePricePerEpoch: abi.NewTokenAmount(5000),
+				VerifiedDeal:         true,
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealAcceptWait, deal.State)
+				require.True(t, deal.Proposal.VerifiedDeal)
+			},
+		},
+		"unverified deal is charged the regular price": {
+			environmentParams: environmentParams{
+				Asks: []storagemarket.StorageAsk{verifiedPriceAsk},
+			},
+			dealParams: dealParams{
+				StoragePricePerEpoch: abi.NewTokenAmount(5000),
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealFailing, deal.State)
+				require.Equal(t, "deal rejected: storage price per epoch less than asking price: 5000 < 9765", deal.Message)
+			},
+		},
+		"verified deal from client that is not verified": {
+			dealParams: dealParams{
+				VerifiedDeal: true,
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealFailing, deal.State)
+				require.Equal(t, "deal rejected: verified deal proposed by a client that is not verified", deal.Message)
+			},
+		},
+		"verified deal with too little datacap": {
+			nodeParams: nodeParams{
+				DataCap: &lowDataCap,
+			},
+			dealParams: dealParams{
+				VerifiedDeal: true,
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealFailing, deal.State)
+				require.Equal(t, "deal rejected: client datacap too low for verified deal: 1000 < 1048576", deal.Message)
+				require.Equal(t, storagemarket.RejectionDataCapTooLow, deal.RejectionReason)
+			},
+		},
+		"Get datacap error": {
+			nodeParams: nodeParams{
+				GetDataCapError: errors.New("could not get datacap"),
+			},
+			dealParams: dealParams{
+				VerifiedDeal: true,
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealFailing, deal.State)
+				require.Equal(t, "error calling node: getting client datacap: could not get datacap", deal.Message)
 			},
 		},
 		"Get balance error": {
@@ -156,6 +319,36 @@ func TestDecideOnProposal(t *testing.T) {
 		dealInspector     func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment)
 	}{
 		"succeeds": {
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealWaitingForData, deal.State)
+				require.Equal(t, deal.Proposal.PieceSize, env.stagingSpace[deal.ProposalCid])
+			},
+		},
+		"insufficient staging space": {
+			environmentParams: environmentParams{
+				StagingSpaceError: errors.New("insufficient staging space"),
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealFailing, deal.State)
+				require.Equal(t, "deal rejected: insufficient staging space", deal.Message)
+				require.Equal(t, storagemarket.RejectionNoStagingSpace, deal.RejectionReason)
+			},
+		},
+		"queues when all transfer slots are taken": {
+			environmentParams: environmentParams{
+				TransferSlots: fullDealSlots(t),
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealTransferQueued, deal.State)
+			},
+		},
+		"manual transfers do not use transfer slots": {
+			environmentParams: environmentParams{
+				TransferSlots: fullDealSlots(t),
+			},
+			dealParams: dealParams{
+				DataRef: &storagemarket.DataRef{TransferType: storagemarket.TTManual},
+			},
 			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
 				tut.AssertDealState(t, storagemarket.StorageDealWaitingForData, deal.State)
 			},
@@ -168,6 +361,20 @@ func TestDecideOnProposal(t *testing.T) {
 			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
 				tut.AssertDealState(t, storagemarket.StorageDealFailing, deal.State)
 				require.Equal(t, "deal rejected: I just don't like it", deal.Message)
+				require.Equal(t, storagemarket.RejectionDeclined, deal.RejectionReason)
+			},
+		},
+		"Custom Decision Rejects Deal With Reason": {
+			environmentParams: environmentParams{
+				DecisionError: &storagemarket.DealRejectedError{
+					Reason:  storagemarket.RejectionPriceTooLow,
+					Details: "price is below our rate for this client",
+				},
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealFailing, deal.State)
+				require.Equal(t, "deal rejected: price is below our rate for this client", deal.Message)
+				require.Equal(t, storagemarket.RejectionPriceTooLow, deal.RejectionReason)
 			},
 		},
 		"Custom Decision Errors": {
@@ -176,7 +383,18 @@ func TestDecideOnProposal(t *testing.T) {
 			},
 			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
 				tut.AssertDealState(t, storagemarket.StorageDealFailing, deal.State)
-				require.Equal(t, "error calling node: custom deal decision logic failed: I can't make up my mind", deal.Message)
+				require.Equal(t, "deal rejected: custom deal decision logic failed: I can't make up my mind", deal.Message)
+				require.Equal(t, storagemarket.RejectionUnspecified, deal.RejectionReason)
+			},
+		},
+		"Custom Decision transient errors are not retried": {
+			environmentParams: environmentParams{
+				DecisionError: storagemarket.NewTransientError(errors.New("decider timed out")),
+				RetryPolicy:   storagemarket.RetryPolicy{MaxRetries: 2},
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealFailing, deal.State)
+				require.Equal(t, uint64(0), deal.Retries)
 			},
 		},
 		"SendSignedResponse errors": {
@@ -196,6 +414,50 @@ func TestDecideOnProposal(t *testing.T) {
 	}
 }
 
+func TestWaitForTransferStart(t *testing.T) {
+	ctx := context.Background()
+	eventProcessor, err := fsm.NewEventProcessor(storagemarket.MinerDeal{}, "State", providerstates.ProviderEvents)
+	require.NoError(t, err)
+	runWaitForTransferStart := makeExecutor(ctx, eventProcessor, providerstates.WaitForTransferStart, storagemarket.StorageDealWaitingForData)
+	tests := map[string]struct {
+		nodeParams        nodeParams
+		dealParams        dealParams
+		environmentParams environmentParams
+		fileStoreParams   tut.TestFileStoreParams
+		pieceStoreParams  tut.TestPieceStoreParams
+		dealInspector     func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment)
+	}{
+		"waits without a timeout": {
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealWaitingForData, deal.State)
+			},
+		},
+		"manual transfers wait without a timeout": {
+			environmentParams: environmentParams{TransferStartTimeout: time.Nanosecond},
+			dealParams: dealParams{
+				DataRef: &storagemarket.DataRef{TransferType: storagemarket.TTManual},
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealWaitingForData, deal.State)
+			},
+		},
+	}
+	for test, data := range tests {
+		t.Run(test, func(t *testing.T) {
+			runWaitForTransferStart(t, data.nodeParams, data.environmentParams, data.dealParams, data.fileStoreParams, data.pieceStoreParams, data.dealInspector)
+		})
+	}
+
+	t.Run("fails deal when transfer does not start in time", func(t *testing.T) {
+		deal := &storagemarket.MinerDeal{State: storagemarket.StorageDealWaitingForData}
+		fsmCtx := fsmtest.NewTestContext(ctx, eventProcessor)
+		require.NoError(t, fsmCtx.Trigger(storagemarket.ProviderEventTransferStartTimedOut, time.Minute))
+		fsmCtx.ReplayEvents(t, deal)
+		tut.AssertDealState(t, storagemarket.StorageDealFailing, deal.State)
+		require.Equal(t, "client did not start sending data within 1m0s", deal.Message)
+	})
+}
+
 func TestVerifyData(t *testing.T) {
 	ctx := context.Background()
 	eventProcessor, err := fsm.NewEventProcessor(storagemarket.MinerDeal{}, "State", providerstates.ProviderEvents)
@@ -220,6 +482,16 @@ func TestVerifyData(t *testing.T) {
 				tut.AssertDealState(t, storagemarket.StorageDealEnsureProviderFunds, deal.State)
 				require.Equal(t, expPath, deal.PiecePath)
 				require.Equal(t, expMetaPath, deal.MetadataPath)
+				require.Equal(t, 0, env.commPSlots.Active())
+			},
+		},
+		"queues when all commP slots are taken": {
+			environmentParams: environmentParams{
+				CommPSlots: fullDealSlots(t),
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealVerifyDataQueued, deal.State)
+				require.Equal(t, "", deal.Message)
 			},
 		},
 		"generate piece CID fails": {
@@ -337,6 +609,46 @@ func TestEnsureProviderFunds(t *testing.T) {
 				require.Equal(t, "error calling node: ensuring funds: not enough funds", deal.Message)
 			},
 		},
+		"ensureFunds transient error is retried": {
+			nodeParams: nodeParams{
+				EnsureFundsError: storagemarket.NewTransientError(errors.New("rpc timeout")),
+			},
+			environmentParams: environmentParams{
+				RetryPolicy: storagemarket.RetryPolicy{MaxRetries: 2},
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealEnsureProviderFunds, deal.State)
+				require.Equal(t, uint64(1), deal.Retries)
+				require.Empty(t, deal.Message)
+			},
+		},
+		"ensureFunds transient error fails when out of retries": {
+			nodeParams: nodeParams{
+				EnsureFundsError: storagemarket.NewTransientError(errors.New("rpc timeout")),
+			},
+			environmentParams: environmentParams{
+				RetryPolicy: storagemarket.RetryPolicy{MaxRetries: 2},
+			},
+			dealParams: dealParams{
+				Retries: 2,
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealFailing, deal.State)
+				require.Equal(t, "error calling node: ensuring funds: rpc timeout", deal.Message)
+			},
+		},
+		"ensureFunds permanent error is not retried": {
+			nodeParams: nodeParams{
+				EnsureFundsError: errors.New("not enough funds"),
+			},
+			environmentParams: environmentParams{
+				RetryPolicy: storagemarket.RetryPolicy{MaxRetries: 2},
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealFailing, deal.State)
+				require.Equal(t, uint64(0), deal.Retries)
+			},
+		},
 	}
 	for test, data := range tests {
 		t.Run(test, func(t *testing.T) {
@@ -363,6 +675,15 @@ func TestPublishDeal(t *testing.T) {
 				tut.AssertDealState(t, storagemarket.StorageDealPublishing, deal.State)
 			},
 		},
+		"records position of deal in publish message": {
+			environmentParams: environmentParams{
+				PublishIndex: 2,
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealPublishing, deal.State)
+				require.Equal(t, uint64(2), deal.PublishIndex)
+			},
+		},
 		"PublishDealsErrors errors": {
 			nodeParams: nodeParams{
 				PublishDealsError: errors.New("could not post to chain"),
@@ -386,6 +707,7 @@ func TestWaitForPublish(t *testing.T) {
 	require.NoError(t, err)
 	runWaitForPublish := makeExecutor(ctx, eventProcessor, providerstates.WaitForPublish, storagemarket.StorageDealPublishing)
 	expDealID, psdReturnBytes := generatePublishDealsReturn(t)
+	batchDealIDs, batchReturnBytes := generateBatchPublishDealsReturn(t, 3)
 
 	tests := map[string]struct {
 		nodeParams        nodeParams
@@ -405,6 +727,30 @@ func TestWaitForPublish(t *testing.T) {
 				require.Equal(t, true, deal.ConnectionClosed)
 			},
 		},
+		"succeeds with deal ID at publish index": {
+			nodeParams: nodeParams{
+				WaitForMessageRetBytes: batchReturnBytes,
+			},
+			dealParams: dealParams{
+				PublishIndex: 1,
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealStaged, deal.State)
+				require.Equal(t, batchDealIDs[1], deal.DealID)
+			},
+		},
+		"publish index out of range": {
+			nodeParams: nodeParams{
+				WaitForMessageRetBytes: psdReturnBytes,
+			},
+			dealParams: dealParams{
+				PublishIndex: 1,
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealFailing, deal.State)
+				require.Equal(t, "PublishStorageDeal error: PublishStorageDeals returned 1 deal IDs, but deal is at index 1", deal.Message)
+			},
+		},
 		"PublishStorageDeal errors": {
 			nodeParams: nodeParams{
 				WaitForMessageExitCode: exitcode.SysErrForbidden,
@@ -426,6 +772,21 @@ func TestWaitForPublish(t *testing.T) {
 				require.Equal(t, "sending response to deal: could not send", deal.Message)
 			},
 		},
+		"succeeds without sending response when connection is closed": {
+			nodeParams: nodeParams{
+				WaitForMessageRetBytes: psdReturnBytes,
+			},
+			dealParams: dealParams{
+				ConnectionClosed: true,
+			},
+			environmentParams: environmentParams{
+				SendSignedResponseError: errors.New("could not send"),
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealStaged, deal.State)
+				require.Equal(t, expDealID, deal.DealID)
+			},
+		},
 	}
 	for test, data := range tests {
 		t.Run(test, func(t *testing.T) {
@@ -559,6 +920,7 @@ func TestRecordPieceInfo(t *testing.T) {
 			},
 			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
 				tut.AssertDealState(t, storagemarket.StorageDealCompleted, deal.State)
+				require.Equal(t, []cid.Cid{deal.ProposalCid}, env.releasedStagingSpace)
 			},
 		},
 		"succeeds w metadata": {
@@ -622,6 +984,89 @@ func TestRecordPieceInfo(t *testing.T) {
 	}
 }
 
+func TestWaitForDealCompletion(t *testing.T) {
+	ctx := context.Background()
+	eventProcessor, err := fsm.NewEventProcessor(storagemarket.MinerDeal{}, "State", providerstates.ProviderEvents)
+	require.NoError(t, err)
+	runWaitForDealCompletion := makeExecutor(ctx, eventProcessor, providerstates.WaitForDealCompletion, storagemarket.StorageDealCompleted)
+	tests := map[string]struct {
+		nodeParams        nodeParams
+		dealParams        dealParams
+		environmentParams environmentParams
+		fileStoreParams   tut.TestFileStoreParams
+		pieceStoreParams  tut.TestPieceStoreParams
+		dealInspector     func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment)
+	}{
+		"waits while deal is active": {
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealCompleted, deal.State)
+			},
+		},
+		"deal expires": {
+			nodeParams: nodeParams{
+				DealExpired: true,
+			},
+			dealParams: dealParams{
+				DealID: abi.DealID(10),
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealExpired, deal.State)
+				pieceStore := env.pieceStore.(*tut.TestPieceStore)
+				require.Equal(t, []abi.DealID{10}, pieceStore.RemovedDeals(deal.Proposal.PieceCID))
+			},
+		},
+		"deal is slashed": {
+			nodeParams: nodeParams{
+				DealSlashedEpoch: abi.ChainEpoch(100),
+			},
+			dealParams: dealParams{
+				DealID: abi.DealID(10),
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealSlashed, deal.State)
+				require.Equal(t, abi.ChainEpoch(100), deal.SlashEpoch)
+				pieceStore := env.pieceStore.(*tut.TestPieceStore)
+				require.Equal(t, []abi.DealID{10}, pieceStore.RemovedDeals(deal.Proposal.PieceCID))
+			},
+		},
+		"piece store error does not stop expiry": {
+			nodeParams: nodeParams{
+				DealExpired: true,
+			},
+			pieceStoreParams: tut.TestPieceStoreParams{
+				RemoveDealForPieceError: errors.New("could not remove deal"),
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealExpired, deal.State)
+			},
+		},
+		"watching deal fails": {
+			nodeParams: nodeParams{
+				OnDealExpiredOrSlashedError: errors.New("chain unavailable"),
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealError, deal.State)
+				require.Equal(t, "error waiting for deal completion: chain unavailable", deal.Message)
+			},
+		},
+		"expiry callback errors": {
+			nodeParams: nodeParams{
+				DealExpired:         true,
+				DealCompletionError: errors.New("lost track of deal"),
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealError, deal.State)
+				require.Equal(t, "error waiting for deal completion: expiry err: lost track of deal", deal.Message)
+			},
+		},
+	}
+	for test, data := range tests {
+		t.Run(test, func(t *testing.T) {
+			runWaitForDealCompletion(t, data.nodeParams, data.environmentParams, data.dealParams, data.fileStoreParams, data.pieceStoreParams, data.dealInspector)
+		})
+	}
+}
+
 func TestFailDeal(t *testing.T) {
 	ctx := context.Background()
 	eventProcessor, err := fsm.NewEventProcessor(storagemarket.MinerDeal{}, "State", providerstates.ProviderEvents)
@@ -638,6 +1083,7 @@ func TestFailDeal(t *testing.T) {
 		"succeeds": {
 			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
 				tut.AssertDealState(t, storagemarket.StorageDealError, deal.State)
+				require.Equal(t, []cid.Cid{deal.ProposalCid}, env.releasedStagingSpace)
 			},
 		},
 		"succeeds, skips response": {
@@ -685,6 +1131,159 @@ func TestFailDeal(t *testing.T) {
 	}
 }
 
+func TestCancelDeal(t *testing.T) {
+	ctx := context.Background()
+	eventProcessor, err := fsm.NewEventProcessor(storagemarket.MinerDeal{}, "State", providerstates.ProviderEvents)
+	require.NoError(t, err)
+	runCancelDeal := makeExecutor(ctx, eventProcessor, providerstates.CancelDeal, storagemarket.StorageDealCancelling)
+	tests := map[string]struct {
+		nodeParams        nodeParams
+		dealParams        dealParams
+		environmentParams environmentParams
+		fileStoreParams   tut.TestFileStoreParams
+		pieceStoreParams  tut.TestPieceStoreParams
+		dealInspector     func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment)
+	}{
+		"succeeds": {
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealCancelled, deal.State)
+				require.Equal(t, []cid.Cid{deal.ProposalCid}, env.closedTransfers)
+				require.True(t, deal.ConnectionClosed)
+			},
+		},
+		"succeeds, file deletions": {
+			dealParams: dealParams{
+				PiecePath:    defaultPath,
+				MetadataPath: defaultMetadataPath,
+			},
+			fileStoreParams: tut.TestFileStoreParams{
+				Files:             []filestore.File{defaultDataFile, defaultMetadataFile},
+				ExpectedDeletions: []filestore.Path{defaultPath, defaultMetadataPath},
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				tut.AssertDealState(t, storagemarket.StorageDealCancelled, deal.State)
+			},
+		},
+		"SendSignedResponse errors": {
+			environmentParams: environmentParams{
+				SendSignedResponseError: errors.New("could not send"),
+			},
+			dealInspector: func(t *testing.T, deal storagemarket.MinerDeal, env *fakeEnvironment) {
+				// the deal is cancelled regardless
+				tut.AssertDealState(t, storagemarket.StorageDealCancelled, deal.State)
+			},
+		},
+	}
+	for test, data := range tests {
+		t.Run(test, func(t *testing.T) {
+			runCancelDeal(t, data.nodeParams, data.environmentParams, data.dealParams, data.fileStoreParams, data.pieceStoreParams, data.dealInspector)
+		})
+	}
+}
+
+func TestCancelEvent(t *testing.T) {
+	eventProcessor, err := fsm.NewEventProcessor(storagemarket.MinerDeal{}, "State", providerstates.ProviderEvents)
+	require.NoError(t, err)
+
+	t.Run("cancels unpublished deal", func(t *testing.T) {
+		deal := &storagemarket.MinerDeal{State: storagemarket.StorageDealTransferring}
+		fsmCtx := fsmtest.NewTestContext(context.Background(), eventProcessor)
+		require.NoError(t, fsmCtx.Trigger(storagemarket.ProviderEventCancelled, "out of disk"))
+		fsmCtx.ReplayEvents(t, deal)
+		// the deal is only cancelled once its transfer is stopped and its data deleted
+		tut.AssertDealState(t, storagemarket.StorageDealCancelling, deal.State)
+		require.Equal(t, "deal cancelled by provider: out of disk", deal.Message)
+	})
+
+	t.Run("records data verified after cancellation", func(t *testing.T) {
+		deal := &storagemarket.MinerDeal{State: storagemarket.StorageDealCancelled}
+		fsmCtx := fsmtest.NewTestContext(context.Background(), eventProcessor)
+		require.NoError(t, fsmCtx.Trigger(storagemarket.ProviderEventVerifiedData, defaultPath, defaultMetadataPath))
+		fsmCtx.ReplayEvents(t, deal)
+		tut.AssertDealState(t, storagemarket.StorageDealCancelling, deal.State)
+		require.Equal(t, defaultPath, deal.PiecePath)
+		require.Equal(t, defaultMetadataPath, deal.MetadataPath)
+		require.True(t, deal.ConnectionClosed)
+	})
+
+	t.Run("completes cancellation", func(t *testing.T) {
+		deal := &storagemarket.MinerDeal{State: storagemarket.StorageDealCancelling}
+		fsmCtx := fsmtest.NewTestContext(context.Background(), eventProcessor)
+		require.NoError(t, fsmCtx.Trigger(storagemarket.ProviderEventCancelCompleted))
+		fsmCtx.ReplayEvents(t, deal)
+		tut.AssertDealState(t, storagemarket.StorageDealCancelled, deal.State)
+		require.True(t, deal.ConnectionClosed)
+		require.True(t, providerstates.IsFinalityState(deal.State))
+		require.False(t, providerstates.IsFinalityState(storagemarket.StorageDealCancelling))
+	})
+
+	t.Run("can cancel", func(t *testing.T) {
+		require.True(t, providerstates.CanCancel(storagemarket.StorageDealWaitingForData))
+		require.True(t, providerstates.CanCancel(storagemarket.StorageDealProviderFunding))
+		require.False(t, providerstates.CanCancel(storagemarket.StorageDealPublish))
+		require.False(t, providerstates.CanCancel(storagemarket.StorageDealSealing))
+		require.False(t, providerstates.CanCancel(storagemarket.StorageDealCompleted))
+	})
+}
+
+func TestCanResume(t *testing.T) {
+	tests := map[string]struct {
+		state        storagemarket.StorageDealStatus
+		transferType string
+		canResume    bool
+	}{
+		"validating":                 {state: storagemarket.StorageDealValidating, canResume: false},
+		"accept wait":                {state: storagemarket.StorageDealAcceptWait, canResume: false},
+		"transfer queued":            {state: storagemarket.StorageDealTransferQueued, canResume: false},
+		"transferring":               {state: storagemarket.StorageDealTransferring, canResume: false},
+		"verify data queued":         {state: storagemarket.StorageDealVerifyDataQueued, canResume: true},
+		"waiting for graphsync data": {state: storagemarket.StorageDealWaitingForData, transferType: storagemarket.TTGraphsync, canResume: false},
+		"waiting for manual data":    {state: storagemarket.StorageDealWaitingForData, transferType: storagemarket.TTManual, canResume: true},
+		"provider funding":           {state: storagemarket.StorageDealProviderFunding, canResume: true},
+		"publishing":                 {state: storagemarket.StorageDealPublishing, canResume: true},
+		"sealing":                    {state: storagemarket.StorageDealSealing, canResume: true},
+		"failing":                    {state: storagemarket.StorageDealFailing, canResume: true},
+		"error":                      {state: storagemarket.StorageDealError, canResume: false},
+		"completed":                  {state: storagemarket.StorageDealCompleted, canResume: true},
+		"expired":                    {state: storagemarket.StorageDealExpired, canResume: false},
+		"cancelling":                 {state: storagemarket.StorageDealCancelling, canResume: true},
+		"cancelled":                  {state: storagemarket.StorageDealCancelled, canResume: false},
+	}
+	for test, data := range tests {
+		t.Run(test, func(t *testing.T) {
+			deal := storagemarket.MinerDeal{
+				State: data.state,
+				Ref:   &storagemarket.DataRef{TransferType: data.transferType},
+			}
+			require.Equal(t, data.canResume, providerstates.CanResume(deal))
+		})
+	}
+}
+
+func TestRestartEvents(t *testing.T) {
+	eventProcessor, err := fsm.NewEventProcessor(storagemarket.MinerDeal{}, "State", providerstates.ProviderEvents)
+	require.NoError(t, err)
+
+	t.Run("restart keeps state and closes connection", func(t *testing.T) {
+		deal := &storagemarket.MinerDeal{State: storagemarket.StorageDealPublishing}
+		fsmCtx := fsmtest.NewTestContext(context.Background(), eventProcessor)
+		require.NoError(t, fsmCtx.Trigger(storagemarket.ProviderEventRestart))
+		fsmCtx.ReplayEvents(t, deal)
+		tut.AssertDealState(t, storagemarket.StorageDealPublishing, deal.State)
+		require.True(t, deal.ConnectionClosed)
+	})
+
+	t.Run("restart failed fails deal", func(t *testing.T) {
+		deal := &storagemarket.MinerDeal{State: storagemarket.StorageDealAcceptWait}
+		fsmCtx := fsmtest.NewTestContext(context.Background(), eventProcessor)
+		require.NoError(t, fsmCtx.Trigger(storagemarket.ProviderEventRestartFailed))
+		fsmCtx.ReplayEvents(t, deal)
+		tut.AssertDealState(t, storagemarket.StorageDealFailing, deal.State)
+		require.True(t, deal.ConnectionClosed)
+		require.Equal(t, "deal could not be resumed after restart: connection to client was lost", deal.Message)
+	})
+}
+
 // all of these default parameters are setup to allow a deal to complete each handler with no errors
 var defaultHeight = abi.ChainEpoch(50)
 var defaultTipSetToken = []byte{1, 2, 3}
@@ -712,6 +1311,36 @@ var defaultAsk = storagemarket.StorageAsk{
 	MaxPieceSize: 1 << 20,
 }
 
+// collateralBoundsAsk requires between 1000 and 2000 provider collateral for a deal of the default piece size
+var collateralBoundsAsk = storagemarket.StorageAsk{
+	Price:                 defaultAsk.Price,
+	MinPieceSize:          defaultAsk.MinPieceSize,
+	MaxPieceSize:          defaultAsk.MaxPieceSize,
+	MinProviderCollateral: abi.NewTokenAmount(1000 * 1024),
+	MaxProviderCollateral: abi.NewTokenAmount(2000 * 1024),
+}
+
+// longDurationAsk is a tier at half the default price for deals of at least the given duration
+func longDurationAsk(minDuration abi.ChainEpoch) storagemarket.StorageAsk {
+	return storagemarket.StorageAsk{
+		Price:        abi.NewTokenAmount(5000000),
+		MinPieceSize: defaultAsk.MinPieceSize,
+		MaxPieceSize: defaultAsk.MaxPieceSize,
+		MinDuration:  minDuration,
+	}
+}
+
+// verifiedPriceAsk charges verified deals a tenth of the default price
+var verifiedPriceAsk = storagemarket.StorageAsk{
+	Price:         defaultAsk.Price,
+	MinPieceSize:  defaultAsk.MinPieceSize,
+	MaxPieceSize:  defaultAsk.MaxPieceSize,
+	VerifiedPrice: abi.NewTokenAmount(1000000),
+}
+
+var defaultDataCap = abi.NewStoragePower(1 << 20)
+var lowDataCap = abi.NewStoragePower(1000)
+
 var testData = tut.NewTestIPLDTree()
 var dataBuf = new(bytes.Buffer)
 var blockLocationBuf = new(bytes.Buffer)
@@ -738,6 +1367,20 @@ func generatePublishDealsReturn(t *testing.T) (abi.DealID, []byte) {
 	return dealId, psdReturnBytes.Bytes()
 }
 
+func generateBatchPublishDealsReturn(t *testing.T, count int) ([]abi.DealID, []byte) {
+	var dealIDs []abi.DealID
+	for i := 0; i < count; i++ {
+		dealIDs = append(dealIDs, abi.DealID(rand.Uint64()))
+	}
+
+	psdReturn := market.PublishStorageDealsReturn{IDs: dealIDs}
+	psdReturnBytes := bytes.NewBuffer([]byte{})
+	err := psdReturn.MarshalCBOR(psdReturnBytes)
+	require.NoError(t, err)
+
+	return dealIDs, psdReturnBytes.Bytes()
+}
+
 type nodeParams struct {
 	MinerAddr                           address.Address
 	MinerWorkerError                    error
@@ -760,6 +1403,19 @@ type nodeParams struct {
 	WaitForMessageError                 error
 	WaitForMessageExitCode              exitcode.ExitCode
 	WaitForMessageRetBytes              []byte
+	OnDealExpiredOrSlashedError         error
+	DealExpired                         bool
+	DealSlashedEpoch                    abi.ChainEpoch
+	DealCompletionError                 error
+	DataCap                             *abi.StoragePower
+	GetDataCapError                     error
+}
+
+// fullDealSlots returns deal slots whose only slot is taken by another deal
+func fullDealSlots(t *testing.T) *dealslots.DealSlots {
+	slots := dealslots.NewDealSlots(1)
+	require.True(t, slots.TryAcquire(tut.GenerateCids(1)[0]))
+	return slots
 }
 
 type dealParams struct {
@@ -773,11 +1429,15 @@ type dealParams struct {
 	PieceSize            abi.PaddedPieceSize
 	StartEpoch           abi.ChainEpoch
 	EndEpoch             abi.ChainEpoch
+	PublishIndex         uint64
+	Retries              uint64
+	Labels               storagemarket.DealLabels
+	VerifiedDeal         bool
 }
 
 type environmentParams struct {
 	Address                 address.Address
-	Ask                     storagemarket.StorageAsk
+	Asks                    []storagemarket.StorageAsk
 	DataTransferError       error
 	PieceCid                cid.Cid
 	Path                    filestore.Path
@@ -790,6 +1450,12 @@ type environmentParams struct {
 	RejectDeal              bool
 	RejectReason            string
 	DecisionError           error
+	PublishIndex            uint64
+	RetryPolicy             storagemarket.RetryPolicy
+	StagingSpaceError       error
+	TransferSlots           *dealslots.DealSlots
+	TransferStartTimeout    time.Duration
+	CommPSlots              *dealslots.DealSlots
 }
 
 type executor func(t *testing.T,
@@ -837,6 +1503,11 @@ func makeExecutor(ctx context.Context,
 			WaitForMessageError:    nodeParams.WaitForMessageError,
 			WaitForMessageExitCode: nodeParams.WaitForMessageExitCode,
 			WaitForMessageRetBytes: nodeParams.WaitForMessageRetBytes,
+
+			OnDealExpiredOrSlashedError: nodeParams.OnDealExpiredOrSlashedError,
+			DealExpired:                 nodeParams.DealExpired,
+			DealSlashedEpoch:            nodeParams.DealSlashedEpoch,
+			DealCompletionError:         nodeParams.DealCompletionError,
 		}
 
 		node := &testnodes.FakeProviderNode{
@@ -850,6 +1521,8 @@ func makeExecutor(ctx context.Context,
 			LocatePieceForDealWithinSectorError: nodeParams.LocatePieceForDealWithinSectorError,
 			DealCommittedSyncError:              nodeParams.DealCommittedSyncError,
 			DealCommittedAsyncError:             nodeParams.DealCommittedAsyncError,
+			DataCap:                             nodeParams.DataCap,
+			GetDataCapError:                     nodeParams.GetDataCapError,
 		}
 
 		if nodeParams.MinerAddr == address.Undef {
@@ -866,6 +1539,7 @@ func makeExecutor(ctx context.Context,
 			StoragePricePerEpoch: defaultStoragePricePerEpoch,
 			ProviderCollateral:   defaultProviderCollateral,
 			ClientCollateral:     defaultClientCollateral,
+			VerifiedDeal:         dealParams.VerifiedDeal,
 		}
 		if !dealParams.StoragePricePerEpoch.Nil() {
 			proposal.StoragePricePerEpoch = dealParams.StoragePricePerEpoch
@@ -907,6 +1581,9 @@ func makeExecutor(ctx context.Context,
 		if dealParams.DealID != abi.DealID(0) {
 			dealState.DealID = dealParams.DealID
 		}
+		dealState.PublishIndex = dealParams.PublishIndex
+		dealState.Retries = dealParams.Retries
+		dealState.Labels = dealParams.Labels
 		fs := tut.NewTestFileStore(fileStoreParams)
 		pieceStore := tut.NewTestPieceStoreWithParams(pieceStoreParams)
 		expectedTags := make(map[string]struct{})
@@ -918,7 +1595,7 @@ func makeExecutor(ctx context.Context,
 			receivedTags:            make(map[string]struct{}),
 			address:                 params.Address,
 			node:                    node,
-			ask:                     params.Ask,
+			asks:                    params.Asks,
 			dataTransferError:       params.DataTransferError,
 			pieceCid:                params.PieceCid,
 			path:                    params.Path,
@@ -930,9 +1607,22 @@ func makeExecutor(ctx context.Context,
 			rejectReason:            params.RejectReason,
 			decisionError:           params.DecisionError,
 			dealAcceptanceBuffer:    abi.ChainEpoch(params.DealAcceptanceBuffer),
+			publishIndex:            params.PublishIndex,
+			retryPolicy:             params.RetryPolicy,
+			stagingSpaceError:       params.StagingSpaceError,
+			stagingSpace:            make(map[cid.Cid]abi.PaddedPieceSize),
+			transferSlots:           params.TransferSlots,
+			transferStartTimeout:    params.TransferStartTimeout,
+			commPSlots:              params.CommPSlots,
 			fs:                      fs,
 			pieceStore:              pieceStore,
 		}
+		if environment.transferSlots == nil {
+			environment.transferSlots = dealslots.NewDealSlots(0)
+		}
+		if environment.commPSlots == nil {
+			environment.commPSlots = dealslots.NewDealSlots(0)
+		}
 		if environment.pieceCid == cid.Undef {
 			environment.pieceCid = defaultPieceCid
 		}
@@ -945,8 +1635,8 @@ func makeExecutor(ctx context.Context,
 		if environment.address == address.Undef {
 			environment.address = defaultProviderAddress
 		}
-		if environment.ask == storagemarket.StorageAskUndefined {
-			environment.ask = defaultAsk
+		if environment.asks == nil {
+			environment.asks = []storagemarket.StorageAsk{defaultAsk}
 		}
 
 		fsmCtx := fsmtest.NewTestContext(ctx, eventProcessor)
@@ -964,7 +1654,7 @@ func makeExecutor(ctx context.Context,
 type fakeEnvironment struct {
 	address                 address.Address
 	node                    storagemarket.StorageProviderNode
-	ask                     storagemarket.StorageAsk
+	asks                    []storagemarket.StorageAsk
 	dataTransferError       error
 	pieceCid                cid.Cid
 	path                    filestore.Path
@@ -978,31 +1668,76 @@ type fakeEnvironment struct {
 	fs                      filestore.FileStore
 	pieceStore              piecestore.PieceStore
 	dealAcceptanceBuffer    abi.ChainEpoch
+	publishIndex            uint64
 	expectedTags            map[string]struct{}
 	receivedTags            map[string]struct{}
+	closedTransfers         []cid.Cid
+	retryPolicy             storagemarket.RetryPolicy
+	stagingSpaceError       error
+	stagingSpace            map[cid.Cid]abi.PaddedPieceSize
+	releasedStagingSpace    []cid.Cid
+	transferSlots           *dealslots.DealSlots
+	transferStartTimeout    time.Duration
+	commPSlots              *dealslots.DealSlots
+}
+
+func (fe *fakeEnvironment) TransferSlots() *dealslots.DealSlots {
+	return fe.transferSlots
+}
+
+func (fe *fakeEnvironment) TransferStartTimeout() time.Duration {
+	return fe.transferStartTimeout
+}
+
+func (fe *fakeEnvironment) CommPSlots() *dealslots.DealSlots {
+	return fe.commPSlots
+}
+
+func (fe *fakeEnvironment) ReserveStagingSpace(proposalCid cid.Cid, pieceSize abi.PaddedPieceSize) error {
+	if fe.stagingSpaceError != nil {
+		return fe.stagingSpaceError
+	}
+	fe.stagingSpace[proposalCid] = pieceSize
+	return nil
 }
 
-func (fe *fakeEnvironment) Address() address.Address {
-	return fe.address
+func (fe *fakeEnvironment) ReleaseStagingSpace(proposalCid cid.Cid) {
+	delete(fe.stagingSpace, proposalCid)
+	fe.releasedStagingSpace = append(fe.releasedStagingSpace, proposalCid)
+}
+
+func (fe *fakeEnvironment) IsMinerActor(addr address.Address) bool {
+	return addr == fe.address
 }
 
 func (fe *fakeEnvironment) Node() storagemarket.StorageProviderNode {
 	return fe.node
 }
 
-func (fe *fakeEnvironment) Ask() storagemarket.StorageAsk {
-	return fe.ask
+func (fe *fakeEnvironment) Asks(miner address.Address) []storagemarket.StorageAsk {
+	if miner != fe.address {
+		return nil
+	}
+	return fe.asks
 }
 
 func (fe *fakeEnvironment) StartDataTransfer(ctx context.Context, to peer.ID, voucher datatransfer.Voucher, baseCid cid.Cid, selector ipld.Node) error {
 	return fe.dataTransferError
 }
 
+func (fe *fakeEnvironment) RetryPolicy() storagemarket.RetryPolicy {
+	return fe.retryPolicy
+}
+
+func (fe *fakeEnvironment) CloseDataTransfer(proposalCid cid.Cid) {
+	fe.closedTransfers = append(fe.closedTransfers, proposalCid)
+}
+
 func (fe *fakeEnvironment) GeneratePieceCommitmentToFile(payloadCid cid.Cid, selector ipld.Node) (cid.Cid, filestore.Path, filestore.Path, error) {
 	return fe.pieceCid, fe.path, fe.metadataPath, fe.generateCommPError
 }
 
-func (fe *fakeEnvironment) SendSignedResponse(ctx context.Context, response *network.Response) error {
+func (fe *fakeEnvironment) SendSignedResponse(ctx context.Context, miner address.Address, response *network.Response) error {
 	return fe.sendSignedResponseError
 }
 
@@ -1034,3 +1769,8 @@ func (fe *fakeEnvironment) DealAcceptanceBuffer() abi.ChainEpoch {
 func (fe *fakeEnvironment) RunCustomDecisionLogic(context.Context, storagemarket.MinerDeal) (bool, string, error) {
 	return !fe.rejectDeal, fe.rejectReason, fe.decisionError
 }
+
+func (fe *fakeEnvironment) PublishDeal(ctx context.Context, deal storagemarket.MinerDeal) (cid.Cid, uint64, error) {
+	mcid, err := fe.node.PublishDeals(ctx, []storagemarket.MinerDeal{deal})
+	return mcid, fe.publishIndex, err
+}
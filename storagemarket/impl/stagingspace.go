@@ -0,0 +1,121 @@
+package storageimpl
+
+import (
+	"sync"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-fil-markets/filestore"
+)
+
+// StagingSpace tracks how much of the provider's FileStore is reserved for data staged
+// for deals, between accepting a deal and handing its piece off for sealing.
+// A budget of zero means staging space is limited by the free space on the FileStore's
+// disk if it can report it, and is unlimited otherwise
+type StagingSpace struct {
+	budget uint64
+	disk   filestore.SpaceReporter
+	// diskBudget is true if the budget was taken from the free space on the disk
+	diskBudget bool
+
+	lk           sync.Mutex
+	reserved     uint64
+	reservations map[cid.Cid]uint64
+}
+
+// NewStagingSpace returns a new StagingSpace that never reserves more than budget bytes.
+// If the FileStore can report the space left on its disk, reservations also have to fit
+// in that space, and a budget of zero is replaced with the space free on the disk now, so
+// that reservations for data not yet written cannot add up to more than the disk holds
+func NewStagingSpace(budget uint64, fs filestore.FileStore) *StagingSpace {
+	disk, _ := fs.(filestore.SpaceReporter)
+	s := &StagingSpace{
+		budget:       budget,
+		disk:         disk,
+		reservations: make(map[cid.Cid]uint64),
+	}
+	if budget == 0 && disk != nil {
+		free, err := disk.FreeSpace()
+		if err != nil {
+			log.Warnf("checking free staging space, staging space is only limited per deal: %s", err)
+			return s
+		}
+		s.budget = free
+		s.diskBudget = true
+	}
+	return s
+}
+
+// StagingSpaceForPiece is the space reserved for a deal with the given piece size.
+// The CAR file and its padding written for a deal never exceed the padded piece size,
+// so that is enough for the piece itself. When all blocks are indexed for retrieval,
+// the metadata file is bounded by the number of blocks, which is allowed for with an
+// extra 1/128 of the piece size
+func StagingSpaceForPiece(pieceSize abi.PaddedPieceSize) uint64 {
+	return uint64(pieceSize) + uint64(pieceSize)/128
+}
+
+// Reserve reserves size bytes for a deal, replacing any existing reservation for it.
+// It fails if the reservation would exceed the budget, or the space left on the disk.
+// Only the new reservation is checked against the disk, as data already staged for other
+// deals is no longer free space. The budget keeps room for data that is reserved but not
+// yet written
+func (s *StagingSpace) Reserve(proposalCid cid.Cid, size uint64) error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	reserved := s.reserved - s.reservations[proposalCid]
+	if s.disk != nil {
+		free, err := s.disk.FreeSpace()
+		if err != nil {
+			return xerrors.Errorf("checking free staging space: %w", err)
+		}
+		if size > free {
+			return xerrors.Errorf("insufficient staging space: deal needs %d bytes, but only %d are free on disk", size, free)
+		}
+	}
+	if (s.budget > 0 || s.diskBudget) && reserved+size > s.budget {
+		var free uint64
+		if reserved < s.budget {
+			free = s.budget - reserved
+		}
+		return xerrors.Errorf("insufficient staging space: deal needs %d bytes, but only %d of %d are free", size, free, s.budget)
+	}
+	s.reservations[proposalCid] = size
+	s.reserved = reserved + size
+	return nil
+}
+
+// Restore records a reservation for a deal that was accepted before the provider
+// restarted, even if it exceeds the budget. A budget taken from the free space on the disk
+// grows by the restored reservation, as the deal's data may already be on the disk
+func (s *StagingSpace) Restore(proposalCid cid.Cid, size uint64) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	if s.diskBudget {
+		s.budget = s.budget - s.reservations[proposalCid] + size
+	}
+	s.reserved = s.reserved - s.reservations[proposalCid] + size
+	s.reservations[proposalCid] = size
+}
+
+// Release frees the space reserved for a deal. Releasing a deal without a reservation
+// does nothing
+func (s *StagingSpace) Release(proposalCid cid.Cid) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	s.reserved -= s.reservations[proposalCid]
+	delete(s.reservations, proposalCid)
+}
+
+// Reserved returns the total number of bytes currently reserved
+func (s *StagingSpace) Reserved() uint64 {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	return s.reserved
+}
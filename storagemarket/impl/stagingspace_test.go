@@ -0,0 +1,91 @@
+package storageimpl_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-fil-markets/filestore"
+	"github.com/filecoin-project/go-fil-markets/shared_testutil"
+	storageimpl "github.com/filecoin-project/go-fil-markets/storagemarket/impl"
+)
+
+func TestStagingSpace(t *testing.T) {
+	deals := shared_testutil.GenerateCids(3)
+
+	t.Run("rejects reservations over budget", func(t *testing.T) {
+		space := storageimpl.NewStagingSpace(100, nil)
+		require.NoError(t, space.Reserve(deals[0], 60))
+		require.EqualError(t, space.Reserve(deals[1], 50), "insufficient staging space: deal needs 50 bytes, but only 40 of 100 are free")
+		require.NoError(t, space.Reserve(deals[1], 40))
+		require.Equal(t, uint64(100), space.Reserved())
+	})
+
+	t.Run("released space can be reserved again", func(t *testing.T) {
+		space := storageimpl.NewStagingSpace(100, nil)
+		require.NoError(t, space.Reserve(deals[0], 60))
+		space.Release(deals[0])
+		space.Release(deals[1])
+		require.Equal(t, uint64(0), space.Reserved())
+		require.NoError(t, space.Reserve(deals[1], 100))
+	})
+
+	t.Run("reserving again replaces the reservation", func(t *testing.T) {
+		space := storageimpl.NewStagingSpace(100, nil)
+		require.NoError(t, space.Reserve(deals[0], 60))
+		require.NoError(t, space.Reserve(deals[0], 80))
+		require.Equal(t, uint64(80), space.Reserved())
+	})
+
+	t.Run("restored reservations may exceed the budget", func(t *testing.T) {
+		space := storageimpl.NewStagingSpace(100, nil)
+		space.Restore(deals[0], 80)
+		space.Restore(deals[1], 80)
+		require.Equal(t, uint64(160), space.Reserved())
+		require.Error(t, space.Reserve(deals[2], 1))
+	})
+
+	t.Run("zero budget is unlimited", func(t *testing.T) {
+		space := storageimpl.NewStagingSpace(0, nil)
+		require.NoError(t, space.Reserve(deals[0], 1<<40))
+		require.Equal(t, uint64(1<<40), space.Reserved())
+	})
+
+	t.Run("rejects reservations over the free disk space", func(t *testing.T) {
+		space := storageimpl.NewStagingSpace(0, &diskFileStore{free: 50})
+		require.EqualError(t, space.Reserve(deals[0], 60), "insufficient staging space: deal needs 60 bytes, but only 50 are free on disk")
+		require.NoError(t, space.Reserve(deals[0], 50))
+	})
+
+	t.Run("zero budget is the free disk space", func(t *testing.T) {
+		// each reservation fits on the disk alone, as neither has written any data
+		// yet, but together they need more space than the disk has
+		space := storageimpl.NewStagingSpace(0, &diskFileStore{free: 100})
+		require.NoError(t, space.Reserve(deals[0], 60))
+		require.EqualError(t, space.Reserve(deals[1], 60), "insufficient staging space: deal needs 60 bytes, but only 40 of 100 are free")
+		require.NoError(t, space.Reserve(deals[1], 40))
+	})
+
+	t.Run("restored reservations grow a budget from the disk", func(t *testing.T) {
+		space := storageimpl.NewStagingSpace(0, &diskFileStore{free: 100})
+		space.Restore(deals[0], 80)
+		require.NoError(t, space.Reserve(deals[1], 100))
+		require.Error(t, space.Reserve(deals[2], 1))
+	})
+
+	t.Run("fails if the free disk space is unknown", func(t *testing.T) {
+		space := storageimpl.NewStagingSpace(100, &diskFileStore{err: errors.New("disk gone")})
+		require.Error(t, space.Reserve(deals[0], 10))
+	})
+}
+
+type diskFileStore struct {
+	filestore.FileStore
+	free uint64
+	err  error
+}
+
+func (fs *diskFileStore) FreeSpace() (uint64, error) {
+	return fs.free, fs.err
+}
@@ -0,0 +1,153 @@
+package storedask
+
+import (
+	"context"
+	"time"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/hannahhoward/go-pubsub"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-fil-markets/shared"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+)
+
+// DefaultRenewalBuffer is the number of epochs before expiry at which an ask is renewed
+const DefaultRenewalBuffer abi.ChainEpoch = 100
+
+// DefaultRenewalPollInterval is how often the chain height is checked for expiring asks
+const DefaultRenewalPollInterval = time.Minute
+
+// RenewalBuffer sets the number of epochs before expiry at which an ask is renewed
+func RenewalBuffer(buffer abi.ChainEpoch) StoredAskOption {
+	return func(s *StoredAsk) {
+		s.renewalBuffer = buffer
+	}
+}
+
+// RenewalPollInterval sets how often the chain height is checked for expiring asks
+func RenewalPollInterval(interval time.Duration) StoredAskOption {
+	return func(s *StoredAsk) {
+		s.renewalPollInterval = interval
+	}
+}
+
+// RenewalSubscriber is called with the new ask whenever an ask is renewed
+type RenewalSubscriber func(ask storagemarket.SignedStorageAsk)
+
+// SubscribeToRenewals registers a subscriber that is called whenever an ask is renewed
+func (s *StoredAsk) SubscribeToRenewals(subscriber RenewalSubscriber) shared.Unsubscribe {
+	return shared.Unsubscribe(s.renewals.Subscribe(subscriber))
+}
+
+// Start watches the chain height in the background, renewing asks as they approach
+// expiry, until Stop is called or the context is cancelled
+func (s *StoredAsk) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.renewalPollInterval)
+		defer ticker.Stop()
+		for {
+			if err := s.RenewExpiringAsks(ctx); err != nil {
+				log.Errorf("renewing asks: %s", err)
+			}
+			select {
+			case <-ticker.C:
+			case <-s.stop:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops watching the chain height for expiring asks
+func (s *StoredAsk) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+}
+
+// RenewExpiringAsks re-signs every ask that expires within the renewal buffer of the current
+// chain height, keeping its terms and duration and incrementing its sequence number. It can
+// be called directly from a chain head change notification instead of using Start
+func (s *StoredAsk) RenewExpiringAsks(ctx context.Context) error {
+	tok, height, err := s.spn.GetChainHead(ctx)
+	if err != nil {
+		return xerrors.Errorf("getting chain head: %w", err)
+	}
+
+	var renewed []*storagemarket.SignedStorageAsk
+
+	s.askLk.Lock()
+	if s.ask != nil && s.expiring(s.ask.Ask, height) {
+		ask, err := s.renew(ctx, tok, height, s.ask.Ask)
+		if err != nil {
+			s.askLk.Unlock()
+			return err
+		}
+		if err := s.saveAsk(ask); err != nil {
+			s.askLk.Unlock()
+			return err
+		}
+		renewed = append(renewed, ask)
+	}
+
+	tiers := make([]*storagemarket.SignedStorageAsk, 0, len(s.tiers))
+	tiersRenewed := false
+	for _, tier := range s.tiers {
+		if !s.expiring(tier.Ask, height) {
+			tiers = append(tiers, tier)
+			continue
+		}
+		ask, err := s.renew(ctx, tok, height, tier.Ask)
+		if err != nil {
+			s.askLk.Unlock()
+			return err
+		}
+		tiers = append(tiers, ask)
+		renewed = append(renewed, ask)
+		tiersRenewed = true
+	}
+	if tiersRenewed {
+		if err := s.saveTiers(tiers); err != nil {
+			s.askLk.Unlock()
+			return err
+		}
+	}
+	s.askLk.Unlock()
+
+	for _, ask := range renewed {
+		if err := s.renewals.Publish(*ask); err != nil {
+			log.Errorf("failed to publish ask renewal: %s", err)
+		}
+	}
+	return nil
+}
+
+func (s *StoredAsk) expiring(ask *storagemarket.StorageAsk, height abi.ChainEpoch) bool {
+	return height >= ask.Expiry-s.renewalBuffer
+}
+
+// renew signs a copy of the given ask that starts at the given height, with the same
+// duration and the next sequence number
+func (s *StoredAsk) renew(ctx context.Context, tok shared.TipSetToken, height abi.ChainEpoch, old *storagemarket.StorageAsk) (*storagemarket.SignedStorageAsk, error) {
+	ask := *old
+	ask.Timestamp = height
+	ask.Expiry = height + (old.Expiry - old.Timestamp)
+	ask.SeqNo = old.SeqNo + 1
+	return s.sign(ctx, tok, &ask)
+}
+
+func renewalDispatcher(evt pubsub.Event, fn pubsub.SubscriberFn) error {
+	ask, ok := evt.(storagemarket.SignedStorageAsk)
+	if !ok {
+		return xerrors.New("wrong type of event")
+	}
+	cb, ok := fn.(RenewalSubscriber)
+	if !ok {
+		return xerrors.New("wrong type of callback")
+	}
+	cb(ask)
+	return nil
+}
@@ -4,14 +4,18 @@ import (
 	"bytes"
 	"context"
 	"sync"
+	"time"
 
 	"github.com/filecoin-project/go-address"
 	cborutil "github.com/filecoin-project/go-cbor-util"
 	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/hannahhoward/go-pubsub"
 	"github.com/ipfs/go-datastore"
 	logging "github.com/ipfs/go-log/v2"
+	cbg "github.com/whyrusleeping/cbor-gen"
 	"golang.org/x/xerrors"
 
+	"github.com/filecoin-project/go-fil-markets/shared"
 	"github.com/filecoin-project/go-fil-markets/storagemarket"
 	"github.com/filecoin-project/go-fil-markets/storagemarket/impl/providerutils"
 )
@@ -19,6 +23,9 @@ import (
 var log = logging.Logger("storedask")
 var defaultPrice = abi.NewTokenAmount(500_000_000)
 
+// legacyAskKey is the key asks were saved under before each StoredAsk had its own key
+var legacyAskKey = datastore.Key{}
+
 const defaultDuration abi.ChainEpoch = 1000000
 const defaultMinPieceSize abi.PaddedPieceSize = 256
 
@@ -26,26 +33,52 @@ const defaultMinPieceSize abi.PaddedPieceSize = 256
 const defaultMaxPieceSize abi.PaddedPieceSize = 1 << 20
 
 type StoredAsk struct {
-	askLk sync.RWMutex
-	ask   *storagemarket.SignedStorageAsk
-	ds    datastore.Batching
-	dsKey datastore.Key
-	spn   storagemarket.StorageProviderNode
-	actor address.Address
+	askLk    sync.RWMutex
+	ask      *storagemarket.SignedStorageAsk
+	tiers    []*storagemarket.SignedStorageAsk
+	ds       datastore.Batching
+	dsKey    datastore.Key
+	tiersKey datastore.Key
+	spn      storagemarket.StorageProviderNode
+	actor    address.Address
+
+	renewalBuffer       abi.ChainEpoch
+	renewalPollInterval time.Duration
+	renewals            *pubsub.PubSub
+	stop                chan struct{}
+	stopOnce            sync.Once
 }
 
-func NewStoredAsk(ds datastore.Batching, dsKey datastore.Key, spn storagemarket.StorageProviderNode, actor address.Address) (*StoredAsk, error) {
+// StoredAskOption allows custom configuration of a StoredAsk
+type StoredAskOption func(*StoredAsk)
+
+// NewStoredAsk returns a StoredAsk for the given miner actor, loading its asks from the
+// datastore or creating a default ask if there are none
+func NewStoredAsk(ds datastore.Batching, dsKey datastore.Key, spn storagemarket.StorageProviderNode, actor address.Address, options ...StoredAskOption) (*StoredAsk, error) {
 
 	s := &StoredAsk{
-		ds:    ds,
-		spn:   spn,
-		actor: actor,
+		ds:                  ds,
+		dsKey:               dsKey,
+		tiersKey:            dsKey.ChildString("tiers"),
+		spn:                 spn,
+		actor:               actor,
+		renewalBuffer:       DefaultRenewalBuffer,
+		renewalPollInterval: DefaultRenewalPollInterval,
+		renewals:            pubsub.New(renewalDispatcher),
+		stop:                make(chan struct{}),
+	}
+	for _, option := range options {
+		option(s)
 	}
 
 	if err := s.tryLoadAsk(); err != nil {
 		return nil, err
 	}
 
+	if err := s.tryLoadTiers(); err != nil {
+		return nil, err
+	}
+
 	if s.ask == nil {
 		// TODO: we should be fine with this state, and just say it means 'not actively accepting deals'
 		// for now... lets just set a price
@@ -64,21 +97,60 @@ func (s *StoredAsk) AddAsk(price abi.TokenAmount, duration abi.ChainEpoch, optio
 		seqno = s.ask.Ask.SeqNo + 1
 	}
 
+	ask, err := s.signAsk(price, duration, seqno, options)
+	if err != nil {
+		return err
+	}
+
+	return s.saveAsk(ask)
+}
+
+// AddAskTier adds an ask that applies to the piece size and duration ranges set by its
+// options, alongside the default ask. A tier with the same ranges as an existing tier
+// replaces it, with the next sequence number
+func (s *StoredAsk) AddAskTier(price abi.TokenAmount, duration abi.ChainEpoch, options ...storagemarket.StorageAskOption) error {
+	s.askLk.Lock()
+	defer s.askLk.Unlock()
+
+	var tier storagemarket.StorageAsk
+	applyDefaults(&tier)
+	for _, option := range options {
+		option(&tier)
+	}
+
+	tiers := make([]*storagemarket.SignedStorageAsk, 0, len(s.tiers)+1)
+	var seqno uint64
+	for _, existing := range s.tiers {
+		if existing.Ask.SameTier(tier) {
+			seqno = existing.Ask.SeqNo + 1
+			continue
+		}
+		tiers = append(tiers, existing)
+	}
+
+	ask, err := s.signAsk(price, duration, seqno, options)
+	if err != nil {
+		return err
+	}
+
+	return s.saveTiers(append(tiers, ask))
+}
+
+func (s *StoredAsk) signAsk(price abi.TokenAmount, duration abi.ChainEpoch, seqno uint64, options []storagemarket.StorageAskOption) (*storagemarket.SignedStorageAsk, error) {
 	ctx := context.TODO()
 
 	_, height, err := s.spn.GetChainHead(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	ask := &storagemarket.StorageAsk{
-		Price:        price,
-		Timestamp:    height,
-		Expiry:       height + duration,
-		Miner:        s.actor,
-		SeqNo:        seqno,
-		MinPieceSize: defaultMinPieceSize,
-		MaxPieceSize: defaultMaxPieceSize,
+		Price:     price,
+		Timestamp: height,
+		Expiry:    height + duration,
+		Miner:     s.actor,
+		SeqNo:     seqno,
 	}
+	applyDefaults(ask)
 
 	for _, option := range options {
 		option(ask)
@@ -86,19 +158,27 @@ func (s *StoredAsk) AddAsk(price abi.TokenAmount, duration abi.ChainEpoch, optio
 
 	tok, _, err := s.spn.GetChainHead(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	return s.sign(ctx, tok, ask)
+}
+
+func (s *StoredAsk) sign(ctx context.Context, tok shared.TipSetToken, ask *storagemarket.StorageAsk) (*storagemarket.SignedStorageAsk, error) {
 	sig, err := providerutils.SignMinerData(ctx, ask, s.actor, tok, s.spn.GetMinerWorkerAddress, s.spn.SignBytes)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return s.saveAsk(&storagemarket.SignedStorageAsk{
+	return &storagemarket.SignedStorageAsk{
 		Ask:       ask,
 		Signature: sig,
-	})
+	}, nil
+}
 
+func applyDefaults(ask *storagemarket.StorageAsk) {
+	ask.MinPieceSize = defaultMinPieceSize
+	ask.MaxPieceSize = defaultMaxPieceSize
 }
 
 func (s *StoredAsk) GetAsk(addr address.Address) *storagemarket.SignedStorageAsk {
@@ -114,11 +194,33 @@ func (s *StoredAsk) GetAsk(addr address.Address) *storagemarket.SignedStorageAsk
 	return &ask
 }
 
+// GetAsks returns the default ask followed by any additional ask tiers
+func (s *StoredAsk) GetAsks(addr address.Address) []*storagemarket.SignedStorageAsk {
+	s.askLk.RLock()
+	defer s.askLk.RUnlock()
+	if s.actor != addr {
+		return nil
+	}
+	var asks []*storagemarket.SignedStorageAsk
+	if s.ask != nil {
+		ask := *s.ask
+		asks = append(asks, &ask)
+	}
+	for _, tier := range s.tiers {
+		ask := *tier
+		asks = append(asks, &ask)
+	}
+	return asks
+}
+
 func (s *StoredAsk) tryLoadAsk() error {
 	s.askLk.Lock()
 	defer s.askLk.Unlock()
 
-	err := s.loadAsk()
+	err := s.loadAsk(s.dsKey)
+	if xerrors.Is(err, datastore.ErrNotFound) && s.dsKey != legacyAskKey {
+		err = s.migrateLegacyAsk()
+	}
 	if err != nil {
 		if xerrors.Is(err, datastore.ErrNotFound) {
 			log.Warn("no previous ask found, miner will not accept deals until a price is set")
@@ -130,8 +232,8 @@ func (s *StoredAsk) tryLoadAsk() error {
 	return nil
 }
 
-func (s *StoredAsk) loadAsk() error {
-	askb, err := s.ds.Get(s.dsKey)
+func (s *StoredAsk) loadAsk(key datastore.Key) error {
+	askb, err := s.ds.Get(key)
 	if err != nil {
 		return xerrors.Errorf("failed to load most recent ask from disk: %w", err)
 	}
@@ -145,6 +247,23 @@ func (s *StoredAsk) loadAsk() error {
 	return nil
 }
 
+// migrateLegacyAsk loads an ask of this miner actor saved under the legacy ask key, and
+// saves it again under the key of this StoredAsk
+func (s *StoredAsk) migrateLegacyAsk() error {
+	if err := s.loadAsk(legacyAskKey); err != nil {
+		return err
+	}
+	if miner := s.ask.Ask.Miner; miner != s.actor {
+		s.ask = nil
+		return xerrors.Errorf("legacy ask is for miner actor %s: %w", miner, datastore.ErrNotFound)
+	}
+
+	if err := s.saveAsk(s.ask); err != nil {
+		return xerrors.Errorf("saving legacy ask under %s: %w", s.dsKey, err)
+	}
+	return s.ds.Delete(legacyAskKey)
+}
+
 func (s *StoredAsk) saveAsk(a *storagemarket.SignedStorageAsk) error {
 	b, err := cborutil.Dump(a)
 	if err != nil {
@@ -158,3 +277,56 @@ func (s *StoredAsk) saveAsk(a *storagemarket.SignedStorageAsk) error {
 	s.ask = a
 	return nil
 }
+
+func (s *StoredAsk) tryLoadTiers() error {
+	s.askLk.Lock()
+	defer s.askLk.Unlock()
+
+	tiersb, err := s.ds.Get(s.tiersKey)
+	if err != nil {
+		if xerrors.Is(err, datastore.ErrNotFound) {
+			return nil
+		}
+		return xerrors.Errorf("failed to load ask tiers from disk: %w", err)
+	}
+
+	br := cbg.GetPeeker(bytes.NewReader(tiersb))
+	maj, extra, err := cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return xerrors.New("expected cbor array of ask tiers")
+	}
+
+	tiers := make([]*storagemarket.SignedStorageAsk, 0, extra)
+	for i := 0; i < int(extra); i++ {
+		var tier storagemarket.SignedStorageAsk
+		if err := tier.UnmarshalCBOR(br); err != nil {
+			return err
+		}
+		tiers = append(tiers, &tier)
+	}
+
+	s.tiers = tiers
+	return nil
+}
+
+func (s *StoredAsk) saveTiers(tiers []*storagemarket.SignedStorageAsk) error {
+	buf := new(bytes.Buffer)
+	if _, err := buf.Write(cbg.CborEncodeMajorType(cbg.MajArray, uint64(len(tiers)))); err != nil {
+		return err
+	}
+	for _, tier := range tiers {
+		if err := tier.MarshalCBOR(buf); err != nil {
+			return err
+		}
+	}
+
+	if err := s.ds.Put(s.tiersKey, buf.Bytes()); err != nil {
+		return err
+	}
+
+	s.tiers = tiers
+	return nil
+}
@@ -1,6 +1,7 @@
 package storedask_test
 
 import (
+	"context"
 	"errors"
 	"testing"
 
@@ -54,6 +55,56 @@ func TestStoredAsk(t *testing.T) {
 		require.Equal(t, ask.Ask.Price, testPrice)
 		require.Equal(t, ask.Ask.Expiry-ask.Ask.Timestamp, testDuration)
 	})
+	t.Run("moving an ask saved under the legacy key", func(t *testing.T) {
+		legacyDs := dss.MutexWrap(datastore.NewMapDatastore())
+		legacyAsk, err := storedask.NewStoredAsk(legacyDs, datastore.Key{}, spn, actor)
+		require.NoError(t, err)
+		require.NoError(t, legacyAsk.AddAsk(testPrice, testDuration))
+
+		otherAddr, err := address.NewActorAddress([]byte("other"))
+		require.NoError(t, err)
+		otherAsk, err := storedask.NewStoredAsk(legacyDs, datastore.NewKey("other-ask"), spn, otherAddr)
+		require.NoError(t, err)
+		require.NotEqual(t, testPrice, otherAsk.GetAsk(otherAddr).Ask.Price)
+
+		movedAsk, err := storedask.NewStoredAsk(legacyDs, datastore.NewKey("latest-ask"), spn, actor)
+		require.NoError(t, err)
+		require.Equal(t, legacyAsk.GetAsk(actor), movedAsk.GetAsk(actor))
+		has, err := legacyDs.Has(datastore.NewKey("latest-ask"))
+		require.NoError(t, err)
+		require.True(t, has)
+		has, err = legacyDs.Has(datastore.Key{})
+		require.NoError(t, err)
+		require.False(t, has)
+	})
+	t.Run("adding ask tiers", func(t *testing.T) {
+		tierPrice := abi.NewTokenAmount(500000000)
+		err := storedAsk.AddAskTier(tierPrice, testDuration, storagemarket.MinDuration(100000))
+		require.NoError(t, err)
+		err = storedAsk.AddAskTier(tierPrice, testDuration, storagemarket.MinPieceSize(1<<10), storagemarket.MaxPieceSize(1<<20))
+		require.NoError(t, err)
+
+		asks := storedAsk.GetAsks(actor)
+		require.Len(t, asks, 3)
+		require.Equal(t, storedAsk.GetAsk(actor), asks[0])
+		require.Equal(t, abi.ChainEpoch(100000), asks[1].Ask.MinDuration)
+		require.Equal(t, uint64(0), asks[1].Ask.SeqNo)
+		require.Equal(t, abi.PaddedPieceSize(1<<10), asks[2].Ask.MinPieceSize)
+
+		// a tier with the same ranges replaces the existing one
+		newTierPrice := abi.NewTokenAmount(400000000)
+		err = storedAsk.AddAskTier(newTierPrice, testDuration, storagemarket.MinDuration(100000))
+		require.NoError(t, err)
+		asks = storedAsk.GetAsks(actor)
+		require.Len(t, asks, 3)
+		require.Equal(t, newTierPrice, asks[2].Ask.Price)
+		require.Equal(t, uint64(1), asks[2].Ask.SeqNo)
+	})
+	t.Run("reloading ask tiers from disk", func(t *testing.T) {
+		storedAsk2, err := storedask.NewStoredAsk(ds, datastore.NewKey("latest-ask"), spn, actor)
+		require.NoError(t, err)
+		require.Equal(t, storedAsk.GetAsks(actor), storedAsk2.GetAsks(actor))
+	})
 	t.Run("node errors", func(t *testing.T) {
 		spnStateIDErr := &testnodes.FakeProviderNode{
 			FakeCommonNode: testnodes.FakeCommonNode{
@@ -92,3 +143,66 @@ func TestStoredAsk(t *testing.T) {
 		require.Error(t, err)
 	})
 }
+
+func TestRenewExpiringAsks(t *testing.T) {
+	ctx := context.Background()
+	ds := dss.MutexWrap(datastore.NewMapDatastore())
+	spn := &testnodes.FakeProviderNode{
+		FakeCommonNode: testnodes.FakeCommonNode{
+			SMState: testnodes.NewStorageMarketState(),
+		},
+	}
+	actor := address.TestAddress2
+	storedAsk, err := storedask.NewStoredAsk(ds, datastore.NewKey("latest-ask"), spn, actor, storedask.RenewalBuffer(10))
+	require.NoError(t, err)
+
+	testPrice := abi.NewTokenAmount(1000000000)
+	testDuration := abi.ChainEpoch(200)
+	require.NoError(t, storedAsk.AddAsk(testPrice, testDuration))
+	require.NoError(t, storedAsk.AddAskTier(testPrice, testDuration*2, storagemarket.MinDuration(1000)))
+
+	renewals := make(chan storagemarket.SignedStorageAsk, 2)
+	unsub := storedAsk.SubscribeToRenewals(func(ask storagemarket.SignedStorageAsk) {
+		renewals <- ask
+	})
+	defer unsub()
+
+	t.Run("does not renew asks outside the renewal buffer", func(t *testing.T) {
+		spn.SMState.Epoch = 189
+		require.NoError(t, storedAsk.RenewExpiringAsks(ctx))
+		require.Len(t, renewals, 0)
+		require.Equal(t, uint64(1), storedAsk.GetAsk(actor).Ask.SeqNo)
+	})
+
+	t.Run("renews asks within the renewal buffer", func(t *testing.T) {
+		spn.SMState.Epoch = 190
+		require.NoError(t, storedAsk.RenewExpiringAsks(ctx))
+		require.Len(t, renewals, 1)
+		renewed := <-renewals
+
+		ask := storedAsk.GetAsk(actor)
+		require.Equal(t, *ask, renewed)
+		require.Equal(t, uint64(2), ask.Ask.SeqNo)
+		require.Equal(t, abi.ChainEpoch(190), ask.Ask.Timestamp)
+		require.Equal(t, abi.ChainEpoch(190)+testDuration, ask.Ask.Expiry)
+		require.Equal(t, testPrice, ask.Ask.Price)
+
+		tier := storedAsk.GetAsks(actor)[1]
+		require.Equal(t, uint64(0), tier.Ask.SeqNo)
+		require.Equal(t, abi.ChainEpoch(1000), tier.Ask.MinDuration)
+	})
+
+	t.Run("renews expiring ask tiers", func(t *testing.T) {
+		// both the renewed default ask and the tier are now within the buffer
+		spn.SMState.Epoch = 395
+		require.NoError(t, storedAsk.RenewExpiringAsks(ctx))
+		require.Len(t, renewals, 2)
+		<-renewals
+		<-renewals
+
+		tier := storedAsk.GetAsks(actor)[1]
+		require.Equal(t, uint64(1), tier.Ask.SeqNo)
+		require.Equal(t, abi.ChainEpoch(395)+testDuration*2, tier.Ask.Expiry)
+		require.Equal(t, abi.ChainEpoch(1000), tier.Ask.MinDuration)
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"io/ioutil"
 	"math/rand"
 	"path/filepath"
+	"reflect"
 	"testing"
 	"time"
 
@@ -25,6 +26,8 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/filecoin-project/go-fil-markets/filestore"
+	"github.com/filecoin-project/go-fil-markets/journal"
+	"github.com/filecoin-project/go-fil-markets/metrics"
 	"github.com/filecoin-project/go-fil-markets/pieceio"
 	"github.com/filecoin-project/go-fil-markets/pieceio/cario"
 	"github.com/filecoin-project/go-fil-markets/piecestore"
@@ -74,7 +77,8 @@ func TestMakeDeal(t *testing.T) {
 	err = h.Provider.AddAsk(big.NewInt(0), 50_000)
 	assert.NoError(t, err)
 
-	result := h.ProposeStorageDeal(t, &storagemarket.DataRef{TransferType: storagemarket.TTGraphsync, Root: h.PayloadCid})
+	labels := storagemarket.DealLabels{"dataset": "d1"}
+	result := h.ProposeStorageDeal(t, &storagemarket.DataRef{TransferType: storagemarket.TTGraphsync, Root: h.PayloadCid}, storagemarket.WithDealLabels(labels))
 	proposalCid := result.ProposalCid
 
 	time.Sleep(time.Millisecond * 200)
@@ -131,6 +135,48 @@ func TestMakeDeal(t *testing.T) {
 	assert.Equal(t, expProviderStates, providerstates)
 	assert.Equal(t, expClientStates, clientstates)
 
+	// every event is journaled, along with the state it moved the deal to
+	journaledStates := func(j *journal.Journal, market shared.Market, expStates []storagemarket.StorageDealStatus) func() bool {
+		return func() bool {
+			entries, err := j.Query(journal.Query{Market: market, Deal: proposalCid.String()})
+			require.NoError(t, err)
+			var states []string
+			for _, entry := range entries {
+				states = append(states, entry.State)
+			}
+			var exp []string
+			for _, state := range expStates {
+				exp = append(exp, storagemarket.DealStates[state])
+			}
+			return reflect.DeepEqual(exp, states)
+		}
+	}
+	require.Eventually(t, journaledStates(h.ProviderJournal, shared.StorageProvider, expProviderStates), time.Second, 10*time.Millisecond)
+	require.Eventually(t, journaledStates(h.ClientJournal, shared.StorageClient, expClientStates), time.Second, 10*time.Millisecond)
+
+	// and the arguments it was sent with
+	entries, err := h.ProviderJournal.Query(journal.Query{Market: shared.StorageProvider, Deal: proposalCid.String()})
+	require.NoError(t, err)
+	verified := false
+	for _, entry := range entries {
+		if entry.Event == storagemarket.ProviderEvents[storagemarket.ProviderEventVerifiedData] {
+			verified = true
+			require.Len(t, entry.Args, 2)
+		}
+	}
+	require.True(t, verified)
+
+	// the deal is counted in its current state, and the provider generated one piece commitment
+	require.Eventually(t, func() bool {
+		return h.ProviderMetrics.DealsInState(shared.StorageProvider, storagemarket.DealStates[storagemarket.StorageDealCompleted]) == 1 &&
+			h.ClientMetrics.DealsInState(shared.StorageClient, storagemarket.DealStates[storagemarket.StorageDealActive]) == 1
+	}, time.Second, 10*time.Millisecond)
+	require.Zero(t, h.ProviderMetrics.DealsInState(shared.StorageProvider, storagemarket.DealStates[storagemarket.StorageDealValidating]))
+	require.Equal(t, int64(1), h.ProviderMetrics.Events(shared.StorageProvider, storagemarket.ProviderEvents[storagemarket.ProviderEventOpen]))
+	require.Len(t, h.ProviderMetrics.TimesInState(shared.StorageProvider, storagemarket.DealStates[storagemarket.StorageDealValidating]), 1)
+	require.Len(t, h.ProviderMetrics.CommPDurations(shared.StorageProvider), 1)
+	require.Len(t, h.ClientMetrics.CommPDurations(shared.StorageClient), 1)
+
 	// check a couple of things to make sure we're getting the whole deal
 	assert.Equal(t, h.TestData.Host1.ID(), providerSeenDeal.Client)
 	assert.Empty(t, providerSeenDeal.Message)
@@ -140,6 +186,7 @@ func TestMakeDeal(t *testing.T) {
 	cd, err := h.Client.GetLocalDeal(ctx, proposalCid)
 	assert.NoError(t, err)
 	shared_testutil.AssertDealState(t, storagemarket.StorageDealActive, cd.State)
+	assert.Equal(t, labels, cd.Labels)
 
 	providerDeals, err := h.Provider.ListLocalDeals()
 	assert.NoError(t, err)
@@ -147,6 +194,29 @@ func TestMakeDeal(t *testing.T) {
 	pd := providerDeals[0]
 	assert.Equal(t, pd.ProposalCid, proposalCid)
 	shared_testutil.AssertDealState(t, storagemarket.StorageDealCompleted, pd.State)
+	assert.Equal(t, labels, pd.Labels)
+
+	// local deals can be listed by their labels
+	providerDeals, err = h.Provider.ListLocalDeals(storagemarket.HasLabels(labels))
+	assert.NoError(t, err)
+	assert.Len(t, providerDeals, 1)
+	providerDeals, err = h.Provider.ListLocalDeals(storagemarket.HasLabels(storagemarket.DealLabels{"dataset": "d2"}))
+	assert.NoError(t, err)
+	assert.Empty(t, providerDeals)
+	clientDeals, err := h.Client.ListLocalDeals(ctx, storagemarket.HasLabelKey("dataset"))
+	assert.NoError(t, err)
+	assert.Len(t, clientDeals, 1)
+	clientDeals, err = h.Client.ListLocalDeals(ctx, storagemarket.HasLabelKey("owner"))
+	assert.NoError(t, err)
+	assert.Empty(t, clientDeals)
+
+	// the client can still query the provider once the deal stream is closed
+	dealState, err := h.Client.GetProviderDealState(ctx, proposalCid)
+	assert.NoError(t, err)
+	shared_testutil.AssertDealState(t, storagemarket.StorageDealCompleted, dealState.State)
+	assert.Equal(t, proposalCid, dealState.Proposal)
+	assert.Equal(t, pd.PublishCid, dealState.PublishCid)
+	assert.Equal(t, pd.DealID, dealState.DealID)
 }
 
 func TestMakeDealOffline(t *testing.T) {
@@ -204,6 +274,92 @@ func TestMakeDealOffline(t *testing.T) {
 	shared_testutil.AssertDealState(t, storagemarket.StorageDealCompleted, pd.State)
 }
 
+func TestCancelDealOffline(t *testing.T) {
+	ctx := context.Background()
+	h := newHarness(t, ctx)
+	h.Client.Run(ctx)
+
+	carBuf := new(bytes.Buffer)
+	err := cario.NewCarIO().WriteCar(ctx, h.TestData.Bs1, h.PayloadCid, shared.AllSelector(), carBuf)
+	require.NoError(t, err)
+
+	commP, size, err := pieceio.GeneratePieceCommitment(abi.RegisteredProof_StackedDRG2KiBPoSt, carBuf, uint64(carBuf.Len()))
+	assert.NoError(t, err)
+
+	dataRef := &storagemarket.DataRef{
+		TransferType: storagemarket.TTManual,
+		Root:         h.PayloadCid,
+		PieceCid:     &commP,
+		PieceSize:    size,
+	}
+
+	result := h.ProposeStorageDeal(t, dataRef)
+	proposalCid := result.ProposalCid
+
+	time.Sleep(time.Millisecond * 100)
+
+	providerDeals, err := h.Provider.ListLocalDeals()
+	assert.NoError(t, err)
+	shared_testutil.AssertDealState(t, storagemarket.StorageDealWaitingForData, providerDeals[0].State)
+
+	err = h.Provider.CancelDeal(ctx, proposalCid, "data was never sent")
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 100)
+
+	providerDeals, err = h.Provider.ListLocalDeals()
+	assert.NoError(t, err)
+
+	pd := providerDeals[0]
+	shared_testutil.AssertDealState(t, storagemarket.StorageDealCancelled, pd.State)
+	assert.Equal(t, "deal cancelled by provider: data was never sent", pd.Message)
+
+	// a cancelled deal cannot be cancelled again
+	err = h.Provider.CancelDeal(ctx, proposalCid, "data was never sent")
+	require.Error(t, err)
+}
+
+func TestClientCancelDeal(t *testing.T) {
+	ctx := context.Background()
+	h := newHarness(t, ctx)
+	h.Client.Run(ctx)
+
+	carBuf := new(bytes.Buffer)
+	err := cario.NewCarIO().WriteCar(ctx, h.TestData.Bs1, h.PayloadCid, shared.AllSelector(), carBuf)
+	require.NoError(t, err)
+
+	commP, size, err := pieceio.GeneratePieceCommitment(abi.RegisteredProof_StackedDRG2KiBPoSt, carBuf, uint64(carBuf.Len()))
+	assert.NoError(t, err)
+
+	dataRef := &storagemarket.DataRef{
+		TransferType: storagemarket.TTManual,
+		Root:         h.PayloadCid,
+		PieceCid:     &commP,
+		PieceSize:    size,
+	}
+
+	result := h.ProposeStorageDeal(t, dataRef)
+	proposalCid := result.ProposalCid
+
+	time.Sleep(time.Millisecond * 100)
+
+	err = h.Client.CancelDeal(ctx, proposalCid, "found a cheaper provider")
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 100)
+
+	cd, err := h.Client.GetLocalDeal(ctx, proposalCid)
+	assert.NoError(t, err)
+	shared_testutil.AssertDealState(t, storagemarket.StorageDealCancelled, cd.State)
+
+	providerDeals, err := h.Provider.ListLocalDeals()
+	assert.NoError(t, err)
+
+	pd := providerDeals[0]
+	shared_testutil.AssertDealState(t, storagemarket.StorageDealCancelled, pd.State)
+	assert.Equal(t, "deal cancelled by client: found a cheaper provider", pd.Message)
+}
+
 func TestMakeDealNonBlocking(t *testing.T) {
 	ctx := context.Background()
 	h := newHarness(t, ctx)
@@ -245,9 +401,34 @@ type harness struct {
 	ProviderNode *testnodes.FakeProviderNode
 	ProviderInfo storagemarket.StorageProviderInfo
 	TestData     *shared_testutil.Libp2pTestData
+
+	ClientJournal   *journal.Journal
+	ProviderJournal *journal.Journal
+	ClientMetrics   *metrics.MemoryRecorder
+	ProviderMetrics *metrics.MemoryRecorder
+}
+
+func TestRenewExpiredAsk(t *testing.T) {
+	ctx := context.Background()
+	h := newHarness(t, ctx, storedask.RenewalPollInterval(10*time.Millisecond))
+
+	ask, err := h.Client.GetAsk(ctx, h.ProviderInfo)
+	require.NoError(t, err)
+
+	// the provider stops serving the ask once it expires, until it is renewed
+	h.ProviderNode.SMState.SetEpoch(ask.Ask.Expiry + 1)
+	require.Eventually(t, func() bool {
+		renewed, err := h.Client.GetAsk(ctx, h.ProviderInfo)
+		if err != nil {
+			return false
+		}
+		return renewed.Ask.SeqNo > ask.Ask.SeqNo && renewed.Ask.Expiry > ask.Ask.Expiry+1
+	}, 5*time.Second, 50*time.Millisecond)
+
+	require.NoError(t, h.Provider.Stop())
 }
 
-func newHarness(t *testing.T, ctx context.Context) *harness {
+func newHarness(t *testing.T, ctx context.Context, askOptions ...storedask.StoredAskOption) *harness {
 	epoch := abi.ChainEpoch(100)
 	td := shared_testutil.NewLibp2pTestData(ctx, t)
 	fpath := filepath.Join("storagemarket", "fixtures", "payload.txt")
@@ -284,6 +465,8 @@ func newHarness(t *testing.T, ctx context.Context) *harness {
 	dt1 := graphsync.NewGraphSyncDataTransfer(td.Host1, td.GraphSync1, td.DTStoredCounter1)
 	require.NoError(t, dt1.RegisterVoucherType(&requestvalidation.StorageDataTransferVoucher{}, &fakeDTValidator{}))
 
+	clientJournal := journal.NewJournal(datastore.NewMapDatastore())
+	clientMetrics := metrics.NewMemoryRecorder()
 	client, err := storageimpl.NewClient(
 		network.NewFromLibp2pHost(td.Host1),
 		td.Bs1,
@@ -291,14 +474,18 @@ func newHarness(t *testing.T, ctx context.Context) *harness {
 		discovery.NewLocal(td.Ds1),
 		td.Ds1,
 		&clientNode,
+		storageimpl.ClientDealJournal(clientJournal),
+		storageimpl.ClientMetricsRecorder(clientMetrics),
 	)
 	require.NoError(t, err)
 
 	dt2 := graphsync.NewGraphSyncDataTransfer(td.Host2, td.GraphSync2, td.DTStoredCounter2)
 	require.NoError(t, dt2.RegisterVoucherType(&requestvalidation.StorageDataTransferVoucher{}, &fakeDTValidator{}))
 
-	storedAsk, err := storedask.NewStoredAsk(td.Ds2, datastore.NewKey("latest-ask"), providerNode, providerAddr)
+	storedAsk, err := storedask.NewStoredAsk(td.Ds2, datastore.NewKey("latest-ask"), providerNode, providerAddr, askOptions...)
 	assert.NoError(t, err)
+	providerJournal := journal.NewJournal(datastore.NewMapDatastore())
+	providerMetrics := metrics.NewMemoryRecorder()
 	provider, err := storageimpl.NewProvider(
 		network.NewFromLibp2pHost(td.Host2),
 		td.Ds2,
@@ -310,6 +497,8 @@ func newHarness(t *testing.T, ctx context.Context) *harness {
 		providerAddr,
 		abi.RegisteredProof_StackedDRG2KiBPoSt,
 		storedAsk,
+		storageimpl.DealJournal(providerJournal),
+		storageimpl.MetricsRecorder(providerMetrics),
 	)
 	assert.NoError(t, err)
 
@@ -340,10 +529,15 @@ func newHarness(t *testing.T, ctx context.Context) *harness {
 		ProviderNode: providerNode,
 		ProviderInfo: providerInfo,
 		TestData:     td,
+
+		ClientJournal:   clientJournal,
+		ProviderJournal: providerJournal,
+		ClientMetrics:   clientMetrics,
+		ProviderMetrics: providerMetrics,
 	}
 }
 
-func (h *harness) ProposeStorageDeal(t *testing.T, dataRef *storagemarket.DataRef) *storagemarket.ProposeStorageDealResult {
+func (h *harness) ProposeStorageDeal(t *testing.T, dataRef *storagemarket.DataRef, options ...storagemarket.ProposeStorageDealOption) *storagemarket.ProposeStorageDealResult {
 	result, err := h.Client.ProposeStorageDeal(
 		h.Ctx,
 		h.ProviderAddr,
@@ -354,6 +548,7 @@ func (h *harness) ProposeStorageDeal(t *testing.T, dataRef *storagemarket.DataRe
 		big.NewInt(1),
 		big.NewInt(0),
 		abi.RegisteredProof_StackedDRG2KiBPoSt,
+		options...,
 	)
 	assert.NoError(t, err)
 	return result
@@ -0,0 +1,96 @@
+package storagemarket
+
+import (
+	"golang.org/x/xerrors"
+)
+
+// MaxDealLabelsSize is the most bytes the keys and values of a deal's labels can add up to
+const MaxDealLabelsSize = 4096
+
+// DealLabels are arbitrary key value pairs a client attaches to a storage deal when it
+// proposes it, such as an identifier for the dataset the deal stores. They are sent to
+// the provider with the proposal and kept with the deal on both sides, but are not part
+// of the signed deal proposal or published on chain
+type DealLabels map[string]string
+
+// Validate checks that no label key is empty and that the labels are within MaxDealLabelsSize
+func (l DealLabels) Validate() error {
+	size := 0
+	for key, value := range l {
+		if key == "" {
+			return xerrors.New("deal label keys cannot be empty")
+		}
+		size += len(key) + len(value)
+	}
+	if size > MaxDealLabelsSize {
+		return xerrors.Errorf("deal labels are too large: %d bytes > %d bytes", size, MaxDealLabelsSize)
+	}
+	return nil
+}
+
+// LabelSelector picks deals by their labels
+type LabelSelector func(labels DealLabels) bool
+
+// HasLabels selects deals that have every one of the given labels, with the same values
+func HasLabels(labels DealLabels) LabelSelector {
+	return func(dealLabels DealLabels) bool {
+		for key, value := range labels {
+			if dealValue, ok := dealLabels[key]; !ok || dealValue != value {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// HasLabelKey selects deals that have a label with the given key, whatever its value
+func HasLabelKey(key string) LabelSelector {
+	return func(dealLabels DealLabels) bool {
+		_, ok := dealLabels[key]
+		return ok
+	}
+}
+
+// SelectLabels returns true if the labels are picked by every one of the selectors
+func SelectLabels(labels DealLabels, selectors []LabelSelector) bool {
+	for _, selector := range selectors {
+		if !selector(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// ProposeStorageDealParams are the optional parameters of a new storage deal
+type ProposeStorageDealParams struct {
+	Labels       DealLabels
+	VerifiedDeal bool
+	Ask          *StorageAsk
+}
+
+// ProposeStorageDealOption sets an optional parameter of a new storage deal
+type ProposeStorageDealOption func(*ProposeStorageDealParams)
+
+// WithDealLabels attaches labels to a new storage deal
+func WithDealLabels(labels DealLabels) ProposeStorageDealOption {
+	return func(params *ProposeStorageDealParams) {
+		params.Labels = labels
+	}
+}
+
+// WithVerifiedDeal asks the provider to make the new storage deal against the client's
+// datacap, at the provider's verified price. The request is part of the signed deal proposal
+func WithVerifiedDeal() ProposeStorageDealOption {
+	return func(params *ProposeStorageDealParams) {
+		params.VerifiedDeal = true
+	}
+}
+
+// WithStorageAsk sets the provider ask the provider collateral of a new storage deal is
+// worked out from, when no collateral is given. Without it the client queries the
+// provider for its asks
+func WithStorageAsk(ask StorageAsk) ProposeStorageDealOption {
+	return func(params *ProposeStorageDealParams) {
+		params.Ask = &ask
+	}
+}
@@ -0,0 +1,90 @@
+package storagemarket
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	cbg "github.com/whyrusleeping/cbor-gen"
+	xerrors "golang.org/x/xerrors"
+)
+
+// DealLabels are encoded by hand as a CBOR map, since cbor-gen only generates encodings
+// for structs. Keys are written in sorted order so the same labels always encode the same way
+
+func (t *DealLabels) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+
+	keys := make([]string, 0, len(*t))
+	for key := range *t {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajMap, uint64(len(keys)))); err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		value := (*t)[key]
+		if len(key) > cbg.MaxLength {
+			return xerrors.New("deal label key was too long")
+		}
+		if len(value) > cbg.MaxLength {
+			return xerrors.Errorf("value of deal label %s was too long", key)
+		}
+
+		if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len(key)))); err != nil {
+			return err
+		}
+		if _, err := w.Write([]byte(key)); err != nil {
+			return err
+		}
+		if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len(value)))); err != nil {
+			return err
+		}
+		if _, err := w.Write([]byte(value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *DealLabels) UnmarshalCBOR(r io.Reader) error {
+	br := cbg.GetPeeker(r)
+
+	maj, extra, err := cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajMap {
+		return fmt.Errorf("cbor input should be of type map")
+	}
+
+	if extra > MaxDealLabelsSize {
+		return fmt.Errorf("deal labels had too many entries: %d", extra)
+	}
+
+	if extra == 0 {
+		*t = nil
+		return nil
+	}
+
+	labels := make(DealLabels, extra)
+	for i := uint64(0); i < extra; i++ {
+		key, err := cbg.ReadString(br)
+		if err != nil {
+			return xerrors.Errorf("reading deal label key: %w", err)
+		}
+		value, err := cbg.ReadString(br)
+		if err != nil {
+			return xerrors.Errorf("reading value of deal label %s: %w", key, err)
+		}
+		labels[key] = value
+	}
+	*t = labels
+	return nil
+}
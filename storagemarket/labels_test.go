@@ -0,0 +1,92 @@
+package storagemarket_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+)
+
+func TestDealLabelsCBOR(t *testing.T) {
+	tests := map[string]struct {
+		labels   storagemarket.DealLabels
+		expected storagemarket.DealLabels
+	}{
+		"nil labels": {},
+		"empty labels": {
+			labels: storagemarket.DealLabels{},
+		},
+		"labels": {
+			labels:   storagemarket.DealLabels{"dataset": "d1", "owner": "team", "note": ""},
+			expected: storagemarket.DealLabels{"dataset": "d1", "owner": "team", "note": ""},
+		},
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			require.NoError(t, data.labels.MarshalCBOR(buf))
+
+			var out storagemarket.DealLabels
+			require.NoError(t, out.UnmarshalCBOR(buf))
+			require.Equal(t, data.expected, out)
+		})
+	}
+
+	t.Run("encoding does not depend on map order", func(t *testing.T) {
+		labels := storagemarket.DealLabels{"a": "1", "b": "2", "c": "3", "d": "4"}
+		first := new(bytes.Buffer)
+		require.NoError(t, labels.MarshalCBOR(first))
+		for i := 0; i < 10; i++ {
+			buf := new(bytes.Buffer)
+			require.NoError(t, labels.MarshalCBOR(buf))
+			require.Equal(t, first.Bytes(), buf.Bytes())
+		}
+	})
+}
+
+func TestDealLabelsValidate(t *testing.T) {
+	require.NoError(t, storagemarket.DealLabels(nil).Validate())
+	require.NoError(t, storagemarket.DealLabels{"dataset": strings.Repeat("a", storagemarket.MaxDealLabelsSize-7)}.Validate())
+	require.Error(t, storagemarket.DealLabels{"dataset": strings.Repeat("a", storagemarket.MaxDealLabelsSize-6)}.Validate())
+	require.Error(t, storagemarket.DealLabels{"": "d1"}.Validate())
+}
+
+func TestLabelSelectors(t *testing.T) {
+	labels := storagemarket.DealLabels{"dataset": "d1", "owner": "team"}
+	tests := map[string]struct {
+		selectors []storagemarket.LabelSelector
+		expected  bool
+	}{
+		"no selectors": {
+			expected: true,
+		},
+		"matching labels": {
+			selectors: []storagemarket.LabelSelector{storagemarket.HasLabels(storagemarket.DealLabels{"dataset": "d1"})},
+			expected:  true,
+		},
+		"different value": {
+			selectors: []storagemarket.LabelSelector{storagemarket.HasLabels(storagemarket.DealLabels{"dataset": "d2"})},
+			expected:  false,
+		},
+		"one label missing": {
+			selectors: []storagemarket.LabelSelector{storagemarket.HasLabels(storagemarket.DealLabels{"dataset": "d1", "region": "eu"})},
+			expected:  false,
+		},
+		"matching key": {
+			selectors: []storagemarket.LabelSelector{storagemarket.HasLabelKey("owner")},
+			expected:  true,
+		},
+		"all selectors must match": {
+			selectors: []storagemarket.LabelSelector{storagemarket.HasLabelKey("owner"), storagemarket.HasLabelKey("region")},
+			expected:  false,
+		},
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, data.expected, storagemarket.SelectLabels(labels, data.selectors))
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package network
+
+import (
+	"io"
+
+	cborutil "github.com/filecoin-project/go-cbor-util"
+	"github.com/libp2p/go-libp2p-core/protocol"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-fil-markets/shared"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+)
+
+// DealMessageTranslator converts storage deal messages to and from the wire format of
+// one version of the deal protocol. Fields a version does not know about are dropped
+// when writing to it, and left unset when reading from it. A response that changes in
+// translation is signed again with the resigning function
+type DealMessageTranslator interface {
+	ReadDealProposal(r io.Reader) (Proposal, error)
+	WriteDealProposal(w io.Writer, proposal Proposal) error
+	ReadDealResponse(r io.Reader) (SignedResponse, error)
+	WriteDealResponse(w io.Writer, response SignedResponse, resign ResigningFunc) error
+}
+
+// DealProtocol is a version of the storage deal protocol, along with the translator
+// for its messages
+type DealProtocol struct {
+	ID         protocol.ID
+	Translator DealMessageTranslator
+}
+
+// DefaultDealProtocols are the versions of the storage deal protocol the network
+// supports unless told otherwise, from most to least preferred
+var DefaultDealProtocols = []DealProtocol{
+	{ID: storagemarket.DealProtocolID110, Translator: CurrentDealTranslator{}},
+	{ID: storagemarket.DealProtocolID101, Translator: DealTranslator101{}},
+}
+
+// CurrentDealTranslator reads and writes deal messages in their current encoding
+type CurrentDealTranslator struct{}
+
+var _ DealMessageTranslator = CurrentDealTranslator{}
+
+// proposalLengths is the field count of proposals from before cancellations
+var proposalLengths = shared.TupleLengths{3}
+
+// ReadDealProposal reads a proposal in the current encoding
+func (CurrentDealTranslator) ReadDealProposal(r io.Reader) (Proposal, error) {
+	var proposal Proposal
+	if err := proposalLengths.Unmarshal(r, &proposal); err != nil {
+		return ProposalUndefined, err
+	}
+	return proposal, nil
+}
+
+// WriteDealProposal writes a proposal in the current encoding. Proposals that do not
+// cancel a deal are written without the cancellation field
+func (CurrentDealTranslator) WriteDealProposal(w io.Writer, proposal Proposal) error {
+	return proposalLengths.Marshal(w, &proposal)
+}
+
+// ReadDealResponse reads a response in the current encoding
+func (CurrentDealTranslator) ReadDealResponse(r io.Reader) (SignedResponse, error) {
+	var response SignedResponse
+	if err := response.UnmarshalCBOR(r); err != nil {
+		return SignedResponseUndefined, err
+	}
+	return response, nil
+}
+
+// WriteDealResponse writes a response in the current encoding
+func (CurrentDealTranslator) WriteDealResponse(w io.Writer, response SignedResponse, resign ResigningFunc) error {
+	return cborutil.WriteCborRPC(w, &response)
+}
+
+// DealTranslator101 reads and writes deal messages in the encoding of version 1.0.1 of
+// the deal protocol, whose proposals only hold the signed deal proposal and the piece,
+// and whose responses have no rejection reason. Deals cannot be cancelled over 1.0.1
+type DealTranslator101 struct{}
+
+var _ DealMessageTranslator = DealTranslator101{}
+
+// proposal101Lengths is the field count of 1.0.1 proposals
+var proposal101Lengths = shared.TupleLengths{2}
+
+// ReadDealProposal reads a 1.0.1 proposal, which has no labels
+func (DealTranslator101) ReadDealProposal(r io.Reader) (Proposal, error) {
+	var proposal Proposal
+	if err := proposal101Lengths.Unmarshal(r, &proposal); err != nil {
+		return ProposalUndefined, err
+	}
+	return proposal, nil
+}
+
+// WriteDealProposal writes a proposal as a 1.0.1 proposal, dropping its labels
+func (DealTranslator101) WriteDealProposal(w io.Writer, proposal Proposal) error {
+	if proposal.Cancel != nil {
+		return xerrors.New("deal protocol 1.0.1 does not support cancelling deals")
+	}
+	proposal.Labels = nil
+	return proposal101Lengths.Marshal(w, &proposal)
+}
+
+// ReadDealResponse reads a 1.0.1 response. Responses without a rejection reason are
+// encoded the same way they were in 1.0.1
+func (DealTranslator101) ReadDealResponse(r io.Reader) (SignedResponse, error) {
+	return CurrentDealTranslator{}.ReadDealResponse(r)
+}
+
+// WriteDealResponse writes a response as a 1.0.1 response, dropping its rejection reason
+// and signing it again if it had one
+func (DealTranslator101) WriteDealResponse(w io.Writer, response SignedResponse, resign ResigningFunc) error {
+	if response.Response.Reason != storagemarket.RejectionNone {
+		response.Response.Reason = storagemarket.RejectionNone
+		if resign == nil {
+			return xerrors.New("cannot sign 1.0.1 deal response again without a resigning function")
+		}
+		sig, err := resign(&response.Response)
+		if err != nil {
+			return xerrors.Errorf("signing 1.0.1 deal response: %w", err)
+		}
+		response.Signature = sig
+	}
+	return CurrentDealTranslator{}.WriteDealResponse(w, response, resign)
+}
@@ -3,7 +3,6 @@ package network
 import (
 	"bufio"
 
-	cborutil "github.com/filecoin-project/go-cbor-util"
 	"github.com/libp2p/go-libp2p-core/host"
 	"github.com/libp2p/go-libp2p-core/mux"
 	"github.com/libp2p/go-libp2p-core/peer"
@@ -13,18 +12,18 @@ import (
 const TagPriority = 100
 
 type dealStream struct {
-	p        peer.ID
-	host     host.Host
-	rw       mux.MuxedStream
-	buffered *bufio.Reader
+	p          peer.ID
+	host       host.Host
+	rw         mux.MuxedStream
+	buffered   *bufio.Reader
+	translator DealMessageTranslator
 }
 
 var _ StorageDealStream = (*dealStream)(nil)
 
 func (d *dealStream) ReadDealProposal() (Proposal, error) {
-	var ds Proposal
-
-	if err := ds.UnmarshalCBOR(d.buffered); err != nil {
+	ds, err := d.translator.ReadDealProposal(d.buffered)
+	if err != nil {
 		log.Warn(err)
 		return ProposalUndefined, err
 	}
@@ -32,20 +31,15 @@ func (d *dealStream) ReadDealProposal() (Proposal, error) {
 }
 
 func (d *dealStream) WriteDealProposal(dp Proposal) error {
-	return cborutil.WriteCborRPC(d.rw, &dp)
+	return d.translator.WriteDealProposal(d.rw, dp)
 }
 
 func (d *dealStream) ReadDealResponse() (SignedResponse, error) {
-	var dr SignedResponse
-
-	if err := dr.UnmarshalCBOR(d.buffered); err != nil {
-		return SignedResponseUndefined, err
-	}
-	return dr, nil
+	return d.translator.ReadDealResponse(d.buffered)
 }
 
-func (d *dealStream) WriteDealResponse(dr SignedResponse) error {
-	return cborutil.WriteCborRPC(d.rw, &dr)
+func (d *dealStream) WriteDealResponse(dr SignedResponse, resign ResigningFunc) error {
+	return d.translator.WriteDealResponse(d.rw, dr, resign)
 }
 
 func (d *dealStream) Close() error {
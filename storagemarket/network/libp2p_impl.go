@@ -8,15 +8,32 @@ import (
 	"github.com/libp2p/go-libp2p-core/host"
 	"github.com/libp2p/go-libp2p-core/network"
 	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/protocol"
+	"golang.org/x/xerrors"
 
 	"github.com/filecoin-project/go-fil-markets/storagemarket"
 )
 
 var log = logging.Logger("storagemarket_network")
 
+// Option is an option for configuring the libp2p storage market network
+type Option func(impl *libp2pStorageMarketNetwork)
+
+// SupportedDealProtocols sets the versions of the storage deal protocol the network
+// supports, from most to least preferred
+func SupportedDealProtocols(protocols ...DealProtocol) Option {
+	return func(impl *libp2pStorageMarketNetwork) {
+		impl.dealProtocols = protocols
+	}
+}
+
 // NewFromLibp2pHost builds a storage market network on top of libp2p
-func NewFromLibp2pHost(h host.Host) StorageMarketNetwork {
-	return &libp2pStorageMarketNetwork{host: h}
+func NewFromLibp2pHost(h host.Host, options ...Option) StorageMarketNetwork {
+	impl := &libp2pStorageMarketNetwork{host: h, dealProtocols: DefaultDealProtocols}
+	for _, option := range options {
+		option(impl)
+	}
+	return impl
 }
 
 // libp2pStorageMarketNetwork transforms the libp2p host interface, which sends and receives
@@ -25,6 +42,8 @@ type libp2pStorageMarketNetwork struct {
 	host host.Host
 	// inbound messages from the network are forwarded to the receiver
 	receiver StorageReceiver
+	// dealProtocols are the supported versions of the deal protocol, most preferred first
+	dealProtocols []DealProtocol
 }
 
 func (impl *libp2pStorageMarketNetwork) NewAskStream(id peer.ID) (StorageAskStream, error) {
@@ -38,25 +57,51 @@ func (impl *libp2pStorageMarketNetwork) NewAskStream(id peer.ID) (StorageAskStre
 }
 
 func (impl *libp2pStorageMarketNetwork) NewDealStream(id peer.ID) (StorageDealStream, error) {
-	s, err := impl.host.NewStream(context.Background(), id, storagemarket.DealProtocolID)
+	protocolIDs := make([]protocol.ID, 0, len(impl.dealProtocols))
+	for _, dealProtocol := range impl.dealProtocols {
+		protocolIDs = append(protocolIDs, dealProtocol.ID)
+	}
+	// libp2p picks the first of the protocols, in order, that the peer also supports
+	s, err := impl.host.NewStream(context.Background(), id, protocolIDs...)
 	if err != nil {
 		return nil, err
 	}
+	translator, ok := impl.dealTranslator(s.Protocol())
+	if !ok {
+		s.Reset() // nolint: errcheck,gosec
+		return nil, xerrors.Errorf("peer %s negotiated unsupported deal protocol %s", id, s.Protocol())
+	}
 	buffered := bufio.NewReaderSize(s, 16)
-	return &dealStream{p: id, rw: s, buffered: buffered, host: impl.host}, nil
+	return &dealStream{p: id, rw: s, buffered: buffered, host: impl.host, translator: translator}, nil
+}
+
+func (impl *libp2pStorageMarketNetwork) NewDealStatusStream(id peer.ID) (DealStatusStream, error) {
+	s, err := impl.host.NewStream(context.Background(), id, storagemarket.DealStatusProtocolID)
+	if err != nil {
+		log.Warn(err)
+		return nil, err
+	}
+	buffered := bufio.NewReaderSize(s, 16)
+	return &dealStatusStream{p: id, rw: s, buffered: buffered}, nil
 }
 
 func (impl *libp2pStorageMarketNetwork) SetDelegate(r StorageReceiver) error {
 	impl.receiver = r
-	impl.host.SetStreamHandler(storagemarket.DealProtocolID, impl.handleNewDealStream)
+	for _, dealProtocol := range impl.dealProtocols {
+		impl.host.SetStreamHandler(dealProtocol.ID, impl.handleNewDealStream(dealProtocol.Translator))
+	}
 	impl.host.SetStreamHandler(storagemarket.AskProtocolID, impl.handleNewAskStream)
+	impl.host.SetStreamHandler(storagemarket.DealStatusProtocolID, impl.handleNewDealStatusStream)
 	return nil
 }
 
 func (impl *libp2pStorageMarketNetwork) StopHandlingRequests() error {
 	impl.receiver = nil
-	impl.host.RemoveStreamHandler(storagemarket.DealProtocolID)
+	for _, dealProtocol := range impl.dealProtocols {
+		impl.host.RemoveStreamHandler(dealProtocol.ID)
+	}
 	impl.host.RemoveStreamHandler(storagemarket.AskProtocolID)
+	impl.host.RemoveStreamHandler(storagemarket.DealStatusProtocolID)
 	return nil
 }
 
@@ -72,7 +117,30 @@ func (impl *libp2pStorageMarketNetwork) handleNewAskStream(s network.Stream) {
 	impl.receiver.HandleAskStream(as)
 }
 
-func (impl *libp2pStorageMarketNetwork) handleNewDealStream(s network.Stream) {
+func (impl *libp2pStorageMarketNetwork) handleNewDealStream(translator DealMessageTranslator) network.StreamHandler {
+	return func(s network.Stream) {
+		if impl.receiver == nil {
+			log.Warn("no receiver set")
+			s.Reset() // nolint: errcheck,gosec
+			return
+		}
+		remotePID := s.Conn().RemotePeer()
+		buffered := bufio.NewReaderSize(s, 16)
+		ds := &dealStream{remotePID, impl.host, s, buffered, translator}
+		impl.receiver.HandleDealStream(ds)
+	}
+}
+
+func (impl *libp2pStorageMarketNetwork) dealTranslator(id protocol.ID) (DealMessageTranslator, bool) {
+	for _, dealProtocol := range impl.dealProtocols {
+		if dealProtocol.ID == id {
+			return dealProtocol.Translator, true
+		}
+	}
+	return nil, false
+}
+
+func (impl *libp2pStorageMarketNetwork) handleNewDealStatusStream(s network.Stream) {
 	if impl.receiver == nil {
 		log.Warn("no receiver set")
 		s.Reset() // nolint: errcheck,gosec
@@ -80,8 +148,8 @@ func (impl *libp2pStorageMarketNetwork) handleNewDealStream(s network.Stream) {
 	}
 	remotePID := s.Conn().RemotePeer()
 	buffered := bufio.NewReaderSize(s, 16)
-	ds := &dealStream{remotePID, impl.host, s, buffered}
-	impl.receiver.HandleDealStream(ds)
+	qs := &dealStatusStream{remotePID, s, buffered}
+	impl.receiver.HandleDealStatusStream(qs)
 }
 
 func (impl *libp2pStorageMarketNetwork) ID() peer.ID {
@@ -1,15 +1,19 @@
 package network_test
 
 import (
+	"bytes"
 	"context"
+	"io"
 	"testing"
 	"time"
 
+	"github.com/filecoin-project/specs-actors/actors/crypto"
 	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/filecoin-project/go-fil-markets/shared_testutil"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
 	"github.com/filecoin-project/go-fil-markets/storagemarket/network"
 )
 
@@ -17,6 +21,8 @@ type testReceiver struct {
 	t                 *testing.T
 	dealStreamHandler func(network.StorageDealStream)
 	askStreamHandler  func(network.StorageAskStream)
+
+	dealStatusStreamHandler func(network.DealStatusStream)
 }
 
 func (tr *testReceiver) HandleDealStream(s network.StorageDealStream) {
@@ -32,6 +38,13 @@ func (tr *testReceiver) HandleAskStream(s network.StorageAskStream) {
 	}
 }
 
+func (tr *testReceiver) HandleDealStatusStream(s network.DealStatusStream) {
+	defer s.Close()
+	if tr.dealStatusStreamHandler != nil {
+		tr.dealStatusStreamHandler(s)
+	}
+}
+
 func TestAskStreamSendReceiveAskRequest(t *testing.T) {
 	ctx := context.Background()
 	td := shared_testutil.NewLibp2pTestData(ctx, t)
@@ -77,8 +90,35 @@ func TestAskStreamSendReceiveAskResponse(t *testing.T) {
 	}}
 	require.NoError(t, toNetwork.SetDelegate(tr2))
 
-	assertAskResponseReceived(ctx, t, fromNetwork, toHost, achan)
+	assertAskResponseReceived(ctx, t, fromNetwork, toHost, achan, shared_testutil.MakeTestStorageAskResponse())
+
+}
+
+func TestAskStreamSendReceiveAskResponseWithTiers(t *testing.T) {
+	ctx := context.Background()
+	td := shared_testutil.NewLibp2pTestData(ctx, t)
+	fromNetwork := network.NewFromLibp2pHost(td.Host1)
+	toNetwork := network.NewFromLibp2pHost(td.Host2)
+	toHost := td.Host2.ID()
 
+	// host1 gets no-op receiver
+	tr := &testReceiver{t: t}
+	require.NoError(t, fromNetwork.SetDelegate(tr))
+
+	// host2 gets receiver
+	achan := make(chan network.AskResponse)
+	tr2 := &testReceiver{t: t, askStreamHandler: func(s network.StorageAskStream) {
+		a, err := s.ReadAskResponse()
+		require.NoError(t, err)
+		achan <- a
+	}}
+	require.NoError(t, toNetwork.SetDelegate(tr2))
+
+	ar := shared_testutil.MakeTestStorageAskResponse()
+	tier := shared_testutil.MakeTestSignedStorageAsk()
+	tier.Ask.MinDuration = 1000
+	ar.Asks = []*storagemarket.SignedStorageAsk{ar.Ask, tier}
+	assertAskResponseReceived(ctx, t, fromNetwork, toHost, achan, ar)
 }
 
 func TestAskStreamSendReceiveMultipleSuccessful(t *testing.T) {
@@ -187,7 +227,7 @@ func TestDealStreamSendReceiveMultipleSuccessful(t *testing.T) {
 		_, err := s.ReadDealProposal()
 		require.NoError(t, err)
 
-		require.NoError(t, s.WriteDealResponse(dr))
+		require.NoError(t, s.WriteDealResponse(dr, nil))
 		done <- true
 	}}
 	require.NoError(t, toNetwork.SetDelegate(tr2))
@@ -217,6 +257,247 @@ func TestDealStreamSendReceiveMultipleSuccessful(t *testing.T) {
 
 }
 
+// versionTranslator reads and writes messages in the current encoding, and records the
+// version of the deal protocol it was used for
+type versionTranslator struct {
+	network.CurrentDealTranslator
+	version string
+	used    chan string
+}
+
+func (vt versionTranslator) ReadDealProposal(r io.Reader) (network.Proposal, error) {
+	vt.used <- vt.version
+	return vt.CurrentDealTranslator.ReadDealProposal(r)
+}
+
+func TestDealStreamNegotiatesProtocolVersion(t *testing.T) {
+	used := make(chan string, 1)
+	v2 := network.DealProtocol{ID: "/fil/storage/mk/test/2", Translator: versionTranslator{version: "2", used: used}}
+	v1 := network.DealProtocol{ID: "/fil/storage/mk/test/1", Translator: versionTranslator{version: "1", used: used}}
+	tests := map[string]struct {
+		fromProtocols []network.DealProtocol
+		toProtocols   []network.DealProtocol
+		expected      string
+	}{
+		"both peers support the newest version": {
+			fromProtocols: []network.DealProtocol{v2, v1},
+			toProtocols:   []network.DealProtocol{v2, v1},
+			expected:      "2",
+		},
+		"sending peer only supports the older version": {
+			fromProtocols: []network.DealProtocol{v1},
+			toProtocols:   []network.DealProtocol{v2, v1},
+			expected:      "1",
+		},
+		"receiving peer only supports the older version": {
+			fromProtocols: []network.DealProtocol{v2, v1},
+			toProtocols:   []network.DealProtocol{v1},
+			expected:      "1",
+		},
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			bgCtx := context.Background()
+			td := shared_testutil.NewLibp2pTestData(bgCtx, t)
+			fromNetwork := network.NewFromLibp2pHost(td.Host1, network.SupportedDealProtocols(data.fromProtocols...))
+			toNetwork := network.NewFromLibp2pHost(td.Host2, network.SupportedDealProtocols(data.toProtocols...))
+
+			dr := shared_testutil.MakeTestStorageNetworkSignedResponse()
+			dchan := make(chan network.Proposal, 1)
+			tr2 := &testReceiver{t: t, dealStreamHandler: func(s network.StorageDealStream) {
+				readD, err := s.ReadDealProposal()
+				require.NoError(t, err)
+				dchan <- readD
+				require.NoError(t, s.WriteDealResponse(dr))
+			}}
+			require.NoError(t, toNetwork.SetDelegate(tr2))
+
+			ds, err := fromNetwork.NewDealStream(td.Host2.ID())
+			require.NoError(t, err)
+
+			dp := shared_testutil.MakeTestStorageNetworkProposal()
+			require.NoError(t, ds.WriteDealProposal(dp))
+
+			responseReceived, err := ds.ReadDealResponse()
+			require.NoError(t, err)
+			assert.Equal(t, dr, responseReceived)
+
+			ctx, cancel := context.WithTimeout(bgCtx, 10*time.Second)
+			defer cancel()
+			select {
+			case <-ctx.Done():
+				t.Fatal("deal proposal not received")
+			case dealReceived := <-dchan:
+				assert.Equal(t, dp, dealReceived)
+			}
+			assert.Equal(t, data.expected, <-used)
+		})
+	}
+}
+
+func TestDealStreamTranslatesProposals(t *testing.T) {
+	legacyOnly := network.SupportedDealProtocols(network.DealProtocol{
+		ID:         storagemarket.DealProtocolID101,
+		Translator: network.DealTranslator101{},
+	})
+	tests := map[string]struct {
+		fromOptions    []network.Option
+		toOptions      []network.Option
+		keepsNewFields bool
+	}{
+		"both peers support the newest version": {
+			keepsNewFields: true,
+		},
+		"sending peer only supports 1.0.1": {
+			fromOptions: []network.Option{legacyOnly},
+		},
+		"receiving peer only supports 1.0.1": {
+			toOptions: []network.Option{legacyOnly},
+		},
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			bgCtx := context.Background()
+			td := shared_testutil.NewLibp2pTestData(bgCtx, t)
+			fromNetwork := network.NewFromLibp2pHost(td.Host1, data.fromOptions...)
+			toNetwork := network.NewFromLibp2pHost(td.Host2, data.toOptions...)
+
+			dr := shared_testutil.MakeTestStorageNetworkSignedResponse()
+			dr.Response.Reason = storagemarket.RejectionPriceTooLow
+			resignature := &crypto.Signature{Type: crypto.SigTypeBLS, Data: []byte("resigned")}
+			resign := func(data interface{}) (*crypto.Signature, error) {
+				return resignature, nil
+			}
+			dchan := make(chan network.Proposal, 1)
+			tr2 := &testReceiver{t: t, dealStreamHandler: func(s network.StorageDealStream) {
+				readD, err := s.ReadDealProposal()
+				require.NoError(t, err)
+				dchan <- readD
+				require.NoError(t, s.WriteDealResponse(dr, resign))
+			}}
+			require.NoError(t, toNetwork.SetDelegate(tr2))
+
+			ds, err := fromNetwork.NewDealStream(td.Host2.ID())
+			require.NoError(t, err)
+
+			dp := shared_testutil.MakeTestStorageNetworkProposal()
+			dp.Labels = storagemarket.DealLabels{"dataset": "d1"}
+			require.NoError(t, ds.WriteDealProposal(dp))
+
+			expectedResponse := dr
+			if !data.keepsNewFields {
+				expectedResponse.Response.Reason = storagemarket.RejectionNone
+				expectedResponse.Signature = resignature
+			}
+			responseReceived, err := ds.ReadDealResponse()
+			require.NoError(t, err)
+			assert.Equal(t, expectedResponse, responseReceived)
+
+			ctx, cancel := context.WithTimeout(bgCtx, 10*time.Second)
+			defer cancel()
+			var dealReceived network.Proposal
+			select {
+			case <-ctx.Done():
+				t.Fatal("deal proposal not received")
+			case dealReceived = <-dchan:
+			}
+
+			expected := dp
+			if !data.keepsNewFields {
+				expected.Labels = nil
+			}
+			assert.Equal(t, expected, dealReceived)
+		})
+	}
+}
+
+func TestDealStatusStreamSendReceiveMultipleSuccessful(t *testing.T) {
+	// send query, read in handler, send response back, read response
+	ctxBg := context.Background()
+	td := shared_testutil.NewLibp2pTestData(ctxBg, t)
+	nw1 := network.NewFromLibp2pHost(td.Host1)
+	nw2 := network.NewFromLibp2pHost(td.Host2)
+	require.NoError(t, td.Host1.Connect(ctxBg, peer.AddrInfo{ID: td.Host2.ID()}))
+
+	dsq := shared_testutil.MakeTestDealStatusRequest()
+	dsr := shared_testutil.MakeTestDealStatusResponse()
+
+	// host2 gets a query and sends a response
+	done := make(chan network.DealStatusRequest, 1)
+	tr2 := &testReceiver{t: t, dealStatusStreamHandler: func(s network.DealStatusStream) {
+		q, err := s.ReadDealStatusRequest()
+		require.NoError(t, err)
+
+		require.NoError(t, s.WriteDealStatusResponse(dsr))
+		done <- q
+	}}
+	require.NoError(t, nw2.SetDelegate(tr2))
+
+	ctx, cancel := context.WithTimeout(ctxBg, 10*time.Second)
+	defer cancel()
+
+	qs, err := nw1.NewDealStatusStream(td.Host2.ID())
+	require.NoError(t, err)
+
+	require.NoError(t, qs.WriteDealStatusRequest(dsq))
+	resp, err := qs.ReadDealStatusResponse()
+	require.NoError(t, err)
+
+	select {
+	case <-ctx.Done():
+		t.Error("request not received")
+	case q := <-done:
+		assert.Equal(t, dsq, q)
+	}
+
+	assert.Equal(t, dsr, resp)
+}
+
+func TestDealStreamSendReceiveDealCancel(t *testing.T) {
+	ctxBg := context.Background()
+	td := shared_testutil.NewLibp2pTestData(ctxBg, t)
+	nw1 := network.NewFromLibp2pHost(td.Host1)
+	nw2 := network.NewFromLibp2pHost(td.Host2)
+	require.NoError(t, td.Host1.Connect(ctxBg, peer.AddrInfo{ID: td.Host2.ID()}))
+
+	sdc := shared_testutil.MakeTestSignedDealCancel()
+	dr := shared_testutil.MakeTestStorageNetworkSignedResponse()
+	dr.Response.State = storagemarket.StorageDealCancelled
+
+	// host2 gets a cancellation and acknowledges it
+	done := make(chan network.Proposal, 1)
+	tr2 := &testReceiver{t: t, dealStreamHandler: func(s network.StorageDealStream) {
+		p, err := s.ReadDealProposal()
+		require.NoError(t, err)
+		require.NoError(t, s.WriteDealResponse(dr, nil))
+		done <- p
+	}}
+	require.NoError(t, nw2.SetDelegate(tr2))
+
+	ctx, cancel := context.WithTimeout(ctxBg, 10*time.Second)
+	defer cancel()
+
+	ds, err := nw1.NewDealStream(td.Host2.ID())
+	require.NoError(t, err)
+	require.NoError(t, ds.WriteDealProposal(network.Proposal{Cancel: &sdc}))
+	resp, err := ds.ReadDealResponse()
+	require.NoError(t, err)
+	assert.Equal(t, dr, resp)
+
+	select {
+	case <-ctx.Done():
+		t.Error("cancellation not received")
+	case p := <-done:
+		assert.Equal(t, network.Proposal{Cancel: &sdc}, p)
+	}
+
+	t.Run("not supported by 1.0.1", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := network.DealTranslator101{}.WriteDealProposal(&buf, network.Proposal{Cancel: &sdc})
+		require.Error(t, err)
+	})
+}
+
 func TestLibp2pStorageMarketNetwork_StopHandlingRequests(t *testing.T) {
 	bgCtx := context.Background()
 	td := shared_testutil.NewLibp2pTestData(bgCtx, t)
@@ -274,7 +555,7 @@ func assertDealResponseReceived(parentCtx context.Context, t *testing.T, fromNet
 	require.NoError(t, err)
 
 	dr := shared_testutil.MakeTestStorageNetworkSignedResponse()
-	require.NoError(t, ds1.WriteDealResponse(dr))
+	require.NoError(t, ds1.WriteDealResponse(dr, nil))
 
 	var responseReceived network.SignedResponse
 	select {
@@ -312,7 +593,8 @@ func assertAskRequestReceived(inCtx context.Context, t *testing.T, fromNetwork n
 func assertAskResponseReceived(inCtx context.Context, t *testing.T,
 	fromNetwork network.StorageMarketNetwork,
 	toHost peer.ID,
-	achan chan network.AskResponse) {
+	achan chan network.AskResponse,
+	ar network.AskResponse) {
 	ctx, cancel := context.WithTimeout(inCtx, 10*time.Second)
 	defer cancel()
 
@@ -321,7 +603,6 @@ func assertAskResponseReceived(inCtx context.Context, t *testing.T,
 	require.NoError(t, err)
 
 	// send queryresponse to host2
-	ar := shared_testutil.MakeTestStorageAskResponse()
 	require.NoError(t, as1.WriteAskResponse(ar))
 
 	// read queryresponse
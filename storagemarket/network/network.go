@@ -1,9 +1,14 @@
 package network
 
 import (
+	"github.com/filecoin-project/specs-actors/actors/crypto"
 	"github.com/libp2p/go-libp2p-core/peer"
 )
 
+// ResigningFunc signs a message again, for when a response has to be translated to an
+// older version of the deal protocol that encodes it differently
+type ResigningFunc func(data interface{}) (*crypto.Signature, error)
+
 // StorageAskStream is a stream for reading/writing requests &
 // responses on the Storage Ask protocol
 type StorageAskStream interface {
@@ -20,24 +25,37 @@ type StorageDealStream interface {
 	ReadDealProposal() (Proposal, error)
 	WriteDealProposal(Proposal) error
 	ReadDealResponse() (SignedResponse, error)
-	WriteDealResponse(SignedResponse) error
+	WriteDealResponse(SignedResponse, ResigningFunc) error
 	RemotePeer() peer.ID
 	TagProtectedConnection(identifier string)
 	UntagProtectedConnection(identifier string)
 	Close() error
 }
 
+// DealStatusStream is a stream for reading and writing requests
+// and responses on the deal status protocol
+type DealStatusStream interface {
+	ReadDealStatusRequest() (DealStatusRequest, error)
+	WriteDealStatusRequest(DealStatusRequest) error
+	ReadDealStatusResponse() (DealStatusResponse, error)
+	WriteDealStatusResponse(DealStatusResponse) error
+	RemotePeer() peer.ID
+	Close() error
+}
+
 // StorageReceiver implements functions for receiving
 // incoming data on storage protocols
 type StorageReceiver interface {
 	HandleAskStream(StorageAskStream)
 	HandleDealStream(StorageDealStream)
+	HandleDealStatusStream(DealStatusStream)
 }
 
 // StorageMarketNetwork is a network abstraction for the storage market
 type StorageMarketNetwork interface {
 	NewAskStream(peer.ID) (StorageAskStream, error)
 	NewDealStream(peer.ID) (StorageDealStream, error)
+	NewDealStatusStream(peer.ID) (DealStatusStream, error)
 	SetDelegate(StorageReceiver) error
 	StopHandlingRequests() error
 	ID() peer.ID
@@ -1,15 +1,18 @@
 package network
 
 import (
+	"io"
+
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/specs-actors/actors/builtin/market"
 	"github.com/filecoin-project/specs-actors/actors/crypto"
 	"github.com/ipfs/go-cid"
 
+	"github.com/filecoin-project/go-fil-markets/shared"
 	"github.com/filecoin-project/go-fil-markets/storagemarket"
 )
 
-//go:generate cbor-gen-for AskRequest AskResponse Proposal Response SignedResponse
+//go:generate cbor-gen-for AskRequest Proposal SignedResponse DealStatusRequest DealStatusResponse DealCancel SignedDealCancel AskResponseTuple ResponseTuple
 
 // Proposal is the data sent over the network from client to provider when proposing
 // a deal
@@ -17,6 +20,13 @@ type Proposal struct {
 	DealProposal *market.ClientDealProposal
 
 	Piece *storagemarket.DataRef
+
+	// Labels are the client's labels for the deal
+	Labels storagemarket.DealLabels
+
+	// Cancel withdraws an earlier proposal instead of proposing a deal. The provider
+	// replies with a response in the StorageDealCancelled state if it cancelled the deal
+	Cancel *SignedDealCancel
 }
 
 var ProposalUndefined = Proposal{}
@@ -31,6 +41,26 @@ type Response struct {
 
 	// StorageDealProposalAccepted
 	PublishMessage *cid.Cid
+
+	// Reason is why the deal was rejected, if it was. Message holds the details
+	Reason storagemarket.RejectionReason
+}
+
+// responseLengths are the field counts of responses from before rejection reasons. Peers
+// that predate rejection reasons verify the response signature over that encoding
+var responseLengths = shared.TupleLengths{4}
+
+// ResponseTuple is the encoding of a Response with every field
+type ResponseTuple Response
+
+// MarshalCBOR encodes a response without a rejection reason in the original four fields
+func (t *Response) MarshalCBOR(w io.Writer) error {
+	return responseLengths.Marshal(w, (*ResponseTuple)(t))
+}
+
+// UnmarshalCBOR decodes a response with or without a rejection reason
+func (t *Response) UnmarshalCBOR(r io.Reader) error {
+	return responseLengths.Unmarshal(r, (*ResponseTuple)(t))
 }
 
 // SignedResponse is a response that is signed
@@ -53,6 +83,56 @@ var AskRequestUndefined = AskRequest{}
 // to an ask request
 type AskResponse struct {
 	Ask *storagemarket.SignedStorageAsk
+	// Asks holds every ask the miner offers, starting with the default ask. It is
+	// only set when the miner has ask tiers in addition to the default ask
+	Asks []*storagemarket.SignedStorageAsk
 }
 
 var AskResponseUndefined = AskResponse{}
+
+// askResponseLengths are the field counts of ask responses from before ask tiers
+var askResponseLengths = shared.TupleLengths{1}
+
+// AskResponseTuple is the encoding of an AskResponse with every field
+type AskResponseTuple AskResponse
+
+// MarshalCBOR encodes a response carrying only the default ask in the original single field
+func (t *AskResponse) MarshalCBOR(w io.Writer) error {
+	return askResponseLengths.Marshal(w, (*AskResponseTuple)(t))
+}
+
+// UnmarshalCBOR decodes an ask response with or without ask tiers
+func (t *AskResponse) UnmarshalCBOR(r io.Reader) error {
+	return askResponseLengths.Unmarshal(r, (*AskResponseTuple)(t))
+}
+
+// DealStatusRequest is sent to query deal state for a given proposal
+type DealStatusRequest struct {
+	Proposal cid.Cid
+}
+
+var DealStatusRequestUndefined = DealStatusRequest{}
+
+// DealStatusResponse is the state of a deal on the provider, signed by
+// the provider's worker address
+type DealStatusResponse struct {
+	DealState storagemarket.ProviderDealState
+
+	Signature *crypto.Signature
+}
+
+var DealStatusResponseUndefined = DealStatusResponse{}
+
+// DealCancel is sent by a client on the deal protocol to withdraw a deal proposal that
+// has not yet been published
+type DealCancel struct {
+	Proposal cid.Cid
+	Reason   string
+}
+
+// SignedDealCancel is a deal cancellation signed by the client address of the deal
+type SignedDealCancel struct {
+	DealCancel DealCancel
+
+	Signature *crypto.Signature
+}
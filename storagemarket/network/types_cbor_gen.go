@@ -58,23 +58,39 @@ func (t *AskRequest) UnmarshalCBOR(r io.Reader) error {
 	return nil
 }
 
-func (t *AskResponse) MarshalCBOR(w io.Writer) error {
+func (t *Proposal) MarshalCBOR(w io.Writer) error {
 	if t == nil {
 		_, err := w.Write(cbg.CborNull)
 		return err
 	}
-	if _, err := w.Write([]byte{129}); err != nil {
+	if _, err := w.Write([]byte{132}); err != nil {
 		return err
 	}
 
-	// t.Ask (storagemarket.SignedStorageAsk) (struct)
-	if err := t.Ask.MarshalCBOR(w); err != nil {
+	// t.DealProposal (market.ClientDealProposal) (struct)
+	if err := t.DealProposal.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.Piece (storagemarket.DataRef) (struct)
+	if err := t.Piece.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.Labels (storagemarket.DealLabels) (struct)
+	if err := t.Labels.MarshalCBOR(w); err != nil {
 		return err
 	}
+
+	// t.Cancel (SignedDealCancel) (struct)
+	if err := t.Cancel.MarshalCBOR(w); err != nil {
+		return err
+	}
+
 	return nil
 }
 
-func (t *AskResponse) UnmarshalCBOR(r io.Reader) error {
+func (t *Proposal) UnmarshalCBOR(r io.Reader) error {
 	br := cbg.GetPeeker(r)
 
 	maj, extra, err := cbg.CborReadHeader(br)
@@ -85,11 +101,11 @@ func (t *AskResponse) UnmarshalCBOR(r io.Reader) error {
 		return fmt.Errorf("cbor input should be of type array")
 	}
 
-	if extra != 1 {
+	if extra != 4 {
 		return fmt.Errorf("cbor input had wrong number of fields")
 	}
 
-	// t.Ask (storagemarket.SignedStorageAsk) (struct)
+	// t.DealProposal (market.ClientDealProposal) (struct)
 
 	{
 
@@ -103,9 +119,60 @@ func (t *AskResponse) UnmarshalCBOR(r io.Reader) error {
 				return err
 			}
 		} else {
-			t.Ask = new(storagemarket.SignedStorageAsk)
-			if err := t.Ask.UnmarshalCBOR(br); err != nil {
-				return xerrors.Errorf("unmarshaling t.Ask pointer: %w", err)
+			t.DealProposal = new(market.ClientDealProposal)
+			if err := t.DealProposal.UnmarshalCBOR(br); err != nil {
+				return xerrors.Errorf("unmarshaling t.DealProposal pointer: %w", err)
+			}
+		}
+
+	}
+	// t.Piece (storagemarket.DataRef) (struct)
+
+	{
+
+		pb, err := br.PeekByte()
+		if err != nil {
+			return err
+		}
+		if pb == cbg.CborNull[0] {
+			var nbuf [1]byte
+			if _, err := br.Read(nbuf[:]); err != nil {
+				return err
+			}
+		} else {
+			t.Piece = new(storagemarket.DataRef)
+			if err := t.Piece.UnmarshalCBOR(br); err != nil {
+				return xerrors.Errorf("unmarshaling t.Piece pointer: %w", err)
+			}
+		}
+
+	}
+	// t.Labels (storagemarket.DealLabels) (struct)
+
+	{
+
+		if err := t.Labels.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.Labels: %w", err)
+		}
+
+	}
+	// t.Cancel (SignedDealCancel) (struct)
+
+	{
+
+		pb, err := br.PeekByte()
+		if err != nil {
+			return err
+		}
+		if pb == cbg.CborNull[0] {
+			var nbuf [1]byte
+			if _, err := br.Read(nbuf[:]); err != nil {
+				return err
+			}
+		} else {
+			t.Cancel = new(SignedDealCancel)
+			if err := t.Cancel.UnmarshalCBOR(br); err != nil {
+				return xerrors.Errorf("unmarshaling t.Cancel pointer: %w", err)
 			}
 		}
 
@@ -113,7 +180,7 @@ func (t *AskResponse) UnmarshalCBOR(r io.Reader) error {
 	return nil
 }
 
-func (t *Proposal) MarshalCBOR(w io.Writer) error {
+func (t *SignedResponse) MarshalCBOR(w io.Writer) error {
 	if t == nil {
 		_, err := w.Write(cbg.CborNull)
 		return err
@@ -122,19 +189,19 @@ func (t *Proposal) MarshalCBOR(w io.Writer) error {
 		return err
 	}
 
-	// t.DealProposal (market.ClientDealProposal) (struct)
-	if err := t.DealProposal.MarshalCBOR(w); err != nil {
+	// t.Response (network.Response) (struct)
+	if err := t.Response.MarshalCBOR(w); err != nil {
 		return err
 	}
 
-	// t.Piece (storagemarket.DataRef) (struct)
-	if err := t.Piece.MarshalCBOR(w); err != nil {
+	// t.Signature (crypto.Signature) (struct)
+	if err := t.Signature.MarshalCBOR(w); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (t *Proposal) UnmarshalCBOR(r io.Reader) error {
+func (t *SignedResponse) UnmarshalCBOR(r io.Reader) error {
 	br := cbg.GetPeeker(r)
 
 	maj, extra, err := cbg.CborReadHeader(br)
@@ -149,7 +216,16 @@ func (t *Proposal) UnmarshalCBOR(r io.Reader) error {
 		return fmt.Errorf("cbor input had wrong number of fields")
 	}
 
-	// t.DealProposal (market.ClientDealProposal) (struct)
+	// t.Response (network.Response) (struct)
+
+	{
+
+		if err := t.Response.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.Response: %w", err)
+		}
+
+	}
+	// t.Signature (crypto.Signature) (struct)
 
 	{
 
@@ -163,14 +239,110 @@ func (t *Proposal) UnmarshalCBOR(r io.Reader) error {
 				return err
 			}
 		} else {
-			t.DealProposal = new(market.ClientDealProposal)
-			if err := t.DealProposal.UnmarshalCBOR(br); err != nil {
-				return xerrors.Errorf("unmarshaling t.DealProposal pointer: %w", err)
+			t.Signature = new(crypto.Signature)
+			if err := t.Signature.UnmarshalCBOR(br); err != nil {
+				return xerrors.Errorf("unmarshaling t.Signature pointer: %w", err)
 			}
 		}
 
 	}
-	// t.Piece (storagemarket.DataRef) (struct)
+	return nil
+}
+
+func (t *DealStatusRequest) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write([]byte{129}); err != nil {
+		return err
+	}
+
+	// t.Proposal (cid.Cid) (struct)
+
+	if err := cbg.WriteCid(w, t.Proposal); err != nil {
+		return xerrors.Errorf("failed to write cid field t.Proposal: %w", err)
+	}
+
+	return nil
+}
+
+func (t *DealStatusRequest) UnmarshalCBOR(r io.Reader) error {
+	br := cbg.GetPeeker(r)
+
+	maj, extra, err := cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 1 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.Proposal (cid.Cid) (struct)
+
+	{
+
+		c, err := cbg.ReadCid(br)
+		if err != nil {
+			return xerrors.Errorf("failed to read cid field t.Proposal: %w", err)
+		}
+
+		t.Proposal = c
+
+	}
+	return nil
+}
+
+func (t *DealStatusResponse) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write([]byte{130}); err != nil {
+		return err
+	}
+
+	// t.DealState (storagemarket.ProviderDealState) (struct)
+	if err := t.DealState.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.Signature (crypto.Signature) (struct)
+	if err := t.Signature.MarshalCBOR(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *DealStatusResponse) UnmarshalCBOR(r io.Reader) error {
+	br := cbg.GetPeeker(r)
+
+	maj, extra, err := cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 2 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
+
+	// t.DealState (storagemarket.ProviderDealState) (struct)
+
+	{
+
+		if err := t.DealState.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.DealState: %w", err)
+		}
+
+	}
+	// t.Signature (crypto.Signature) (struct)
 
 	{
 
@@ -184,9 +356,9 @@ func (t *Proposal) UnmarshalCBOR(r io.Reader) error {
 				return err
 			}
 		} else {
-			t.Piece = new(storagemarket.DataRef)
-			if err := t.Piece.UnmarshalCBOR(br); err != nil {
-				return xerrors.Errorf("unmarshaling t.Piece pointer: %w", err)
+			t.Signature = new(crypto.Signature)
+			if err := t.Signature.UnmarshalCBOR(br); err != nil {
+				return xerrors.Errorf("unmarshaling t.Signature pointer: %w", err)
 			}
 		}
 
@@ -194,55 +366,98 @@ func (t *Proposal) UnmarshalCBOR(r io.Reader) error {
 	return nil
 }
 
-func (t *Response) MarshalCBOR(w io.Writer) error {
+func (t *DealCancel) MarshalCBOR(w io.Writer) error {
 	if t == nil {
 		_, err := w.Write(cbg.CborNull)
 		return err
 	}
-	if _, err := w.Write([]byte{132}); err != nil {
+	if _, err := w.Write([]byte{130}); err != nil {
 		return err
 	}
 
-	// t.State (uint64) (uint64)
+	// t.Proposal (cid.Cid) (struct)
 
-	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.State))); err != nil {
-		return err
+	if err := cbg.WriteCid(w, t.Proposal); err != nil {
+		return xerrors.Errorf("failed to write cid field t.Proposal: %w", err)
 	}
 
-	// t.Message (string) (string)
-	if len(t.Message) > cbg.MaxLength {
-		return xerrors.Errorf("Value in field t.Message was too long")
+	// t.Reason (string) (string)
+	if len(t.Reason) > cbg.MaxLength {
+		return xerrors.Errorf("Value in field t.Reason was too long")
 	}
 
-	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len(t.Message)))); err != nil {
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len(t.Reason)))); err != nil {
 		return err
 	}
-	if _, err := w.Write([]byte(t.Message)); err != nil {
+	if _, err := w.Write([]byte(t.Reason)); err != nil {
 		return err
 	}
 
-	// t.Proposal (cid.Cid) (struct)
+	return nil
+}
 
-	if err := cbg.WriteCid(w, t.Proposal); err != nil {
-		return xerrors.Errorf("failed to write cid field t.Proposal: %w", err)
+func (t *DealCancel) UnmarshalCBOR(r io.Reader) error {
+	br := cbg.GetPeeker(r)
+
+	maj, extra, err := cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
 	}
 
-	// t.PublishMessage (cid.Cid) (struct)
+	if extra != 2 {
+		return fmt.Errorf("cbor input had wrong number of fields")
+	}
 
-	if t.PublishMessage == nil {
-		if _, err := w.Write(cbg.CborNull); err != nil {
-			return err
+	// t.Proposal (cid.Cid) (struct)
+
+	{
+
+		c, err := cbg.ReadCid(br)
+		if err != nil {
+			return xerrors.Errorf("failed to read cid field t.Proposal: %w", err)
 		}
-	} else {
-		if err := cbg.WriteCid(w, *t.PublishMessage); err != nil {
-			return xerrors.Errorf("failed to write cid field t.PublishMessage: %w", err)
+
+		t.Proposal = c
+
+	}
+	// t.Reason (string) (string)
+
+	{
+		sval, err := cbg.ReadString(br)
+		if err != nil {
+			return err
 		}
+
+		t.Reason = string(sval)
+	}
+	return nil
+}
+
+func (t *SignedDealCancel) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write([]byte{130}); err != nil {
+		return err
+	}
+
+	// t.DealCancel (network.DealCancel) (struct)
+	if err := t.DealCancel.MarshalCBOR(w); err != nil {
+		return err
 	}
 
+	// t.Signature (crypto.Signature) (struct)
+	if err := t.Signature.MarshalCBOR(w); err != nil {
+		return err
+	}
 	return nil
 }
 
-func (t *Response) UnmarshalCBOR(r io.Reader) error {
+func (t *SignedDealCancel) UnmarshalCBOR(r io.Reader) error {
 	br := cbg.GetPeeker(r)
 
 	maj, extra, err := cbg.CborReadHeader(br)
@@ -253,47 +468,89 @@ func (t *Response) UnmarshalCBOR(r io.Reader) error {
 		return fmt.Errorf("cbor input should be of type array")
 	}
 
-	if extra != 4 {
+	if extra != 2 {
 		return fmt.Errorf("cbor input had wrong number of fields")
 	}
 
-	// t.State (uint64) (uint64)
+	// t.DealCancel (network.DealCancel) (struct)
 
 	{
 
-		maj, extra, err = cbg.CborReadHeader(br)
-		if err != nil {
-			return err
+		if err := t.DealCancel.UnmarshalCBOR(br); err != nil {
+			return xerrors.Errorf("unmarshaling t.DealCancel: %w", err)
 		}
-		if maj != cbg.MajUnsignedInt {
-			return fmt.Errorf("wrong type for uint64 field")
-		}
-		t.State = uint64(extra)
 
 	}
-	// t.Message (string) (string)
+	// t.Signature (crypto.Signature) (struct)
 
 	{
-		sval, err := cbg.ReadString(br)
+
+		pb, err := br.PeekByte()
 		if err != nil {
 			return err
 		}
+		if pb == cbg.CborNull[0] {
+			var nbuf [1]byte
+			if _, err := br.Read(nbuf[:]); err != nil {
+				return err
+			}
+		} else {
+			t.Signature = new(crypto.Signature)
+			if err := t.Signature.UnmarshalCBOR(br); err != nil {
+				return xerrors.Errorf("unmarshaling t.Signature pointer: %w", err)
+			}
+		}
 
-		t.Message = string(sval)
 	}
-	// t.Proposal (cid.Cid) (struct)
+	return nil
+}
 
-	{
+func (t *AskResponseTuple) MarshalCBOR(w io.Writer) error {
+	if t == nil {
+		_, err := w.Write(cbg.CborNull)
+		return err
+	}
+	if _, err := w.Write([]byte{130}); err != nil {
+		return err
+	}
 
-		c, err := cbg.ReadCid(br)
-		if err != nil {
-			return xerrors.Errorf("failed to read cid field t.Proposal: %w", err)
+	// t.Ask (storagemarket.SignedStorageAsk) (struct)
+	if err := t.Ask.MarshalCBOR(w); err != nil {
+		return err
+	}
+
+	// t.Asks ([]*storagemarket.SignedStorageAsk) (slice)
+	if len(t.Asks) > cbg.MaxLength {
+		return xerrors.Errorf("Slice value in field t.Asks was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajArray, uint64(len(t.Asks)))); err != nil {
+		return err
+	}
+	for _, v := range t.Asks {
+		if err := v.MarshalCBOR(w); err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
-		t.Proposal = c
+func (t *AskResponseTuple) UnmarshalCBOR(r io.Reader) error {
+	br := cbg.GetPeeker(r)
 
+	maj, extra, err := cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+	if maj != cbg.MajArray {
+		return fmt.Errorf("cbor input should be of type array")
+	}
+
+	if extra != 2 {
+		return fmt.Errorf("cbor input had wrong number of fields")
 	}
-	// t.PublishMessage (cid.Cid) (struct)
+
+	// t.Ask (storagemarket.SignedStorageAsk) (struct)
 
 	{
 
@@ -307,41 +564,100 @@ func (t *Response) UnmarshalCBOR(r io.Reader) error {
 				return err
 			}
 		} else {
-
-			c, err := cbg.ReadCid(br)
-			if err != nil {
-				return xerrors.Errorf("failed to read cid field t.PublishMessage: %w", err)
+			t.Ask = new(storagemarket.SignedStorageAsk)
+			if err := t.Ask.UnmarshalCBOR(br); err != nil {
+				return xerrors.Errorf("unmarshaling t.Ask pointer: %w", err)
 			}
+		}
 
-			t.PublishMessage = &c
+	}
+	// t.Asks ([]*storagemarket.SignedStorageAsk) (slice)
+
+	maj, extra, err = cbg.CborReadHeader(br)
+	if err != nil {
+		return err
+	}
+
+	if extra > cbg.MaxLength {
+		return fmt.Errorf("t.Asks: array too large (%d)", extra)
+	}
+
+	if maj != cbg.MajArray {
+		return fmt.Errorf("expected cbor array")
+	}
+
+	if extra > 0 {
+		t.Asks = make([]*storagemarket.SignedStorageAsk, extra)
+	}
+
+	for i := 0; i < int(extra); i++ {
+
+		var v storagemarket.SignedStorageAsk
+		if err := v.UnmarshalCBOR(br); err != nil {
+			return err
 		}
 
+		t.Asks[i] = &v
 	}
+
 	return nil
 }
 
-func (t *SignedResponse) MarshalCBOR(w io.Writer) error {
+func (t *ResponseTuple) MarshalCBOR(w io.Writer) error {
 	if t == nil {
 		_, err := w.Write(cbg.CborNull)
 		return err
 	}
-	if _, err := w.Write([]byte{130}); err != nil {
+	if _, err := w.Write([]byte{133}); err != nil {
 		return err
 	}
 
-	// t.Response (network.Response) (struct)
-	if err := t.Response.MarshalCBOR(w); err != nil {
+	// t.State (uint64) (uint64)
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.State))); err != nil {
 		return err
 	}
 
-	// t.Signature (crypto.Signature) (struct)
-	if err := t.Signature.MarshalCBOR(w); err != nil {
+	// t.Message (string) (string)
+	if len(t.Message) > cbg.MaxLength {
+		return xerrors.Errorf("Value in field t.Message was too long")
+	}
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajTextString, uint64(len(t.Message)))); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(t.Message)); err != nil {
 		return err
 	}
+
+	// t.Proposal (cid.Cid) (struct)
+
+	if err := cbg.WriteCid(w, t.Proposal); err != nil {
+		return xerrors.Errorf("failed to write cid field t.Proposal: %w", err)
+	}
+
+	// t.PublishMessage (cid.Cid) (struct)
+
+	if t.PublishMessage == nil {
+		if _, err := w.Write(cbg.CborNull); err != nil {
+			return err
+		}
+	} else {
+		if err := cbg.WriteCid(w, *t.PublishMessage); err != nil {
+			return xerrors.Errorf("failed to write cid field t.PublishMessage: %w", err)
+		}
+	}
+
+	// t.Reason (storagemarket.RejectionReason) (uint64)
+
+	if _, err := w.Write(cbg.CborEncodeMajorType(cbg.MajUnsignedInt, uint64(t.Reason))); err != nil {
+		return err
+	}
+
 	return nil
 }
 
-func (t *SignedResponse) UnmarshalCBOR(r io.Reader) error {
+func (t *ResponseTuple) UnmarshalCBOR(r io.Reader) error {
 	br := cbg.GetPeeker(r)
 
 	maj, extra, err := cbg.CborReadHeader(br)
@@ -352,20 +668,47 @@ func (t *SignedResponse) UnmarshalCBOR(r io.Reader) error {
 		return fmt.Errorf("cbor input should be of type array")
 	}
 
-	if extra != 2 {
+	if extra != 5 {
 		return fmt.Errorf("cbor input had wrong number of fields")
 	}
 
-	// t.Response (network.Response) (struct)
+	// t.State (uint64) (uint64)
 
 	{
 
-		if err := t.Response.UnmarshalCBOR(br); err != nil {
-			return xerrors.Errorf("unmarshaling t.Response: %w", err)
+		maj, extra, err = cbg.CborReadHeader(br)
+		if err != nil {
+			return err
 		}
+		if maj != cbg.MajUnsignedInt {
+			return fmt.Errorf("wrong type for uint64 field")
+		}
+		t.State = uint64(extra)
 
 	}
-	// t.Signature (crypto.Signature) (struct)
+	// t.Message (string) (string)
+
+	{
+		sval, err := cbg.ReadString(br)
+		if err != nil {
+			return err
+		}
+
+		t.Message = string(sval)
+	}
+	// t.Proposal (cid.Cid) (struct)
+
+	{
+
+		c, err := cbg.ReadCid(br)
+		if err != nil {
+			return xerrors.Errorf("failed to read cid field t.Proposal: %w", err)
+		}
+
+		t.Proposal = c
+
+	}
+	// t.PublishMessage (cid.Cid) (struct)
 
 	{
 
@@ -379,11 +722,28 @@ func (t *SignedResponse) UnmarshalCBOR(r io.Reader) error {
 				return err
 			}
 		} else {
-			t.Signature = new(crypto.Signature)
-			if err := t.Signature.UnmarshalCBOR(br); err != nil {
-				return xerrors.Errorf("unmarshaling t.Signature pointer: %w", err)
+
+			c, err := cbg.ReadCid(br)
+			if err != nil {
+				return xerrors.Errorf("failed to read cid field t.PublishMessage: %w", err)
 			}
+
+			t.PublishMessage = &c
+		}
+
+	}
+	// t.Reason (storagemarket.RejectionReason) (uint64)
+
+	{
+
+		maj, extra, err = cbg.CborReadHeader(br)
+		if err != nil {
+			return err
+		}
+		if maj != cbg.MajUnsignedInt {
+			return fmt.Errorf("wrong type for uint64 field")
 		}
+		t.Reason = storagemarket.RejectionReason(extra)
 
 	}
 	return nil
@@ -0,0 +1,137 @@
+package providerselect
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+)
+
+// Proposal is a deal to propose to whichever candidate accepts it first. The price of the
+// deal is the price of the candidate it is proposed to
+type Proposal struct {
+	Client     address.Address
+	Data       *storagemarket.DataRef
+	StartEpoch abi.ChainEpoch
+	EndEpoch   abi.ChainEpoch
+	ProofType  abi.RegisteredProof
+	Options    []storagemarket.ProposeStorageDealOption
+}
+
+// Accepted is a deal a candidate accepted
+type Accepted struct {
+	Candidate   Candidate
+	ProposalCid cid.Cid
+}
+
+// AllRejectedError is returned when every candidate rejected a deal
+type AllRejectedError struct {
+	// Rejections holds the *storagemarket.DealRejectedError of each candidate, in the
+	// order the deal was proposed to them
+	Rejections []error
+}
+
+func (e *AllRejectedError) Error() string {
+	return fmt.Sprintf("all %d candidates rejected the deal: %v", len(e.Rejections), e.Rejections)
+}
+
+// ProposeToBest proposes a deal to each of the given candidates in turn, moving on to the
+// next when a candidate rejects the deal, until one of them accepts it. A deal that fails
+// for any other reason stops the search and its error is returned
+func (s *Selector) ProposeToBest(ctx context.Context, candidates []Candidate, proposal Proposal) (*Accepted, error) {
+	if len(candidates) == 0 {
+		return nil, xerrors.New("no candidates to propose the deal to")
+	}
+
+	w := newDealWatcher()
+	unsubscribe := s.client.SubscribeToEvents(w.onEvent)
+	defer unsubscribe()
+
+	var rejections []error
+	for _, candidate := range candidates {
+		info := candidate.Provider
+		options := append([]storagemarket.ProposeStorageDealOption{storagemarket.WithStorageAsk(candidate.Ask)}, proposal.Options...)
+		result, err := s.client.ProposeStorageDeal(ctx, proposal.Client, &info, proposal.Data,
+			proposal.StartEpoch, proposal.EndEpoch, candidate.Price, abi.TokenAmount{}, proposal.ProofType, options...)
+		if err != nil {
+			return nil, xerrors.Errorf("proposing deal to %s: %w", info.Address, err)
+		}
+
+		err = w.wait(ctx, result.ProposalCid)
+		var rejected *storagemarket.DealRejectedError
+		if xerrors.As(err, &rejected) {
+			log.Infof("provider %s rejected deal %s: %s", info.Address, result.ProposalCid, err)
+			rejections = append(rejections, err)
+			continue
+		}
+		if err != nil {
+			return nil, xerrors.Errorf("deal %s with %s: %w", result.ProposalCid, info.Address, err)
+		}
+		return &Accepted{Candidate: candidate, ProposalCid: result.ProposalCid}, nil
+	}
+	return nil, &AllRejectedError{Rejections: rejections}
+}
+
+// dealWatcher records whether proposed deals were accepted. Events for a deal can arrive
+// before its proposal call returns, so outcomes are kept until they are waited on
+type dealWatcher struct {
+	lk       sync.Mutex
+	outcomes map[cid.Cid]error
+	changed  chan struct{}
+}
+
+func newDealWatcher() *dealWatcher {
+	return &dealWatcher{
+		outcomes: make(map[cid.Cid]error),
+		changed:  make(chan struct{}, 1),
+	}
+}
+
+func (w *dealWatcher) onEvent(event storagemarket.ClientEvent, deal storagemarket.ClientDeal) {
+	var outcome error
+	switch {
+	case event == storagemarket.ClientEventDealAccepted:
+	case event == storagemarket.ClientEventDealRejected:
+		outcome = deal.RejectionError()
+	case deal.State == storagemarket.StorageDealFailing || deal.State == storagemarket.StorageDealError ||
+		deal.State == storagemarket.StorageDealCancelled:
+		outcome = xerrors.New(deal.Message)
+	default:
+		return
+	}
+
+	w.lk.Lock()
+	if _, ok := w.outcomes[deal.ProposalCid]; !ok {
+		w.outcomes[deal.ProposalCid] = outcome
+	}
+	w.lk.Unlock()
+
+	select {
+	case w.changed <- struct{}{}:
+	default:
+	}
+}
+
+// wait waits until the given deal is accepted or fails, and returns nil if it was accepted
+func (w *dealWatcher) wait(ctx context.Context, proposalCid cid.Cid) error {
+	for {
+		w.lk.Lock()
+		outcome, ok := w.outcomes[proposalCid]
+		w.lk.Unlock()
+		if ok {
+			return outcome
+		}
+
+		select {
+		case <-w.changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package providerselect
+
+import (
+	mathbig "math/big"
+	"time"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+)
+
+// ScoreFunc scores a candidate for a deal, where a higher score is better. It is given
+// all the candidates for the deal, so that it can score candidates relative to each other
+type ScoreFunc func(candidate Candidate, all []Candidate) float64
+
+// Weights are how much the price, success rate and latency of a candidate count towards
+// its score
+type Weights struct {
+	Price       float64
+	SuccessRate float64
+	Latency     float64
+}
+
+// DefaultWeights favor cheap providers, then reliable ones, then fast ones
+var DefaultWeights = Weights{
+	Price:       0.5,
+	SuccessRate: 0.3,
+	Latency:     0.2,
+}
+
+// WeightedScore scores candidates by a weighted sum of their price, success rate and latency.
+// Price and latency are scaled so that the cheapest and fastest of all candidates score one
+// and the most expensive and slowest score zero
+func WeightedScore(weights Weights) ScoreFunc {
+	return func(candidate Candidate, all []Candidate) float64 {
+		minPrice, maxPrice := candidate.Price, candidate.Price
+		minLatency, maxLatency := candidate.Latency, candidate.Latency
+		for _, other := range all {
+			if other.Price.LessThan(minPrice) {
+				minPrice = other.Price
+			}
+			if other.Price.GreaterThan(maxPrice) {
+				maxPrice = other.Price
+			}
+			if other.Latency < minLatency {
+				minLatency = other.Latency
+			}
+			if other.Latency > maxLatency {
+				maxLatency = other.Latency
+			}
+		}
+
+		priceScore := 1 - priceFraction(big.Sub(candidate.Price, minPrice), big.Sub(maxPrice, minPrice))
+		latencyScore := 1 - latencyFraction(candidate.Latency-minLatency, maxLatency-minLatency)
+		return weights.Price*priceScore + weights.SuccessRate*candidate.SuccessRate + weights.Latency*latencyScore
+	}
+}
+
+// CheapestFirst scores candidates by price alone
+func CheapestFirst() ScoreFunc {
+	return WeightedScore(Weights{Price: 1})
+}
+
+func priceFraction(part abi.TokenAmount, whole abi.TokenAmount) float64 {
+	if big.Cmp(whole, big.Zero()) == 0 {
+		return 0
+	}
+	f, _ := new(mathbig.Rat).SetFrac(part.Int, whole.Int).Float64()
+	return f
+}
+
+func latencyFraction(part time.Duration, whole time.Duration) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return float64(part) / float64(whole)
+}
@@ -0,0 +1,263 @@
+package providerselect
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	logging "github.com/ipfs/go-log/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+)
+
+var log = logging.Logger("providerselect")
+
+// DefaultAskTimeout is how long a provider has to answer an ask query unless told otherwise
+const DefaultAskTimeout = 10 * time.Second
+
+// DefaultQueryParallelism is how many providers are queried for asks at once unless told otherwise
+const DefaultQueryParallelism = 16
+
+// DealParams are the properties of a deal a provider's ask has to fit
+type DealParams struct {
+	PieceSize    abi.PaddedPieceSize
+	Duration     abi.ChainEpoch
+	VerifiedDeal bool
+
+	// MaxPrice is the most the client will pay per GiB per epoch. It is not limited if unset
+	MaxPrice abi.TokenAmount
+}
+
+// Candidate is a storage provider whose ask fits a deal
+type Candidate struct {
+	Provider storagemarket.StorageProviderInfo
+
+	// Ask is the cheapest of the provider's asks that covers the deal
+	Ask storagemarket.StorageAsk
+
+	// Price is the price per epoch the provider asks for the whole deal
+	Price abi.TokenAmount
+
+	// Latency is how long the provider took to answer the ask query
+	Latency time.Duration
+
+	// SuccessRate is the share of the client's finished deals with the provider that
+	// succeeded. Each provider starts out with one success and one failure, so providers
+	// the client has not made deals with have a success rate of one half
+	SuccessRate float64
+
+	// Score is what the selector's scoring function gave the candidate. Higher is better
+	Score float64
+}
+
+// Selector finds the storage providers best suited to a deal, by querying every provider
+// on chain for its asks and scoring those whose asks fit the deal
+type Selector struct {
+	client      storagemarket.StorageClient
+	askTimeout  time.Duration
+	parallelism int
+	score       ScoreFunc
+}
+
+// Option configures a Selector
+type Option func(*Selector)
+
+// AskTimeout sets how long a provider has to answer an ask query before it is dropped
+func AskTimeout(timeout time.Duration) Option {
+	return func(s *Selector) {
+		s.askTimeout = timeout
+	}
+}
+
+// QueryParallelism sets how many providers are queried for asks at once
+func QueryParallelism(parallelism int) Option {
+	return func(s *Selector) {
+		s.parallelism = parallelism
+	}
+}
+
+// Scoring sets the function candidates are ranked by
+func Scoring(score ScoreFunc) Option {
+	return func(s *Selector) {
+		s.score = score
+	}
+}
+
+// NewSelector returns a selector that finds providers through the given storage client
+func NewSelector(client storagemarket.StorageClient, options ...Option) *Selector {
+	s := &Selector{
+		client:      client,
+		askTimeout:  DefaultAskTimeout,
+		parallelism: DefaultQueryParallelism,
+		score:       WeightedScore(DefaultWeights),
+	}
+	for _, option := range options {
+		option(s)
+	}
+	if s.parallelism < 1 {
+		s.parallelism = 1
+	}
+	return s
+}
+
+// Candidates queries every provider on chain for its asks, drops those that do not answer
+// in time or whose asks do not fit the deal, and returns the rest from best to worst score
+func (s *Selector) Candidates(ctx context.Context, deal DealParams) ([]Candidate, error) {
+	successRates, err := s.successRates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	providers, err := s.client.ListProviders(ctx)
+	if err != nil {
+		return nil, xerrors.Errorf("listing providers: %w", err)
+	}
+
+	var lk sync.Mutex
+	var candidates []Candidate
+	var wg sync.WaitGroup
+	for i := 0; i < s.parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for info := range providers {
+				candidate, ok := s.query(ctx, info, deal)
+				if !ok {
+					continue
+				}
+				candidate.SuccessRate = successRates.rate(info.Address)
+				lk.Lock()
+				candidates = append(candidates, candidate)
+				lk.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	s.Rank(candidates)
+	return candidates, nil
+}
+
+// Rank scores the given candidates and sorts them from best to worst score. Candidates
+// with the same score are ordered by price
+func (s *Selector) Rank(candidates []Candidate) {
+	for i := range candidates {
+		candidates[i].Score = s.score(candidates[i], candidates)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Score != candidates[j].Score {
+			return candidates[i].Score > candidates[j].Score
+		}
+		if candidates[i].Price.LessThan(candidates[j].Price) {
+			return true
+		}
+		if candidates[j].Price.LessThan(candidates[i].Price) {
+			return false
+		}
+		return bytes.Compare(candidates[i].Provider.Address.Bytes(), candidates[j].Provider.Address.Bytes()) < 0
+	})
+}
+
+type askResult struct {
+	asks []*storagemarket.SignedStorageAsk
+	err  error
+}
+
+// query asks a provider for its asks and returns it as a candidate if one of them fits the deal
+func (s *Selector) query(ctx context.Context, info storagemarket.StorageProviderInfo, deal DealParams) (Candidate, bool) {
+	if uint64(deal.PieceSize) > info.SectorSize {
+		return Candidate{}, false
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.askTimeout)
+	defer cancel()
+
+	// ask streams do not take a context, so a provider that never answers is abandoned
+	// rather than waited on
+	start := time.Now()
+	result := make(chan askResult, 1)
+	go func() {
+		asks, err := s.client.GetAsks(ctx, info)
+		result <- askResult{asks, err}
+	}()
+
+	var res askResult
+	select {
+	case res = <-result:
+	case <-ctx.Done():
+		log.Debugf("provider %s did not answer ask query in time", info.Address)
+		return Candidate{}, false
+	}
+	latency := time.Since(start)
+	if res.err != nil {
+		log.Debugf("querying asks of provider %s: %s", info.Address, res.err)
+		return Candidate{}, false
+	}
+
+	asks := make([]storagemarket.StorageAsk, 0, len(res.asks))
+	for _, signedAsk := range res.asks {
+		asks = append(asks, *signedAsk.Ask)
+	}
+	ask, ok := storagemarket.SelectAsk(asks, deal.PieceSize, deal.Duration)
+	if !ok {
+		return Candidate{}, false
+	}
+
+	pricePerGiB := ask.DealPrice(deal.VerifiedDeal)
+	if !deal.MaxPrice.Nil() && pricePerGiB.GreaterThan(deal.MaxPrice) {
+		return Candidate{}, false
+	}
+
+	return Candidate{
+		Provider: info,
+		Ask:      ask,
+		Price:    big.Div(big.Mul(pricePerGiB, abi.NewTokenAmount(int64(deal.PieceSize))), abi.NewTokenAmount(1<<30)),
+		Latency:  latency,
+	}, true
+}
+
+type dealCounts struct {
+	succeeded uint64
+	total     uint64
+}
+
+type successRates map[address.Address]dealCounts
+
+func (sr successRates) rate(provider address.Address) float64 {
+	counts := sr[provider]
+	return float64(counts.succeeded+1) / float64(counts.total+2)
+}
+
+// successRates counts how many of the client's finished deals with each provider succeeded
+func (s *Selector) successRates(ctx context.Context) (successRates, error) {
+	deals, err := s.client.ListLocalDeals(ctx)
+	if err != nil {
+		return nil, xerrors.Errorf("listing local deals: %w", err)
+	}
+	rates := make(successRates)
+	for _, deal := range deals {
+		counts := rates[deal.Proposal.Provider]
+		switch deal.State {
+		case storagemarket.StorageDealActive, storagemarket.StorageDealExpired:
+			counts.succeeded++
+			counts.total++
+		case storagemarket.StorageDealError, storagemarket.StorageDealSlashed:
+			counts.total++
+		default:
+			continue
+		}
+		rates[deal.Proposal.Provider] = counts
+	}
+	return rates, nil
+}
@@ -0,0 +1,314 @@
+package providerselect_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin/market"
+	specst "github.com/filecoin-project/specs-actors/support/testing"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-fil-markets/shared"
+	"github.com/filecoin-project/go-fil-markets/shared_testutil"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/go-fil-markets/storagemarket/providerselect"
+)
+
+const sectorSize = 1 << 30
+
+type fakeClient struct {
+	storagemarket.StorageClient
+
+	providers  []storagemarket.StorageProviderInfo
+	asks       map[address.Address][]storagemarket.StorageAsk
+	unanswered map[address.Address]bool
+	localDeals []storagemarket.ClientDeal
+
+	// outcomes are the events sent for deals proposed to each provider
+	outcomes map[address.Address]storagemarket.ClientDeal
+
+	lk         sync.Mutex
+	proposed   []address.Address
+	prices     []abi.TokenAmount
+	dealAsks   []*storagemarket.StorageAsk
+	subscriber storagemarket.ClientSubscriber
+}
+
+func (fc *fakeClient) ListProviders(ctx context.Context) (<-chan storagemarket.StorageProviderInfo, error) {
+	out := make(chan storagemarket.StorageProviderInfo)
+	go func() {
+		defer close(out)
+		for _, p := range fc.providers {
+			select {
+			case out <- p:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (fc *fakeClient) ListLocalDeals(ctx context.Context, selectors ...storagemarket.LabelSelector) ([]storagemarket.ClientDeal, error) {
+	return fc.localDeals, nil
+}
+
+func (fc *fakeClient) GetAsks(ctx context.Context, info storagemarket.StorageProviderInfo) ([]*storagemarket.SignedStorageAsk, error) {
+	if fc.unanswered[info.Address] {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	asks, ok := fc.asks[info.Address]
+	if !ok {
+		return nil, errors.New("failed to open stream to miner")
+	}
+	out := make([]*storagemarket.SignedStorageAsk, 0, len(asks))
+	for i := range asks {
+		out = append(out, &storagemarket.SignedStorageAsk{Ask: &asks[i]})
+	}
+	return out, nil
+}
+
+func (fc *fakeClient) SubscribeToEvents(subscriber storagemarket.ClientSubscriber) shared.Unsubscribe {
+	fc.subscriber = subscriber
+	return func() {}
+}
+
+func (fc *fakeClient) ProposeStorageDeal(ctx context.Context, addr address.Address, info *storagemarket.StorageProviderInfo, data *storagemarket.DataRef, startEpoch abi.ChainEpoch, endEpoch abi.ChainEpoch, price abi.TokenAmount, collateral abi.TokenAmount, rt abi.RegisteredProof, options ...storagemarket.ProposeStorageDealOption) (*storagemarket.ProposeStorageDealResult, error) {
+	fc.lk.Lock()
+	fc.proposed = append(fc.proposed, info.Address)
+	fc.prices = append(fc.prices, price)
+	var params storagemarket.ProposeStorageDealParams
+	for _, option := range options {
+		option(&params)
+	}
+	fc.dealAsks = append(fc.dealAsks, params.Ask)
+	fc.lk.Unlock()
+
+	proposalCid := shared_testutil.GenerateCids(1)[0]
+	deal, ok := fc.outcomes[info.Address]
+	if !ok {
+		return nil, errors.New("provider unreachable")
+	}
+	deal.ProposalCid = proposalCid
+	// the deal is decided before the proposal call returns, as it can be for a real client
+	event := storagemarket.ClientEventDealAccepted
+	if deal.RejectionReason != storagemarket.RejectionNone {
+		event = storagemarket.ClientEventDealRejected
+	} else if deal.State == storagemarket.StorageDealFailing {
+		event = storagemarket.ClientEventDataTransferFailed
+	}
+	fc.subscriber(event, deal)
+	return &storagemarket.ProposeStorageDealResult{ProposalCid: proposalCid}, nil
+}
+
+func makeAsk(price int64, minPieceSize abi.PaddedPieceSize, maxPieceSize abi.PaddedPieceSize) storagemarket.StorageAsk {
+	return storagemarket.StorageAsk{
+		Price:        abi.NewTokenAmount(price),
+		MinPieceSize: minPieceSize,
+		MaxPieceSize: maxPieceSize,
+	}
+}
+
+func TestCandidates(t *testing.T) {
+	ctx := context.Background()
+	cheap := specst.NewIDAddr(t, 101)
+	expensive := specst.NewIDAddr(t, 102)
+	tooSmall := specst.NewIDAddr(t, 103)
+	smallSector := specst.NewIDAddr(t, 104)
+	slow := specst.NewIDAddr(t, 105)
+	failing := specst.NewIDAddr(t, 106)
+	overBudget := specst.NewIDAddr(t, 107)
+
+	info := func(addr address.Address, sectorSize uint64) storagemarket.StorageProviderInfo {
+		return storagemarket.StorageProviderInfo{Address: addr, SectorSize: sectorSize}
+	}
+	client := &fakeClient{
+		providers: []storagemarket.StorageProviderInfo{
+			info(overBudget, sectorSize),
+			info(expensive, sectorSize),
+			info(tooSmall, sectorSize),
+			info(smallSector, 1<<20),
+			info(slow, sectorSize),
+			info(failing, sectorSize),
+			info(cheap, sectorSize),
+		},
+		asks: map[address.Address][]storagemarket.StorageAsk{
+			cheap: {
+				makeAsk(3000, 256, 1<<30),
+				makeAsk(1000, 1<<20, 1<<30),
+			},
+			expensive:   {makeAsk(2000, 256, 1<<30)},
+			tooSmall:    {makeAsk(1000, 256, 1<<10)},
+			smallSector: {makeAsk(1000, 256, 1<<20)},
+			slow:        {makeAsk(1000, 256, 1<<30)},
+			overBudget:  {makeAsk(6000, 256, 1<<30)},
+		},
+		unanswered: map[address.Address]bool{slow: true},
+		localDeals: []storagemarket.ClientDeal{
+			{ClientDealProposal: proposalWith(expensive), State: storagemarket.StorageDealActive},
+			{ClientDealProposal: proposalWith(expensive), State: storagemarket.StorageDealExpired},
+			{ClientDealProposal: proposalWith(expensive), State: storagemarket.StorageDealSealing},
+			{ClientDealProposal: proposalWith(cheap), State: storagemarket.StorageDealError},
+		},
+	}
+
+	selector := providerselect.NewSelector(client,
+		providerselect.AskTimeout(50*time.Millisecond),
+		providerselect.Scoring(providerselect.CheapestFirst()))
+	candidates, err := selector.Candidates(ctx, providerselect.DealParams{
+		PieceSize: 1 << 29,
+		Duration:  10000,
+		MaxPrice:  abi.NewTokenAmount(5000),
+	})
+	require.NoError(t, err)
+	require.Len(t, candidates, 2)
+
+	require.Equal(t, cheap, candidates[0].Provider.Address)
+	require.Equal(t, abi.NewTokenAmount(1000), candidates[0].Ask.Price)
+	require.Equal(t, abi.NewTokenAmount(500), candidates[0].Price)
+	require.Equal(t, float64(1)/3, candidates[0].SuccessRate)
+
+	require.Equal(t, expensive, candidates[1].Provider.Address)
+	require.Equal(t, abi.NewTokenAmount(1000), candidates[1].Price)
+	require.Equal(t, float64(3)/4, candidates[1].SuccessRate)
+	require.True(t, candidates[0].Score > candidates[1].Score)
+}
+
+func proposalWith(provider address.Address) market.ClientDealProposal {
+	return market.ClientDealProposal{Proposal: market.DealProposal{Provider: provider}}
+}
+
+func TestWeightedScore(t *testing.T) {
+	a := providerselect.Candidate{
+		Provider:    storagemarket.StorageProviderInfo{Address: specst.NewIDAddr(t, 101)},
+		Price:       abi.NewTokenAmount(100),
+		Latency:     100 * time.Millisecond,
+		SuccessRate: 0.5,
+	}
+	b := providerselect.Candidate{
+		Provider:    storagemarket.StorageProviderInfo{Address: specst.NewIDAddr(t, 102)},
+		Price:       abi.NewTokenAmount(200),
+		Latency:     10 * time.Millisecond,
+		SuccessRate: 0.9,
+	}
+	c := providerselect.Candidate{
+		Provider:    storagemarket.StorageProviderInfo{Address: specst.NewIDAddr(t, 103)},
+		Price:       abi.NewTokenAmount(300),
+		Latency:     50 * time.Millisecond,
+		SuccessRate: 0.1,
+	}
+
+	tests := map[string]struct {
+		weights  providerselect.Weights
+		expected []providerselect.Candidate
+	}{
+		"price": {
+			weights:  providerselect.Weights{Price: 1},
+			expected: []providerselect.Candidate{a, b, c},
+		},
+		"success rate": {
+			weights:  providerselect.Weights{SuccessRate: 1},
+			expected: []providerselect.Candidate{b, a, c},
+		},
+		"latency": {
+			weights:  providerselect.Weights{Latency: 1},
+			expected: []providerselect.Candidate{b, c, a},
+		},
+		"default weights": {
+			weights:  providerselect.DefaultWeights,
+			expected: []providerselect.Candidate{b, a, c},
+		},
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			selector := providerselect.NewSelector(&fakeClient{}, providerselect.Scoring(providerselect.WeightedScore(data.weights)))
+			candidates := []providerselect.Candidate{c, b, a}
+			selector.Rank(candidates)
+			addrs := make([]address.Address, 0, len(candidates))
+			for _, candidate := range candidates {
+				addrs = append(addrs, candidate.Provider.Address)
+			}
+			expected := make([]address.Address, 0, len(data.expected))
+			for _, candidate := range data.expected {
+				expected = append(expected, candidate.Provider.Address)
+			}
+			require.Equal(t, expected, addrs)
+		})
+	}
+}
+
+func TestProposeToBest(t *testing.T) {
+	ctx := context.Background()
+	first := specst.NewIDAddr(t, 101)
+	second := specst.NewIDAddr(t, 102)
+	third := specst.NewIDAddr(t, 103)
+	candidates := []providerselect.Candidate{
+		{Provider: storagemarket.StorageProviderInfo{Address: first}, Price: abi.NewTokenAmount(100), Ask: storagemarket.StorageAsk{SeqNo: 1}},
+		{Provider: storagemarket.StorageProviderInfo{Address: second}, Price: abi.NewTokenAmount(200), Ask: storagemarket.StorageAsk{SeqNo: 2}},
+		{Provider: storagemarket.StorageProviderInfo{Address: third}, Price: abi.NewTokenAmount(300)},
+	}
+	rejected := storagemarket.ClientDeal{
+		State:           storagemarket.StorageDealFailing,
+		Message:         "storage price per epoch less than asking price",
+		RejectionReason: storagemarket.RejectionPriceTooLow,
+	}
+	accepted := storagemarket.ClientDeal{State: storagemarket.StorageDealProposalAccepted}
+	failed := storagemarket.ClientDeal{State: storagemarket.StorageDealFailing, Message: "data transfer failed"}
+	proposal := providerselect.Proposal{
+		Data:       &storagemarket.DataRef{TransferType: storagemarket.TTGraphsync, Root: shared_testutil.GenerateCids(1)[0]},
+		StartEpoch: 100,
+		EndEpoch:   10100,
+	}
+
+	t.Run("falls back to the next candidate on rejection", func(t *testing.T) {
+		client := &fakeClient{outcomes: map[address.Address]storagemarket.ClientDeal{
+			first:  rejected,
+			second: accepted,
+			third:  accepted,
+		}}
+		result, err := providerselect.NewSelector(client).ProposeToBest(ctx, candidates, proposal)
+		require.NoError(t, err)
+		require.Equal(t, second, result.Candidate.Provider.Address)
+		require.Equal(t, []address.Address{first, second}, client.proposed)
+		require.Equal(t, []abi.TokenAmount{abi.NewTokenAmount(100), abi.NewTokenAmount(200)}, client.prices)
+		// the asks are passed on so the client does not query the providers again
+		require.Equal(t, []*storagemarket.StorageAsk{&candidates[0].Ask, &candidates[1].Ask}, client.dealAsks)
+	})
+
+	t.Run("all candidates reject", func(t *testing.T) {
+		client := &fakeClient{outcomes: map[address.Address]storagemarket.ClientDeal{
+			first:  rejected,
+			second: rejected,
+			third:  rejected,
+		}}
+		_, err := providerselect.NewSelector(client).ProposeToBest(ctx, candidates, proposal)
+		var allRejected *providerselect.AllRejectedError
+		require.True(t, xerrors.As(err, &allRejected))
+		require.Len(t, allRejected.Rejections, 3)
+		var reason *storagemarket.DealRejectedError
+		require.True(t, xerrors.As(allRejected.Rejections[0], &reason))
+		require.Equal(t, storagemarket.RejectionPriceTooLow, reason.Reason)
+	})
+
+	t.Run("other failures stop the search", func(t *testing.T) {
+		client := &fakeClient{outcomes: map[address.Address]storagemarket.ClientDeal{
+			first:  failed,
+			second: accepted,
+		}}
+		_, err := providerselect.NewSelector(client).ProposeToBest(ctx, candidates, proposal)
+		require.Error(t, err)
+		require.Equal(t, []address.Address{first}, client.proposed)
+	})
+
+	t.Run("no candidates", func(t *testing.T) {
+		_, err := providerselect.NewSelector(&fakeClient{}).ProposeToBest(ctx, nil, proposal)
+		require.Error(t, err)
+	})
+}
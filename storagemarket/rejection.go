@@ -0,0 +1,114 @@
+package storagemarket
+
+// RejectionReason is a machine readable reason a provider rejected a storage deal
+type RejectionReason uint64
+
+const (
+	// RejectionNone means the deal was not rejected
+	RejectionNone RejectionReason = iota
+
+	// RejectionUnspecified means the provider rejected the deal without saying why
+	RejectionUnspecified
+
+	// RejectionInvalidSignature means the client signature on the proposal did not verify
+	RejectionInvalidSignature
+
+	// RejectionWrongProvider means the deal was proposed to a different provider
+	RejectionWrongProvider
+
+	// RejectionInvalidLabels means the deal labels were malformed or too large
+	RejectionInvalidLabels
+
+	// RejectionStartEpochTooSoon means the deal would start before the provider could seal it
+	RejectionStartEpochTooSoon
+
+	// RejectionNoAsk means the provider is not taking deals because it has no ask
+	RejectionNoAsk
+
+	// RejectionCollateralTooLow means the provider collateral is below the ask minimum
+	RejectionCollateralTooLow
+
+	// RejectionCollateralTooHigh means the provider collateral is above the ask maximum
+	RejectionCollateralTooHigh
+
+	// RejectionPriceTooLow means the price per epoch is below the asking price
+	RejectionPriceTooLow
+
+	// RejectionPieceTooSmall means the piece is smaller than the ask minimum
+	RejectionPieceTooSmall
+
+	// RejectionPieceTooLarge means the piece is larger than the ask maximum
+	RejectionPieceTooLarge
+
+	// RejectionDurationTooShort means the deal is shorter than the ask minimum
+	RejectionDurationTooShort
+
+	// RejectionDurationTooLong means the deal is longer than the ask maximum
+	RejectionDurationTooLong
+
+	// RejectionClientNotVerified means a verified deal was proposed by a client that is not verified
+	RejectionClientNotVerified
+
+	// RejectionDataCapTooLow means the client does not have enough datacap for a verified deal
+	RejectionDataCapTooLow
+
+	// RejectionInsufficientFunds means the client does not have enough market funds for the deal
+	RejectionInsufficientFunds
+
+	// RejectionDeclined means the provider's deal decision logic declined the deal
+	RejectionDeclined
+
+	// RejectionNoStagingSpace means the provider has no room to stage the deal data
+	RejectionNoStagingSpace
+
+	// RejectionPieceCIDMismatch means the transferred data does not match the proposed piece CID
+	RejectionPieceCIDMismatch
+)
+
+// RejectionReasons maps rejection reason codes to string names
+var RejectionReasons = map[RejectionReason]string{
+	RejectionNone:              "RejectionNone",
+	RejectionUnspecified:       "RejectionUnspecified",
+	RejectionInvalidSignature:  "RejectionInvalidSignature",
+	RejectionWrongProvider:     "RejectionWrongProvider",
+	RejectionInvalidLabels:     "RejectionInvalidLabels",
+	RejectionStartEpochTooSoon: "RejectionStartEpochTooSoon",
+	RejectionNoAsk:             "RejectionNoAsk",
+	RejectionCollateralTooLow:  "RejectionCollateralTooLow",
+	RejectionCollateralTooHigh: "RejectionCollateralTooHigh",
+	RejectionPriceTooLow:       "RejectionPriceTooLow",
+	RejectionPieceTooSmall:     "RejectionPieceTooSmall",
+	RejectionPieceTooLarge:     "RejectionPieceTooLarge",
+	RejectionDurationTooShort:  "RejectionDurationTooShort",
+	RejectionDurationTooLong:   "RejectionDurationTooLong",
+	RejectionClientNotVerified: "RejectionClientNotVerified",
+	RejectionDataCapTooLow:     "RejectionDataCapTooLow",
+	RejectionInsufficientFunds: "RejectionInsufficientFunds",
+	RejectionDeclined:          "RejectionDeclined",
+	RejectionNoStagingSpace:    "RejectionNoStagingSpace",
+	RejectionPieceCIDMismatch:  "RejectionPieceCIDMismatch",
+}
+
+// DealRejectedError is the error for a storage deal the provider rejected. Match on it
+// with errors.As to find out why the deal was rejected
+type DealRejectedError struct {
+	Reason RejectionReason
+
+	// Details is the provider's explanation of the rejection
+	Details string
+}
+
+func (e *DealRejectedError) Error() string {
+	if e.Details == "" {
+		return RejectionReasons[e.Reason]
+	}
+	return e.Details
+}
+
+// RejectionError returns a *DealRejectedError if the provider rejected the deal, and nil otherwise
+func (d ClientDeal) RejectionError() error {
+	if d.RejectionReason == RejectionNone {
+		return nil
+	}
+	return &DealRejectedError{Reason: d.RejectionReason, Details: d.Message}
+}
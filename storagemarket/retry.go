@@ -0,0 +1,89 @@
+package storagemarket
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// RetryPolicy decides whether a deal retries a call to its node that failed, and how long
+// it waits before doing so. The zero value never retries
+type RetryPolicy struct {
+	// MaxRetries is the number of times failed node calls are retried over the life of a deal
+	MaxRetries uint64
+	// InitialBackoff is the delay before the first retry. It doubles after each retry
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between retries, if set
+	MaxBackoff time.Duration
+	// IsTransient reports whether a call that failed with the given error may succeed if
+	// retried. If nil, IsTransientError is used
+	IsTransient func(err error) bool
+}
+
+// DefaultRetryPolicy retries transient node errors a few times over several minutes
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries:     5,
+	InitialBackoff: 10 * time.Second,
+	MaxBackoff:     5 * time.Minute,
+}
+
+// Backoff returns how long to wait before retrying a node call that failed with the given
+// error, for a deal that has already been retried the given number of times. It returns false
+// if the call should not be retried
+func (rp RetryPolicy) Backoff(retries uint64, err error) (time.Duration, bool) {
+	if retries >= rp.MaxRetries {
+		return 0, false
+	}
+	isTransient := rp.IsTransient
+	if isTransient == nil {
+		isTransient = IsTransientError
+	}
+	if !isTransient(err) {
+		return 0, false
+	}
+
+	delay := rp.InitialBackoff
+	for i := uint64(0); i < retries; i++ {
+		delay *= 2
+		if rp.MaxBackoff > 0 && delay >= rp.MaxBackoff {
+			return rp.MaxBackoff, true
+		}
+	}
+	if rp.MaxBackoff > 0 && delay > rp.MaxBackoff {
+		delay = rp.MaxBackoff
+	}
+	return delay, true
+}
+
+type transientError struct {
+	err error
+}
+
+func (e transientError) Error() string {
+	return e.err.Error()
+}
+
+func (e transientError) Unwrap() error {
+	return e.err
+}
+
+// NewTransientError marks an error returned by a node as temporary, so calls that fail
+// with it are retried according to the deal's retry policy
+func NewTransientError(err error) error {
+	return transientError{err}
+}
+
+// IsTransientError returns true for errors marked with NewTransientError, context deadlines
+// and other timeouts, such as those of a node RPC
+func IsTransientError(err error) bool {
+	var te transientError
+	if xerrors.As(err, &te) {
+		return true
+	}
+	if xerrors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var timeout interface{ Timeout() bool }
+	return xerrors.As(err, &timeout) && timeout.Timeout()
+}
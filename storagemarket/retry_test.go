@@ -0,0 +1,79 @@
+package storagemarket_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string { return "i/o timeout" }
+func (timeoutError) Timeout() bool { return true }
+
+func TestIsTransientError(t *testing.T) {
+	tests := map[string]struct {
+		err       error
+		transient bool
+	}{
+		"plain error":      {err: errors.New("actor not found"), transient: false},
+		"marked transient": {err: storagemarket.NewTransientError(errors.New("connection refused")), transient: true},
+		"wrapped transient": {
+			err:       xerrors.Errorf("ensuring funds: %w", storagemarket.NewTransientError(errors.New("connection refused"))),
+			transient: true,
+		},
+		"deadline exceeded": {err: xerrors.Errorf("calling node: %w", context.DeadlineExceeded), transient: true},
+		"timeout":           {err: xerrors.Errorf("calling node: %w", timeoutError{}), transient: true},
+		"cancelled":         {err: context.Canceled, transient: false},
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, data.transient, storagemarket.IsTransientError(data.err))
+		})
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	transient := storagemarket.NewTransientError(errors.New("timeout"))
+	policy := storagemarket.RetryPolicy{
+		MaxRetries:     4,
+		InitialBackoff: time.Second,
+		MaxBackoff:     5 * time.Second,
+	}
+
+	tests := map[string]struct {
+		policy  storagemarket.RetryPolicy
+		retries uint64
+		err     error
+		delay   time.Duration
+		retry   bool
+	}{
+		"first retry":     {policy: policy, retries: 0, err: transient, delay: time.Second, retry: true},
+		"doubles":         {policy: policy, retries: 2, err: transient, delay: 4 * time.Second, retry: true},
+		"capped":          {policy: policy, retries: 3, err: transient, delay: 5 * time.Second, retry: true},
+		"out of retries":  {policy: policy, retries: 4, err: transient, retry: false},
+		"permanent error": {policy: policy, retries: 0, err: errors.New("invalid params"), retry: false},
+		"zero value":      {policy: storagemarket.RetryPolicy{}, retries: 0, err: transient, retry: false},
+		"custom classification": {
+			policy: storagemarket.RetryPolicy{
+				MaxRetries:  1,
+				IsTransient: func(error) bool { return true },
+			},
+			err:   errors.New("invalid params"),
+			retry: true,
+		},
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			delay, retry := data.policy.Backoff(data.retries, data.err)
+			require.Equal(t, data.retry, retry)
+			require.Equal(t, data.delay, delay)
+		})
+	}
+}
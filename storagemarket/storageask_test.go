@@ -0,0 +1,103 @@
+package storagemarket_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+)
+
+func TestStorageAskCBOR(t *testing.T) {
+	baseAsk := storagemarket.StorageAsk{
+		Price:        abi.NewTokenAmount(1000),
+		MinPieceSize: 256,
+		MaxPieceSize: 1 << 20,
+		Miner:        address.TestAddress2,
+		Timestamp:    10,
+		Expiry:       20,
+		SeqNo:        1,
+	}
+
+	t.Run("ask without collateral bounds uses original encoding", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		require.NoError(t, baseAsk.MarshalCBOR(buf))
+		require.Equal(t, byte(0x87), buf.Bytes()[0])
+
+		var out storagemarket.StorageAsk
+		require.NoError(t, out.UnmarshalCBOR(buf))
+		require.True(t, out.MinProviderCollateral.Nil())
+		require.True(t, out.MaxProviderCollateral.Nil())
+		require.Equal(t, baseAsk.Price, out.Price)
+		require.Equal(t, baseAsk.SeqNo, out.SeqNo)
+	})
+
+	t.Run("ask with collateral bounds round trips", func(t *testing.T) {
+		ask := baseAsk
+		storagemarket.MinProviderCollateral(abi.NewTokenAmount(5))(&ask)
+		buf := new(bytes.Buffer)
+		require.NoError(t, ask.MarshalCBOR(buf))
+		require.Equal(t, byte(0x89), buf.Bytes()[0])
+
+		var out storagemarket.StorageAsk
+		require.NoError(t, out.UnmarshalCBOR(buf))
+		require.Equal(t, abi.NewTokenAmount(5), out.MinProviderCollateral)
+		_, max := out.ProviderCollateralBounds(1 << 20)
+		require.True(t, max.Nil())
+	})
+
+	t.Run("ask with duration bounds round trips", func(t *testing.T) {
+		ask := baseAsk
+		storagemarket.MaxDuration(1000)(&ask)
+		buf := new(bytes.Buffer)
+		require.NoError(t, ask.MarshalCBOR(buf))
+		require.Equal(t, byte(0x8b), buf.Bytes()[0])
+
+		var out storagemarket.StorageAsk
+		require.NoError(t, out.UnmarshalCBOR(buf))
+		require.Equal(t, abi.ChainEpoch(1000), out.MaxDuration)
+		require.True(t, out.VerifiedPrice.Nil())
+		require.Equal(t, baseAsk.Price, out.DealPrice(true))
+	})
+
+	t.Run("ask with wrong number of fields fails", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		require.NoError(t, baseAsk.MarshalCBOR(buf))
+		encoded := buf.Bytes()
+		encoded[0] = 0x88
+
+		var out storagemarket.StorageAsk
+		require.Error(t, out.UnmarshalCBOR(bytes.NewReader(encoded)))
+	})
+
+	t.Run("ask with verified price round trips", func(t *testing.T) {
+		ask := baseAsk
+		storagemarket.VerifiedPrice(abi.NewTokenAmount(100))(&ask)
+		buf := new(bytes.Buffer)
+		require.NoError(t, ask.MarshalCBOR(buf))
+		require.Equal(t, byte(0x8c), buf.Bytes()[0])
+
+		var out storagemarket.StorageAsk
+		require.NoError(t, out.UnmarshalCBOR(buf))
+		require.Equal(t, abi.NewTokenAmount(100), out.VerifiedPrice)
+		min, max := out.ProviderCollateralBounds(1 << 20)
+		require.True(t, min.Nil())
+		require.True(t, max.Nil())
+		require.Equal(t, abi.ChainEpoch(0), out.MinDuration)
+		require.Equal(t, abi.NewTokenAmount(100), out.DealPrice(true))
+		require.Equal(t, baseAsk.Price, out.DealPrice(false))
+		require.Equal(t, baseAsk.Price, baseAsk.DealPrice(true))
+	})
+
+	t.Run("collateral bounds scale with piece size", func(t *testing.T) {
+		ask := baseAsk
+		storagemarket.MinProviderCollateral(abi.NewTokenAmount(1 << 30))(&ask)
+		storagemarket.MaxProviderCollateral(abi.NewTokenAmount(2 << 30))(&ask)
+		min, max := ask.ProviderCollateralBounds(1 << 20)
+		require.Equal(t, abi.NewTokenAmount(1<<20), min)
+		require.Equal(t, abi.NewTokenAmount(2<<20), max)
+	})
+}
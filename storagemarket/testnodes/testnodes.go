@@ -3,6 +3,7 @@ package testnodes
 import (
 	"context"
 	"io"
+	"sync"
 
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/specs-actors/actors/abi"
@@ -22,6 +23,7 @@ import (
 // StorageMarketState represents a state for the storage market that can be inspected
 // - methods on the provider nodes will affect this state
 type StorageMarketState struct {
+	epochLk      sync.RWMutex
 	TipSetToken  shared.TipSetToken
 	Epoch        abi.ChainEpoch
 	DealId       abi.DealID
@@ -68,9 +70,18 @@ func (sma *StorageMarketState) Deals(addr address.Address) []storagemarket.Stora
 
 // StateKey returns a state key with the storage market states set Epoch
 func (sma *StorageMarketState) StateKey() (shared.TipSetToken, abi.ChainEpoch) {
+	sma.epochLk.RLock()
+	defer sma.epochLk.RUnlock()
 	return sma.TipSetToken, sma.Epoch
 }
 
+// SetEpoch moves the chain to the given epoch while nodes using the state may be reading it
+func (sma *StorageMarketState) SetEpoch(epoch abi.ChainEpoch) {
+	sma.epochLk.Lock()
+	defer sma.epochLk.Unlock()
+	sma.Epoch = epoch
+}
+
 // AddDeal adds a deal to the current state of the storage market
 func (sma *StorageMarketState) AddDeal(deal storagemarket.StorageDeal) (shared.TipSetToken, abi.ChainEpoch) {
 	for _, addr := range []address.Address{deal.Client, deal.Provider} {
@@ -98,6 +109,11 @@ type FakeCommonNode struct {
 	WaitForMessageExitCode  exitcode.ExitCode
 	WaitForMessageRetBytes  []byte
 	WaitForMessageNodeError error
+
+	OnDealExpiredOrSlashedError error
+	DealExpired                 bool
+	DealSlashedEpoch            abi.ChainEpoch
+	DealCompletionError         error
 }
 
 // GetChainHead returns the state id in the storage market state
@@ -141,6 +157,21 @@ func (n *FakeCommonNode) WaitForMessage(ctx context.Context, mcid cid.Cid, onCom
 	return onCompletion(n.WaitForMessageExitCode, n.WaitForMessageRetBytes, n.WaitForMessageNodeError)
 }
 
+// OnDealExpiredOrSlashed calls onDealExpired or onDealSlashed immediately if the deal is set
+// to have expired or been slashed, and otherwise leaves the deal active
+func (n *FakeCommonNode) OnDealExpiredOrSlashed(ctx context.Context, dealID abi.DealID, onDealExpired storagemarket.DealExpiredCallback, onDealSlashed storagemarket.DealSlashedCallback) error {
+	if n.OnDealExpiredOrSlashedError != nil {
+		return n.OnDealExpiredOrSlashedError
+	}
+
+	if n.DealExpired {
+		onDealExpired(n.DealCompletionError)
+	} else if n.DealSlashedEpoch != 0 {
+		onDealSlashed(n.DealSlashedEpoch, n.DealCompletionError)
+	}
+	return nil
+}
+
 // GetBalance returns the funds in the storage market state
 func (n *FakeCommonNode) GetBalance(ctx context.Context, addr address.Address, tok shared.TipSetToken) (storagemarket.Balance, error) {
 	if n.GetBalanceError == nil {
@@ -163,6 +194,7 @@ type FakeClientNode struct {
 	ValidatePublishedError  error
 	DealCommittedSyncError  error
 	DealCommittedAsyncError error
+	SignBytesError          error
 }
 
 // ListClientDeals just returns the deals in the storage market state
@@ -207,6 +239,14 @@ func (n *FakeClientNode) ValidateAskSignature(ctx context.Context, ask *storagem
 	return n.ValidationError == nil, n.ValidationError
 }
 
+// SignBytes simulates signing data by returning a test signature
+func (n *FakeClientNode) SignBytes(ctx context.Context, signer address.Address, b []byte) (*crypto.Signature, error) {
+	if n.SignBytesError == nil {
+		return shared_testutil.MakeTestSignature(), nil
+	}
+	return nil, n.SignBytesError
+}
+
 var _ storagemarket.StorageClientNode = (*FakeClientNode)(nil)
 
 // FakeProviderNode implements functions specific to the StorageProviderNode
@@ -218,22 +258,33 @@ type FakeProviderNode struct {
 	PieceSectorID                       uint64
 	PublishDealID                       abi.DealID
 	PublishDealsError                   error
+	PublishDealsCalls                   [][]storagemarket.MinerDeal
 	OnDealCompleteError                 error
 	LocatePieceForDealWithinSectorError error
 	DealCommittedSyncError              error
 	DealCommittedAsyncError             error
 	SignBytesError                      error
+	DataCap                             *abi.StoragePower
+	GetDataCapError                     error
+
+	publishLk sync.Mutex
 }
 
-// PublishDeals simulates publishing a deal by adding it to the storage market state
-func (n *FakeProviderNode) PublishDeals(ctx context.Context, deal storagemarket.MinerDeal) (cid.Cid, error) {
+// PublishDeals simulates publishing deals by adding them to the storage market state
+func (n *FakeProviderNode) PublishDeals(ctx context.Context, deals []storagemarket.MinerDeal) (cid.Cid, error) {
+	n.publishLk.Lock()
+	defer n.publishLk.Unlock()
+
+	n.PublishDealsCalls = append(n.PublishDealsCalls, deals)
 	if n.PublishDealsError == nil {
-		sd := storagemarket.StorageDeal{
-			DealProposal: deal.Proposal,
-			DealState:    market.DealState{},
-		}
+		for _, deal := range deals {
+			sd := storagemarket.StorageDeal{
+				DealProposal: deal.Proposal,
+				DealState:    market.DealState{},
+			}
 
-		n.SMState.AddDeal(sd)
+			n.SMState.AddDeal(sd)
+		}
 
 		return shared_testutil.GenerateCids(1)[0], nil
 	}
@@ -282,4 +333,12 @@ func (n *FakeProviderNode) LocatePieceForDealWithinSector(ctx context.Context, d
 	return 0, 0, 0, n.LocatePieceForDealWithinSectorError
 }
 
+// GetDataCap returns the datacap specified by DataCap
+func (n *FakeProviderNode) GetDataCap(ctx context.Context, addr address.Address, tok shared.TipSetToken) (*abi.StoragePower, error) {
+	if n.GetDataCapError == nil {
+		return n.DataCap, nil
+	}
+	return nil, n.GetDataCapError
+}
+
 var _ storagemarket.StorageProviderNode = (*FakeProviderNode)(nil)
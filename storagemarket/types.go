@@ -6,6 +6,7 @@ import (
 
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
 	"github.com/filecoin-project/specs-actors/actors/builtin/market"
 	"github.com/filecoin-project/specs-actors/actors/crypto"
 	"github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
@@ -17,10 +18,20 @@ import (
 	"github.com/filecoin-project/go-fil-markets/shared"
 )
 
-//go:generate cbor-gen-for ClientDeal MinerDeal Balance SignedStorageAsk StorageAsk StorageDeal DataRef
+//go:generate cbor-gen-for ClientDealTuple MinerDealTuple Balance SignedStorageAsk StorageDeal DataRef ProviderDealState DealGroup StorageAskTuple
+
+// DealProtocolID is the newest version of the storage deal protocol
+const DealProtocolID = DealProtocolID110
+
+// DealProtocolID110 is the version of the storage deal protocol that sends deal labels
+// with proposals, and lets clients cancel deals they proposed
+const DealProtocolID110 = "/fil/storage/mk/1.1.0"
+
+// DealProtocolID101 is the original version of the storage deal protocol
+const DealProtocolID101 = "/fil/storage/mk/1.0.1"
 
-const DealProtocolID = "/fil/storage/mk/1.0.1"
 const AskProtocolID = "/fil/storage/ask/1.0.1"
+const DealStatusProtocolID = "/fil/storage/status/1.0.1"
 
 type Balance struct {
 	Locked    abi.TokenAmount
@@ -57,6 +68,13 @@ const (
 	StorageDealPublishing            // Waiting for deal to appear on chain
 	StorageDealError                 // deal failed with an unexpected error
 	StorageDealCompleted             // on provider side, indicates deal is active and info for retrieval is recorded
+	StorageDealCheckForAcceptance    // Client lost its stream to the provider and is querying the provider for the deal state
+	StorageDealCancelled             // deal was cancelled before it was published
+	StorageDealTransferQueued        // Waiting for a free data transfer slot before requesting data
+	StorageDealVerifyDataQueued      // Waiting for a free slot to generate the piece commitment for transferred data
+	StorageDealExpired               // deal reached its end epoch and is no longer stored
+	StorageDealSlashed               // deal was slashed because the provider stopped proving its sector
+	StorageDealCancelling            // stopping the transfer and deleting the data of a cancelled deal
 )
 
 // DealStates maps StorageDealStatus codes to string names
@@ -85,6 +103,13 @@ var DealStates = map[StorageDealStatus]string{
 	StorageDealPublishing:            "StorageDealPublishing",
 	StorageDealError:                 "StorageDealError",
 	StorageDealCompleted:             "StorageDealCompleted",
+	StorageDealCheckForAcceptance:    "StorageDealCheckForAcceptance",
+	StorageDealCancelled:             "StorageDealCancelled",
+	StorageDealTransferQueued:        "StorageDealTransferQueued",
+	StorageDealVerifyDataQueued:      "StorageDealVerifyDataQueued",
+	StorageDealExpired:               "StorageDealExpired",
+	StorageDealSlashed:               "StorageDealSlashed",
+	StorageDealCancelling:            "StorageDealCancelling",
 }
 
 func init() {
@@ -109,6 +134,19 @@ type StorageAsk struct {
 	Timestamp    abi.ChainEpoch
 	Expiry       abi.ChainEpoch
 	SeqNo        uint64
+
+	// Provider collateral per GiB the provider requires for a deal. Zero or unset means
+	// unbounded; asks created before collateral bounds were introduced leave these unset
+	MinProviderCollateral abi.TokenAmount
+	MaxProviderCollateral abi.TokenAmount
+
+	// Range of deal durations the ask applies to. Zero means unbounded
+	MinDuration abi.ChainEpoch
+	MaxDuration abi.ChainEpoch
+
+	// Price per GiB / Epoch for deals from verified clients. Unset means verified
+	// deals are charged the regular price
+	VerifiedPrice abi.TokenAmount
 }
 
 // StorageAskOption allows custom configuration of a storage ask
@@ -126,8 +164,120 @@ func MaxPieceSize(maxPieceSize abi.PaddedPieceSize) StorageAskOption {
 	}
 }
 
+// MinProviderCollateral sets the minimum provider collateral per GiB the provider requires for a deal
+func MinProviderCollateral(collateral abi.TokenAmount) StorageAskOption {
+	return func(sa *StorageAsk) {
+		sa.MinProviderCollateral = collateral
+	}
+}
+
+// MaxProviderCollateral sets the maximum provider collateral per GiB the provider will put up for a deal
+func MaxProviderCollateral(collateral abi.TokenAmount) StorageAskOption {
+	return func(sa *StorageAsk) {
+		sa.MaxProviderCollateral = collateral
+	}
+}
+
+// MinDuration sets the minimum deal duration the ask applies to
+func MinDuration(minDuration abi.ChainEpoch) StorageAskOption {
+	return func(sa *StorageAsk) {
+		sa.MinDuration = minDuration
+	}
+}
+
+// MaxDuration sets the maximum deal duration the ask applies to
+func MaxDuration(maxDuration abi.ChainEpoch) StorageAskOption {
+	return func(sa *StorageAsk) {
+		sa.MaxDuration = maxDuration
+	}
+}
+
+// VerifiedPrice sets the price per GiB / Epoch for deals from verified clients
+func VerifiedPrice(price abi.TokenAmount) StorageAskOption {
+	return func(sa *StorageAsk) {
+		sa.VerifiedPrice = price
+	}
+}
+
+// DealPrice returns the price per GiB / Epoch the ask charges for a deal
+func (sa StorageAsk) DealPrice(verifiedDeal bool) abi.TokenAmount {
+	if verifiedDeal && !sa.VerifiedPrice.Nil() {
+		return sa.VerifiedPrice
+	}
+	return sa.Price
+}
+
+// Covers returns true if a deal with the given piece size and duration falls within
+// the piece size and duration ranges of the ask
+func (sa StorageAsk) Covers(pieceSize abi.PaddedPieceSize, duration abi.ChainEpoch) bool {
+	if pieceSize < sa.MinPieceSize || pieceSize > sa.MaxPieceSize {
+		return false
+	}
+	if sa.MinDuration != 0 && duration < sa.MinDuration {
+		return false
+	}
+	if sa.MaxDuration != 0 && duration > sa.MaxDuration {
+		return false
+	}
+	return true
+}
+
+// SameTier returns true if both asks cover the same piece size and duration ranges
+func (sa StorageAsk) SameTier(other StorageAsk) bool {
+	return sa.MinPieceSize == other.MinPieceSize && sa.MaxPieceSize == other.MaxPieceSize &&
+		sa.MinDuration == other.MinDuration && sa.MaxDuration == other.MaxDuration
+}
+
+// SelectAsk returns the cheapest of the given asks that covers a deal with the given
+// piece size and duration, or false if none of them do
+func SelectAsk(asks []StorageAsk, pieceSize abi.PaddedPieceSize, duration abi.ChainEpoch) (StorageAsk, bool) {
+	var selected StorageAsk
+	found := false
+	for _, ask := range asks {
+		if !ask.Covers(pieceSize, duration) {
+			continue
+		}
+		if !found || ask.Price.LessThan(selected.Price) {
+			selected = ask
+			found = true
+		}
+	}
+	return selected, found
+}
+
+// ProviderCollateralBounds returns the minimum and maximum provider collateral the ask allows
+// for a deal with the given piece size. A bound the ask leaves unset or at zero is returned as
+// a nil amount
+func (sa StorageAsk) ProviderCollateralBounds(pieceSize abi.PaddedPieceSize) (min abi.TokenAmount, max abi.TokenAmount) {
+	forSize := func(perGiB abi.TokenAmount) abi.TokenAmount {
+		if perGiB.Nil() || perGiB.IsZero() {
+			return abi.TokenAmount{}
+		}
+		return big.Div(big.Mul(perGiB, abi.NewTokenAmount(int64(pieceSize))), abi.NewTokenAmount(1<<30))
+	}
+	return forSize(sa.MinProviderCollateral), forSize(sa.MaxProviderCollateral)
+}
+
 var StorageAskUndefined = StorageAsk{}
 
+// storageAskLengths are the field counts of the original ask and of the asks that added
+// collateral bounds and duration bounds, before the verified price. Asks that leave the
+// later terms unset keep a shorter encoding, so peers that predate those terms can read them
+var storageAskLengths = shared.TupleLengths{7, 9, 11}
+
+// StorageAskTuple is the encoding of a StorageAsk with every field
+type StorageAskTuple StorageAsk
+
+// MarshalCBOR encodes the ask with the fewest fields that hold its terms
+func (t *StorageAsk) MarshalCBOR(w io.Writer) error {
+	return storageAskLengths.Marshal(w, (*StorageAskTuple)(t))
+}
+
+// UnmarshalCBOR decodes an ask encoded with any of its field counts
+func (t *StorageAsk) UnmarshalCBOR(r io.Reader) error {
+	return storageAskLengths.Unmarshal(r, (*StorageAskTuple)(t))
+}
+
 type MinerDeal struct {
 	market.ClientDealProposal
 	ProposalCid      cid.Cid
@@ -143,9 +293,51 @@ type MinerDeal struct {
 
 	Ref *DataRef
 
-	DealID abi.DealID
+	DealID       abi.DealID
+	PublishIndex uint64
+
+	// Retries counts the node calls retried for the deal after transient errors
+	Retries uint64
+
+	// SlashEpoch is the epoch at which the deal was slashed, if it was
+	SlashEpoch abi.ChainEpoch
+
+	// Labels are the labels the client attached to the deal
+	Labels DealLabels
+
+	// RejectionReason is why the provider rejected the deal, if it did
+	RejectionReason RejectionReason
+}
+
+// minerDealLengths is the field count of deals stored before fields were added to the end
+// of MinerDeal, so those deals can still be read
+var minerDealLengths = shared.TupleLengths{13}
+
+// MinerDealTuple is the encoding of a MinerDeal with every field
+type MinerDealTuple MinerDeal
+
+// MarshalCBOR encodes the deal with the fewest fields that hold its state
+func (t *MinerDeal) MarshalCBOR(w io.Writer) error {
+	return minerDealLengths.Marshal(w, (*MinerDealTuple)(t))
 }
 
+// UnmarshalCBOR decodes a deal encoded with any of its field counts
+func (t *MinerDeal) UnmarshalCBOR(r io.Reader) error {
+	return minerDealLengths.Unmarshal(r, (*MinerDealTuple)(t))
+}
+
+// ProviderDealState is the state of a deal on the provider, as reported to
+// the client through the deal status protocol
+type ProviderDealState struct {
+	State      StorageDealStatus
+	Message    string
+	Proposal   cid.Cid
+	PublishCid *cid.Cid
+	DealID     abi.DealID
+}
+
+var ProviderDealStateUndefined = ProviderDealState{}
+
 type ProviderEvent uint64
 
 const (
@@ -236,6 +428,52 @@ const (
 
 	// ProviderEventFailed indicates a deal has failed and should no longer be processed
 	ProviderEventFailed
+
+	// ProviderEventRestart is used to resume the deal after a state machine shutdown
+	ProviderEventRestart
+
+	// ProviderEventRestartFailed happens when a deal cannot be resumed after a state machine
+	// shutdown, because it depended on a connection to the client that was lost
+	ProviderEventRestartFailed
+
+	// ProviderEventCancelled happens when the provider cancels a deal before it is published
+	ProviderEventCancelled
+
+	// ProviderEventClientCancelled happens when the client cancels a deal before it is published
+	ProviderEventClientCancelled
+
+	// ProviderEventNodeErrorRetry happens when a node call fails with a transient error, and
+	// the current state is entered again to retry it
+	ProviderEventNodeErrorRetry
+
+	// ProviderEventTransferQueued happens when a deal is accepted, but all data transfer
+	// slots are taken
+	ProviderEventTransferQueued
+
+	// ProviderEventVerifyDataQueued happens when data for a deal has been transferred, but all
+	// slots for generating piece commitments are taken
+	ProviderEventVerifyDataQueued
+
+	// ProviderEventVerifyDataSlotAcquired happens when a deal waiting to verify its data gets a slot
+	ProviderEventVerifyDataSlotAcquired
+
+	// ProviderEventDealExpired happens when a completed deal reaches its end epoch
+	ProviderEventDealExpired
+
+	// ProviderEventDealSlashed happens when a completed deal is slashed on chain
+	ProviderEventDealSlashed
+
+	// ProviderEventDealCompletionFailed happens when a completed deal can no longer be watched for
+	// expiry or slashing
+	ProviderEventDealCompletionFailed
+
+	// ProviderEventTransferStartTimedOut happens when the client does not start sending the
+	// data for an accepted deal in time
+	ProviderEventTransferStartTimedOut
+
+	// ProviderEventCancelCompleted happens when the transfer of a cancelled deal has been
+	// stopped and its data deleted
+	ProviderEventCancelCompleted
 )
 
 // ProviderEvents maps provider event codes to string names
@@ -269,6 +507,19 @@ var ProviderEvents = map[ProviderEvent]string{
 	ProviderEventReadMetadataErrored:    "ProviderEventReadMetadataErrored",
 	ProviderEventDealCompleted:          "ProviderEventDealCompleted",
 	ProviderEventFailed:                 "ProviderEventFailed",
+	ProviderEventRestart:                "ProviderEventRestart",
+	ProviderEventRestartFailed:          "ProviderEventRestartFailed",
+	ProviderEventCancelled:              "ProviderEventCancelled",
+	ProviderEventClientCancelled:        "ProviderEventClientCancelled",
+	ProviderEventNodeErrorRetry:         "ProviderEventNodeErrorRetry",
+	ProviderEventTransferQueued:         "ProviderEventTransferQueued",
+	ProviderEventVerifyDataQueued:       "ProviderEventVerifyDataQueued",
+	ProviderEventVerifyDataSlotAcquired: "ProviderEventVerifyDataSlotAcquired",
+	ProviderEventDealExpired:            "ProviderEventDealExpired",
+	ProviderEventDealSlashed:            "ProviderEventDealSlashed",
+	ProviderEventDealCompletionFailed:   "ProviderEventDealCompletionFailed",
+	ProviderEventTransferStartTimedOut:  "ProviderEventTransferStartTimedOut",
+	ProviderEventCancelCompleted:        "ProviderEventCancelCompleted",
 }
 
 type ClientDeal struct {
@@ -283,6 +534,35 @@ type ClientDeal struct {
 	Message          string
 	PublishMessage   *cid.Cid
 	ConnectionClosed bool
+
+	// Retries counts the node calls retried for the deal after transient errors
+	Retries uint64
+
+	// SlashEpoch is the epoch at which the deal was slashed, if it was
+	SlashEpoch abi.ChainEpoch
+
+	// Labels are the labels the client attached to the deal
+	Labels DealLabels
+
+	// RejectionReason is why the provider rejected the deal, if it did
+	RejectionReason RejectionReason
+}
+
+// clientDealLengths is the field count of deals stored before fields were added to the end
+// of ClientDeal, so those deals can still be read
+var clientDealLengths = shared.TupleLengths{11}
+
+// ClientDealTuple is the encoding of a ClientDeal with every field
+type ClientDealTuple ClientDeal
+
+// MarshalCBOR encodes the deal with the fewest fields that hold its state
+func (t *ClientDeal) MarshalCBOR(w io.Writer) error {
+	return clientDealLengths.Marshal(w, (*ClientDealTuple)(t))
+}
+
+// UnmarshalCBOR decodes a deal encoded with any of its field counts
+func (t *ClientDeal) UnmarshalCBOR(r io.Reader) error {
+	return clientDealLengths.Unmarshal(r, (*ClientDealTuple)(t))
 }
 
 type ClientEvent uint64
@@ -350,6 +630,38 @@ const (
 
 	// ClientEventFailed happens when a deal terminates in failure
 	ClientEventFailed
+
+	// ClientEventRestart is used to resume the deal after a state machine shutdown
+	ClientEventRestart
+
+	// ClientEventStreamLost happens when a deal is resumed after a state machine shutdown, but
+	// it depended on a stream to the provider that was lost
+	ClientEventStreamLost
+
+	// ClientEventWaitForDealState happens when the client is waiting to query the provider
+	// again for the state of a deal
+	ClientEventWaitForDealState
+
+	// ClientEventCancelled happens when the client cancels a deal before it is published
+	ClientEventCancelled
+
+	// ClientEventNodeErrorRetry happens when a node call fails with a transient error, and
+	// the current state is entered again to retry it
+	ClientEventNodeErrorRetry
+
+	// ClientEventDealExpired happens when an active deal reaches its end epoch
+	ClientEventDealExpired
+
+	// ClientEventDealSlashed happens when an active deal is slashed on chain
+	ClientEventDealSlashed
+
+	// ClientEventDealCompletionFailed happens when an active deal can no longer be watched for
+	// expiry or slashing
+	ClientEventDealCompletionFailed
+
+	// ClientEventDealAcceptanceExpired happens when the provider of a deal whose stream was lost
+	// has still not published it by the deal's start epoch
+	ClientEventDealAcceptanceExpired
 )
 
 // ClientEvents maps client event codes to string names
@@ -375,6 +687,15 @@ var ClientEvents = map[ClientEvent]string{
 	ClientEventDealActivationFailed:       "ClientEventDealActivationFailed",
 	ClientEventDealActivated:              "ClientEventDealActivated",
 	ClientEventFailed:                     "ClientEventFailed",
+	ClientEventRestart:                    "ClientEventRestart",
+	ClientEventStreamLost:                 "ClientEventStreamLost",
+	ClientEventWaitForDealState:           "ClientEventWaitForDealState",
+	ClientEventCancelled:                  "ClientEventCancelled",
+	ClientEventNodeErrorRetry:             "ClientEventNodeErrorRetry",
+	ClientEventDealExpired:                "ClientEventDealExpired",
+	ClientEventDealSlashed:                "ClientEventDealSlashed",
+	ClientEventDealCompletionFailed:       "ClientEventDealCompletionFailed",
+	ClientEventDealAcceptanceExpired:      "ClientEventDealAcceptanceExpired",
 }
 
 // StorageDeal is a local combination of a proposal and a current deal state
@@ -384,6 +705,8 @@ type StorageDeal struct {
 }
 
 type DealSectorCommittedCallback func(err error)
+type DealExpiredCallback func(err error)
+type DealSlashedCallback func(slashEpoch abi.ChainEpoch, err error)
 type FundsAddedCallback func(err error)
 type DealsPublishedCallback func(err error)
 type MessagePublishedCallback func(mcid cid.Cid, err error)
@@ -400,14 +723,28 @@ type StorageProvider interface {
 
 	AddAsk(price abi.TokenAmount, duration abi.ChainEpoch, options ...StorageAskOption) error
 
+	// AddAskTier adds an additional ask that applies to the piece size and duration ranges
+	// set by its options, replacing any existing tier with the same ranges
+	AddAskTier(price abi.TokenAmount, duration abi.ChainEpoch, options ...StorageAskOption) error
+
+	// AddMinerAsk sets the default ask for one of the miner actors served by this provider
+	AddMinerAsk(miner address.Address, price abi.TokenAmount, duration abi.ChainEpoch, options ...StorageAskOption) error
+
+	// AddMinerAskTier adds an ask tier for one of the miner actors served by this provider
+	AddMinerAskTier(miner address.Address, price abi.TokenAmount, duration abi.ChainEpoch, options ...StorageAskOption) error
+
 	// ListAsks lists current asks
 	ListAsks(addr address.Address) []*SignedStorageAsk
 
+	// MinerActors lists the miner actors this provider serves deals for
+	MinerActors() []address.Address
+
 	// ListDeals lists on-chain deals associated with this storage provider
 	ListDeals(ctx context.Context) ([]StorageDeal, error)
 
-	// ListLocalDeals lists deals processed by this storage provider
-	ListLocalDeals() ([]MinerDeal, error)
+	// ListLocalDeals lists deals processed by this storage provider, optionally only those
+	// whose labels are picked by all of the given selectors
+	ListLocalDeals(selectors ...LabelSelector) ([]MinerDeal, error)
 
 	// AddStorageCollateral adds storage collateral
 	AddStorageCollateral(ctx context.Context, amount abi.TokenAmount) error
@@ -415,8 +752,18 @@ type StorageProvider interface {
 	// GetStorageCollateral returns the current collateral balance
 	GetStorageCollateral(ctx context.Context) (Balance, error)
 
+	// AddMinerStorageCollateral adds storage collateral for one of the miner actors served by this provider
+	AddMinerStorageCollateral(ctx context.Context, miner address.Address, amount abi.TokenAmount) error
+
+	// GetMinerStorageCollateral returns the collateral balance of one of the miner actors served by this provider
+	GetMinerStorageCollateral(ctx context.Context, miner address.Address) (Balance, error)
+
 	ImportDataForDeal(ctx context.Context, propCid cid.Cid, data io.Reader) error
 
+	// CancelDeal aborts a deal that has not yet been published, cleaning up any data
+	// received for it and notifying the client if it is still connected
+	CancelDeal(ctx context.Context, propCid cid.Cid, reason string) error
+
 	SubscribeToEvents(subscriber ProviderSubscriber) shared.Unsubscribe
 }
 
@@ -444,8 +791,9 @@ type StorageProviderNode interface {
 
 	GetChainHead(ctx context.Context) (shared.TipSetToken, abi.ChainEpoch, error)
 
-	// Publishes deal on chain, returns the message cid, but does not wait for message to appear
-	PublishDeals(ctx context.Context, deal MinerDeal) (cid.Cid, error)
+	// Publishes deals on chain in a single message, returns the message cid, but does not wait for message to appear.
+	// The deal IDs in the message return value are in the same order as the given deals
+	PublishDeals(ctx context.Context, deals []MinerDeal) (cid.Cid, error)
 
 	// ListProviderDeals lists all deals associated with a storage provider
 	ListProviderDeals(ctx context.Context, addr address.Address, tok shared.TipSetToken) ([]StorageDeal, error)
@@ -461,7 +809,15 @@ type StorageProviderNode interface {
 
 	OnDealSectorCommitted(ctx context.Context, provider address.Address, dealID abi.DealID, cb DealSectorCommittedCallback) error
 
+	// OnDealExpiredOrSlashed calls onDealExpired when an active deal reaches its end epoch, or
+	// onDealSlashed when it is slashed, whichever happens first
+	OnDealExpiredOrSlashed(ctx context.Context, dealID abi.DealID, onDealExpired DealExpiredCallback, onDealSlashed DealSlashedCallback) error
+
 	LocatePieceForDealWithinSector(ctx context.Context, dealID abi.DealID, tok shared.TipSetToken) (sectorID uint64, offset uint64, length uint64, err error)
+
+	// GetDataCap returns the remaining datacap of a verified client, or nil if the
+	// address is not a verified client
+	GetDataCap(ctx context.Context, addr address.Address, tok shared.TipSetToken) (*abi.StoragePower, error)
 }
 
 // Node dependencies for a StorageClient
@@ -494,7 +850,14 @@ type StorageClientNode interface {
 
 	OnDealSectorCommitted(ctx context.Context, provider address.Address, dealID abi.DealID, cb DealSectorCommittedCallback) error
 
+	// OnDealExpiredOrSlashed calls onDealExpired when an active deal reaches its end epoch, or
+	// onDealSlashed when it is slashed, whichever happens first
+	OnDealExpiredOrSlashed(ctx context.Context, dealID abi.DealID, onDealExpired DealExpiredCallback, onDealSlashed DealSlashedCallback) error
+
 	ValidateAskSignature(ctx context.Context, ask *SignedStorageAsk, tok shared.TipSetToken) (bool, error)
+
+	// SignBytes signs the given data with the given client address
+	SignBytes(ctx context.Context, signer address.Address, b []byte) (*crypto.Signature, error)
 }
 
 type StorageClientProofs interface {
@@ -540,8 +903,9 @@ type StorageClient interface {
 	// ListDeals lists on-chain deals associated with this storage client
 	ListDeals(ctx context.Context, addr address.Address) ([]StorageDeal, error)
 
-	// ListLocalDeals lists deals initiated by this storage client
-	ListLocalDeals(ctx context.Context) ([]ClientDeal, error)
+	// ListLocalDeals lists deals initiated by this storage client, optionally only those
+	// whose labels are picked by all of the given selectors
+	ListLocalDeals(ctx context.Context, selectors ...LabelSelector) ([]ClientDeal, error)
 
 	// GetLocalDeal lists deals that are in progress or rejected
 	GetLocalDeal(ctx context.Context, cid cid.Cid) (ClientDeal, error)
@@ -549,11 +913,28 @@ type StorageClient interface {
 	// GetAsk returns the current ask for a storage provider
 	GetAsk(ctx context.Context, info StorageProviderInfo) (*SignedStorageAsk, error)
 
+	// GetAsks returns all current asks for a storage provider, starting with its default ask
+	GetAsks(ctx context.Context, info StorageProviderInfo) ([]*SignedStorageAsk, error)
+
+	// GetProviderDealState queries a provider for the current state of a client's deal
+	GetProviderDealState(ctx context.Context, proposalCid cid.Cid) (*ProviderDealState, error)
+
 	//// FindStorageOffers lists providers and queries them to find offers that satisfy some criteria based on price, duration, etc.
 	//FindStorageOffers(criteria AskCriteria, limit uint) []*StorageOffer
 
-	// ProposeStorageDeal initiates deal negotiation with a Storage Provider
-	ProposeStorageDeal(ctx context.Context, addr address.Address, info *StorageProviderInfo, data *DataRef, startEpoch abi.ChainEpoch, endEpoch abi.ChainEpoch, price abi.TokenAmount, collateral abi.TokenAmount, rt abi.RegisteredProof) (*ProposeStorageDealResult, error)
+	// ProposeStorageDeal initiates deal negotiation with a Storage Provider. A nil collateral
+	// proposes the minimum provider collateral the provider's ask requires
+	ProposeStorageDeal(ctx context.Context, addr address.Address, info *StorageProviderInfo, data *DataRef, startEpoch abi.ChainEpoch, endEpoch abi.ChainEpoch, price abi.TokenAmount, collateral abi.TokenAmount, rt abi.RegisteredProof, options ...ProposeStorageDealOption) (*ProposeStorageDealResult, error)
+
+	// ProposeReplicatedDeal stores the same data with the given number of providers, and
+	// tracks the deals as a group that replaces deals that fail with deals with other providers
+	ProposeReplicatedDeal(ctx context.Context, addr address.Address, data *DataRef, replicas uint64, startEpoch abi.ChainEpoch, endEpoch abi.ChainEpoch, maxPrice abi.TokenAmount, rt abi.RegisteredProof, options ...ProposeStorageDealOption) (*ProposeReplicatedDealResult, error)
+
+	// GetDealGroup returns a group of replicated deals and its aggregate status
+	GetDealGroup(ctx context.Context, id DealGroupID) (DealGroupInfo, error)
+
+	// ListDealGroups lists the client's groups of replicated deals
+	ListDealGroups(ctx context.Context) ([]DealGroupInfo, error)
 
 	// GetPaymentEscrow returns the current funds available for deal payment
 	GetPaymentEscrow(ctx context.Context, addr address.Address) (Balance, error)
@@ -561,5 +942,9 @@ type StorageClient interface {
 	// AddStorageCollateral adds storage collateral
 	AddPaymentEscrow(ctx context.Context, addr address.Address, amount abi.TokenAmount) error
 
+	// CancelDeal withdraws a deal that has not yet been published. Deals the provider has
+	// seen are only cancelled once the provider confirms it cancelled them too
+	CancelDeal(ctx context.Context, proposalCid cid.Cid, reason string) error
+
 	SubscribeToEvents(subscriber ClientSubscriber) shared.Unsubscribe
 }
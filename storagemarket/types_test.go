@@ -0,0 +1,143 @@
+package storagemarket_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/stretchr/testify/require"
+	cbg "github.com/whyrusleeping/cbor-gen"
+
+	"github.com/filecoin-project/go-fil-markets/shared_testutil"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+)
+
+func TestMinerDealCBOR(t *testing.T) {
+	baseDeal, err := shared_testutil.MakeTestMinerDeal(storagemarket.StorageDealActive,
+		shared_testutil.MakeTestClientDealProposal(), shared_testutil.MakeTestDataRef(false))
+	require.NoError(t, err)
+	baseDeal.DealID = 10
+	baseDeal.Message = "active"
+
+	t.Run("deal without later fields uses original encoding", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		require.NoError(t, baseDeal.MarshalCBOR(buf))
+		require.Equal(t, byte(0x8d), buf.Bytes()[0])
+
+		var out storagemarket.MinerDeal
+		require.NoError(t, out.UnmarshalCBOR(buf))
+		require.Equal(t, baseDeal.ProposalCid, out.ProposalCid)
+		require.Equal(t, baseDeal.DealID, out.DealID)
+	})
+
+	t.Run("deal stored with original encoding decodes", func(t *testing.T) {
+		deal := *baseDeal
+		deal.PublishIndex = 2
+		deal.Retries = 3
+		deal.SlashEpoch = 100
+		deal.Labels = storagemarket.DealLabels{"team": "archive"}
+		deal.RejectionReason = storagemarket.RejectionReason(1)
+		buf := new(bytes.Buffer)
+		require.NoError(t, deal.MarshalCBOR(buf))
+		require.Equal(t, byte(0x92), buf.Bytes()[0])
+
+		var out storagemarket.MinerDeal
+		require.NoError(t, out.UnmarshalCBOR(bytes.NewReader(firstFields(t, buf.Bytes(), 13))))
+		require.Equal(t, baseDeal.ProposalCid, out.ProposalCid)
+		require.Equal(t, baseDeal.Proposal.PieceCID, out.Proposal.PieceCID)
+		require.Equal(t, baseDeal.Client, out.Client)
+		require.Equal(t, baseDeal.State, out.State)
+		require.Equal(t, baseDeal.Message, out.Message)
+		require.Equal(t, baseDeal.Ref, out.Ref)
+		require.Equal(t, baseDeal.DealID, out.DealID)
+		require.Zero(t, out.PublishIndex)
+		require.Zero(t, out.Retries)
+		require.Equal(t, abi.ChainEpoch(0), out.SlashEpoch)
+		require.Nil(t, out.Labels)
+		require.Equal(t, storagemarket.RejectionNone, out.RejectionReason)
+	})
+
+	t.Run("deal with later fields round trips", func(t *testing.T) {
+		deal := *baseDeal
+		deal.PublishIndex = 2
+		deal.Retries = 3
+		deal.Labels = storagemarket.DealLabels{"team": "archive"}
+		buf := new(bytes.Buffer)
+		require.NoError(t, deal.MarshalCBOR(buf))
+
+		var out storagemarket.MinerDeal
+		require.NoError(t, out.UnmarshalCBOR(buf))
+		require.Equal(t, uint64(2), out.PublishIndex)
+		require.Equal(t, uint64(3), out.Retries)
+		require.Equal(t, deal.Labels, out.Labels)
+	})
+}
+
+func TestClientDealCBOR(t *testing.T) {
+	baseDeal, err := shared_testutil.MakeTestClientDeal(storagemarket.StorageDealActive,
+		shared_testutil.MakeTestClientDealProposal(), false)
+	require.NoError(t, err)
+	baseDeal.DealID = 10
+	baseDeal.Message = "active"
+
+	t.Run("deal without later fields uses original encoding", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		require.NoError(t, baseDeal.MarshalCBOR(buf))
+		require.Equal(t, byte(0x8b), buf.Bytes()[0])
+
+		var out storagemarket.ClientDeal
+		require.NoError(t, out.UnmarshalCBOR(buf))
+		require.Equal(t, baseDeal.ProposalCid, out.ProposalCid)
+		require.Equal(t, baseDeal.DealID, out.DealID)
+	})
+
+	t.Run("deal stored with original encoding decodes", func(t *testing.T) {
+		deal := *baseDeal
+		deal.Retries = 3
+		deal.SlashEpoch = 100
+		deal.Labels = storagemarket.DealLabels{"team": "archive"}
+		deal.RejectionReason = storagemarket.RejectionReason(1)
+		buf := new(bytes.Buffer)
+		require.NoError(t, deal.MarshalCBOR(buf))
+		require.Equal(t, byte(0x8f), buf.Bytes()[0])
+
+		var out storagemarket.ClientDeal
+		require.NoError(t, out.UnmarshalCBOR(bytes.NewReader(firstFields(t, buf.Bytes(), 11))))
+		require.Equal(t, baseDeal.ProposalCid, out.ProposalCid)
+		require.Equal(t, baseDeal.Proposal.PieceCID, out.Proposal.PieceCID)
+		require.Equal(t, baseDeal.Miner, out.Miner)
+		require.Equal(t, baseDeal.MinerWorker, out.MinerWorker)
+		require.Equal(t, baseDeal.State, out.State)
+		require.Equal(t, baseDeal.Message, out.Message)
+		require.Equal(t, baseDeal.DataRef, out.DataRef)
+		require.Equal(t, baseDeal.DealID, out.DealID)
+		require.Zero(t, out.Retries)
+		require.Equal(t, abi.ChainEpoch(0), out.SlashEpoch)
+		require.Nil(t, out.Labels)
+		require.Equal(t, storagemarket.RejectionNone, out.RejectionReason)
+	})
+
+	t.Run("deal with wrong number of fields fails", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		require.NoError(t, baseDeal.MarshalCBOR(buf))
+
+		var out storagemarket.ClientDeal
+		require.Error(t, out.UnmarshalCBOR(bytes.NewReader(firstFields(t, buf.Bytes(), 10))))
+	})
+}
+
+// firstFields cuts an encoded tuple down to its first n fields, which is how deals were
+// encoded before fields were added to the end of them
+func firstFields(t *testing.T, encoded []byte, n uint64) []byte {
+	r := bytes.NewReader(encoded)
+	_, _, err := cbg.CborReadHeader(r)
+	require.NoError(t, err)
+
+	out := bytes.NewBuffer(cbg.CborEncodeMajorType(cbg.MajArray, n))
+	for i := uint64(0); i < n; i++ {
+		var field cbg.Deferred
+		require.NoError(t, field.UnmarshalCBOR(r))
+		out.Write(field.Raw)
+	}
+	return out.Bytes()
+}